@@ -12,6 +12,8 @@ import (
 	"strings"
 
 	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
 	"google.golang.org/protobuf/types/pluginpb"
 )
 
@@ -55,7 +57,7 @@ func generateFile(plugin *protogen.Plugin, file *protogen.File, conf config) {
 
 	d.generateEncoding(conf)
 	for _, service := range file.Services {
-		d.generateService(service)
+		d.generateService(service, conf)
 	}
 }
 
@@ -235,7 +237,7 @@ func (d *drpc) generateEncoding(conf config) {
 // service generation
 //
 
-func (d *drpc) generateService(service *protogen.Service) {
+func (d *drpc) generateService(service *protogen.Service, conf config) {
 	// Client interface
 	d.P("type ", d.ClientIface(service), " interface {")
 	d.P("DRPCConn() ", d.Ident("storj.io/drpc", "Conn"))
@@ -300,6 +302,12 @@ func (d *drpc) generateService(service *protogen.Service) {
 	d.P("}")
 	d.P()
 
+	// Method metadata. The options are only available from the file
+	// descriptor that protoc-gen-go generates.
+	if conf.protolib == "google.golang.org/protobuf" && hasMethodOptions(service) {
+		d.generateMethodMetadata(service)
+	}
+
 	// Registration helper
 	d.P("func DRPCRegister", service.GoName, "(mux ", d.Ident("storj.io/drpc", "Mux"), ", impl ", d.ServerIface(service), ") error {")
 	d.P("return mux.Register(impl, ", d.ServerDesc(service), "{})")
@@ -311,6 +319,39 @@ func (d *drpc) generateService(service *protogen.Service) {
 	}
 }
 
+func hasOptions(method *protogen.Method) bool {
+	opts, ok := method.Desc.Options().(*descriptorpb.MethodOptions)
+	return ok && opts != nil && proto.Size(opts) > 0
+}
+
+func hasMethodOptions(service *protogen.Service) bool {
+	for _, method := range service.Methods {
+		if hasOptions(method) {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *drpc) generateMethodMetadata(service *protogen.Service) {
+	d.P("func (", d.ServerDesc(service), ") MethodMetadata(n int) map[string]interface{} {")
+	d.P("switch n {")
+	for i, method := range service.Methods {
+		if !hasOptions(method) {
+			continue
+		}
+		d.P("case ", i, ":")
+		d.P("return map[string]interface{}{", d.Ident("storj.io/drpc", "MethodOptionsKey"), ": ",
+			d.QualifiedGoIdent(d.file.GoDescriptorIdent), ".Services().Get(", service.Desc.Index(), ")",
+			".Methods().Get(", method.Desc.Index(), ").Options()}")
+	}
+	d.P("default:")
+	d.P("return nil")
+	d.P("}")
+	d.P("}")
+	d.P()
+}
+
 //
 // client methods
 //
@@ -12,10 +12,11 @@ import (
 
 // These error classes represent some common errors that drpc generates.
 var (
-	Error         = errs.Class("drpc")
-	InternalError = errs.Class("internal error")
-	ProtocolError = errs.Class("protocol error")
-	ClosedError   = errs.Class("closed")
+	Error            = errs.Class("drpc")
+	InternalError    = errs.Class("internal error")
+	ProtocolError    = errs.Class("protocol error")
+	ClosedError      = errs.Class("closed")
+	MessageSizeError = errs.Class("message too large")
 )
 
 // Transport is an interface describing what is required for a drpc connection.
@@ -38,12 +39,14 @@ type Conn interface {
 	// Closed returns a channel that is closed if the connection is definitely closed.
 	Closed() <-chan struct{}
 
-	// Invoke issues a unary RPC to the remote. Only one Invoke or Stream may be
-	// open at once.
+	// Invoke issues a unary RPC to the remote. It is safe to call concurrently
+	// with Invoke and NewStream, but only one Invoke or Stream may be open at
+	// once, so concurrent calls wait for the open one to finish.
 	Invoke(ctx context.Context, rpc string, enc Encoding, in, out Message) error
 
-	// NewStream starts a stream with the remote. Only one Invoke or Stream may be
-	// open at once.
+	// NewStream starts a stream with the remote. It is safe to call concurrently
+	// with Invoke and NewStream, but only one Invoke or Stream may be open at
+	// once, so concurrent calls wait for the open one to finish.
 	NewStream(ctx context.Context, rpc string, enc Encoding) (Stream, error)
 }
 
@@ -57,10 +60,12 @@ type Stream interface {
 	// MsgSend sends the Message to the remote.
 	MsgSend(msg Message, enc Encoding) error
 
-	// MsgRecv receives a Message from the remote.
+	// MsgRecv receives a Message from the remote. It returns io.EOF only once
+	// the remote has called CloseSend, and other errors if the stream failed.
 	MsgRecv(msg Message, enc Encoding) error
 
 	// CloseSend signals to the remote that we will no longer send any messages.
+	// Messages can still be received from the remote after calling it.
 	CloseSend() error
 
 	// Close closes the stream.
@@ -82,6 +87,24 @@ type Description interface {
 	Method(n int) (rpc string, encoding Encoding, receiver Receiver, method interface{}, ok bool)
 }
 
+// MetadataDescription is a Description that also carries arbitrary metadata
+// about its methods, like the options of the methods in their proto
+// definitions, so that servers can make decisions about an rpc, such as
+// whether it requires authentication, without knowing its rpc string.
+type MetadataDescription interface {
+	Description
+
+	// MethodMetadata returns the metadata of the nth method. It may return
+	// nil if the method has none. The returned map must not be modified.
+	MethodMetadata(n int) map[string]interface{}
+}
+
+// MethodOptionsKey is the key of the metadata that protoc-gen-go-drpc
+// generates for methods with options in their proto definitions. Its value is
+// the *descriptorpb.MethodOptions of the method, from which custom options can
+// be read with proto.GetExtension.
+const MethodOptionsKey = "drpc.method_options"
+
 // Mux is a type that can have an implementation and a Description registered with it.
 type Mux interface {
 	// Register marks that the description should dispatch RPCs that it describes to
@@ -92,16 +115,71 @@ type Mux interface {
 // Handler handles streams and RPCs dispatched to it by a Server.
 type Handler interface {
 	// HandleRPC executes the RPC identified by the rpc string using the stream to
-	// communicate with the remote.
+	// communicate with the remote. A returned error is sent on the stream in
+	// place of a clean close, so that after the messages already sent, the
+	// remote's receives fail with it, including any code from drpcerr or
+	// drpcstatus, instead of returning io.EOF. It is not sent if the stream has
+	// already been closed for sending, since the remote has already seen io.EOF.
 	HandleRPC(stream Stream, rpc string) (err error)
 }
 
+// InvokeFunc is the signature of a function that issues a unary RPC, like
+// Conn.Invoke.
+type InvokeFunc func(ctx context.Context, rpc string, enc Encoding, in, out Message) error
+
+// NewStreamFunc is the signature of a function that starts a stream, like
+// Conn.NewStream.
+type NewStreamFunc func(ctx context.Context, rpc string, enc Encoding) (Stream, error)
+
+// ClientInterceptor intercepts the RPCs issued by a client connection.
+type ClientInterceptor interface {
+	// InterceptInvoke is called in place of Invoke. It is expected to call next
+	// to issue the RPC, and it may pass a modified context to do so.
+	InterceptInvoke(ctx context.Context, rpc string, enc Encoding, in, out Message, next InvokeFunc) error
+
+	// InterceptNewStream is called in place of NewStream. It is expected to call
+	// next to start the stream, and it may pass a modified context to do so.
+	InterceptNewStream(ctx context.Context, rpc string, enc Encoding, next NewStreamFunc) (Stream, error)
+}
+
+// ServerHandler is the signature of a function that serves an RPC after any
+// input has been decoded. The in message is nil for RPCs that have a stream
+// of input messages.
+type ServerHandler func(ctx context.Context, rpc string, in Message, stream Stream) (out Message, err error)
+
+// ServerInterceptor intercepts the RPCs dispatched by a server. It receives
+// the decoded input message, if any, and is expected to call next to serve
+// the RPC. It may pass a modified context to next, which is then returned by
+// the Context method of the stream the handler sees. It can short-circuit the
+// RPC by returning without calling next.
+//
+// Server interceptors are called by the handler, so only handlers that support
+// them, like drpcmux.Mux through drpcmux.Options, call them. The mux also
+// makes the kind of rpc available with drpcmux.MethodFromContext.
+type ServerInterceptor func(ctx context.Context, rpc string, in Message, stream Stream, next ServerHandler) (out Message, err error)
+
 // Encoding represents a way to marshal/unmarshal Message types.
 type Encoding interface {
 	// Marshal returns the encoded form of msg.
 	Marshal(msg Message) ([]byte, error)
 
 	// Unmarshal reads the encoded form of some Message into msg.
-	// The buf is expected to contain only a single complete Message.
+	// The buf is expected to contain only a single complete Message. It is
+	// reused once Unmarshal returns, so msg must not retain any reference to it.
 	Unmarshal(buf []byte, msg Message) error
 }
+
+// Compressor represents a way to compress and decompress encoded messages.
+type Compressor interface {
+	// Name identifies the compression format. Peers only send compressed
+	// messages to each other if their Compressors have the same name.
+	Name() string
+
+	// Compress returns the compressed form of buf.
+	Compress(buf []byte) ([]byte, error)
+
+	// Decompress returns the original form of the compressed buf. It returns
+	// an error without decompressing any further if the original form is
+	// larger than limit bytes.
+	Decompress(buf []byte, limit int) ([]byte, error)
+}
@@ -0,0 +1,9 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+// Package drpcaccess provides a listener that records every connection it
+// accepts and rejects connections from source addresses that are not allowed.
+//
+// It can wrap a drpcproxy.Listener so that the client address from the PROXY
+// protocol header is the one that is checked and recorded.
+package drpcaccess
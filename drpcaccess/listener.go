@@ -0,0 +1,166 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcaccess
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/zeebo/errs"
+)
+
+// Error wraps all of the errors returned by this package.
+var Error = errs.Class("drpcaccess")
+
+// Options controls configuration settings for a listener.
+type Options struct {
+	// Allow, if not empty, is the ranges of source addresses that may connect.
+	// Connections from any other address, including ones that are not IP
+	// addresses, are rejected.
+	Allow []*net.IPNet
+
+	// Deny is the ranges of source addresses that may not connect, even if
+	// they are in Allow.
+	Deny []*net.IPNet
+
+	// Accepted is called with every connection that is allowed. It is not
+	// called if nil.
+	Accepted func(Event)
+
+	// Rejected is called with every connection that is rejected. It is not
+	// called if nil.
+	Rejected func(Event)
+}
+
+// Event describes a connection that was accepted by the listener.
+type Event struct {
+	// Time is when the connection was accepted.
+	Time time.Time
+
+	// RemoteAddr and LocalAddr are the addresses of the connection.
+	RemoteAddr net.Addr
+	LocalAddr  net.Addr
+}
+
+// ParseCIDRs parses the CIDR notation IP address ranges, like "192.0.2.0/24",
+// for use in the Options.
+func ParseCIDRs(cidrs ...string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, Error.Wrap(err)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// Listener wraps a net.Listener to check and record the connections it
+// accepts. The check happens before anything is read from the connection by
+// drpc, and rejected connections are closed.
+type Listener struct {
+	net.Listener
+	opts Options
+}
+
+// New returns a Listener that accepts connections from lis.
+func New(lis net.Listener, opts Options) *Listener {
+	return &Listener{Listener: lis, opts: opts}
+}
+
+// Accept returns the next connection. It is checked by the first call to Read
+// or Write on it, because the address of a connection from a wrapped listener
+// like a drpcproxy.Listener may not be known until something is read, and
+// reading it here would let a slow client delay accepting others.
+func (l *Listener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &checkedConn{Conn: conn, lis: l, accepted: time.Now()}, nil
+}
+
+// allowed returns true if a connection from the address may be served.
+func (l *Listener) allowed(addr net.Addr) bool {
+	var ip net.IP
+	switch addr := addr.(type) {
+	case *net.TCPAddr:
+		ip = addr.IP
+	case *net.UDPAddr:
+		ip = addr.IP
+	case *net.IPAddr:
+		ip = addr.IP
+	}
+
+	if ip != nil && contains(l.opts.Deny, ip) {
+		return false
+	}
+	if len(l.opts.Allow) == 0 {
+		return true
+	}
+	return ip != nil && contains(l.opts.Allow, ip)
+}
+
+// contains returns true if any of the ranges contain the ip.
+func contains(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkedConn checks the connection the first time it is read from or written
+// to, closing it if it is rejected.
+type checkedConn struct {
+	net.Conn
+	lis      *Listener
+	accepted time.Time
+
+	once sync.Once
+	err  error
+}
+
+func (c *checkedConn) check() error {
+	c.once.Do(func() {
+		ev := Event{
+			Time:       c.accepted,
+			RemoteAddr: c.Conn.RemoteAddr(),
+			LocalAddr:  c.Conn.LocalAddr(),
+		}
+
+		if c.lis.allowed(ev.RemoteAddr) {
+			if c.lis.opts.Accepted != nil {
+				c.lis.opts.Accepted(ev)
+			}
+			return
+		}
+
+		c.err = Error.New("connection from %v rejected", ev.RemoteAddr)
+		if c.lis.opts.Rejected != nil {
+			c.lis.opts.Rejected(ev)
+		}
+		_ = c.Conn.Close()
+	})
+	return c.err
+}
+
+// Read checks the connection if necessary and reads from it.
+func (c *checkedConn) Read(p []byte) (int, error) {
+	if err := c.check(); err != nil {
+		return 0, err
+	}
+	return c.Conn.Read(p)
+}
+
+// Write checks the connection if necessary and writes to it.
+func (c *checkedConn) Write(p []byte) (int, error) {
+	if err := c.check(); err != nil {
+		return 0, err
+	}
+	return c.Conn.Write(p)
+}
@@ -0,0 +1,153 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcaccess
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/zeebo/assert"
+
+	"storj.io/drpc"
+	"storj.io/drpc/drpcconn"
+	"storj.io/drpc/drpcproxy"
+	"storj.io/drpc/drpcserver"
+	"storj.io/drpc/drpctest"
+)
+
+type echo struct{}
+
+func (echo) HandleRPC(stream drpc.Stream, rpc string) error {
+	var in []byte
+	if err := stream.MsgRecv(&in, drpctest.ByteEncoding{}); err != nil {
+		return err
+	}
+	return stream.MsgSend(&in, drpctest.ByteEncoding{})
+}
+
+// events records the events passed to the callbacks.
+type events struct {
+	mu       sync.Mutex
+	accepted []Event
+	rejected []Event
+}
+
+func (e *events) options(allow, deny []*net.IPNet) Options {
+	return Options{
+		Allow: allow,
+		Deny:  deny,
+		Accepted: func(ev Event) {
+			e.mu.Lock()
+			defer e.mu.Unlock()
+			e.accepted = append(e.accepted, ev)
+		},
+		Rejected: func(ev Event) {
+			e.mu.Lock()
+			defer e.mu.Unlock()
+			e.rejected = append(e.rejected, ev)
+		},
+	}
+}
+
+func (e *events) counts() (accepted, rejected int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.accepted), len(e.rejected)
+}
+
+func cidrs(t *testing.T, cidrs ...string) []*net.IPNet {
+	nets, err := ParseCIDRs(cidrs...)
+	assert.NoError(t, err)
+	return nets
+}
+
+func TestAllowed(t *testing.T) {
+	lis := New(nil, Options{
+		Allow: cidrs(t, "192.0.2.0/24", "2001:db8::/32"),
+		Deny:  cidrs(t, "192.0.2.128/25"),
+	})
+
+	assert.That(t, lis.allowed(&net.TCPAddr{IP: net.ParseIP("192.0.2.1")}))
+	assert.That(t, lis.allowed(&net.TCPAddr{IP: net.ParseIP("2001:db8::1")}))
+	assert.That(t, !lis.allowed(&net.TCPAddr{IP: net.ParseIP("192.0.2.200")}))
+	assert.That(t, !lis.allowed(&net.TCPAddr{IP: net.ParseIP("198.51.100.1")}))
+	assert.That(t, !lis.allowed(&net.UnixAddr{Name: "sock", Net: "unix"}))
+
+	// without an allow list, only denied addresses are rejected.
+	lis = New(nil, Options{Deny: cidrs(t, "192.0.2.0/24")})
+	assert.That(t, !lis.allowed(&net.TCPAddr{IP: net.ParseIP("192.0.2.1")}))
+	assert.That(t, lis.allowed(&net.TCPAddr{IP: net.ParseIP("198.51.100.1")}))
+	assert.That(t, lis.allowed(&net.UnixAddr{Name: "sock", Net: "unix"}))
+
+	_, err := ParseCIDRs("not a cidr")
+	assert.That(t, Error.Has(err))
+}
+
+func TestListener(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	serve := func(wrap func(net.Listener) net.Listener) string {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+		ctx.Run(func(ctx context.Context) { _ = drpcserver.New(echo{}).Serve(ctx, wrap(lis)) })
+		return lis.Addr().String()
+	}
+	invoke := func(addr string, header string) error {
+		rawconn, err := net.Dial("tcp", addr)
+		assert.NoError(t, err)
+		if header != "" {
+			_, err = rawconn.Write([]byte(header))
+			assert.NoError(t, err)
+		}
+
+		conn := drpcconn.New(rawconn)
+		defer func() { _ = conn.Close() }()
+
+		in, out := []byte("data"), []byte(nil)
+		return conn.Invoke(ctx, "rpc", drpctest.ByteEncoding{}, &in, &out)
+	}
+
+	{ // connections from a denied range are rejected
+		var ev events
+		addr := serve(func(lis net.Listener) net.Listener {
+			return New(lis, ev.options(nil, cidrs(t, "127.0.0.0/8")))
+		})
+		assert.Error(t, invoke(addr, ""))
+
+		accepted, rejected := ev.counts()
+		assert.Equal(t, accepted, 0)
+		assert.Equal(t, rejected, 1)
+		assert.Equal(t, ev.rejected[0].RemoteAddr.(*net.TCPAddr).IP.String(), "127.0.0.1")
+		assert.That(t, !ev.rejected[0].Time.IsZero())
+	}
+
+	{ // and ones from an allowed range are served
+		var ev events
+		addr := serve(func(lis net.Listener) net.Listener {
+			return New(lis, ev.options(cidrs(t, "127.0.0.0/8"), cidrs(t, "10.0.0.0/8")))
+		})
+		assert.NoError(t, invoke(addr, ""))
+
+		accepted, rejected := ev.counts()
+		assert.Equal(t, accepted, 1)
+		assert.Equal(t, rejected, 0)
+	}
+
+	{ // the address from a PROXY protocol header is the one checked
+		var ev events
+		addr := serve(func(lis net.Listener) net.Listener {
+			return New(drpcproxy.New(lis), ev.options(cidrs(t, "127.0.0.0/8"), cidrs(t, "10.0.0.0/8")))
+		})
+		assert.Error(t, invoke(addr, "PROXY TCP4 10.0.0.5 192.0.2.2 56324 443\r\n"))
+		assert.NoError(t, invoke(addr, "PROXY TCP4 127.0.0.5 192.0.2.2 56324 443\r\n"))
+
+		accepted, rejected := ev.counts()
+		assert.Equal(t, accepted, 1)
+		assert.Equal(t, rejected, 1)
+		assert.Equal(t, ev.rejected[0].RemoteAddr.String(), "10.0.0.5:56324")
+	}
+}
@@ -0,0 +1,6 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+// Package drpcauth provides helpers to authenticate rpcs with bearer tokens
+// sent in metadata.
+package drpcauth
@@ -0,0 +1,74 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcauth
+
+import (
+	"context"
+	"strings"
+
+	"storj.io/drpc"
+	"storj.io/drpc/drpcerr"
+	"storj.io/drpc/drpcmetadata"
+	"storj.io/drpc/drpcstatus"
+)
+
+// AuthorizationKey is the metadata key that tokens are sent with.
+const AuthorizationKey = "authorization"
+
+// bearerPrefix precedes the token in the metadata value.
+const bearerPrefix = "Bearer "
+
+// WithToken returns a context that sends the token as a bearer token with any
+// rpcs issued with it. If the context already has a token, the new one is
+// used instead.
+func WithToken(ctx context.Context, token string) context.Context {
+	return drpcmetadata.WithOutgoingMetadata(ctx, drpcmetadata.Metadata{
+		AuthorizationKey: {bearerPrefix + token},
+	})
+}
+
+// TokenFromContext returns the bearer token sent by the remote for the rpc the
+// context belongs to, and false if there is none.
+func TokenFromContext(ctx context.Context) (string, bool) {
+	md, _ := drpcmetadata.MetadataFromContext(ctx)
+	values := md.Get(AuthorizationKey)
+	if len(values) == 0 {
+		return "", false
+	}
+
+	// the last value is the most recent one added by WithToken.
+	value := values[len(values)-1]
+	if len(value) < len(bearerPrefix) || !strings.EqualFold(value[:len(bearerPrefix)], bearerPrefix) {
+		return "", false
+	}
+	return value[len(bearerPrefix):], true
+}
+
+// VerifyFunc checks the token and returns the context the handler is called
+// with, which may be enriched with the identity of the caller. It is passed
+// the context of the rpc.
+type VerifyFunc func(ctx context.Context, token string) (context.Context, error)
+
+// TokenAuth returns a drpc.ServerInterceptor that rejects rpcs without a valid
+// bearer token before the handler runs. Rpcs without a token and rpcs that the
+// verify function returns an error for fail with an Unauthenticated status,
+// unless the error already has a code.
+func TokenAuth(verify VerifyFunc) drpc.ServerInterceptor {
+	return func(ctx context.Context, rpc string, in drpc.Message, stream drpc.Stream, next drpc.ServerHandler) (drpc.Message, error) {
+		token, ok := TokenFromContext(ctx)
+		if !ok {
+			return nil, drpcstatus.Errorf(drpcstatus.Unauthenticated, "missing bearer token")
+		}
+
+		ctx, err := verify(ctx, token)
+		if err != nil {
+			if drpcerr.Code(err) != 0 {
+				return nil, err
+			}
+			return nil, drpcstatus.Errorf(drpcstatus.Unauthenticated, "invalid bearer token: %v", err)
+		}
+
+		return next(ctx, rpc, in, stream)
+	}
+}
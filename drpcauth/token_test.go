@@ -0,0 +1,109 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcauth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/zeebo/assert"
+	"github.com/zeebo/errs"
+
+	"storj.io/drpc"
+	"storj.io/drpc/drpchealth"
+	"storj.io/drpc/drpcmetadata"
+	"storj.io/drpc/drpcmux"
+	"storj.io/drpc/drpcpipe"
+	"storj.io/drpc/drpcstatus"
+)
+
+type identityKey struct{}
+
+func verify(ctx context.Context, token string) (context.Context, error) {
+	switch token {
+	case "secret":
+		return context.WithValue(ctx, identityKey{}, "alice"), nil
+	case "banned":
+		return nil, drpcstatus.Errorf(drpcstatus.PermissionDenied, "banned")
+	default:
+		return nil, errs.New("unknown token")
+	}
+}
+
+// incoming returns a context as the server sees it for an rpc issued with ctx.
+func incoming(ctx context.Context) context.Context {
+	md, _ := drpcmetadata.OutgoingMetadataFromContext(ctx)
+	return drpcmetadata.WithIncomingMetadata(context.Background(), md)
+}
+
+func TestTokenAuth(t *testing.T) {
+	icpt := TokenAuth(verify)
+
+	var identity interface{}
+	next := func(ctx context.Context, rpc string, in drpc.Message, stream drpc.Stream) (drpc.Message, error) {
+		identity = ctx.Value(identityKey{})
+		return nil, nil
+	}
+	call := func(ctx context.Context) error {
+		identity = nil
+		_, err := icpt(incoming(ctx), "/rpc", nil, nil, next)
+		return err
+	}
+
+	{ // valid tokens enrich the context
+		assert.NoError(t, call(WithToken(context.Background(), "secret")))
+		assert.Equal(t, identity, "alice")
+	}
+
+	{ // the most recent token is used
+		ctx := WithToken(WithToken(context.Background(), "invalid"), "secret")
+		assert.NoError(t, call(ctx))
+		assert.Equal(t, identity, "alice")
+	}
+
+	{ // missing tokens are unauthenticated
+		err := call(context.Background())
+		assert.Equal(t, drpcstatus.CodeFromError(err), drpcstatus.Unauthenticated)
+		assert.Nil(t, identity)
+	}
+
+	{ // values that are not bearer tokens are missing tokens
+		ctx := drpcmetadata.WithOutgoingMetadata(context.Background(), drpcmetadata.Metadata{
+			AuthorizationKey: {"Basic secret"},
+		})
+		err := call(ctx)
+		assert.Equal(t, drpcstatus.CodeFromError(err), drpcstatus.Unauthenticated)
+	}
+
+	{ // invalid tokens are unauthenticated
+		err := call(WithToken(context.Background(), "invalid"))
+		assert.Equal(t, drpcstatus.CodeFromError(err), drpcstatus.Unauthenticated)
+		assert.Nil(t, identity)
+	}
+
+	{ // codes from the verify function are kept
+		err := call(WithToken(context.Background(), "banned"))
+		assert.Equal(t, drpcstatus.CodeFromError(err), drpcstatus.PermissionDenied)
+	}
+}
+
+func TestTokenAuth_Conn(t *testing.T) {
+	ctx := context.Background()
+
+	mux := drpcmux.NewWithOptions(drpcmux.Options{Interceptor: TokenAuth(verify)})
+	assert.NoError(t, drpchealth.Register(mux, drpchealth.New()))
+
+	conn, cleanup := drpcpipe.New(mux)
+	defer cleanup()
+	cli := drpchealth.NewClient(conn)
+
+	_, err := cli.Check(WithToken(ctx, "secret"), "")
+	assert.NoError(t, err)
+
+	_, err = cli.Check(ctx, "")
+	assert.Equal(t, drpcstatus.CodeFromError(err), drpcstatus.Unauthenticated)
+
+	_, err = cli.Check(WithToken(ctx, "invalid"), "")
+	assert.Equal(t, drpcstatus.CodeFromError(err), drpcstatus.Unauthenticated)
+}
@@ -0,0 +1,223 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcbalance
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/zeebo/errs"
+
+	"storj.io/drpc"
+	"storj.io/drpc/drpcsignal"
+)
+
+// Error wraps the errors returned by the balancing conn itself.
+var Error = errs.Class("drpcbalance")
+
+// Options controls configuration settings for a conn.
+type Options struct {
+	// Picker chooses the target for each rpc. If nil, RoundRobin is used.
+	Picker Picker
+
+	// Backoff is how long a target is skipped after it fails to dial or its
+	// conn is closed. Zero means 1s.
+	Backoff time.Duration
+}
+
+// DialFunc returns a conn to the target.
+type DialFunc func(ctx context.Context, target string) (drpc.Conn, error)
+
+// Conn is a drpc.Conn that sends rpcs to one of many targets.
+type Conn struct {
+	opts Options
+	dial DialFunc
+	done drpcsignal.Chan
+
+	mu      sync.Mutex
+	closed  bool
+	targets []*target
+}
+
+// target is the state kept for a single target.
+type target struct {
+	name  string
+	conn  drpc.Conn // nil until dialed, or after failing
+	retry time.Time // the target is skipped until this time
+}
+
+var _ drpc.Conn = (*Conn)(nil)
+
+// New returns a conn that balances rpcs across the targets, dialing them with
+// the dial function the first time they are picked.
+func New(targets []string, dial DialFunc) *Conn {
+	return NewWithOptions(targets, dial, Options{})
+}
+
+// NewWithOptions is like New but uses the provided options.
+func NewWithOptions(targets []string, dial DialFunc, opts Options) *Conn {
+	if opts.Picker == nil {
+		opts.Picker = RoundRobin()
+	}
+	if opts.Backoff == 0 {
+		opts.Backoff = time.Second
+	}
+
+	c := &Conn{
+		opts: opts,
+		dial: dial,
+	}
+	for _, name := range targets {
+		c.targets = append(c.targets, &target{name: name})
+	}
+	return c
+}
+
+// Close closes the conn and all of the conns to the targets.
+func (c *Conn) Close() (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	c.done.Close()
+
+	var eg errs.Group
+	for _, t := range c.targets {
+		if t.conn != nil {
+			eg.Add(t.conn.Close())
+			t.conn = nil
+		}
+	}
+	return eg.Err()
+}
+
+// Closed returns a channel that is closed once the conn is closed.
+func (c *Conn) Closed() <-chan struct{} { return c.done.Get() }
+
+// Invoke issues the rpc on the conn for a picked target.
+func (c *Conn) Invoke(ctx context.Context, rpc string, enc drpc.Encoding, in, out drpc.Message) (err error) {
+	t, conn, err := c.pick(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.check(t, conn)
+
+	return conn.Invoke(ctx, rpc, enc, in, out)
+}
+
+// NewStream starts a stream on the conn for a picked target. The stream
+// uses that target for its entire lifetime.
+func (c *Conn) NewStream(ctx context.Context, rpc string, enc drpc.Encoding) (_ drpc.Stream, err error) {
+	t, conn, err := c.pick(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer c.check(t, conn)
+
+	return conn.NewStream(ctx, rpc, enc)
+}
+
+//
+// helpers
+//
+
+// pick chooses an available target and returns it along with its conn,
+// dialing it if necessary. Targets that fail to dial are skipped and another
+// target is picked.
+func (c *Conn) pick(ctx context.Context) (*target, drpc.Conn, error) {
+	for {
+		t, conn, err := c.choose()
+		if err != nil || conn != nil {
+			return t, conn, err
+		}
+
+		conn, err = c.dial(ctx, t.name)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, nil, err
+			}
+			c.fail(t, nil)
+			continue
+		}
+
+		c.mu.Lock()
+		if c.closed {
+			c.mu.Unlock()
+			_ = conn.Close()
+			return nil, nil, Error.New("connection closed")
+		} else if t.conn != nil {
+			// someone else dialed the target concurrently, so use theirs.
+			_ = conn.Close()
+			conn = t.conn
+		} else {
+			t.conn = conn
+		}
+		c.mu.Unlock()
+
+		return t, conn, nil
+	}
+}
+
+// choose uses the picker to select an available target. It returns the
+// target's conn if it has already been dialed.
+func (c *Conn) choose() (*target, drpc.Conn, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil, nil, Error.New("connection closed")
+	}
+
+	now := time.Now()
+	var available []*target
+	var names []string
+	for _, t := range c.targets {
+		if t.conn != nil && closed(t.conn.Closed()) {
+			t.conn, t.retry = nil, now.Add(c.opts.Backoff)
+		}
+		if now.Before(t.retry) {
+			continue
+		}
+		available = append(available, t)
+		names = append(names, t.name)
+	}
+	if len(available) == 0 {
+		return nil, nil, Error.New("no targets available")
+	}
+
+	t := available[c.opts.Picker.Pick(names)]
+	return t, t.conn, nil
+}
+
+// check marks the target as failing if its conn has been closed.
+func (c *Conn) check(t *target, conn drpc.Conn) {
+	if closed(conn.Closed()) {
+		c.fail(t, conn)
+	}
+}
+
+// fail causes the target to be skipped until the backoff passes if its conn
+// is still the provided conn.
+func (c *Conn) fail(t *target, conn drpc.Conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if t.conn == conn {
+		t.conn, t.retry = nil, time.Now().Add(c.opts.Backoff)
+	}
+}
+
+// closed returns true if the channel is closed.
+func closed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
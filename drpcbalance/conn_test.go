@@ -0,0 +1,190 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcbalance
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+	"github.com/zeebo/errs"
+
+	"storj.io/drpc"
+	"storj.io/drpc/drpcpipe"
+	"storj.io/drpc/drpctest"
+)
+
+// named responds to every message it receives with its name.
+type named string
+
+func (n named) HandleRPC(stream drpc.Stream, rpc string) error {
+	for {
+		var in []byte
+		if err := stream.MsgRecv(&in, drpctest.ByteEncoding{}); err != nil {
+			return nil
+		}
+		out := []byte(n)
+		if err := stream.MsgSend(&out, drpctest.ByteEncoding{}); err != nil {
+			return err
+		}
+	}
+}
+
+// servers runs an in-memory server for each name and dials them, failing to
+// dial any names in the down set.
+type servers struct {
+	mu    sync.Mutex
+	down  map[string]bool
+	dials map[string]int
+	conns []drpc.Conn
+	done  []func()
+}
+
+func newServers() *servers {
+	return &servers{down: make(map[string]bool), dials: make(map[string]int)}
+}
+
+func (s *servers) setDown(name string, down bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.down[name] = down
+}
+
+func (s *servers) dial(ctx context.Context, name string) (drpc.Conn, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.dials[name]++
+	if s.down[name] {
+		return nil, errs.New("%s is down", name)
+	}
+	conn, cleanup := drpcpipe.New(named(name))
+	s.conns = append(s.conns, conn)
+	s.done = append(s.done, cleanup)
+	return conn, nil
+}
+
+func (s *servers) cleanup() {
+	for _, fn := range s.done {
+		fn()
+	}
+}
+
+func invoke(ctx context.Context, conn drpc.Conn) (string, error) {
+	in, out := []byte("data"), []byte(nil)
+	err := conn.Invoke(ctx, "/rpc", drpctest.ByteEncoding{}, &in, &out)
+	return string(out), err
+}
+
+func TestConn_Distribution(t *testing.T) {
+	for _, picker := range []struct {
+		name   string
+		picker Picker
+		min    int
+	}{
+		{"RoundRobin", RoundRobin(), 100},
+		{"Random", Random(), 60},
+	} {
+		t.Run(picker.name, func(t *testing.T) {
+			ctx := drpctest.NewTracker(t)
+			defer ctx.Close()
+
+			srvs := newServers()
+			defer srvs.cleanup()
+
+			conn := NewWithOptions([]string{"a", "b", "c"}, srvs.dial, Options{Picker: picker.picker})
+			defer func() { _ = conn.Close() }()
+
+			counts := make(map[string]int)
+			for i := 0; i < 300; i++ {
+				name, err := invoke(ctx, conn)
+				assert.NoError(t, err)
+				counts[name]++
+			}
+
+			assert.Equal(t, len(counts), 3)
+			for name, count := range counts {
+				assert.That(t, count >= picker.min)
+				assert.Equal(t, srvs.dials[name], 1)
+			}
+		})
+	}
+}
+
+func TestConn_Failing(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	srvs := newServers()
+	defer srvs.cleanup()
+	srvs.setDown("b", true)
+
+	conn := NewWithOptions([]string{"a", "b", "c"}, srvs.dial, Options{Backoff: 50 * time.Millisecond})
+	defer func() { _ = conn.Close() }()
+
+	// b is skipped while it fails to dial.
+	for i := 0; i < 10; i++ {
+		name, err := invoke(ctx, conn)
+		assert.NoError(t, err)
+		assert.That(t, name != "b")
+	}
+	assert.Equal(t, srvs.dials["b"], 1)
+
+	// b is dialed again after the backoff.
+	srvs.setDown("b", false)
+	time.Sleep(100 * time.Millisecond)
+
+	seen := make(map[string]bool)
+	for i := 0; i < 10; i++ {
+		name, err := invoke(ctx, conn)
+		assert.NoError(t, err)
+		seen[name] = true
+	}
+	assert.That(t, seen["b"])
+
+	// closed conns cause their target to be skipped.
+	srvs.mu.Lock()
+	for _, c := range srvs.conns {
+		_ = c.Close()
+	}
+	srvs.mu.Unlock()
+	srvs.setDown("a", true)
+	srvs.setDown("b", true)
+	srvs.setDown("c", true)
+
+	_, err := invoke(ctx, conn)
+	assert.That(t, Error.Has(err))
+}
+
+func TestConn_StreamPinned(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	srvs := newServers()
+	defer srvs.cleanup()
+
+	conn := New([]string{"a", "b", "c"}, srvs.dial)
+	defer func() { _ = conn.Close() }()
+
+	stream, err := conn.NewStream(ctx, "/rpc", drpctest.ByteEncoding{})
+	assert.NoError(t, err)
+
+	var first string
+	for i := 0; i < 5; i++ {
+		// invokes in between move on to other targets.
+		_, err := invoke(ctx, conn)
+		assert.NoError(t, err)
+
+		in, out := []byte("data"), []byte(nil)
+		assert.NoError(t, stream.MsgSend(&in, drpctest.ByteEncoding{}))
+		assert.NoError(t, stream.MsgRecv(&out, drpctest.ByteEncoding{}))
+		if i == 0 {
+			first = string(out)
+		}
+		assert.Equal(t, string(out), first)
+	}
+	assert.NoError(t, stream.Close())
+}
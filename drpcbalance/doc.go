@@ -0,0 +1,9 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+// Package drpcbalance provides a conn that spreads rpcs across many targets.
+//
+// Each rpc is sent to a target chosen by a Picker. Targets that fail to dial or
+// whose conns close are skipped until a backoff passes, after which they are
+// dialed again. Streams stay on the target that was chosen when they started.
+package drpcbalance
@@ -0,0 +1,33 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcbalance
+
+import (
+	"math/rand"
+	"sync/atomic"
+)
+
+// Picker chooses which target an rpc is sent to.
+type Picker interface {
+	// Pick returns the index of the target to use from the non-empty list of
+	// targets that are currently available. It may be called concurrently.
+	Pick(targets []string) int
+}
+
+// RoundRobin returns a Picker that cycles through the available targets.
+func RoundRobin() Picker { return new(roundRobin) }
+
+type roundRobin struct{ next atomic.Uint64 }
+
+func (r *roundRobin) Pick(targets []string) int {
+	return int((r.next.Add(1) - 1) % uint64(len(targets)))
+}
+
+// Random returns a Picker that chooses an available target uniformly at
+// random.
+func Random() Picker { return random{} }
+
+type random struct{}
+
+func (random) Pick(targets []string) int { return rand.Intn(len(targets)) }
@@ -0,0 +1,228 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcbreaker
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"storj.io/drpc"
+	"storj.io/drpc/drpcstatus"
+)
+
+// State is the state of a breaker.
+type State int
+
+const (
+	// Closed lets every rpc through.
+	Closed State = iota
+
+	// Open rejects every rpc until the cooldown passes.
+	Open
+
+	// HalfOpen lets a single rpc through to probe the remote.
+	HalfOpen
+)
+
+// String returns a string representation of the state.
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "Closed"
+	case Open:
+		return "Open"
+	case HalfOpen:
+		return "HalfOpen"
+	default:
+		return "State(" + strconv.Itoa(int(s)) + ")"
+	}
+}
+
+// Options controls configuration settings for a breaker.
+type Options struct {
+	// Threshold is how many failures in a row open the breaker. Zero means 5.
+	Threshold int
+
+	// Cooldown is how long the breaker stays open before it half opens. Zero
+	// means 30s.
+	Cooldown time.Duration
+
+	// Key groups rpcs that share a breaker. If nil, every rpc has its own
+	// breaker. Returning the same key for every rpc makes a single breaker
+	// cover everything sent through the conn.
+	Key func(rpc string) string
+
+	// IsFailure reports if the error counts as a failure. If nil, errors with
+	// the Unavailable or Unknown status codes, which include broken
+	// transports, are failures, and others, like a canceled context or an
+	// error returned by the remote handler with a code, are not.
+	IsFailure func(err error) bool
+}
+
+// Breaker is a drpc.ClientInterceptor that rejects rpcs while the remote is
+// failing. Unitary rpcs count as failures if Invoke fails, and streams count
+// as failures if they fail to start.
+type Breaker struct {
+	opts Options
+
+	mu       sync.Mutex
+	breakers map[string]*breaker
+}
+
+var _ drpc.ClientInterceptor = (*Breaker)(nil)
+
+// breaker is the state for a single key.
+type breaker struct {
+	state    State
+	failures int       // consecutive failures while closed
+	until    time.Time // when an open breaker half opens
+	probing  bool      // set while a half open breaker has a probe running
+}
+
+// New returns a Breaker with the provided options.
+func New(opts Options) *Breaker {
+	if opts.Threshold == 0 {
+		opts.Threshold = 5
+	}
+	if opts.Cooldown == 0 {
+		opts.Cooldown = 30 * time.Second
+	}
+	if opts.IsFailure == nil {
+		opts.IsFailure = isFailure
+	}
+	return &Breaker{
+		opts:     opts,
+		breakers: make(map[string]*breaker),
+	}
+}
+
+// State returns the current state of the breaker for the rpc.
+func (b *Breaker) State(rpc string) State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	br := b.breakerLocked(b.key(rpc))
+	if br.state == Open && !time.Now().Before(br.until) {
+		return HalfOpen
+	}
+	return br.state
+}
+
+// InterceptInvoke issues the rpc if the breaker allows it and records whether
+// it failed.
+func (b *Breaker) InterceptInvoke(ctx context.Context, rpc string, enc drpc.Encoding, in, out drpc.Message, next drpc.InvokeFunc) error {
+	key := b.key(rpc)
+	probe, err := b.allow(key)
+	if err != nil {
+		return err
+	}
+	err = next(ctx, rpc, enc, in, out)
+	b.record(key, probe, err)
+	return err
+}
+
+// InterceptNewStream starts the stream if the breaker allows it and records
+// whether it failed to start.
+func (b *Breaker) InterceptNewStream(ctx context.Context, rpc string, enc drpc.Encoding, next drpc.NewStreamFunc) (drpc.Stream, error) {
+	key := b.key(rpc)
+	probe, err := b.allow(key)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := next(ctx, rpc, enc)
+	b.record(key, probe, err)
+	return stream, err
+}
+
+//
+// helpers
+//
+
+// key returns the key of the breaker for the rpc.
+func (b *Breaker) key(rpc string) string {
+	if b.opts.Key == nil {
+		return rpc
+	}
+	return b.opts.Key(rpc)
+}
+
+// breakerLocked returns the breaker for the key, creating it if necessary. It
+// must be called with the mutex held.
+func (b *Breaker) breakerLocked(key string) *breaker {
+	br, ok := b.breakers[key]
+	if !ok {
+		br = new(breaker)
+		b.breakers[key] = br
+	}
+	return br
+}
+
+// allow returns an error if the breaker for the key rejects the rpc, and if
+// not, whether the rpc is the probe of a half open breaker. The result of the
+// rpc must be passed to record along with it.
+func (b *Breaker) allow(key string) (probe bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	br := b.breakerLocked(key)
+	if br.state == Open && !time.Now().Before(br.until) {
+		br.state = HalfOpen
+	}
+
+	switch {
+	case br.state == Open, br.state == HalfOpen && br.probing:
+		return false, drpcstatus.Errorf(drpcstatus.Unavailable, "circuit breaker open for %s", key)
+	case br.state == HalfOpen:
+		br.probing = true
+		return true, nil
+	}
+	return false, nil
+}
+
+// record updates the breaker for the key with the result of an rpc it allowed.
+// Only the result of the probe changes the state of a half open breaker.
+func (b *Breaker) record(key string, probe bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	br := b.breakerLocked(key)
+	failed := err != nil && b.opts.IsFailure(err)
+
+	switch {
+	case br.state == Open:
+		// another rpc already opened the breaker while this one ran.
+
+	case br.state == HalfOpen && !probe:
+		// the rpc was allowed before the breaker opened, so it says
+		// nothing about whether the remote has recovered.
+
+	case br.state == HalfOpen && failed:
+		br.state, br.probing = Open, false
+		br.until = time.Now().Add(b.opts.Cooldown)
+
+	case br.state == HalfOpen:
+		br.state, br.probing, br.failures = Closed, false, 0
+
+	case failed:
+		if br.failures++; br.failures >= b.opts.Threshold {
+			br.state, br.failures = Open, 0
+			br.until = time.Now().Add(b.opts.Cooldown)
+		}
+
+	default:
+		br.failures = 0
+	}
+}
+
+// isFailure is the default for Options.IsFailure.
+func isFailure(err error) bool {
+	switch drpcstatus.CodeFromError(err) {
+	case drpcstatus.Unavailable, drpcstatus.Unknown:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcbreaker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+
+	"storj.io/drpc"
+	"storj.io/drpc/drpcstatus"
+)
+
+func TestBreaker(t *testing.T) {
+	ctx := context.Background()
+	unavailable := drpcstatus.Errorf(drpcstatus.Unavailable, "unavailable")
+
+	b := New(Options{Threshold: 3, Cooldown: 20 * time.Millisecond})
+
+	calls, fail := 0, true
+	invoke := func() error {
+		return b.InterceptInvoke(ctx, "/rpc", nil, nil, nil,
+			func(ctx context.Context, rpc string, enc drpc.Encoding, in, out drpc.Message) error {
+				calls++
+				if fail {
+					return unavailable
+				}
+				return nil
+			})
+	}
+
+	// failures below the threshold keep the breaker closed.
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, invoke(), unavailable)
+	}
+	assert.Equal(t, b.State("/rpc"), Closed)
+
+	// the failure at the threshold opens it, and rpcs are then rejected
+	// without being issued.
+	assert.Equal(t, invoke(), unavailable)
+	assert.Equal(t, b.State("/rpc"), Open)
+
+	err := invoke()
+	assert.Equal(t, drpcstatus.CodeFromError(err), drpcstatus.Unavailable)
+	assert.Equal(t, calls, 3)
+
+	// other rpcs have their own breaker.
+	assert.Equal(t, b.State("/other"), Closed)
+
+	// after the cooldown a failed probe reopens it.
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, b.State("/rpc"), HalfOpen)
+	assert.Equal(t, invoke(), unavailable)
+	assert.Equal(t, calls, 4)
+	assert.Equal(t, b.State("/rpc"), Open)
+
+	// and a successful probe closes it.
+	time.Sleep(20 * time.Millisecond)
+	fail = false
+	assert.NoError(t, invoke())
+	assert.Equal(t, calls, 5)
+	assert.Equal(t, b.State("/rpc"), Closed)
+}
+
+func TestBreaker_HalfOpenSingleProbe(t *testing.T) {
+	ctx := context.Background()
+	b := New(Options{Threshold: 1, Cooldown: time.Millisecond})
+
+	fail := func(ctx context.Context, rpc string, enc drpc.Encoding, in, out drpc.Message) error {
+		return errors.New("broken")
+	}
+	assert.Error(t, b.InterceptInvoke(ctx, "/rpc", nil, nil, nil, fail))
+	time.Sleep(time.Millisecond)
+
+	// while the probe runs, other rpcs are rejected.
+	probing, release := make(chan struct{}), make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- b.InterceptInvoke(ctx, "/rpc", nil, nil, nil,
+			func(ctx context.Context, rpc string, enc drpc.Encoding, in, out drpc.Message) error {
+				close(probing)
+				<-release
+				return nil
+			})
+	}()
+
+	<-probing
+	err := b.InterceptInvoke(ctx, "/rpc", nil, nil, nil, fail)
+	assert.Equal(t, drpcstatus.CodeFromError(err), drpcstatus.Unavailable)
+
+	close(release)
+	assert.NoError(t, <-done)
+	assert.Equal(t, b.State("/rpc"), Closed)
+}
+
+func TestBreaker_HalfOpenOnlyProbeRecorded(t *testing.T) {
+	ctx := context.Background()
+	b := New(Options{Threshold: 1, Cooldown: time.Millisecond})
+
+	block := func(started chan struct{}, release chan error) drpc.InvokeFunc {
+		return func(ctx context.Context, rpc string, enc drpc.Encoding, in, out drpc.Message) error {
+			close(started)
+			return <-release
+		}
+	}
+
+	// an rpc is allowed while the breaker is closed, and then the breaker
+	// opens and half opens while it runs.
+	oldStarted, oldRelease, oldDone := make(chan struct{}), make(chan error), make(chan error)
+	go func() { oldDone <- b.InterceptInvoke(ctx, "/rpc", nil, nil, nil, block(oldStarted, oldRelease)) }()
+	<-oldStarted
+
+	fail := func(ctx context.Context, rpc string, enc drpc.Encoding, in, out drpc.Message) error {
+		return errors.New("broken")
+	}
+	assert.Error(t, b.InterceptInvoke(ctx, "/rpc", nil, nil, nil, fail))
+	time.Sleep(time.Millisecond)
+
+	probeStarted, probeRelease, probeDone := make(chan struct{}), make(chan error), make(chan error)
+	go func() { probeDone <- b.InterceptInvoke(ctx, "/rpc", nil, nil, nil, block(probeStarted, probeRelease)) }()
+	<-probeStarted
+
+	// the old rpc finishing does not close the breaker or end the probe.
+	oldRelease <- nil
+	assert.NoError(t, <-oldDone)
+	assert.Equal(t, b.State("/rpc"), HalfOpen)
+	err := b.InterceptInvoke(ctx, "/rpc", nil, nil, nil, fail)
+	assert.Equal(t, drpcstatus.CodeFromError(err), drpcstatus.Unavailable)
+
+	// but the probe does.
+	probeRelease <- nil
+	assert.NoError(t, <-probeDone)
+	assert.Equal(t, b.State("/rpc"), Closed)
+}
+
+func TestBreaker_Stream(t *testing.T) {
+	ctx := context.Background()
+	b := New(Options{Threshold: 2, Key: func(string) string { return "" }})
+
+	dials := 0
+	next := func(ctx context.Context, rpc string, enc drpc.Encoding) (drpc.Stream, error) {
+		dials++
+		return nil, errors.New("dial failed")
+	}
+
+	// streams that fail to start count towards the breaker, which is shared
+	// by every rpc, and an open breaker rejects them before calling next.
+	_, err := b.InterceptNewStream(ctx, "/a", nil, next)
+	assert.Error(t, err)
+	_, err = b.InterceptNewStream(ctx, "/b", nil, next)
+	assert.Error(t, err)
+	assert.Equal(t, b.State("/c"), Open)
+
+	_, err = b.InterceptNewStream(ctx, "/c", nil, next)
+	assert.Equal(t, drpcstatus.CodeFromError(err), drpcstatus.Unavailable)
+	assert.Equal(t, dials, 2)
+}
+
+func TestBreaker_IgnoredErrors(t *testing.T) {
+	ctx := context.Background()
+	b := New(Options{Threshold: 1})
+
+	err := b.InterceptInvoke(ctx, "/rpc", nil, nil, nil,
+		func(ctx context.Context, rpc string, enc drpc.Encoding, in, out drpc.Message) error {
+			return drpcstatus.Errorf(drpcstatus.NotFound, "not found")
+		})
+	assert.Error(t, err)
+	assert.Equal(t, b.State("/rpc"), Closed)
+}
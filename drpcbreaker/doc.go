@@ -0,0 +1,12 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+// Package drpcbreaker provides a client interceptor that stops issuing rpcs to
+// a failing remote for a while.
+//
+// Each breaker starts closed and lets every rpc through. After enough failures
+// in a row it opens, and rpcs fail immediately with an Unavailable status
+// without reaching the conn. Once the cooldown passes, it half opens and lets
+// a single rpc through as a probe: if the probe succeeds the breaker closes,
+// and otherwise it opens for another cooldown.
+package drpcbreaker
@@ -0,0 +1,5 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+// Package drpccompress holds implementations of drpc.Compressor.
+package drpccompress
@@ -0,0 +1,63 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpccompress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+
+	"github.com/zeebo/errs"
+
+	"storj.io/drpc"
+)
+
+// Gzip is a drpc.Compressor that uses gzip.
+type Gzip struct {
+	// Level is the gzip compression level to use. 0 means
+	// gzip.DefaultCompression.
+	Level int
+}
+
+var _ drpc.Compressor = Gzip{}
+
+// Name returns "gzip".
+func (g Gzip) Name() string { return "gzip" }
+
+// Compress returns the gzip compressed form of buf.
+func (g Gzip) Compress(buf []byte) ([]byte, error) {
+	level := g.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+
+	var out bytes.Buffer
+	w, err := gzip.NewWriterLevel(&out, level)
+	if err != nil {
+		return nil, errs.Wrap(err)
+	}
+	if _, err := w.Write(buf); err != nil {
+		return nil, errs.Wrap(err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, errs.Wrap(err)
+	}
+	return out.Bytes(), nil
+}
+
+// Decompress returns the original form of the gzip compressed buf. It returns
+// an error if the original form is larger than limit bytes.
+func (g Gzip) Decompress(buf []byte, limit int) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(buf))
+	if err != nil {
+		return nil, errs.Wrap(err)
+	}
+	out, err := io.ReadAll(io.LimitReader(r, int64(limit)+1))
+	if err != nil {
+		return nil, errs.Wrap(err)
+	} else if len(out) > limit {
+		return nil, errs.New("decompressed size exceeds limit %d", limit)
+	}
+	return out, errs.Wrap(r.Close())
+}
@@ -0,0 +1,49 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpccompress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+
+	"github.com/zeebo/assert"
+)
+
+func TestGzip(t *testing.T) {
+	data := bytes.Repeat([]byte("drpc"), 1024)
+
+	for _, level := range []int{0, gzip.BestSpeed, gzip.BestCompression} {
+		g := Gzip{Level: level}
+
+		cdata, err := g.Compress(data)
+		assert.NoError(t, err)
+		assert.That(t, len(cdata) < len(data))
+
+		got, err := g.Decompress(cdata, len(data))
+		assert.NoError(t, err)
+		assert.DeepEqual(t, got, data)
+	}
+
+	_, err := Gzip{}.Decompress(data, len(data))
+	assert.Error(t, err)
+
+	_, err = Gzip{Level: 100}.Compress(data)
+	assert.Error(t, err)
+}
+
+func TestGzip_Limit(t *testing.T) {
+	// 64MiB of zeros compresses to well under the size of a typical message.
+	data := make([]byte, 64<<20)
+	cdata, err := Gzip{}.Compress(data)
+	assert.NoError(t, err)
+	assert.That(t, len(cdata) < 1<<20)
+
+	_, err = Gzip{}.Decompress(cdata, 4<<20)
+	assert.Error(t, err)
+
+	got, err := Gzip{}.Decompress(cdata, len(data))
+	assert.NoError(t, err)
+	assert.Equal(t, len(got), len(data))
+}
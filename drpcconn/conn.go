@@ -5,30 +5,90 @@ package drpcconn
 
 import (
 	"context"
-	"sync"
+	"errors"
+	"io"
+	"reflect"
+	"time"
 
 	"github.com/zeebo/errs"
 
 	"storj.io/drpc"
+	"storj.io/drpc/drpcctx"
 	"storj.io/drpc/drpcenc"
 	"storj.io/drpc/drpcmanager"
 	"storj.io/drpc/drpcmetadata"
+	"storj.io/drpc/drpcsignal"
+	"storj.io/drpc/drpcstats"
 	"storj.io/drpc/drpcstream"
 	"storj.io/drpc/drpcwire"
+	"storj.io/drpc/internal/drpcbuffer"
+	"storj.io/drpc/internal/drpcnet"
 )
 
 // Options controls configuration settings for a conn.
 type Options struct {
-	// Manager controls the options we pass to the manager of this conn.
+	// Manager controls the options we pass to the manager of this conn. An
+	// Encoding or MaximumSendSize set in the stream options is used for every
+	// message sent or received on the conn, including by Invoke.
 	Manager drpcmanager.Options
+
+	// Interceptor is called around every Invoke and NewStream issued on the
+	// conn. It is not called if nil. The context it is called with has the
+	// transport of the conn associated with it, available with
+	// drpcctx.Transport.
+	Interceptor drpc.ClientInterceptor
+
+	// StateCallback, if set, is called with the state of the conn each time
+	// it changes, starting with Ready and ending with Closed, which happens
+	// both on Close and when the transport fails. It is called from a separate
+	// goroutine, for one state at a time and without holding any locks, so it
+	// may call back into the conn.
+	StateCallback func(state ConnState)
+
+	// Dial is used by Dial and DialWithOptions to connect to an address. If
+	// nil, a TCP connection is dialed.
+	Dial DialFunc
+
+	// DefaultTimeout, if positive, is the timeout used for every Invoke and
+	// NewStream whose context has no deadline. A deadline on the context is
+	// always used instead, even if it is later. It includes the time spent
+	// waiting for a previous rpc to finish, and is sent to the server like
+	// any other deadline.
+	DefaultTimeout time.Duration
+
+	// RPCDone, if set, is called once for every Invoke and NewStream when the
+	// rpc finishes, whether it succeeds, fails or is canceled. For streams
+	// that is once the stream is finished, from a separate goroutine.
+	RPCDone func(info drpcstats.RPCInfo)
+
+	// Encodings, if set, are the encodings the conn would rather use than the
+	// one passed to Invoke and NewStream, in order of preference. Each rpc
+	// lists them, followed by drpcenc.Baseline, for the server to select one
+	// before any message is sent, which takes an extra round trip, and uses
+	// the selected one for every message. The server must support encoding
+	// negotiation, like drpcserver.Server does.
+	Encodings []drpcenc.Named
+
+	// Nagle leaves Nagle's algorithm enabled when the transport is a TCP
+	// connection, either directly or wrapped like by a *tls.Conn. By default,
+	// TCP_NODELAY is set on it so that small frames are not delayed. It has
+	// no effect on other transports.
+	Nagle bool
 }
 
 // Conn is a drpc client connection.
 type Conn struct {
 	tr   drpc.Transport
 	man  *drpcmanager.Manager
-	mu   sync.Mutex
-	wbuf []byte
+	enc  drpc.Encoding
+	max  int
+	comp drpc.Compressor
+	encs []drpcenc.Named
+	intc drpc.ClientInterceptor
+	dtmo time.Duration
+	done func(drpcstats.RPCInfo)
+
+	closing drpcsignal.Signal
 }
 
 var _ drpc.Conn = (*Conn)(nil)
@@ -41,10 +101,23 @@ func New(tr drpc.Transport) *Conn {
 // NewWithOptions returns a conn that uses the transport for reads and writes.
 // The Options control details of how the conn operates.
 func NewWithOptions(tr drpc.Transport, opts Options) *Conn {
-	return &Conn{
-		tr:  tr,
-		man: drpcmanager.NewWithOptions(tr, opts.Manager),
+	_ = drpcnet.SetNoDelay(tr, !opts.Nagle)
+
+	c := &Conn{
+		tr:   tr,
+		man:  drpcmanager.NewWithOptions(tr, opts.Manager),
+		enc:  opts.Manager.Stream.Encoding,
+		max:  opts.Manager.Stream.MaximumSendSize,
+		comp: opts.Manager.Stream.Compressor,
+		encs: opts.Encodings,
+		intc: opts.Interceptor,
+		dtmo: opts.DefaultTimeout,
+		done: opts.RPCDone,
 	}
+	if opts.StateCallback != nil {
+		go c.watchState(opts.StateCallback)
+	}
+	return c
 }
 
 // Transport returns the transport the conn is using.
@@ -52,6 +125,12 @@ func (c *Conn) Transport() drpc.Transport {
 	return c.tr
 }
 
+// Stats returns the number of bytes, including framing, and messages sent over
+// the connection so far. It is safe to call concurrently with anything else.
+func (c *Conn) Stats() drpcstats.Stats {
+	return c.man.Stats()
+}
+
 // Closed returns a channel that is closed once the connection is closed.
 func (c *Conn) Closed() <-chan struct{} {
 	return c.man.Closed()
@@ -64,79 +143,302 @@ func (c *Conn) Unblocked() <-chan struct{} {
 	return c.man.Unblocked()
 }
 
+// Ping sends a ping to the remote and waits for its response, returning the
+// round trip time. It fails if the context is canceled first or the connection
+// is closed. Since remotes using a version of drpc without pings stop reading
+// when they get one, it fails with drpcmanager.ErrPingUnsupported until the
+// handshake enabled by the Handshake manager option, which happens with the
+// first rpc, has negotiated pings with the remote.
+func (c *Conn) Ping(ctx context.Context) (time.Duration, error) {
+	return c.man.Ping(ctx)
+}
+
+// Negotiated returns the protocol version and features supported by both ends
+// of the connection, and true, once the remote has announced them in response
+// to the handshake enabled by the Handshake manager option.
+func (c *Conn) Negotiated() (drpcwire.Hello, bool) {
+	return c.man.Negotiated()
+}
+
 // Close closes the connection.
 func (c *Conn) Close() (err error) {
+	c.closing.Set(nil)
 	return c.man.Close()
 }
 
-// Invoke issues the rpc on the transport serializing in, waits for a response, and
-// deserializes it into out. Only one Invoke or Stream may be open at a time.
-func (c *Conn) Invoke(ctx context.Context, rpc string, enc drpc.Encoding, in, out drpc.Message) (err error) {
-	var metadata []byte
+// withDefaultTimeout returns a context with the DefaultTimeout applied if the
+// context has no deadline. The returned cancel function is nil if it was not.
+func (c *Conn) withDefaultTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.dtmo <= 0 {
+		return ctx, nil
+	}
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, nil
+	}
+	return context.WithTimeout(ctx, c.dtmo)
+}
+
+// encodeMetadata returns the byte form of any metadata associated with the
+// context, including the time remaining before its deadline, the name of the
+// compressor of the conn and the names of its encodings. It returns nil if
+// there is no metadata.
+func (c *Conn) encodeMetadata(ctx context.Context) (metadata []byte, err error) {
 	if md, ok := drpcmetadata.Get(ctx); ok {
 		metadata, err = drpcmetadata.Encode(metadata, md)
 		if err != nil {
-			return err
+			return nil, err
+		}
+	}
+	if md, ok := drpcmetadata.OutgoingMetadataFromContext(ctx); ok {
+		metadata, err = drpcmetadata.EncodeMetadata(metadata, md)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		// fail locally rather than send an rpc that can never succeed.
+		timeout := time.Until(deadline)
+		if timeout <= 0 {
+			return nil, context.DeadlineExceeded
+		}
+		metadata, err = drpcmetadata.EncodeMetadata(metadata, drpcmetadata.Metadata{
+			drpcmetadata.TimeoutKey: {timeout.String()},
+		})
+		if err != nil {
+			return nil, err
 		}
 	}
+	if c.comp != nil {
+		metadata, err = drpcmetadata.EncodeMetadata(metadata, drpcmetadata.Metadata{
+			drpcmetadata.CompressionKey: {c.comp.Name()},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(c.encs) > 0 {
+		names := make([]string, 0, len(c.encs)+1)
+		for _, enc := range c.encs {
+			names = append(names, enc.Name())
+		}
+		metadata, err = drpcmetadata.EncodeMetadata(metadata, drpcmetadata.Metadata{
+			drpcmetadata.EncodingKey: append(names, drpcenc.Baseline),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return metadata, nil
+}
+
+// Invoke issues the rpc on the transport serializing in, waits for a response, and
+// deserializes it into out. Only one Invoke or Stream may be open at a time, and
+// concurrent calls wait their turn, each on its own stream. A nil in sends an
+// empty request, and a nil out discards the response, including typed nils
+// like a nil pointer of a message type.
+func (c *Conn) Invoke(ctx context.Context, rpc string, enc drpc.Encoding, in, out drpc.Message) (err error) {
+	ctx, cancel := c.withDefaultTimeout(ctx)
+	if cancel != nil {
+		defer cancel()
+	}
+
+	if c.intc != nil {
+		ctx = drpcctx.WithTransport(ctx, c.tr)
+		return c.intc.InterceptInvoke(ctx, rpc, enc, in, out, c.invoke)
+	}
+	return c.invoke(ctx, rpc, enc, in, out)
+}
+
+// trailerKey is used to ask invoke to store the trailer of the rpc.
+type trailerKey struct{}
+
+// InvokeWithTrailer is like Invoke but also returns the trailer the server
+// sent, if any, which is available even if the rpc fails.
+func (c *Conn) InvokeWithTrailer(ctx context.Context, rpc string, enc drpc.Encoding, in, out drpc.Message) (trailer drpcmetadata.Metadata, err error) {
+	err = c.Invoke(context.WithValue(ctx, trailerKey{}, &trailer), rpc, enc, in, out)
+	return trailer, err
+}
+
+// invoke does the work of Invoke after any interceptor has been called.
+func (c *Conn) invoke(ctx context.Context, rpc string, enc drpc.Encoding, in, out drpc.Message) (err error) {
+	if c.enc != nil {
+		enc = c.enc
+	}
+
+	// nothing else sees the stream, so it can be reused once invoke is done
+	// with it if the manager is configured to.
+	var stream *drpcstream.Stream
+	defer func() {
+		if stream != nil {
+			c.man.Release(stream)
+		}
+	}()
 
-	stream, err := c.man.NewClientStream(ctx, rpc)
+	if c.done != nil {
+		start := time.Now()
+		defer func() { c.rpcDone(rpc, false, start, stream, err) }()
+	}
+
+	stream, err = c.man.NewClientStream(ctx, rpc)
 	if err != nil {
 		return err
 	}
 	defer func() { err = errs.Combine(err, stream.Close()) }()
 
-	// we have to protect c.wbuf here even though the manager only allows one
-	// stream at a time because the stream may async close allowing another
-	// concurrent call to Invoke to proceed.
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	// the metadata is encoded once the stream is acquired so that the timeout
+	// sent to the remote does not include time spent waiting for it.
+	metadata, err := c.encodeMetadata(ctx)
+	if err != nil {
+		stream.Cancel(err)
+		return err
+	}
+
+	// the request can only be encoded once the server has selected the
+	// encoding, so the invoke is sent ahead of it.
+	invoked := false
+	if len(c.encs) > 0 {
+		if err := writeInvoke(stream, rpc, metadata); err != nil {
+			return err
+		}
+		invoked = true
 
-	c.wbuf, err = drpcenc.MarshalAppend(in, enc, c.wbuf[:0])
+		sel, err := c.negotiateEncoding(stream)
+		if err != nil {
+			return err
+		} else if sel != nil {
+			enc = sel
+		}
+	}
+
+	// the buffer comes from a pool rather than the conn because the stream
+	// may async close allowing another concurrent call to Invoke to proceed.
+	buf := drpcbuffer.Get()
+	defer drpcbuffer.Put(buf, 0)
+
+	if !isNil(in) {
+		*buf, err = drpcenc.MarshalAppend(in, enc, *buf)
+	}
+	if err == nil {
+		err = drpcstream.CheckSendSize(len(*buf), c.max)
+	}
 	if err != nil {
+		// if nothing has been sent for the stream yet, we cancel it instead
+		// of closing it to avoid telling the remote about it at all.
+		if !invoked {
+			stream.Cancel(err)
+		}
 		return err
 	}
 
-	if err := c.doInvoke(stream, enc, rpc, c.wbuf, metadata, out); err != nil {
+	trailer, _ := ctx.Value(trailerKey{}).(*drpcmetadata.Metadata)
+	if trailer != nil {
+		defer func() { *trailer = stream.Trailer() }()
+	}
+
+	if err := c.doInvoke(stream, enc, rpc, *buf, metadata, out, invoked); err != nil {
 		return err
 	}
+
+	// the trailer is sent after the response, so wait for the server to
+	// finish sending.
+	if trailer != nil {
+		if _, err := stream.RawRecv(); err == nil {
+			return drpc.ProtocolError.New("unexpected message after response")
+		} else if !errors.Is(err, io.EOF) {
+			return err
+		}
+	}
 	return nil
 }
 
-func (c *Conn) doInvoke(stream *drpcstream.Stream, enc drpc.Encoding, rpc string, data []byte, metadata []byte, out drpc.Message) (err error) {
-	if len(metadata) > 0 {
-		if err := stream.RawWrite(drpcwire.KindInvokeMetadata, metadata); err != nil {
+func (c *Conn) doInvoke(stream *drpcstream.Stream, enc drpc.Encoding, rpc string, data []byte, metadata []byte, out drpc.Message, invoked bool) (err error) {
+	if !invoked {
+		if err := writeInvoke(stream, rpc, metadata); err != nil {
 			return err
 		}
 	}
-	if err := stream.RawWrite(drpcwire.KindInvoke, []byte(rpc)); err != nil {
-		return err
-	}
-	if err := stream.RawWrite(drpcwire.KindMessage, data); err != nil {
+	if err := stream.RawWriteMessage(data); err != nil {
 		return err
 	}
 	if err := stream.CloseSend(); err != nil {
 		return err
 	}
-	if err := stream.MsgRecv(out, enc); err != nil {
+	if isNil(out) {
+		_, err := stream.RawRecv()
 		return err
 	}
-	return nil
+	return stream.MsgRecv(out, enc)
 }
 
-// NewStream begins a streaming rpc on the connection. Only one Invoke or Stream may
-// be open at a time.
-func (c *Conn) NewStream(ctx context.Context, rpc string, enc drpc.Encoding) (_ drpc.Stream, err error) {
-	var metadata []byte
-	if md, ok := drpcmetadata.Get(ctx); ok {
-		metadata, err = drpcmetadata.Encode(metadata, md)
-		if err != nil {
-			return nil, err
-		}
+// isNil returns true if the message is nil or a typed nil, like the mux checks
+// for the output message of a handler.
+func isNil(msg drpc.Message) bool {
+	if msg == nil {
+		return true
+	}
+	switch v := reflect.ValueOf(msg); v.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface, reflect.Func, reflect.Chan:
+		return v.IsNil()
+	default:
+		return false
+	}
+}
+
+// NewStream begins a streaming rpc on the connection. Any metadata associated with
+// the context is sent as part of beginning the stream so that it is available to
+// the remote before any messages are. Only one Invoke or Stream may be open at a
+// time, and concurrent calls wait their turn, preferring those with a higher
+// priority set with drpcctx.WithPriority.
+func (c *Conn) NewStream(ctx context.Context, rpc string, enc drpc.Encoding) (stream drpc.Stream, err error) {
+	ctx, cancel := c.withDefaultTimeout(ctx)
+
+	if c.intc != nil {
+		ctx = drpcctx.WithTransport(ctx, c.tr)
+		stream, err = c.intc.InterceptNewStream(ctx, rpc, enc, c.newStream)
+	} else {
+		stream, err = c.newStream(ctx, rpc, enc)
+	}
+	if cancel == nil {
+		return stream, err
+	} else if err != nil {
+		cancel()
+		return nil, err
 	}
 
-	stream, err := c.man.NewClientStream(ctx, rpc)
+	// the stream's context is done once it is finished.
+	go func() {
+		<-stream.Context().Done()
+		cancel()
+	}()
+	return stream, nil
+}
+
+// newStream does the work of NewStream after any interceptor has been called.
+func (c *Conn) newStream(ctx context.Context, rpc string, enc drpc.Encoding) (_ drpc.Stream, err error) {
+	var stream *drpcstream.Stream
+	if c.done != nil {
+		start := time.Now()
+		defer func() {
+			if err != nil {
+				c.rpcDone(rpc, true, start, stream, err)
+				return
+			}
+			go func() {
+				<-stream.Finished()
+				c.rpcDone(rpc, true, start, stream, stream.Err())
+			}()
+		}()
+	}
+
+	stream, err = c.man.NewClientStream(ctx, rpc)
+	if err != nil {
+		return nil, err
+	}
+
+	// as in invoke, the timeout must not include the time spent waiting.
+	metadata, err := c.encodeMetadata(ctx)
 	if err != nil {
+		stream.Cancel(err)
 		return nil, err
 	}
 
@@ -144,17 +446,75 @@ func (c *Conn) NewStream(ctx context.Context, rpc string, enc drpc.Encoding) (_
 		return nil, errs.Combine(err, stream.Close())
 	}
 
+	if len(c.encs) > 0 {
+		sel, err := c.negotiateEncoding(stream)
+		if err != nil {
+			return nil, errs.Combine(err, stream.Close())
+		} else if sel != nil {
+			stream.SetEncoding(sel)
+		}
+	}
+
 	return stream, nil
 }
 
-func (c *Conn) doNewStream(stream *drpcstream.Stream, rpc string, metadata []byte) error {
+// rpcDone calls the RPCDone callback for an rpc that started at start. The
+// stream is nil if the rpc failed before it was created.
+func (c *Conn) rpcDone(rpc string, isStream bool, start time.Time, stream *drpcstream.Stream, err error) {
+	info := drpcstats.RPCInfo{
+		RPC:      rpc,
+		Stream:   isStream,
+		Duration: time.Since(start),
+		Err:      err,
+	}
+	if stream != nil {
+		info.Stats = stream.Stats()
+	}
+	c.done(info)
+}
+
+// writeInvoke writes the metadata, if any, and the invoke for the rpc on the
+// stream.
+func writeInvoke(stream *drpcstream.Stream, rpc string, metadata []byte) error {
 	if len(metadata) > 0 {
 		if err := stream.RawWrite(drpcwire.KindInvokeMetadata, metadata); err != nil {
 			return err
 		}
 	}
-	if err := stream.RawWrite(drpcwire.KindInvoke, []byte(rpc)); err != nil {
+	return stream.RawWrite(drpcwire.KindInvoke, []byte(rpc))
+}
+
+// negotiateEncoding flushes the invoke written to the stream and waits for the
+// server to select one of the encodings listed in its metadata. It returns the
+// selected encoding, or nil for drpcenc.Baseline.
+func (c *Conn) negotiateEncoding(stream *drpcstream.Stream) (drpcenc.Named, error) {
+	if err := stream.RawFlush(); err != nil {
+		return nil, err
+	}
+
+	// the server sends an error instead of a header if it supports none, in
+	// which case it is returned here.
+	header, err := stream.Header()
+	if err != nil {
+		return nil, err
+	}
+
+	names := header.Get(drpcmetadata.EncodingKey)
+	if len(names) != 1 {
+		return nil, drpc.ProtocolError.New("server did not select an encoding")
+	}
+	_, enc, ok := drpcenc.Select(names, c.encs)
+	if !ok {
+		return nil, drpc.ProtocolError.New("server selected unknown encoding: %q", names[0])
+	}
+	return enc, nil
+}
+
+func (c *Conn) doNewStream(stream *drpcstream.Stream, rpc string, metadata []byte) error {
+	if err := writeInvoke(stream, rpc, metadata); err != nil {
 		return err
 	}
+	// the window can only be advertised once the remote knows of the stream.
+	stream.OpenWindow()
 	return nil
 }
@@ -5,13 +5,27 @@ package drpcconn
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/zeebo/assert"
+	"github.com/zeebo/errs"
 
 	"storj.io/drpc"
+	"storj.io/drpc/drpcenc"
+	"storj.io/drpc/drpcerr"
+	"storj.io/drpc/drpcmanager"
+	"storj.io/drpc/drpcmetadata"
+	"storj.io/drpc/drpcserver"
+	"storj.io/drpc/drpcstats"
+	"storj.io/drpc/drpcstatus"
+	"storj.io/drpc/drpcstream"
 	"storj.io/drpc/drpctest"
 	"storj.io/drpc/drpcwire"
 )
@@ -84,3 +98,1150 @@ func TestConn_InvokeFlushesSendClose(t *testing.T) {
 		t.Fatal("took too long for conn to be closed")
 	}
 }
+
+func TestConn_InvokeMetadata(t *testing.T) {
+	run := func(t *testing.T, md drpcmetadata.Metadata, expected drpcmetadata.Metadata) {
+		ctx := drpctest.NewTracker(t)
+		defer ctx.Close()
+
+		pc, ps := net.Pipe()
+		defer func() { assert.NoError(t, pc.Close()) }()
+		defer func() { assert.NoError(t, ps.Close()) }()
+
+		ctx.Run(func(ctx context.Context) {
+			wr := drpcwire.NewWriter(ps, 64)
+			rd := drpcwire.NewReader(ps)
+
+			pkt, _ := rd.ReadPacket()
+			if expected == nil {
+				assert.Equal(t, pkt.Kind, drpcwire.KindInvoke)
+			} else {
+				assert.Equal(t, pkt.Kind, drpcwire.KindInvokeMetadata)
+				got, err := drpcmetadata.DecodeMetadata(pkt.Data)
+				assert.NoError(t, err)
+				assert.DeepEqual(t, got, expected)
+				pkt, _ = rd.ReadPacket()
+				assert.Equal(t, pkt.Kind, drpcwire.KindInvoke)
+			}
+			_, _ = rd.ReadPacket() // Message
+			_, _ = rd.ReadPacket() // CloseSend
+
+			_ = wr.WritePacket(drpcwire.Packet{
+				Data: []byte("qux"),
+				ID:   drpcwire.ID{Stream: pkt.ID.Stream, Message: 1},
+				Kind: drpcwire.KindMessage,
+			})
+			_ = wr.Flush()
+
+			_, _ = rd.ReadPacket() // Close
+		})
+
+		conn := New(pc)
+		defer func() { _ = conn.Close() }()
+
+		in, out := "baz", ""
+		ictx := drpcmetadata.WithOutgoingMetadata(ctx, md)
+		assert.NoError(t, conn.Invoke(ictx, "/com.example.Foo/Bar", testEncoding{}, &in, &out))
+		assert.Equal(t, out, "qux")
+	}
+
+	t.Run("Empty", func(t *testing.T) {
+		run(t, drpcmetadata.Metadata{}, nil)
+	})
+
+	t.Run("Values", func(t *testing.T) {
+		run(t,
+			drpcmetadata.Metadata{"Key": {"a", "b"}, "other": {"c"}},
+			drpcmetadata.Metadata{"key": {"a", "b"}, "other": {"c"}},
+		)
+	})
+}
+
+type jsonEncoding struct{}
+
+func (jsonEncoding) Marshal(msg drpc.Message) ([]byte, error)     { return json.Marshal(msg) }
+func (jsonEncoding) Unmarshal(buf []byte, msg drpc.Message) error { return json.Unmarshal(buf, msg) }
+
+type jsonMessage struct {
+	Value string `json:"value"`
+}
+
+type jsonHandler struct{}
+
+func (jsonHandler) HandleRPC(stream drpc.Stream, rpc string) error {
+	for {
+		var msg jsonMessage
+		if err := stream.MsgRecv(&msg, testEncoding{}); errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		msg.Value += rpc
+		if err := stream.MsgSend(&msg, testEncoding{}); err != nil {
+			return err
+		}
+	}
+}
+
+func TestConn_Encoding(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	pc, ps := net.Pipe()
+	defer func() { _ = pc.Close() }()
+	defer func() { _ = ps.Close() }()
+
+	opts := drpcmanager.Options{Stream: drpcstream.Options{Encoding: jsonEncoding{}}}
+	srv := drpcserver.NewWithOptions(jsonHandler{}, drpcserver.Options{Manager: opts})
+	ctx.Run(func(ctx context.Context) { _ = srv.ServeOne(ctx, ps) })
+
+	conn := NewWithOptions(pc, Options{Manager: opts})
+	defer func() { _ = conn.Close() }()
+
+	{ // invoke uses the configured encoding
+		var out jsonMessage
+		assert.NoError(t, conn.Invoke(ctx, "/invoke", testEncoding{}, &jsonMessage{Value: "in"}, &out))
+		assert.Equal(t, out.Value, "in/invoke")
+	}
+
+	{ // streams use the configured encoding
+		stream, err := conn.NewStream(ctx, "/stream", testEncoding{})
+		assert.NoError(t, err)
+		for _, value := range []string{"a", "b"} {
+			var out jsonMessage
+			assert.NoError(t, stream.MsgSend(&jsonMessage{Value: value}, testEncoding{}))
+			assert.NoError(t, stream.MsgRecv(&out, testEncoding{}))
+			assert.Equal(t, out.Value, value+"/stream")
+		}
+		assert.NoError(t, stream.Close())
+	}
+}
+
+func TestConn_MaximumSendSize(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	pc, ps := net.Pipe()
+	defer func() { _ = pc.Close() }()
+	defer func() { _ = ps.Close() }()
+
+	srv := drpcserver.NewWithOptions(jsonHandler{}, drpcserver.Options{
+		Manager: drpcmanager.Options{Stream: drpcstream.Options{Encoding: jsonEncoding{}}},
+	})
+	ctx.Run(func(ctx context.Context) { _ = srv.ServeOne(ctx, ps) })
+
+	tr := &drpctest.CountingTransport{Transport: pc}
+	conn := NewWithOptions(tr, Options{Manager: drpcmanager.Options{
+		Stream: drpcstream.Options{Encoding: jsonEncoding{}, MaximumSendSize: 100},
+	}})
+	defer func() { _ = conn.Close() }()
+
+	large := &jsonMessage{Value: string(make([]byte, 100))}
+
+	{ // an invoke that is too large writes nothing
+		var out jsonMessage
+		err := conn.Invoke(ctx, "/invoke", testEncoding{}, large, &out)
+		assert.That(t, drpc.MessageSizeError.Has(err))
+		assert.Equal(t, tr.Written(), 0)
+	}
+
+	{ // a stream send that is too large writes nothing and the stream is usable
+		stream, err := conn.NewStream(ctx, "/stream", testEncoding{})
+		assert.NoError(t, err)
+		assert.NoError(t, stream.(*drpcstream.Stream).RawFlush())
+		written := tr.Written()
+
+		assert.That(t, drpc.MessageSizeError.Has(stream.MsgSend(large, testEncoding{})))
+		assert.Equal(t, tr.Written(), written)
+
+		var out jsonMessage
+		assert.NoError(t, stream.MsgSend(&jsonMessage{Value: "small"}, testEncoding{}))
+		assert.NoError(t, stream.MsgRecv(&out, testEncoding{}))
+		assert.Equal(t, out.Value, "small/stream")
+		assert.NoError(t, stream.Close())
+	}
+
+	{ // invokes that are small enough still work
+		var out jsonMessage
+		assert.NoError(t, conn.Invoke(ctx, "/invoke", testEncoding{}, &jsonMessage{Value: "in"}, &out))
+		assert.Equal(t, out.Value, "in/invoke")
+	}
+}
+
+type echoHandler struct{}
+
+func (echoHandler) HandleRPC(stream drpc.Stream, rpc string) error {
+	var msg []byte
+	if err := stream.MsgRecv(&msg, drpctest.ByteEncoding{}); err != nil {
+		return err
+	}
+	return stream.MsgSend(&msg, drpctest.ByteEncoding{})
+}
+
+func BenchmarkInvoke(b *testing.B) {
+	run := func(b *testing.B, reuse bool) {
+		pc, ps := net.Pipe()
+		defer func() { _ = pc.Close() }()
+		defer func() { _ = ps.Close() }()
+
+		srv := drpcserver.New(echoHandler{})
+		go func() { _ = srv.ServeOne(context.Background(), ps) }()
+
+		conn := NewWithOptions(pc, Options{
+			Manager: drpcmanager.Options{ReuseStreams: reuse},
+		})
+		defer func() { _ = conn.Close() }()
+
+		in := make([]byte, 1024)
+		b.ReportAllocs()
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			var out []byte
+			assert.NoError(b, conn.Invoke(context.Background(), "/invoke", drpctest.ByteEncoding{}, &in, &out))
+		}
+	}
+
+	b.Run("Default", func(b *testing.B) { run(b, false) })
+	b.Run("ReuseStreams", func(b *testing.B) { run(b, true) })
+}
+
+func TestConn_StateCallback(t *testing.T) {
+	// newConn returns a conn and the channel its states are reported on.
+	newConn := func(tr drpc.Transport, onReady func(*Conn)) (*Conn, <-chan ConnState) {
+		states := make(chan ConnState, 4)
+		var conn *Conn
+		ready := make(chan struct{})
+		conn = NewWithOptions(tr, Options{StateCallback: func(state ConnState) {
+			states <- state
+			if state == Ready && onReady != nil {
+				<-ready
+				onReady(conn)
+			}
+		}})
+		close(ready)
+		return conn, states
+	}
+	expect := func(states <-chan ConnState, want ...ConnState) {
+		for _, state := range want {
+			assert.Equal(t, <-states, state)
+		}
+	}
+
+	{ // closing the conn goes through closing
+		pc, ps := net.Pipe()
+		defer func() { _ = ps.Close() }()
+
+		conn, states := newConn(pc, nil)
+		expect(states, Ready)
+		assert.NoError(t, conn.Close())
+		expect(states, Closing, Closed)
+	}
+
+	{ // a transport failure closes the conn
+		pc, ps := net.Pipe()
+		_, states := newConn(pc, nil)
+		expect(states, Ready)
+		assert.NoError(t, ps.Close())
+		expect(states, Closed)
+	}
+
+	{ // the callback can call back into the conn
+		pc, ps := net.Pipe()
+		defer func() { _ = ps.Close() }()
+
+		_, states := newConn(pc, func(conn *Conn) { _ = conn.Close() })
+		expect(states, Ready, Closing, Closed)
+	}
+}
+
+func TestConn_Ping(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	opts := Options{Manager: drpcmanager.Options{Handshake: true}}
+	invoke := func(conn *Conn) {
+		in, out := []byte("abc"), []byte(nil)
+		assert.NoError(t, conn.Invoke(ctx, "rpc", drpctest.ByteEncoding{}, &in, &out))
+		assert.Equal(t, string(out), "abc")
+	}
+
+	{ // the remote responds to pings once the handshake negotiates them
+		pc, ps := net.Pipe()
+		defer func() { _ = ps.Close() }()
+
+		srv := drpcserver.New(handlerFunc(func(stream drpc.Stream, rpc string) error {
+			var in []byte
+			if err := stream.MsgRecv(&in, drpctest.ByteEncoding{}); err != nil {
+				return err
+			}
+			return stream.MsgSend(&in, drpctest.ByteEncoding{})
+		}))
+		ctx.Run(func(ctx context.Context) { _ = srv.ServeOne(ctx, ps) })
+
+		conn := NewWithOptions(pc, opts)
+		defer func() { _ = conn.Close() }()
+
+		_, err := conn.Ping(ctx)
+		assert.That(t, errors.Is(err, drpcmanager.ErrPingUnsupported))
+
+		invoke(conn)
+		rtt, err := conn.Ping(ctx)
+		assert.NoError(t, err)
+		assert.That(t, rtt < time.Second)
+	}
+
+	{ // the context deadline is respected if no pong arrives
+		pc, ps := net.Pipe()
+		defer func() { _ = ps.Close() }()
+
+		hello := drpcwire.LocalHello()
+		ctx.Run(func(ctx context.Context) { serveRaw(ps, &hello) })
+
+		conn := NewWithOptions(pc, opts)
+		defer func() { _ = conn.Close() }()
+		invoke(conn)
+
+		pctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+
+		_, err := conn.Ping(pctx)
+		assert.That(t, errors.Is(err, context.DeadlineExceeded))
+	}
+
+	{ // pinging a closed conn fails
+		pc, ps := net.Pipe()
+		defer func() { _ = ps.Close() }()
+
+		conn := New(pc)
+		assert.NoError(t, conn.Close())
+
+		_, err := conn.Ping(ctx)
+		assert.Error(t, err)
+	}
+}
+
+type trailerHandler struct{}
+
+func (trailerHandler) HandleRPC(stream drpc.Stream, rpc string) error {
+	var in []byte
+	if err := stream.MsgRecv(&in, drpctest.ByteEncoding{}); err != nil {
+		return err
+	}
+	if err := drpcmetadata.SetTrailer(stream.Context(), drpcmetadata.Metadata{"cursor": {string(in)}}); err != nil {
+		return err
+	}
+	if rpc == "fail" {
+		return errors.New("failed")
+	}
+	return stream.MsgSend(&in, drpctest.ByteEncoding{})
+}
+
+func TestConn_Trailer(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	pc, ps := net.Pipe()
+	defer func() { _ = pc.Close() }()
+
+	srv := drpcserver.New(trailerHandler{})
+	ctx.Run(func(ctx context.Context) { _ = srv.ServeOne(ctx, ps) })
+
+	conn := New(pc)
+	defer func() { _ = conn.Close() }()
+
+	{ // the trailer of a unitary rpc is returned
+		in, out := []byte("abc"), []byte(nil)
+		trailer, err := conn.InvokeWithTrailer(ctx, "rpc", drpctest.ByteEncoding{}, &in, &out)
+		assert.NoError(t, err)
+		assert.Equal(t, string(out), "abc")
+		assert.DeepEqual(t, trailer, drpcmetadata.Metadata{"cursor": {"abc"}})
+	}
+
+	{ // and is available when it fails
+		in, out := []byte("def"), []byte(nil)
+		trailer, err := conn.InvokeWithTrailer(ctx, "fail", drpctest.ByteEncoding{}, &in, &out)
+		assert.Error(t, err)
+		assert.DeepEqual(t, trailer, drpcmetadata.Metadata{"cursor": {"def"}})
+	}
+
+	{ // the trailer of a stream is available once it finishes
+		stream, err := conn.NewStream(ctx, "rpc", drpctest.ByteEncoding{})
+		assert.NoError(t, err)
+
+		in := []byte("ghi")
+		assert.NoError(t, stream.MsgSend(&in, drpctest.ByteEncoding{}))
+		assert.NoError(t, stream.CloseSend())
+
+		var out []byte
+		assert.NoError(t, stream.MsgRecv(&out, drpctest.ByteEncoding{}))
+		assert.That(t, errors.Is(stream.MsgRecv(&out, drpctest.ByteEncoding{}), io.EOF))
+
+		trailer := stream.(*drpcstream.Stream).Trailer()
+		assert.DeepEqual(t, trailer, drpcmetadata.Metadata{"cursor": {"ghi"}})
+		assert.NoError(t, stream.Close())
+	}
+
+	{ // contexts not belonging to a stream cannot set a trailer
+		assert.Error(t, drpcmetadata.SetTrailer(ctx, drpcmetadata.Metadata{"key": {"value"}}))
+	}
+}
+
+func TestConn_MsgSendBatch(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	pc, ps := net.Pipe()
+	defer func() { _ = pc.Close() }()
+
+	srv := drpcserver.New(handlerFunc(func(stream drpc.Stream, rpc string) error {
+		for {
+			var in []byte
+			if err := stream.MsgRecv(&in, drpctest.ByteEncoding{}); errors.Is(err, io.EOF) {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			if err := stream.MsgSend(&in, drpctest.ByteEncoding{}); err != nil {
+				return err
+			}
+		}
+	}))
+	ctx.Run(func(ctx context.Context) { _ = srv.ServeOne(ctx, ps) })
+
+	conn := NewWithOptions(pc, Options{Manager: drpcmanager.Options{
+		Stream: drpcstream.Options{MaximumSendSize: 4},
+	}})
+	defer func() { _ = conn.Close() }()
+
+	stream, err := conn.NewStream(ctx, "/batch", drpctest.ByteEncoding{})
+	assert.NoError(t, err)
+	st := stream.(*drpcstream.Stream)
+
+	batch := func(values ...string) (int, error) {
+		msgs := make([]drpc.Message, len(values))
+		for i, value := range values {
+			msg := []byte(value)
+			msgs[i] = &msg
+		}
+		return st.MsgSendBatch(msgs, drpctest.ByteEncoding{})
+	}
+	expect := func(values ...string) {
+		for _, value := range values {
+			ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			var out []byte
+			err := st.MsgRecvContext(ctx, &out, drpctest.ByteEncoding{})
+			cancel()
+			assert.NoError(t, err)
+			assert.Equal(t, string(out), value)
+		}
+	}
+
+	// the remote receives every message of a batch in order.
+	values := []string{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9"}
+	n, err := batch(values...)
+	assert.NoError(t, err)
+	assert.Equal(t, n, 10)
+	expect(values...)
+
+	// and the messages before one that fails are still sent.
+	n, err = batch("a", "b", "large", "c")
+	assert.That(t, drpc.MessageSizeError.Has(err))
+	assert.Equal(t, n, 2)
+	expect("a", "b")
+
+	assert.NoError(t, st.Close())
+}
+
+type handlerFunc func(stream drpc.Stream, rpc string) error
+
+func (fn handlerFunc) HandleRPC(stream drpc.Stream, rpc string) error { return fn(stream, rpc) }
+
+var errTestClass = errs.Class("test class")
+
+func init() { drpcerr.RegisterClass(&errTestClass) }
+
+func TestConn_ErrorClass(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	pc, ps := net.Pipe()
+	defer func() { _ = pc.Close() }()
+
+	srv := drpcserver.New(handlerFunc(func(stream drpc.Stream, rpc string) error {
+		var in []byte
+		if err := stream.MsgRecv(&in, drpctest.ByteEncoding{}); err != nil {
+			return err
+		}
+		return errTestClass.New("bad request")
+	}))
+	ctx.Run(func(ctx context.Context) { _ = srv.ServeOne(ctx, ps) })
+
+	conn := New(pc)
+	defer func() { _ = conn.Close() }()
+
+	in, out := []byte("abc"), []byte(nil)
+	trailer, err := conn.InvokeWithTrailer(ctx, "rpc", drpctest.ByteEncoding{}, &in, &out)
+	assert.That(t, errTestClass.Has(err))
+	assert.Equal(t, err.Error(), "test class: bad request")
+	assert.Nil(t, trailer)
+}
+
+type headerHandler struct{}
+
+func (headerHandler) HandleRPC(stream drpc.Stream, rpc string) error {
+	var in []byte
+	if err := stream.MsgRecv(&in, drpctest.ByteEncoding{}); err != nil {
+		return err
+	}
+	md := drpcmetadata.Metadata{"negotiated": {string(in)}}
+	if rpc == "header" {
+		if err := drpcmetadata.SendHeader(stream.Context(), md); err != nil {
+			return err
+		}
+		if err := drpcmetadata.SendHeader(stream.Context(), md); err == nil {
+			return errors.New("second header was sent")
+		}
+	}
+	if err := stream.MsgSend(&in, drpctest.ByteEncoding{}); err != nil {
+		return err
+	}
+	if err := drpcmetadata.SendHeader(stream.Context(), md); err == nil {
+		return errors.New("header was sent after a message")
+	}
+	return nil
+}
+
+func TestConn_Header(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	pc, ps := net.Pipe()
+	defer func() { _ = pc.Close() }()
+
+	srv := drpcserver.New(headerHandler{})
+	ctx.Run(func(ctx context.Context) { _ = srv.ServeOne(ctx, ps) })
+
+	conn := New(pc)
+	defer func() { _ = conn.Close() }()
+
+	call := func(rpc string) drpcmetadata.Metadata {
+		stream, err := conn.NewStream(ctx, rpc, drpctest.ByteEncoding{})
+		assert.NoError(t, err)
+		defer func() { _ = stream.Close() }()
+
+		in := []byte("json")
+		assert.NoError(t, stream.MsgSend(&in, drpctest.ByteEncoding{}))
+
+		header, err := stream.(*drpcstream.Stream).Header()
+		assert.NoError(t, err)
+
+		var out []byte
+		assert.NoError(t, stream.MsgRecv(&out, drpctest.ByteEncoding{}))
+		assert.Equal(t, string(out), "json")
+		assert.That(t, errors.Is(stream.MsgRecv(&out, drpctest.ByteEncoding{}), io.EOF))
+		return header
+	}
+
+	// the header arrives before the first message.
+	assert.DeepEqual(t, call("header"), drpcmetadata.Metadata{"negotiated": {"json"}})
+
+	// streams without a header have none once a message arrives.
+	assert.Nil(t, call("none"))
+}
+
+func TestConn_ClosedAndStreamErr(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	newConn := func(handler drpc.Handler) *Conn {
+		pc, ps := net.Pipe()
+		ctx.Run(func(ctx context.Context) { _ = drpcserver.New(handler).ServeOne(ctx, ps) })
+		return New(pc)
+	}
+	newStream := func(conn *Conn) *drpcstream.Stream {
+		stream, err := conn.NewStream(ctx, "fail", drpctest.ByteEncoding{})
+		assert.NoError(t, err)
+		return stream.(*drpcstream.Stream)
+	}
+
+	{ // a stream that ends cleanly has no error
+		conn := newConn(echoHandler{})
+		defer func() { _ = conn.Close() }()
+
+		stream := newStream(conn)
+		assert.NoError(t, stream.Err())
+
+		in := []byte("data")
+		assert.NoError(t, stream.MsgSend(&in, drpctest.ByteEncoding{}))
+		assert.NoError(t, stream.CloseSend())
+		assert.NoError(t, stream.MsgRecv(&in, drpctest.ByteEncoding{}))
+		assert.That(t, errors.Is(stream.MsgRecv(&in, drpctest.ByteEncoding{}), io.EOF))
+
+		<-stream.Terminated()
+		assert.NoError(t, stream.Err())
+	}
+
+	{ // a stream that the remote fails has the remote's error
+		conn := newConn(trailerHandler{})
+		defer func() { _ = conn.Close() }()
+
+		stream := newStream(conn)
+		in := []byte("data")
+		assert.NoError(t, stream.MsgSend(&in, drpctest.ByteEncoding{}))
+
+		<-stream.Terminated()
+		assert.Equal(t, stream.Err().Error(), "failed")
+	}
+
+	{ // closing the conn closes it and fails its stream
+		conn := newConn(echoHandler{})
+		stream := newStream(conn)
+
+		assert.That(t, !closedCh(conn.Closed()))
+		assert.NoError(t, conn.Close())
+		assert.That(t, closedCh(conn.Closed()))
+
+		<-stream.Terminated()
+		assert.Error(t, stream.Err())
+	}
+}
+
+func closedCh(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestConn_DefaultTimeout(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	srv := drpcserver.New(handlerFunc(func(stream drpc.Stream, rpc string) error {
+		var in []byte
+		if err := stream.MsgRecv(&in, drpctest.ByteEncoding{}); err != nil {
+			return err
+		}
+		select {
+		case <-time.After(100 * time.Millisecond):
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		}
+		return stream.MsgSend(&in, drpctest.ByteEncoding{})
+	}))
+
+	dial := func() *Conn {
+		pc, ps := net.Pipe()
+		ctx.Run(func(ctx context.Context) { _ = srv.ServeOne(ctx, ps) })
+		return NewWithOptions(pc, Options{DefaultTimeout: 20 * time.Millisecond})
+	}
+
+	{ // an rpc without a deadline fails after the default timeout
+		conn := dial()
+		in, out := []byte("abc"), []byte(nil)
+		err := conn.Invoke(ctx, "rpc", drpctest.ByteEncoding{}, &in, &out)
+		assert.That(t, errors.Is(err, context.DeadlineExceeded))
+		assert.NoError(t, conn.Close())
+	}
+
+	{ // and so does a stream
+		conn := dial()
+		stream, err := conn.NewStream(ctx, "rpc", drpctest.ByteEncoding{})
+		assert.NoError(t, err)
+		deadline, ok := stream.Context().Deadline()
+		assert.That(t, ok && time.Until(deadline) <= 20*time.Millisecond)
+
+		in, out := []byte("abc"), []byte(nil)
+		assert.NoError(t, stream.MsgSend(&in, drpctest.ByteEncoding{}))
+		assert.That(t, errors.Is(stream.MsgRecv(&out, drpctest.ByteEncoding{}), context.DeadlineExceeded))
+		assert.NoError(t, conn.Close())
+	}
+
+	{ // an explicit deadline is used even though it is later
+		conn := dial()
+		tctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+
+		in, out := []byte("abc"), []byte(nil)
+		assert.NoError(t, conn.Invoke(tctx, "rpc", drpctest.ByteEncoding{}, &in, &out))
+		assert.Equal(t, string(out), "abc")
+		assert.NoError(t, conn.Close())
+	}
+}
+
+func TestConn_InvokeNilMessages(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	pc, ps := net.Pipe()
+	defer func() { _ = pc.Close() }()
+
+	var received []string
+	srv := drpcserver.New(handlerFunc(func(stream drpc.Stream, rpc string) error {
+		var in []byte
+		if err := stream.MsgRecv(&in, drpctest.ByteEncoding{}); err != nil {
+			return err
+		}
+		received = append(received, string(in))
+		out := append([]byte("got:"), in...)
+		return stream.MsgSend(&out, drpctest.ByteEncoding{})
+	}))
+	ctx.Run(func(ctx context.Context) { _ = srv.ServeOne(ctx, ps) })
+
+	conn := New(pc)
+	defer func() { _ = conn.Close() }()
+
+	{ // a nil in sends an empty request
+		var out []byte
+		assert.NoError(t, conn.Invoke(ctx, "rpc", drpctest.ByteEncoding{}, nil, &out))
+		assert.Equal(t, string(out), "got:")
+	}
+
+	{ // a nil out discards the response
+		in := []byte("abc")
+		assert.NoError(t, conn.Invoke(ctx, "rpc", drpctest.ByteEncoding{}, &in, nil))
+	}
+
+	{ // and both may be nil
+		assert.NoError(t, conn.Invoke(ctx, "rpc", drpctest.ByteEncoding{}, nil, nil))
+	}
+
+	{ // as may nil pointers of a message type
+		var in, out *[]byte
+		assert.NoError(t, conn.Invoke(ctx, "rpc", drpctest.ByteEncoding{}, in, out))
+	}
+
+	{ // the conn is still usable afterward
+		in, out := []byte("def"), []byte(nil)
+		assert.NoError(t, conn.Invoke(ctx, "rpc", drpctest.ByteEncoding{}, &in, &out))
+		assert.Equal(t, string(out), "got:def")
+	}
+
+	assert.DeepEqual(t, received, []string{"", "abc", "", "", "def"})
+}
+
+// serveRaw answers every unitary rpc read from the transport by echoing its
+// message like a server would, announcing the hello, if not nil, when it first
+// reads one in the metadata of an invoke. Any packets on stream zero are
+// ignored.
+func serveRaw(tr drpc.Transport, hello *drpcwire.Hello) {
+	rd, wr := drpcwire.NewReader(tr), drpcwire.NewWriter(tr, 0)
+	for {
+		pkt, err := rd.ReadPacket()
+		if err != nil {
+			return
+		}
+		switch {
+		case pkt.Kind == drpcwire.KindInvokeMetadata && hello != nil:
+			md, err := drpcmetadata.DecodeMetadata(pkt.Data)
+			if err != nil || len(md.Get(drpcmetadata.HelloKey)) == 0 {
+				break
+			}
+			// a later version may send fields that are unknown to us.
+			data := drpcwire.AppendVarint(drpcwire.AppendHello(nil, *hello), 42)
+			_ = wr.WritePacket(drpcwire.Packet{Data: data, Kind: drpcwire.KindHello})
+			hello = nil
+		case pkt.Kind == drpcwire.KindMessage:
+			id := drpcwire.ID{Stream: pkt.ID.Stream, Message: 1}
+			_ = wr.WritePacket(drpcwire.Packet{Data: pkt.Data, ID: id, Kind: drpcwire.KindMessage})
+			id.Message++
+			_ = wr.WritePacket(drpcwire.Packet{ID: id, Kind: drpcwire.KindCloseSend})
+		}
+		_ = wr.Flush()
+	}
+}
+
+func TestConn_Handshake(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	opts := Options{Manager: drpcmanager.Options{Handshake: true}}
+	invoke := func(conn *Conn) {
+		in, out := []byte("abc"), []byte(nil)
+		assert.NoError(t, conn.Invoke(ctx, "rpc", drpctest.ByteEncoding{}, &in, &out))
+		assert.Equal(t, string(out), "abc")
+	}
+
+	{ // a server of the same version negotiates everything
+		pc, ps := net.Pipe()
+		srv := drpcserver.New(handlerFunc(func(stream drpc.Stream, rpc string) error {
+			// the announcement is not passed to the handler.
+			if md, _ := drpcmetadata.MetadataFromContext(stream.Context()); len(md.Get(drpcmetadata.HelloKey)) > 0 {
+				return errors.New("hello in metadata")
+			}
+			var in []byte
+			if err := stream.MsgRecv(&in, drpctest.ByteEncoding{}); err != nil {
+				return err
+			}
+			return stream.MsgSend(&in, drpctest.ByteEncoding{})
+		}))
+		ctx.Run(func(ctx context.Context) { _ = srv.ServeOne(ctx, ps) })
+
+		conn := NewWithOptions(pc, opts)
+		invoke(conn)
+
+		hello, ok := conn.Negotiated()
+		assert.That(t, ok)
+		assert.Equal(t, hello, drpcwire.LocalHello())
+		assert.NoError(t, conn.Close())
+	}
+
+	{ // a server without the handshake falls back to the baseline
+		pc, ps := net.Pipe()
+		ctx.Run(func(ctx context.Context) { serveRaw(ps, nil) })
+
+		conn := NewWithOptions(pc, opts)
+		invoke(conn)
+
+		hello, ok := conn.Negotiated()
+		assert.That(t, !ok)
+		assert.Equal(t, hello, drpcwire.Hello{})
+		assert.NoError(t, conn.Close())
+	}
+
+	{ // a server of a later version has its unknown features ignored
+		pc, ps := net.Pipe()
+		future := drpcwire.Hello{Version: 99, Features: drpcwire.FeatureTrailer | 1<<40}
+		ctx.Run(func(ctx context.Context) { serveRaw(ps, &future) })
+
+		conn := NewWithOptions(pc, opts)
+		invoke(conn)
+
+		hello, ok := conn.Negotiated()
+		assert.That(t, ok)
+		assert.Equal(t, hello, drpcwire.Hello{Version: drpcwire.ProtocolVersion, Features: drpcwire.FeatureTrailer})
+		assert.NoError(t, conn.Close())
+	}
+}
+
+func TestConn_Checksum(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	echo := handlerFunc(func(stream drpc.Stream, rpc string) error {
+		var in []byte
+		if err := stream.MsgRecv(&in, drpctest.ByteEncoding{}); err != nil {
+			return err
+		}
+		return stream.MsgSend(&in, drpctest.ByteEncoding{})
+	})
+
+	run := func(server, client bool) drpcwire.Hello {
+		pc, ps := net.Pipe()
+		srv := drpcserver.NewWithOptions(echo, drpcserver.Options{
+			Manager: drpcmanager.Options{Checksum: server},
+		})
+		ctx.Run(func(ctx context.Context) { _ = srv.ServeOne(ctx, ps) })
+
+		conn := NewWithOptions(pc, Options{
+			Manager: drpcmanager.Options{Handshake: true, Checksum: client},
+		})
+		defer func() { _ = conn.Close() }()
+
+		for i := 0; i < 3; i++ {
+			in, out := []byte("abc"), []byte(nil)
+			assert.NoError(t, conn.Invoke(ctx, "rpc", drpctest.ByteEncoding{}, &in, &out))
+			assert.Equal(t, string(out), "abc")
+		}
+
+		hello, ok := conn.Negotiated()
+		assert.That(t, ok)
+		return hello
+	}
+
+	assert.That(t, run(true, true).Features.Has(drpcwire.FeatureChecksum))
+	assert.That(t, !run(true, false).Features.Has(drpcwire.FeatureChecksum))
+	assert.That(t, !run(false, true).Features.Has(drpcwire.FeatureChecksum))
+}
+
+func TestConn_RPCDone(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	pc, ps := net.Pipe()
+	defer func() { _ = pc.Close() }()
+
+	srv := drpcserver.New(handlerFunc(func(stream drpc.Stream, rpc string) error {
+		var in []byte
+		if err := stream.MsgRecv(&in, drpctest.ByteEncoding{}); err != nil {
+			return err
+		}
+		if rpc == "fail" {
+			return errs.New("failed")
+		}
+		return stream.MsgSend(&in, drpctest.ByteEncoding{})
+	}))
+	ctx.Run(func(ctx context.Context) { _ = srv.ServeOne(ctx, ps) })
+
+	infos := make(chan drpcstats.RPCInfo, 10)
+	conn := NewWithOptions(pc, Options{RPCDone: func(info drpcstats.RPCInfo) { infos <- info }})
+	defer func() { _ = conn.Close() }()
+
+	{ // a unitary rpc that succeeds
+		in, out := []byte("abc"), []byte(nil)
+		assert.NoError(t, conn.Invoke(ctx, "rpc", drpctest.ByteEncoding{}, &in, &out))
+
+		info := <-infos
+		assert.Equal(t, info.RPC, "rpc")
+		assert.That(t, !info.Stream && info.Duration > 0 && info.Err == nil)
+		assert.Equal(t, info.Stats.MessagesWritten, uint64(1))
+		assert.Equal(t, info.Stats.MessagesRead, uint64(1))
+		assert.Equal(t, info.Stats.Read, uint64(3))
+	}
+
+	{ // a unitary rpc that fails
+		in, out := []byte("abc"), []byte(nil)
+		assert.Error(t, conn.Invoke(ctx, "fail", drpctest.ByteEncoding{}, &in, &out))
+
+		info := <-infos
+		assert.Equal(t, info.RPC, "fail")
+		assert.That(t, info.Duration > 0)
+		assert.That(t, strings.Contains(fmt.Sprint(info.Err), "failed"))
+	}
+
+	{ // a stream that is canceled once it is finished
+		sctx, cancel := context.WithCancel(ctx)
+		stream, err := conn.NewStream(sctx, "rpc", drpctest.ByteEncoding{})
+		assert.NoError(t, err)
+
+		in, out := []byte("abc"), []byte(nil)
+		assert.NoError(t, stream.MsgSend(&in, drpctest.ByteEncoding{}))
+		assert.NoError(t, stream.MsgRecv(&out, drpctest.ByteEncoding{}))
+		cancel()
+
+		info := <-infos
+		assert.That(t, info.Stream && info.Duration > 0)
+		assert.That(t, errors.Is(info.Err, context.Canceled))
+	}
+
+	select {
+	case info := <-infos:
+		t.Fatal("extra call to RPCDone:", info)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestConn_ReuseStreams(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	pc, ps := net.Pipe()
+	defer func() { _ = pc.Close() }()
+
+	srv := drpcserver.New(handlerFunc(func(stream drpc.Stream, rpc string) error {
+		var in []byte
+		if err := stream.MsgRecv(&in, drpctest.ByteEncoding{}); err != nil {
+			return err
+		}
+		switch rpc {
+		case "fail":
+			return errs.New("failed")
+		case "trailer":
+			md := drpcmetadata.Metadata{"cursor": {string(in)}}
+			if err := drpcmetadata.SetTrailer(stream.Context(), md); err != nil {
+				return err
+			}
+		}
+		return stream.MsgSend(&in, drpctest.ByteEncoding{})
+	}))
+	ctx.Run(func(ctx context.Context) { _ = srv.ServeOne(ctx, ps) })
+
+	conn := NewWithOptions(pc, Options{
+		Manager: drpcmanager.Options{ReuseStreams: true},
+	})
+	defer func() { _ = conn.Close() }()
+
+	// every rpc sees only its own result, whatever the rpc using the stream
+	// before it left behind.
+	for i := 0; i < 30; i++ {
+		in, out := []byte(fmt.Sprint(i)), []byte(nil)
+
+		switch i % 3 {
+		case 0:
+			_, err := conn.InvokeWithTrailer(ctx, "fail", drpctest.ByteEncoding{}, &in, &out)
+			assert.Error(t, err)
+			assert.Equal(t, string(out), "")
+
+		case 1:
+			trailer, err := conn.InvokeWithTrailer(ctx, "trailer", drpctest.ByteEncoding{}, &in, &out)
+			assert.NoError(t, err)
+			assert.Equal(t, string(out), string(in))
+			assert.DeepEqual(t, trailer, drpcmetadata.Metadata{"cursor": {string(in)}})
+
+		case 2:
+			trailer, err := conn.InvokeWithTrailer(ctx, "echo", drpctest.ByteEncoding{}, &in, &out)
+			assert.NoError(t, err)
+			assert.Equal(t, string(out), string(in))
+			assert.Equal(t, len(trailer), 0)
+		}
+	}
+}
+
+func TestConn_Concurrent(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	pc, ps := net.Pipe()
+	defer func() { _ = pc.Close() }()
+
+	srv := drpcserver.New(handlerFunc(func(stream drpc.Stream, rpc string) error {
+		for {
+			var in []byte
+			if err := stream.MsgRecv(&in, drpctest.ByteEncoding{}); errors.Is(err, io.EOF) {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			in = append([]byte(rpc+":"), in...)
+			if err := stream.MsgSend(&in, drpctest.ByteEncoding{}); err != nil {
+				return err
+			}
+		}
+	}))
+	ctx.Run(func(ctx context.Context) { _ = srv.ServeOne(ctx, ps) })
+
+	conn := New(pc)
+	defer func() { _ = conn.Close() }()
+
+	const calls = 500
+	errch := make(chan error, calls)
+
+	for i := 0; i < calls; i++ {
+		i := i
+		ctx.Run(func(ctx context.Context) {
+			errch <- func() error {
+				rpc, in := fmt.Sprintf("rpc%d", i), []byte(fmt.Sprint(i))
+				exp := rpc + ":" + string(in)
+
+				if i%5 != 0 {
+					var out []byte
+					if err := conn.Invoke(ctx, rpc, drpctest.ByteEncoding{}, &in, &out); err != nil {
+						return err
+					} else if string(out) != exp {
+						return errs.New("invoke %d: got %q, expected %q", i, out, exp)
+					}
+					return nil
+				}
+
+				stream, err := conn.NewStream(ctx, rpc, drpctest.ByteEncoding{})
+				if err != nil {
+					return err
+				}
+				defer func() { _ = stream.Close() }()
+
+				for j := 0; j < 3; j++ {
+					var out []byte
+					if err := stream.MsgSend(&in, drpctest.ByteEncoding{}); err != nil {
+						return err
+					} else if err := stream.MsgRecv(&out, drpctest.ByteEncoding{}); err != nil {
+						return err
+					} else if string(out) != exp {
+						return errs.New("stream %d: got %q, expected %q", i, out, exp)
+					}
+				}
+				return stream.CloseSend()
+			}()
+		})
+	}
+
+	for i := 0; i < calls; i++ {
+		assert.NoError(t, <-errch)
+	}
+}
+
+// namedEncoding is a named encoding of byte slices that tags the data with its
+// name so that it can tell that it was used on both sides.
+type namedEncoding string
+
+func (e namedEncoding) Name() string { return string(e) }
+
+func (e namedEncoding) Marshal(msg drpc.Message) ([]byte, error) {
+	return append([]byte(e+":"), *msg.(*[]byte)...), nil
+}
+
+func (e namedEncoding) Unmarshal(buf []byte, msg drpc.Message) error {
+	if !strings.HasPrefix(string(buf), string(e)+":") {
+		return errs.New("%s: untagged data: %q", e, buf)
+	}
+	*msg.(*[]byte) = append([]byte(nil), buf[len(e)+1:]...)
+	return nil
+}
+
+func TestConn_Encodings(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	echo := handlerFunc(func(stream drpc.Stream, rpc string) error {
+		var in []byte
+		if err := stream.MsgRecv(&in, drpctest.ByteEncoding{}); err != nil {
+			return err
+		}
+		return stream.MsgSend(&in, drpctest.ByteEncoding{})
+	})
+
+	dial := func(server, client []drpcenc.Named) *Conn {
+		pc, ps := net.Pipe()
+		srv := drpcserver.NewWithOptions(echo, drpcserver.Options{Encodings: server})
+		ctx.Run(func(ctx context.Context) { _ = srv.ServeOne(ctx, ps) })
+		return NewWithOptions(pc, Options{Encodings: client})
+	}
+
+	invoke := func(conn *Conn) {
+		in, out := []byte("abc"), []byte(nil)
+		assert.NoError(t, conn.Invoke(ctx, "rpc", drpctest.ByteEncoding{}, &in, &out))
+		assert.Equal(t, string(out), "abc")
+	}
+
+	stream := func(conn *Conn) string {
+		stream, err := conn.NewStream(ctx, "rpc", drpctest.ByteEncoding{})
+		assert.NoError(t, err)
+		defer func() { _ = stream.Close() }()
+
+		in, out := []byte("abc"), []byte(nil)
+		assert.NoError(t, stream.MsgSend(&in, drpctest.ByteEncoding{}))
+		assert.NoError(t, stream.MsgRecv(&out, drpctest.ByteEncoding{}))
+		assert.Equal(t, string(out), "abc")
+
+		header, err := stream.(*drpcstream.Stream).Header()
+		assert.NoError(t, err)
+		return header.Get(drpcmetadata.EncodingKey)[0]
+	}
+
+	jsonEnc, xmlEnc := namedEncoding("json"), namedEncoding("xml")
+
+	{ // the client prefers json, which the server supports
+		conn := dial([]drpcenc.Named{jsonEnc}, []drpcenc.Named{jsonEnc})
+		invoke(conn)
+		assert.Equal(t, stream(conn), "json")
+		assert.NoError(t, conn.Close())
+	}
+
+	{ // the first supported preference of the client is selected
+		conn := dial([]drpcenc.Named{jsonEnc}, []drpcenc.Named{xmlEnc, jsonEnc})
+		invoke(conn)
+		assert.Equal(t, stream(conn), "json")
+		assert.NoError(t, conn.Close())
+	}
+
+	{ // clients fall back to the baseline
+		conn := dial([]drpcenc.Named{jsonEnc}, []drpcenc.Named{xmlEnc})
+		invoke(conn)
+		assert.Equal(t, stream(conn), drpcenc.Baseline)
+		assert.NoError(t, conn.Close())
+	}
+
+	{ // rpcs with no common encoding fail
+		conn := dial(nil, nil)
+		ctx := drpcmetadata.WithOutgoingMetadata(ctx, drpcmetadata.Metadata{
+			drpcmetadata.EncodingKey: {"xml"},
+		})
+		err := conn.Invoke(ctx, "rpc", drpctest.ByteEncoding{}, new([]byte), new([]byte))
+		assert.Error(t, err)
+		assert.That(t, strings.Contains(err.Error(), "no common encoding"))
+		assert.Equal(t, drpcerr.Code(err), uint64(drpcstatus.Unimplemented))
+		assert.NoError(t, conn.Close())
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcconn
+
+import (
+	"context"
+	"net"
+
+	"storj.io/drpc"
+)
+
+// DialFunc returns a transport connected to the address.
+type DialFunc func(ctx context.Context, addr string) (drpc.Transport, error)
+
+// Dial returns a conn over a TCP connection to the address.
+func Dial(ctx context.Context, addr string) (*Conn, error) {
+	return DialWithOptions(ctx, addr, Options{})
+}
+
+// DialWithOptions is like Dial but uses the provided options, including the
+// Dial function used to connect to the address.
+func DialWithOptions(ctx context.Context, addr string, opts Options) (*Conn, error) {
+	dial := opts.Dial
+	if dial == nil {
+		dial = dialTCP
+	}
+
+	tr, err := dial(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	return NewWithOptions(tr, opts), nil
+}
+
+// dialTCP is the default DialFunc.
+func dialTCP(ctx context.Context, addr string) (drpc.Transport, error) {
+	var d net.Dialer
+	return d.DialContext(ctx, "tcp", addr)
+}
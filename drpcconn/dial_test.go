@@ -0,0 +1,66 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcconn
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/zeebo/assert"
+
+	"storj.io/drpc"
+	"storj.io/drpc/drpcserver"
+	"storj.io/drpc/drpctest"
+)
+
+func TestDial(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	srv := drpcserver.New(echoHandler{})
+	invoke := func(conn *Conn) string {
+		in, out := []byte("data"), []byte(nil)
+		assert.NoError(t, conn.Invoke(ctx, "rpc", drpctest.ByteEncoding{}, &in, &out))
+		return string(out)
+	}
+
+	{ // the default dials tcp
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+		ctx.Run(func(ctx context.Context) { _ = srv.Serve(ctx, lis) })
+
+		conn, err := Dial(ctx, lis.Addr().String())
+		assert.NoError(t, err)
+		defer func() { _ = conn.Close() }()
+
+		assert.Equal(t, invoke(conn), "data")
+	}
+
+	{ // a custom dialer is given the address and its transport is used
+		var addrs []string
+		conn, err := DialWithOptions(ctx, "fake:1234", Options{
+			Dial: func(_ context.Context, addr string) (drpc.Transport, error) {
+				addrs = append(addrs, addr)
+				pc, ps := net.Pipe()
+				ctx.Run(func(ctx context.Context) { _ = srv.ServeOne(ctx, ps) })
+				return pc, nil
+			},
+		})
+		assert.NoError(t, err)
+		defer func() { _ = conn.Close() }()
+
+		assert.Equal(t, invoke(conn), "data")
+		assert.DeepEqual(t, addrs, []string{"fake:1234"})
+	}
+
+	{ // errors from the dialer are returned
+		dialErr := errors.New("dial failed")
+		_, err := DialWithOptions(ctx, "fake:1234", Options{
+			Dial: func(ctx context.Context, addr string) (drpc.Transport, error) { return nil, dialErr },
+		})
+		assert.That(t, errors.Is(err, dialErr))
+	}
+}
@@ -1,5 +1,6 @@
 // Copyright (C) 2019 Storj Labs, Inc.
 // See LICENSE for copying information.
 
-// Package drpcconn creates a drpc client connection from a transport.
+// Package drpcconn creates a drpc client connection from a transport, or by
+// dialing an address with a configurable dial function.
 package drpcconn
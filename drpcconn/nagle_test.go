@@ -0,0 +1,79 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+//go:build !windows
+
+package drpcconn
+
+import (
+	"context"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/zeebo/assert"
+
+	"storj.io/drpc"
+	"storj.io/drpc/drpcserver"
+	"storj.io/drpc/drpctest"
+)
+
+func TestConn_Nagle(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer func() { _ = lis.Close() }()
+
+	// tcpPair returns both sides of a tcp connection with TCP_NODELAY set to
+	// noDelay, so that the test observes the option being changed.
+	tcpPair := func(noDelay bool) (client, server *net.TCPConn) {
+		cc, err := net.Dial("tcp", lis.Addr().String())
+		assert.NoError(t, err)
+		sc, err := lis.Accept()
+		assert.NoError(t, err)
+
+		client, server = cc.(*net.TCPConn), sc.(*net.TCPConn)
+		assert.NoError(t, client.SetNoDelay(noDelay))
+		assert.NoError(t, server.SetNoDelay(noDelay))
+		return client, server
+	}
+
+	for _, nagle := range []bool{false, true} {
+		cc, sc := tcpPair(nagle)
+
+		srv := drpcserver.NewWithOptions(handlerFunc(func(stream drpc.Stream, rpc string) error {
+			var in []byte
+			if err := stream.MsgRecv(&in, drpctest.ByteEncoding{}); err != nil {
+				return err
+			}
+			return stream.MsgSend(&in, drpctest.ByteEncoding{})
+		}), drpcserver.Options{Nagle: nagle})
+		ctx.Run(func(ctx context.Context) { _ = srv.ServeOne(ctx, sc) })
+
+		conn := NewWithOptions(cc, Options{Nagle: nagle})
+		assert.Equal(t, noDelay(t, cc), !nagle)
+
+		// once an rpc completes, the server has set up the transport.
+		in, out := []byte("in"), []byte(nil)
+		assert.NoError(t, conn.Invoke(ctx, "rpc", drpctest.ByteEncoding{}, &in, &out))
+		assert.Equal(t, noDelay(t, sc), !nagle)
+
+		assert.NoError(t, conn.Close())
+	}
+}
+
+// noDelay reports if TCP_NODELAY is set on the socket of the conn.
+func noDelay(t *testing.T, conn *net.TCPConn) bool {
+	raw, err := conn.SyscallConn()
+	assert.NoError(t, err)
+
+	var val int
+	var serr error
+	assert.NoError(t, raw.Control(func(fd uintptr) {
+		val, serr = syscall.GetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_NODELAY)
+	}))
+	assert.NoError(t, serr)
+	return val != 0
+}
@@ -0,0 +1,56 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcconn
+
+import "strconv"
+
+// ConnState is the state of a conn reported to the StateCallback.
+type ConnState int
+
+const (
+	// Ready is the state of a conn that can issue rpcs. Conns are given an
+	// established transport, so they start out Ready.
+	Ready ConnState = iota
+
+	// Closing is the state of a conn that Close has been called on but whose
+	// transport is not yet closed.
+	Closing
+
+	// Closed is the state of a conn whose transport is closed, either because
+	// of Close or because of an error.
+	Closed
+)
+
+// String returns the name of the state.
+func (s ConnState) String() string {
+	switch s {
+	case Ready:
+		return "Ready"
+	case Closing:
+		return "Closing"
+	case Closed:
+		return "Closed"
+	default:
+		return "ConnState(" + strconv.Itoa(int(s)) + ")"
+	}
+}
+
+// watchState reports the state of the conn to the callback as it changes until
+// the conn is closed. Reporting from a single goroutine keeps the callbacks in
+// order and lets them call back into the conn, including Close.
+func (c *Conn) watchState(cb func(ConnState)) {
+	cb(Ready)
+
+	select {
+	case <-c.closing.Signal():
+		cb(Closing)
+		<-c.man.Closed()
+	case <-c.man.Closed():
+		if c.closing.IsSet() {
+			cb(Closing)
+		}
+	}
+
+	cb(Closed)
+}
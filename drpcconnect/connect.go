@@ -0,0 +1,160 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcconnect
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/zeebo/errs"
+
+	"storj.io/drpc"
+	"storj.io/drpc/drpcconn"
+)
+
+// Error wraps all of the errors returned by this package.
+var Error = errs.Class("drpcconnect")
+
+// Options controls configuration settings for dialing through a proxy.
+type Options struct {
+	// Username and Password, if Username is not empty, are sent to the proxy
+	// with basic authentication in a Proxy-Authorization header.
+	Username string
+	Password string
+
+	// Header contains any additional headers to send with the CONNECT request.
+	Header http.Header
+
+	// Dial is used to connect to the proxy. If nil, a TCP connection is dialed.
+	Dial func(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// Dial connects to the proxy and asks it to open a tunnel to addr, returning
+// the tunneled conn, which can be used as the transport of a drpcconn.Conn.
+func Dial(ctx context.Context, proxy, addr string) (net.Conn, error) {
+	return DialWithOptions(ctx, proxy, addr, Options{})
+}
+
+// DialWithOptions is like Dial but uses the provided options. The context
+// bounds both connecting to the proxy and the CONNECT round trip, but not the
+// use of the returned conn. If the proxy responds with any status other than
+// 200, the returned error includes it.
+func DialWithOptions(ctx context.Context, proxy, addr string, opts Options) (net.Conn, error) {
+	dial := opts.Dial
+	if dial == nil {
+		var d net.Dialer
+		dial = d.DialContext
+	}
+
+	conn, err := dial(ctx, "tcp", proxy)
+	if err != nil {
+		return nil, Error.Wrap(err)
+	}
+
+	tunnel, err := connect(ctx, conn, proxy, addr, opts)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return tunnel, nil
+}
+
+// NewDialFunc returns a drpcconn.DialFunc that dials addresses through the
+// proxy, for use as the Dial option of a drpcconn.Conn.
+func NewDialFunc(proxy string, opts Options) drpcconn.DialFunc {
+	return func(ctx context.Context, addr string) (drpc.Transport, error) {
+		return DialWithOptions(ctx, proxy, addr, opts)
+	}
+}
+
+// aLongTimeAgo is a deadline in the past used to interrupt blocked reads and
+// writes on a conn.
+var aLongTimeAgo = time.Unix(1, 0)
+
+// connect sends the CONNECT request for addr on the conn to the proxy and
+// reads the response, interrupting both if the context is done first.
+func connect(ctx context.Context, conn net.Conn, proxy, addr string, opts Options) (net.Conn, error) {
+	stop, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			_ = conn.SetDeadline(aLongTimeAgo)
+		case <-stop:
+		}
+	}()
+
+	tunnel, err := roundTrip(conn, proxy, addr, opts)
+
+	close(stop)
+	<-stopped
+
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, Error.Wrap(ctxErr)
+	} else if err != nil {
+		return nil, err
+	}
+	return tunnel, Error.Wrap(conn.SetDeadline(time.Time{}))
+}
+
+// roundTrip does the work of connect.
+func roundTrip(conn net.Conn, proxy, addr string, opts Options) (net.Conn, error) {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	for key, values := range opts.Header {
+		req.Header[key] = append([]string(nil), values...)
+	}
+	if opts.Username != "" {
+		auth := &http.Request{Header: make(http.Header)}
+		auth.SetBasicAuth(opts.Username, opts.Password)
+		req.Header.Set("Proxy-Authorization", auth.Header.Get("Authorization"))
+	}
+
+	if err := req.Write(conn); err != nil {
+		return nil, Error.Wrap(err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, Error.Wrap(err)
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, Error.New("proxy %s refused CONNECT to %s: %s", proxy, addr, resp.Status)
+	}
+
+	// the proxy may have relayed data from addr along with the response.
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, br: br}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn is a net.Conn that reads data buffered while reading the
+// CONNECT response before reading from the conn.
+type bufferedConn struct {
+	net.Conn
+	br *bufio.Reader
+}
+
+// Read reads from the buffer until it is empty and then from the conn.
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	if c.br != nil {
+		if c.br.Buffered() > 0 {
+			return c.br.Read(p)
+		}
+		c.br = nil
+	}
+	return c.Conn.Read(p)
+}
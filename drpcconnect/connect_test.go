@@ -0,0 +1,129 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcconnect
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+
+	"storj.io/drpc"
+	"storj.io/drpc/drpcconn"
+	"storj.io/drpc/drpcserver"
+	"storj.io/drpc/drpctest"
+)
+
+type handlerFunc func(stream drpc.Stream, rpc string) error
+
+func (fn handlerFunc) HandleRPC(stream drpc.Stream, rpc string) error { return fn(stream, rpc) }
+
+// stubProxy serves CONNECT requests that have the basic authentication header
+// by relaying bytes to the requested address.
+func stubProxy(t *testing.T, ctx *drpctest.Tracker, lis net.Listener, auth string) {
+	for {
+		conn, err := lis.Accept()
+		if err != nil {
+			return
+		}
+		ctx.Run(func(ctx context.Context) {
+			defer func() { _ = conn.Close() }()
+
+			br := bufio.NewReader(conn)
+			req, err := http.ReadRequest(br)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, req.Method, http.MethodConnect)
+
+			if req.Header.Get("Proxy-Authorization") != auth {
+				_, _ = io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\nContent-Length: 0\r\n\r\n")
+				return
+			}
+
+			target, err := net.Dial("tcp", req.Host)
+			if err != nil {
+				_, _ = io.WriteString(conn, "HTTP/1.1 502 Bad Gateway\r\nContent-Length: 0\r\n\r\n")
+				return
+			}
+			defer func() { _ = target.Close() }()
+
+			_, _ = io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+			go func() { _, _ = io.Copy(target, br); _ = target.Close() }()
+			_, _ = io.Copy(conn, target)
+		})
+	}
+}
+
+func TestDial(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	// the server the tunnel is opened to.
+	slis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	srv := drpcserver.New(handlerFunc(func(stream drpc.Stream, rpc string) error {
+		var in []byte
+		if err := stream.MsgRecv(&in, drpctest.ByteEncoding{}); err != nil {
+			return err
+		}
+		return stream.MsgSend(&in, drpctest.ByteEncoding{})
+	}))
+	ctx.Run(func(ctx context.Context) { _ = srv.Serve(ctx, slis) })
+
+	plis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer func() { _ = plis.Close() }()
+	ctx.Run(func(context.Context) { stubProxy(t, ctx, plis, "Basic dXNlcjpwYXNz") })
+
+	{ // rpcs can be issued through the tunnel
+		conn, err := drpcconn.DialWithOptions(ctx, slis.Addr().String(), drpcconn.Options{
+			Dial: NewDialFunc(plis.Addr().String(), Options{Username: "user", Password: "pass"}),
+		})
+		assert.NoError(t, err)
+
+		in, out := []byte("data"), []byte(nil)
+		assert.NoError(t, conn.Invoke(ctx, "rpc", drpctest.ByteEncoding{}, &in, &out))
+		assert.Equal(t, string(out), "data")
+		assert.NoError(t, conn.Close())
+	}
+
+	{ // a proxy that refuses the request returns its status
+		_, err := DialWithOptions(ctx, plis.Addr().String(), slis.Addr().String(), Options{Username: "user"})
+		assert.Error(t, err)
+		assert.That(t, Error.Has(err))
+		assert.That(t, strings.Contains(err.Error(), "407 Proxy Authentication Required"))
+	}
+}
+
+func TestDial_Context(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	// a proxy that never responds.
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer func() { _ = lis.Close() }()
+	ctx.Run(func(ctx context.Context) {
+		conn, err := lis.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		<-ctx.Done()
+	})
+
+	tctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+
+	_, err = Dial(tctx, lis.Addr().String(), "example.com:443")
+	assert.That(t, errors.Is(err, context.DeadlineExceeded))
+}
@@ -0,0 +1,7 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+// Package drpcconnect provides transports tunneled through HTTP proxies with
+// the CONNECT method, for networks that only allow connections out through
+// such a proxy.
+package drpcconnect
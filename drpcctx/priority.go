@@ -0,0 +1,25 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcctx
+
+import "context"
+
+// PriorityKey is used to store the priority of a stream with the context.
+type PriorityKey struct{}
+
+// WithPriority associates the priority with the context. When streams started
+// with a context are waiting for a transport in use by another stream, those of
+// higher priority are preferred. It is only a hint: waiting streams of lower
+// priority are let through after a bounded number of others have gone ahead.
+// The default priority is zero.
+func WithPriority(ctx context.Context, priority int) context.Context {
+	return context.WithValue(ctx, PriorityKey{}, priority)
+}
+
+// Priority returns the priority associated with the context, or zero if there
+// is none.
+func Priority(ctx context.Context) int {
+	priority, _ := ctx.Value(PriorityKey{}).(int)
+	return priority
+}
@@ -0,0 +1,10 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+// Package drpcdynamic issues rpcs with protobuf messages that have no compiled
+// Go types, using their descriptors and dynamicpb.
+//
+// This allows generic tools, like proxies, inspectors and command line
+// clients, to invoke any method given only a file descriptor set describing
+// it, such as one written by protoc with --descriptor_set_out.
+package drpcdynamic
@@ -0,0 +1,134 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcdynamic
+
+import (
+	"context"
+	"strings"
+
+	"github.com/zeebo/errs"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"storj.io/drpc"
+)
+
+// Error is the class of errors returned by this package.
+var Error = errs.Class("drpcdynamic")
+
+// Encoding is a drpc.Encoding of protobuf messages in the wire format. Unlike
+// the encodings of generated code, it works with any proto.Message, including
+// a *dynamicpb.Message. A message can only be unmarshaled into a message of the
+// expected type, like those returned by NewInput and NewOutput.
+type Encoding struct{}
+
+// Marshal returns the wire format of the message.
+func (Encoding) Marshal(msg drpc.Message) ([]byte, error) {
+	pm, err := protoMessage(msg)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(pm)
+}
+
+// MarshalAppend appends the wire format of the message to buf.
+func (Encoding) MarshalAppend(buf []byte, msg drpc.Message) ([]byte, error) {
+	pm, err := protoMessage(msg)
+	if err != nil {
+		return nil, err
+	}
+	return proto.MarshalOptions{}.MarshalAppend(buf, pm)
+}
+
+// Unmarshal reads the wire format in buf into the message.
+func (Encoding) Unmarshal(buf []byte, msg drpc.Message) error {
+	pm, err := protoMessage(msg)
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(buf, pm)
+}
+
+// protoMessage returns the message as a proto.Message.
+func protoMessage(msg drpc.Message) (proto.Message, error) {
+	pm, ok := msg.(proto.Message)
+	if !ok {
+		return nil, Error.New("not a protobuf message: %T", msg)
+	}
+	return pm, nil
+}
+
+// ParseFiles returns the files in the wire format of a
+// google.protobuf.FileDescriptorSet. Every dependency of a file must be in the
+// set.
+func ParseFiles(data []byte) (*protoregistry.Files, error) {
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(data, &set); err != nil {
+		return nil, Error.Wrap(err)
+	}
+	files, err := protodesc.NewFiles(&set)
+	return files, Error.Wrap(err)
+}
+
+// RPC returns the rpc string that invokes the method, the same as the one
+// used by generated code.
+func RPC(md protoreflect.MethodDescriptor) string {
+	return "/" + string(md.Parent().FullName()) + "/" + string(md.Name())
+}
+
+// FindMethod returns the descriptor of the method invoked by the rpc string
+// from the files.
+func FindMethod(files *protoregistry.Files, rpc string) (protoreflect.MethodDescriptor, error) {
+	service, method, ok := strings.Cut(strings.TrimPrefix(rpc, "/"), "/")
+	if !ok {
+		return nil, Error.New("invalid rpc: %q", rpc)
+	}
+	desc, err := files.FindDescriptorByName(protoreflect.FullName(service))
+	if err != nil {
+		return nil, Error.New("unknown service: %q", service)
+	}
+	sd, ok := desc.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, Error.New("not a service: %q", service)
+	}
+	md := sd.Methods().ByName(protoreflect.Name(method))
+	if md == nil {
+		return nil, Error.New("unknown method: %q", rpc)
+	}
+	return md, nil
+}
+
+// NewInput returns an empty message of the input type of the method.
+func NewInput(md protoreflect.MethodDescriptor) *dynamicpb.Message {
+	return dynamicpb.NewMessage(md.Input())
+}
+
+// NewOutput returns an empty message of the output type of the method.
+func NewOutput(md protoreflect.MethodDescriptor) *dynamicpb.Message {
+	return dynamicpb.NewMessage(md.Output())
+}
+
+// Invoke issues the unitary rpc of the method on the conn with the input
+// message and returns the output message.
+func Invoke(ctx context.Context, cc drpc.Conn, md protoreflect.MethodDescriptor, in proto.Message) (*dynamicpb.Message, error) {
+	if md.IsStreamingClient() || md.IsStreamingServer() {
+		return nil, Error.New("not a unitary method: %q", RPC(md))
+	}
+	out := NewOutput(md)
+	if err := cc.Invoke(ctx, RPC(md), Encoding{}, in, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// NewStream starts a stream for the rpc of the method on the conn. Messages
+// sent and received on it should use Encoding, and received messages should be
+// created with NewOutput.
+func NewStream(ctx context.Context, cc drpc.Conn, md protoreflect.MethodDescriptor) (drpc.Stream, error) {
+	return cc.NewStream(ctx, RPC(md), Encoding{})
+}
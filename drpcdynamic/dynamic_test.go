@@ -0,0 +1,170 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcdynamic
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/zeebo/assert"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	"storj.io/drpc"
+	"storj.io/drpc/drpcpipe"
+)
+
+// testFiles returns the files of a serialized file descriptor set for an echo
+// service, as if read from a file written by protoc.
+func testFiles(t *testing.T) *protoregistry.Files {
+	field := func(name string, num int32, typ descriptorpb.FieldDescriptorProto_Type) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(name),
+			Number:   proto.Int32(num),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:     typ.Enum(),
+		}
+	}
+
+	data, err := proto.Marshal(&descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{{
+			Name:    proto.String("echo.proto"),
+			Package: proto.String("echo.v1"),
+			Syntax:  proto.String("proto3"),
+			MessageType: []*descriptorpb.DescriptorProto{{
+				Name: proto.String("EchoRequest"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("text", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+					field("count", 2, descriptorpb.FieldDescriptorProto_TYPE_INT64),
+				},
+			}, {
+				Name: proto.String("EchoResponse"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("text", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+				},
+			}},
+			Service: []*descriptorpb.ServiceDescriptorProto{{
+				Name: proto.String("Echo"),
+				Method: []*descriptorpb.MethodDescriptorProto{{
+					Name:       proto.String("Echo"),
+					InputType:  proto.String(".echo.v1.EchoRequest"),
+					OutputType: proto.String(".echo.v1.EchoResponse"),
+				}, {
+					Name:            proto.String("Repeat"),
+					InputType:       proto.String(".echo.v1.EchoRequest"),
+					OutputType:      proto.String(".echo.v1.EchoResponse"),
+					ServerStreaming: proto.Bool(true),
+				}},
+			}},
+		}},
+	})
+	assert.NoError(t, err)
+
+	files, err := ParseFiles(data)
+	assert.NoError(t, err)
+	return files
+}
+
+// echoHandler serves the echo service with dynamic messages as well.
+type echoHandler struct{ files *protoregistry.Files }
+
+func (h echoHandler) HandleRPC(stream drpc.Stream, rpc string) error {
+	md, err := FindMethod(h.files, rpc)
+	if err != nil {
+		return err
+	}
+
+	in := NewInput(md)
+	if err := stream.MsgRecv(in, Encoding{}); err != nil {
+		return err
+	}
+	text := in.Get(md.Input().Fields().ByName("text")).String()
+	count := int(in.Get(md.Input().Fields().ByName("count")).Int())
+
+	send := func(text string) error {
+		out := NewOutput(md)
+		out.Set(md.Output().Fields().ByName("text"), protoreflect.ValueOfString(text))
+		return stream.MsgSend(out, Encoding{})
+	}
+
+	if !md.IsStreamingServer() {
+		return send(strings.Repeat(text, count))
+	}
+	for i := 0; i < count; i++ {
+		if err := send(text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestDynamic(t *testing.T) {
+	ctx := context.Background()
+	files := testFiles(t)
+
+	conn, cleanup := drpcpipe.New(echoHandler{files: files})
+	defer cleanup()
+
+	newRequest := func(md protoreflect.MethodDescriptor, text string, count int64) proto.Message {
+		in := NewInput(md)
+		in.Set(md.Input().Fields().ByName("text"), protoreflect.ValueOfString(text))
+		in.Set(md.Input().Fields().ByName("count"), protoreflect.ValueOfInt64(count))
+		return in
+	}
+
+	t.Run("Invoke", func(t *testing.T) {
+		md, err := FindMethod(files, "/echo.v1.Echo/Echo")
+		assert.NoError(t, err)
+		assert.Equal(t, RPC(md), "/echo.v1.Echo/Echo")
+
+		out, err := Invoke(ctx, conn, md, newRequest(md, "ab", 3))
+		assert.NoError(t, err)
+		assert.Equal(t, out.Get(md.Output().Fields().ByName("text")).String(), "ababab")
+	})
+
+	t.Run("Stream", func(t *testing.T) {
+		md, err := FindMethod(files, "/echo.v1.Echo/Repeat")
+		assert.NoError(t, err)
+
+		_, err = Invoke(ctx, conn, md, newRequest(md, "ab", 3))
+		assert.Error(t, err)
+
+		stream, err := NewStream(ctx, conn, md)
+		assert.NoError(t, err)
+		defer func() { _ = stream.Close() }()
+
+		assert.NoError(t, stream.MsgSend(newRequest(md, "ab", 3), Encoding{}))
+		assert.NoError(t, stream.CloseSend())
+
+		var got []string
+		for {
+			out := NewOutput(md)
+			if err := stream.MsgRecv(out, Encoding{}); errors.Is(err, io.EOF) {
+				break
+			} else {
+				assert.NoError(t, err)
+			}
+			got = append(got, out.Get(md.Output().Fields().ByName("text")).String())
+		}
+		assert.DeepEqual(t, got, []string{"ab", "ab", "ab"})
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		_, err := FindMethod(files, "/echo.v1.Echo/Missing")
+		assert.Error(t, err)
+		_, err = FindMethod(files, "/echo.v1.Missing/Echo")
+		assert.Error(t, err)
+		_, err = FindMethod(files, "invalid")
+		assert.Error(t, err)
+
+		_, err = Encoding{}.Marshal(new(int))
+		assert.Error(t, err)
+	})
+}
@@ -1,5 +1,6 @@
 // Copyright (C) 2021 Storj Labs, Inc.
 // See LICENSE for copying information.
 
-// Package drpcenc holds some helper functions for encoding messages.
+// Package drpcenc holds some helper functions for encoding messages, and for
+// negotiating the encoding of an rpc with the remote.
 package drpcenc
@@ -0,0 +1,37 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcenc
+
+import "storj.io/drpc"
+
+// Baseline is the name of the encoding passed to Invoke, NewStream, MsgSend
+// and MsgRecv, usually the protobuf encoding of the generated code, when the
+// encoding of an rpc is negotiated. Both sides always support it.
+const Baseline = "proto"
+
+// Named is an encoding with a name that identifies it to the remote when the
+// encoding of an rpc is negotiated. See drpcmetadata.EncodingKey.
+type Named interface {
+	drpc.Encoding
+
+	// Name returns the name of the encoding. It must not be Baseline.
+	Name() string
+}
+
+// Select returns the first of the names that is Baseline or the name of one of
+// the encodings, along with the encoding, which is nil for Baseline. It
+// returns false if there is none.
+func Select(names []string, encs []Named) (name string, enc Named, ok bool) {
+	for _, name := range names {
+		if name == Baseline {
+			return name, nil, true
+		}
+		for _, enc := range encs {
+			if enc.Name() == name {
+				return name, enc, true
+			}
+		}
+	}
+	return "", nil, false
+}
@@ -0,0 +1,87 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcerr
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/zeebo/errs"
+)
+
+var classes struct {
+	mu     sync.RWMutex
+	byName map[string]*errs.Class
+}
+
+// RegisterClass allows errors in the class to keep it when they are sent by a
+// server, so that the client can use the class's Has method on them. Errors
+// in classes that are not registered are only sent as their message, which
+// keeps classes that could reveal internal details private. Clients must
+// register the class too. It panics if the class has an empty name.
+func RegisterClass(class *errs.Class) {
+	if class == nil || *class == "" {
+		panic("drpcerr: register of class with an empty name")
+	}
+
+	classes.mu.Lock()
+	defer classes.mu.Unlock()
+
+	if classes.byName == nil {
+		classes.byName = make(map[string]*errs.Class)
+	}
+	classes.byName[string(*class)] = class
+}
+
+// lookupClass returns the registered class with the name.
+func lookupClass(name string) (*errs.Class, bool) {
+	classes.mu.RLock()
+	defer classes.mu.RUnlock()
+
+	class, ok := classes.byName[name]
+	return class, ok
+}
+
+// Classes returns the names of the registered classes that have wrapped the
+// error, outermost first.
+func Classes(err error) (names []string) {
+	for _, class := range errs.Classes(err) {
+		if registered, ok := lookupClass(string(*class)); ok && registered == class {
+			names = append(names, string(*class))
+		}
+	}
+	return names
+}
+
+// WithClasses returns an error with the message and code of err wrapped by the
+// registered classes with the names, outermost first. It is meant for errors
+// received from a remote, where the message starts with the name of each class
+// as it does for errors in the class, and that prefix is removed so that it is
+// not repeated. Names after one that is not registered or is not a prefix are
+// ignored.
+func WithClasses(err error, names []string) error {
+	if err == nil || len(names) == 0 {
+		return err
+	}
+
+	msg := err.Error()
+	var wrap []*errs.Class
+	for _, name := range names {
+		class, ok := lookupClass(name)
+		if !ok || !strings.HasPrefix(msg, name+": ") {
+			break
+		}
+		msg = msg[len(name)+2:]
+		wrap = append(wrap, class)
+	}
+	if len(wrap) == 0 {
+		return err
+	}
+
+	out := WithCode(errs.New("%s", msg), Code(err))
+	for i := len(wrap) - 1; i >= 0; i-- {
+		out = wrap[i].Wrap(out)
+	}
+	return out
+}
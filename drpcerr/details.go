@@ -0,0 +1,49 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcerr
+
+// WithDetails returns an error with the message, code and classes of err that
+// also carries the encoded details, so that they are sent to the remote along
+// with it. The details are opaque bytes: see drpcstatus for typed messages.
+// It returns err if it is nil or there are no details.
+func WithDetails(err error, details [][]byte) error {
+	if err == nil || len(details) == 0 {
+		return err
+	}
+	return &detailsErr{err: err, details: details}
+}
+
+// Details returns the encoded details associated with the error, or nil if
+// there are none. They come from the first error in the chain with a
+// Details() [][]byte method, like the errors returned by WithDetails.
+func Details(err error) [][]byte {
+	for i := 0; i < 100; i++ {
+		prev := err
+		switch v := err.(type) { //nolint: errorlint // this is a custom unwrap loop
+		case interface{ Details() [][]byte }:
+			return v.Details()
+		case interface{ Cause() error }:
+			err = v.Cause()
+		case interface{ Unwrap() error }:
+			err = v.Unwrap()
+		default:
+			return nil
+		}
+		// short-circuit any trivial cycles
+		if shallowEqual(err, prev) {
+			return nil
+		}
+	}
+	return nil
+}
+
+type detailsErr struct {
+	err     error
+	details [][]byte
+}
+
+func (d *detailsErr) Error() string     { return d.err.Error() }
+func (d *detailsErr) Unwrap() error     { return d.err }
+func (d *detailsErr) Cause() error      { return d.err }
+func (d *detailsErr) Details() [][]byte { return d.details }
@@ -2,4 +2,8 @@
 // See LICENSE for copying information.
 
 // Package drpcerr lets one associate error codes with errors.
+//
+// It also lets errs classes be registered so that errors in them keep the
+// class when they are sent by a server and received by a client, and lets
+// encoded details be attached to errors to be sent along with them.
 package drpcerr
@@ -45,3 +45,33 @@ func (u uncomparable) Error() string { return "uncomparable" }
 func (u uncomparable) Unwrap() error { return u }
 
 type opaque struct{ error }
+
+func TestClasses(t *testing.T) {
+	outer, inner := errs.Class("outer"), errs.Class("inner")
+	private := errs.Class("private")
+	RegisterClass(&outer)
+	RegisterClass(&inner)
+
+	err := WithCode(outer.Wrap(private.Wrap(inner.New("boom"))), 5)
+
+	// only registered classes are reported
+	assert.DeepEqual(t, Classes(err), []string{"outer", "inner"})
+	assert.Nil(t, Classes(errors.New("boom")))
+
+	// the classes are restored as long as they prefix the message
+	got := WithClasses(errs.New("outer: inner: boom"), []string{"outer", "inner"})
+	assert.That(t, outer.Has(got))
+	assert.That(t, inner.Has(got))
+	assert.Equal(t, got.Error(), "outer: inner: boom")
+
+	// and the code is kept
+	got = WithClasses(WithCode(errs.New("outer: private: inner: boom"), 5), Classes(err))
+	assert.That(t, outer.Has(got))
+	assert.That(t, !inner.Has(got))
+	assert.Equal(t, got.Error(), "outer: private: inner: boom")
+	assert.Equal(t, Code(got), 5)
+
+	// unregistered classes are not restored
+	got = WithClasses(errs.New("private: boom"), []string{"private"})
+	assert.That(t, !private.Has(got))
+}
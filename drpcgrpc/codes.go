@@ -0,0 +1,47 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcgrpc
+
+import (
+	"math"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"storj.io/drpc/drpcstatus"
+)
+
+// ToGRPC returns the gRPC code for the drpcstatus code. Codes too large for a
+// gRPC code are Unknown.
+func ToGRPC(code drpcstatus.Code) codes.Code {
+	if code > math.MaxUint32 {
+		return codes.Unknown
+	}
+	return codes.Code(code)
+}
+
+// FromGRPC returns the drpcstatus code for the gRPC code.
+func FromGRPC(code codes.Code) drpcstatus.Code {
+	return drpcstatus.Code(code)
+}
+
+// ToGRPCError returns a gRPC status error with the code and message of the
+// error, as returned by drpcstatus.CodeFromError. It returns nil if err is nil.
+func ToGRPCError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return status.Error(ToGRPC(drpcstatus.CodeFromError(err)), err.Error())
+}
+
+// FromGRPCError returns a drpcstatus error with the code and message of the
+// gRPC status error. Errors that are not gRPC status errors have the Unknown
+// code. It returns nil if err is nil.
+func FromGRPCError(err error) error {
+	if err == nil {
+		return nil
+	}
+	st := status.Convert(err)
+	return drpcstatus.Errorf(FromGRPC(st.Code()), "%s", st.Message())
+}
@@ -0,0 +1,71 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcgrpc
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/zeebo/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"storj.io/drpc/drpcstatus"
+)
+
+func TestCodes(t *testing.T) {
+	for _, tc := range []struct {
+		drpc drpcstatus.Code
+		grpc codes.Code
+	}{
+		{drpcstatus.OK, codes.OK},
+		{drpcstatus.Canceled, codes.Canceled},
+		{drpcstatus.Unknown, codes.Unknown},
+		{drpcstatus.InvalidArgument, codes.InvalidArgument},
+		{drpcstatus.DeadlineExceeded, codes.DeadlineExceeded},
+		{drpcstatus.NotFound, codes.NotFound},
+		{drpcstatus.AlreadyExists, codes.AlreadyExists},
+		{drpcstatus.PermissionDenied, codes.PermissionDenied},
+		{drpcstatus.ResourceExhausted, codes.ResourceExhausted},
+		{drpcstatus.FailedPrecondition, codes.FailedPrecondition},
+		{drpcstatus.Aborted, codes.Aborted},
+		{drpcstatus.OutOfRange, codes.OutOfRange},
+		{drpcstatus.Unimplemented, codes.Unimplemented},
+		{drpcstatus.Internal, codes.Internal},
+		{drpcstatus.Unavailable, codes.Unavailable},
+		{drpcstatus.DataLoss, codes.DataLoss},
+		{drpcstatus.Unauthenticated, codes.Unauthenticated},
+		{drpcstatus.Code(100), codes.Code(100)},
+	} {
+		assert.Equal(t, ToGRPC(tc.drpc), tc.grpc)
+		assert.Equal(t, FromGRPC(tc.grpc), tc.drpc)
+		assert.Equal(t, FromGRPC(ToGRPC(tc.drpc)), tc.drpc)
+		assert.Equal(t, ToGRPC(FromGRPC(tc.grpc)), tc.grpc)
+		if tc.drpc <= drpcstatus.Unauthenticated {
+			assert.Equal(t, tc.drpc.String(), tc.grpc.String())
+		}
+	}
+
+	assert.Equal(t, ToGRPC(drpcstatus.Code(math.MaxUint32+1)), codes.Unknown)
+}
+
+func TestErrors(t *testing.T) {
+	assert.NoError(t, ToGRPCError(nil))
+	assert.NoError(t, FromGRPCError(nil))
+
+	err := ToGRPCError(drpcstatus.Errorf(drpcstatus.NotFound, "no such key"))
+	assert.Equal(t, status.Code(err), codes.NotFound)
+	assert.Equal(t, status.Convert(err).Message(), "no such key")
+
+	assert.Equal(t, status.Code(ToGRPCError(context.DeadlineExceeded)), codes.DeadlineExceeded)
+	assert.Equal(t, status.Code(ToGRPCError(errors.New("oops"))), codes.Unknown)
+
+	err = FromGRPCError(status.Error(codes.PermissionDenied, "go away"))
+	assert.Equal(t, drpcstatus.CodeFromError(err), drpcstatus.PermissionDenied)
+	assert.Equal(t, err.Error(), "go away")
+
+	assert.Equal(t, drpcstatus.CodeFromError(FromGRPCError(errors.New("oops"))), drpcstatus.Unknown)
+}
@@ -0,0 +1,10 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+// Package drpcgrpc translates between drpcstatus codes and gRPC codes.
+//
+// It is a separate module so that drpc itself does not depend on gRPC. The
+// codes have the same values, so every gRPC code, including ones that neither
+// defines a name for, maps to drpc and back without loss. It is meant for
+// gateways that bridge between drpc and gRPC.
+package drpcgrpc
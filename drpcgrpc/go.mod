@@ -0,0 +1,18 @@
+module storj.io/drpc/drpcgrpc
+
+go 1.19
+
+require (
+	github.com/zeebo/assert v1.3.0
+	google.golang.org/grpc v1.50.0
+	storj.io/drpc v0.0.0-00010101000000-000000000000
+)
+
+require (
+	github.com/golang/protobuf v1.5.2 // indirect
+	github.com/zeebo/errs v1.2.2 // indirect
+	google.golang.org/genproto v0.0.0-20200526211855-cb27e3aa2013 // indirect
+	google.golang.org/protobuf v1.27.1 // indirect
+)
+
+replace storj.io/drpc => ..
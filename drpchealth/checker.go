@@ -0,0 +1,126 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpchealth
+
+import (
+	"strconv"
+	"sync"
+
+	"storj.io/drpc/drpcstatus"
+)
+
+// Status is the serving status of a service.
+type Status int32
+
+// These are the serving statuses. The values mirror the ones used by gRPC.
+const (
+	Unknown        Status = 0
+	Serving        Status = 1
+	NotServing     Status = 2
+	ServiceUnknown Status = 3 // only sent by Watch
+)
+
+// String returns a human readable form of the status.
+func (s Status) String() string {
+	switch s {
+	case Unknown:
+		return "UNKNOWN"
+	case Serving:
+		return "SERVING"
+	case NotServing:
+		return "NOT_SERVING"
+	case ServiceUnknown:
+		return "SERVICE_UNKNOWN"
+	default:
+		return "Status(" + strconv.FormatInt(int64(s), 10) + ")"
+	}
+}
+
+// Checker keeps track of the serving status of services. The empty service
+// name refers to the health of the server as a whole, and it starts out as
+// Serving. It is safe for concurrent use.
+type Checker struct {
+	mu       sync.Mutex
+	statuses map[string]Status
+	watchers map[string]map[chan Status]struct{}
+}
+
+// New returns a new Checker.
+func New() *Checker {
+	return &Checker{
+		statuses: map[string]Status{"": Serving},
+		watchers: make(map[string]map[chan Status]struct{}),
+	}
+}
+
+// SetStatus sets the serving status of the service and notifies any watchers
+// of the service if it changed.
+func (c *Checker) SetStatus(service string, status Status) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if old, ok := c.statuses[service]; ok && old == status {
+		return
+	}
+	c.statuses[service] = status
+
+	for ch := range c.watchers[service] {
+		notify(ch, status)
+	}
+}
+
+// Check returns the serving status of the service. It returns an error with
+// the drpcstatus.NotFound code if the service is unknown.
+func (c *Checker) Check(service string) (Status, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	status, ok := c.statuses[service]
+	if !ok {
+		return ServiceUnknown, drpcstatus.Errorf(drpcstatus.NotFound, "unknown service: %q", service)
+	}
+	return status, nil
+}
+
+// watch returns a channel that will receive the latest status of the service,
+// starting with the current status.
+func (c *Checker) watch(service string) chan Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ch := make(chan Status, 1)
+	if status, ok := c.statuses[service]; ok {
+		ch <- status
+	} else {
+		ch <- ServiceUnknown
+	}
+
+	if c.watchers[service] == nil {
+		c.watchers[service] = make(map[chan Status]struct{})
+	}
+	c.watchers[service][ch] = struct{}{}
+
+	return ch
+}
+
+// unwatch stops the channel from receiving statuses for the service.
+func (c *Checker) unwatch(service string, ch chan Status) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.watchers[service], ch)
+	if len(c.watchers[service]) == 0 {
+		delete(c.watchers, service)
+	}
+}
+
+// notify replaces any status that the watcher has not yet received with the
+// new status so that slow watchers only observe the latest one.
+func notify(ch chan Status, status Status) {
+	select {
+	case <-ch:
+	default:
+	}
+	ch <- status
+}
@@ -0,0 +1,9 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+// Package drpchealth provides a health checking service for drpc servers.
+//
+// The service follows the semantics of the gRPC health checking protocol,
+// grpc.health.v1.Health, and uses the same rpc names and message encoding so
+// that existing probes can be pointed at it.
+package drpchealth
@@ -0,0 +1,219 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpchealth
+
+import (
+	"context"
+
+	"github.com/zeebo/errs"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"storj.io/drpc"
+)
+
+// These are the rpc names used by the health service.
+const (
+	CheckRPC = "/grpc.health.v1.Health/Check"
+	WatchRPC = "/grpc.health.v1.Health/Watch"
+)
+
+// CheckRequest is the message sent to check the health of a service.
+type CheckRequest struct {
+	Service string
+}
+
+// CheckResponse is the message sent in response to a health check.
+type CheckResponse struct {
+	Status Status
+}
+
+// encoding marshals the health messages in the protobuf wire format used by
+// the gRPC health checking protocol.
+type encoding struct{}
+
+func (encoding) Marshal(msg drpc.Message) ([]byte, error) {
+	switch msg := msg.(type) {
+	case *CheckRequest:
+		var buf []byte
+		if msg.Service != "" {
+			buf = protowire.AppendTag(buf, 1, protowire.BytesType)
+			buf = protowire.AppendString(buf, msg.Service)
+		}
+		return buf, nil
+	case *CheckResponse:
+		var buf []byte
+		if msg.Status != 0 {
+			buf = protowire.AppendTag(buf, 1, protowire.VarintType)
+			buf = protowire.AppendVarint(buf, uint64(msg.Status))
+		}
+		return buf, nil
+	default:
+		return nil, errs.New("unknown health message: %T", msg)
+	}
+}
+
+func (encoding) Unmarshal(buf []byte, msg drpc.Message) error {
+	switch msg := msg.(type) {
+	case *CheckRequest:
+		*msg = CheckRequest{}
+		return unmarshalFields(buf, func(num protowire.Number, typ protowire.Type, buf []byte) (int, error) {
+			if num != 1 || typ != protowire.BytesType {
+				return protowire.ConsumeFieldValue(num, typ, buf), nil
+			}
+			service, n := protowire.ConsumeString(buf)
+			msg.Service = service
+			return n, nil
+		})
+	case *CheckResponse:
+		*msg = CheckResponse{}
+		return unmarshalFields(buf, func(num protowire.Number, typ protowire.Type, buf []byte) (int, error) {
+			if num != 1 || typ != protowire.VarintType {
+				return protowire.ConsumeFieldValue(num, typ, buf), nil
+			}
+			status, n := protowire.ConsumeVarint(buf)
+			msg.Status = Status(status)
+			return n, nil
+		})
+	default:
+		return errs.New("unknown health message: %T", msg)
+	}
+}
+
+// unmarshalFields calls field with the value of every field in buf.
+func unmarshalFields(buf []byte, field func(num protowire.Number, typ protowire.Type, buf []byte) (int, error)) error {
+	for len(buf) > 0 {
+		num, typ, n := protowire.ConsumeTag(buf)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		buf = buf[n:]
+
+		n, err := field(num, typ, buf)
+		if err != nil {
+			return err
+		} else if n < 0 {
+			return protowire.ParseError(n)
+		}
+		buf = buf[n:]
+	}
+	return nil
+}
+
+//
+// server
+//
+
+// Register registers the health service backed by the Checker with the mux.
+func Register(mux drpc.Mux, c *Checker) error {
+	return mux.Register(server{c: c}, description{})
+}
+
+// server implements the rpcs of the health service.
+type server struct{ c *Checker }
+
+func (s server) Check(ctx context.Context, req *CheckRequest) (*CheckResponse, error) {
+	status, err := s.c.Check(req.Service)
+	if err != nil {
+		return nil, err
+	}
+	return &CheckResponse{Status: status}, nil
+}
+
+func (s server) Watch(req *CheckRequest, stream drpc.Stream) error {
+	ch := s.c.watch(req.Service)
+	defer s.c.unwatch(req.Service, ch)
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case status := <-ch:
+			if err := stream.MsgSend(&CheckResponse{Status: status}, encoding{}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// description describes the health service to a mux.
+type description struct{}
+
+func (description) NumMethods() int { return 2 }
+
+func (description) Method(n int) (string, drpc.Encoding, drpc.Receiver, interface{}, bool) {
+	switch n {
+	case 0:
+		return CheckRPC, encoding{},
+			func(srv interface{}, ctx context.Context, in1, in2 interface{}) (drpc.Message, error) {
+				return srv.(server).Check(ctx, in1.(*CheckRequest))
+			}, server.Check, true
+	case 1:
+		return WatchRPC, encoding{},
+			func(srv interface{}, ctx context.Context, in1, in2 interface{}) (drpc.Message, error) {
+				return nil, srv.(server).Watch(in1.(*CheckRequest), in2.(drpc.Stream))
+			}, server.Watch, true
+	default:
+		return "", nil, nil, nil, false
+	}
+}
+
+//
+// client
+//
+
+// Client issues health checks over a drpc.Conn.
+type Client struct {
+	cc drpc.Conn
+}
+
+// NewClient returns a Client that issues health checks over the conn.
+func NewClient(cc drpc.Conn) *Client {
+	return &Client{cc: cc}
+}
+
+// Check returns the serving status of the service on the remote.
+func (c *Client) Check(ctx context.Context, service string) (Status, error) {
+	var out CheckResponse
+	if err := c.cc.Invoke(ctx, CheckRPC, encoding{}, &CheckRequest{Service: service}, &out); err != nil {
+		return Unknown, err
+	}
+	return out.Status, nil
+}
+
+// Watch returns a WatchStream that receives the serving status of the service
+// on the remote every time it changes, starting with the current status.
+func (c *Client) Watch(ctx context.Context, service string) (*WatchStream, error) {
+	stream, err := c.cc.NewStream(ctx, WatchRPC, encoding{})
+	if err != nil {
+		return nil, err
+	}
+	if err := stream.MsgSend(&CheckRequest{Service: service}, encoding{}); err != nil {
+		_ = stream.Close()
+		return nil, err
+	}
+	if err := stream.CloseSend(); err != nil {
+		_ = stream.Close()
+		return nil, err
+	}
+	return &WatchStream{stream: stream}, nil
+}
+
+// WatchStream receives serving status transitions from the remote.
+type WatchStream struct {
+	stream drpc.Stream
+}
+
+// Recv blocks until the next serving status is received.
+func (w *WatchStream) Recv() (Status, error) {
+	var out CheckResponse
+	if err := w.stream.MsgRecv(&out, encoding{}); err != nil {
+		return Unknown, err
+	}
+	return out.Status, nil
+}
+
+// Close closes the stream, causing the remote to stop sending statuses.
+func (w *WatchStream) Close() error {
+	return w.stream.Close()
+}
@@ -0,0 +1,105 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpchealth
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/zeebo/assert"
+
+	"storj.io/drpc/drpcconn"
+	"storj.io/drpc/drpcmux"
+	"storj.io/drpc/drpcserver"
+	"storj.io/drpc/drpcstatus"
+	"storj.io/drpc/drpctest"
+)
+
+func TestEncoding(t *testing.T) {
+	// the messages match the encoding of the gRPC health protocol.
+	buf, err := encoding{}.Marshal(&CheckRequest{Service: "svc"})
+	assert.NoError(t, err)
+	assert.DeepEqual(t, buf, []byte{0x0a, 0x03, 's', 'v', 'c'})
+
+	buf, err = encoding{}.Marshal(&CheckResponse{Status: NotServing})
+	assert.NoError(t, err)
+	assert.DeepEqual(t, buf, []byte{0x08, 0x02})
+
+	var req CheckRequest
+	assert.NoError(t, encoding{}.Unmarshal([]byte{0x10, 0x01, 0x0a, 0x03, 's', 'v', 'c'}, &req))
+	assert.Equal(t, req.Service, "svc")
+
+	var resp CheckResponse
+	assert.NoError(t, encoding{}.Unmarshal([]byte{0x08, 0x01}, &resp))
+	assert.Equal(t, resp.Status, Serving)
+
+	assert.Error(t, encoding{}.Unmarshal([]byte{0x08}, &resp))
+}
+
+func TestHealth(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	checker := New()
+	mux := drpcmux.New()
+	assert.NoError(t, Register(mux, checker))
+
+	pc, ps := net.Pipe()
+	defer func() { _ = pc.Close() }()
+	defer func() { _ = ps.Close() }()
+
+	srv := drpcserver.New(mux)
+	ctx.Run(func(ctx context.Context) { _ = srv.ServeOne(ctx, ps) })
+
+	conn := drpcconn.New(pc)
+	defer func() { _ = conn.Close() }()
+	cli := NewClient(conn)
+
+	{ // the server as a whole starts out serving
+		status, err := cli.Check(ctx, "")
+		assert.NoError(t, err)
+		assert.Equal(t, status, Serving)
+	}
+
+	{ // unknown services return a not found error
+		_, err := cli.Check(ctx, "svc")
+		assert.Equal(t, drpcstatus.CodeFromError(err), drpcstatus.NotFound)
+	}
+
+	{ // statuses can be changed at runtime
+		checker.SetStatus("svc", NotServing)
+		status, err := cli.Check(ctx, "svc")
+		assert.NoError(t, err)
+		assert.Equal(t, status, NotServing)
+	}
+
+	{ // watches receive the current status and then any transitions
+		stream, err := cli.Watch(ctx, "other")
+		assert.NoError(t, err)
+
+		status, err := stream.Recv()
+		assert.NoError(t, err)
+		assert.Equal(t, status, ServiceUnknown)
+
+		checker.SetStatus("other", Serving)
+		status, err = stream.Recv()
+		assert.NoError(t, err)
+		assert.Equal(t, status, Serving)
+
+		checker.SetStatus("other", Serving) // unchanged statuses are not sent
+		checker.SetStatus("other", NotServing)
+		status, err = stream.Recv()
+		assert.NoError(t, err)
+		assert.Equal(t, status, NotServing)
+
+		assert.NoError(t, stream.Close())
+	}
+
+	{ // the conn is still usable after the watch is closed
+		status, err := cli.Check(ctx, "other")
+		assert.NoError(t, err)
+		assert.Equal(t, status, NotServing)
+	}
+}
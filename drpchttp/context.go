@@ -27,6 +27,7 @@ func Context(req *http.Request) (context.Context, error) {
 // buildContext adds key/value pairs in entries that are of the form
 // `urlencode(key)=urlencode(value)` to the passed in context.
 func buildContext(ctx context.Context, entries []string) (context.Context, error) {
+	var md drpcmetadata.Metadata
 	for _, entry := range entries {
 		var key, value string
 		var err error
@@ -46,8 +47,15 @@ func buildContext(ctx context.Context, entries []string) (context.Context, error
 		}
 
 		ctx = drpcmetadata.Add(ctx, key, value)
+		if md == nil {
+			md = make(drpcmetadata.Metadata)
+		}
+		md.Append(key, value)
 	}
 
+	if md != nil {
+		ctx = drpcmetadata.WithIncomingMetadata(ctx, md)
+	}
 	return ctx, nil
 }
 
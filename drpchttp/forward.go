@@ -0,0 +1,82 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpchttp
+
+import (
+	"errors"
+	"io"
+
+	"storj.io/drpc"
+	"storj.io/drpc/drpcmetadata"
+)
+
+// Forward returns a drpc.Handler that issues every rpc it handles on the conn,
+// so that a handler returned by New can serve the rpcs of the drpc server the
+// conn is to. The messages are passed through without being decoded, so the
+// protocols that use JSON only work if the server understands it. The request
+// message is sent before any response is received, which supports the unitary
+// and server-streaming rpcs the protocols of New support.
+//
+// Metadata attached to the request is sent with the rpc, and the trailer of
+// the rpc, if the stream returned by the conn has a Trailer method like
+// drpcconn.Conn's streams do, is set on the response with
+// drpcmetadata.SetTrailer.
+func Forward(conn drpc.Conn) drpc.Handler {
+	return forwarder{conn: conn}
+}
+
+// forwarder implements drpc.Handler by issuing rpcs on a drpc.Conn.
+type forwarder struct {
+	conn drpc.Conn
+}
+
+func (f forwarder) HandleRPC(stream drpc.Stream, rpc string) (err error) {
+	out, err := f.conn.NewStream(stream.Context(), rpc, rawEncoding{})
+	if err != nil {
+		return err
+	}
+	defer func() { _ = out.Close() }()
+	defer forwardTrailer(stream, out)
+
+	var msg []byte
+	if err := stream.MsgRecv(&msg, rawEncoding{}); err != nil {
+		return err
+	} else if err := out.MsgSend(&msg, rawEncoding{}); err != nil {
+		return err
+	} else if err := out.CloseSend(); err != nil {
+		return err
+	}
+
+	for {
+		if err := out.MsgRecv(&msg, rawEncoding{}); errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		} else if err := stream.MsgSend(&msg, rawEncoding{}); err != nil {
+			return err
+		}
+	}
+}
+
+// forwardTrailer sets the trailer of the out stream, if it has one, on the
+// stream being handled.
+func forwardTrailer(stream, out drpc.Stream) {
+	if tr, ok := out.(interface{ Trailer() drpcmetadata.Metadata }); ok {
+		if md := tr.Trailer(); len(md) > 0 {
+			_ = drpcmetadata.SetTrailer(stream.Context(), md)
+		}
+	}
+}
+
+// rawEncoding is a drpc.Encoding for *[]byte that passes the bytes through.
+type rawEncoding struct{}
+
+func (rawEncoding) Marshal(msg drpc.Message) ([]byte, error) {
+	return *msg.(*[]byte), nil
+}
+
+func (rawEncoding) Unmarshal(buf []byte, msg drpc.Message) error {
+	*msg.(*[]byte) = append((*msg.(*[]byte))[:0], buf...)
+	return nil
+}
@@ -49,11 +49,17 @@ func New(handler drpc.Handler) http.Handler {
 // The content types "application/grpc-web+proto", "application/grpc-web+json",
 // "application/grpc-web-text+proto", and "application/grpc-web-text+json" will
 // serve unitary and server-streaming RPCs using the protocol described by
-// the grpc-web project. Informally, messages are framed with a 5 byte
-// header where the first byte is some flags, and the second through fourth
-// are the message length in big endian. Response codes and status messages
-// are sent as HTTP Trailers. The "-text" series of content types mean that
-// the whole request and response bodies are base64 encoded.
+// the grpc-web project, as will "application/grpc-web" and
+// "application/grpc-web-text", which mean proto. Informally, messages are
+// framed with a 5 byte header where the first byte is some flags, and the
+// second through fourth are the message length in big endian. Response codes
+// and status messages are sent in a final frame of the body with the first
+// bit of the flags set, along with any trailer the handler set with
+// drpcmetadata.SetTrailer. The "-text" series of content types mean that the
+// whole request and response bodies are base64 encoded.
+//
+// To serve the RPCs of a drpc server through a drpc.Conn to it instead of a
+// local drpc.Handler, see Forward.
 func NewWithOptions(handler drpc.Handler, os ...Option) http.Handler {
 	opts := options{protocols: defaultProtocols()}
 	for _, o := range os {
@@ -64,6 +64,14 @@ func defaultProtocols() map[string]Protocol {
 			unmarshal: JSONUnmarshal,
 		},
 
+		"application/grpc-web": grpcWebProtocol{
+			ct:        "application/grpc-web",
+			read:      grpcRead,
+			write:     normalWrite,
+			marshal:   protoMarshal,
+			unmarshal: protoUnmarshal,
+		},
+
 		"application/grpc-web+proto": grpcWebProtocol{
 			ct:        "application/grpc-web+proto",
 			read:      grpcRead,
@@ -80,6 +88,14 @@ func defaultProtocols() map[string]Protocol {
 			unmarshal: JSONUnmarshal,
 		},
 
+		"application/grpc-web-text": grpcWebProtocol{
+			ct:        "application/grpc-web-text",
+			read:      base64Read(grpcRead),
+			write:     base64Write(normalWrite),
+			marshal:   protoMarshal,
+			unmarshal: protoUnmarshal,
+		},
+
 		"application/grpc-web-text+proto": grpcWebProtocol{
 			ct:        "application/grpc-web-text+proto",
 			read:      base64Read(grpcRead),
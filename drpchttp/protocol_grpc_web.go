@@ -10,13 +10,16 @@ import (
 	"io"
 	"net/http"
 	"net/textproto"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/zeebo/errs"
 
 	"storj.io/drpc"
 	"storj.io/drpc/drpcerr"
+	"storj.io/drpc/drpcmetadata"
 )
 
 //
@@ -33,12 +36,13 @@ type grpcWebProtocol struct {
 
 func (gwp grpcWebProtocol) NewStream(rw http.ResponseWriter, req *http.Request) Stream {
 	rw.Header().Set("Content-Type", gwp.ct)
-	return &grpcWebStream{
-		ctx: req.Context(),
+	gws := &grpcWebStream{
 		gwp: gwp,
 		in:  req.Body,
 		rw:  rw,
 	}
+	gws.ctx = context.WithValue(req.Context(), drpcmetadata.StreamKey{}, gws)
+	return gws
 }
 
 func (gwp grpcWebProtocol) framedWrite(rw http.ResponseWriter, hdr byte, buf []byte) error {
@@ -56,12 +60,29 @@ type grpcWebStream struct {
 	gwp grpcWebProtocol
 	in  io.ReadCloser
 	rw  http.ResponseWriter
+
+	mu      sync.Mutex
+	trailer drpcmetadata.Metadata
 }
 
 func (gws *grpcWebStream) Context() context.Context { return gws.ctx }
 func (gws *grpcWebStream) CloseSend() error         { return nil }
 func (gws *grpcWebStream) Close() error             { return gws.in.Close() }
 
+// SetTrailer adds the metadata to the trailer sent in the body of the response
+// after the last message, so that handlers can use drpcmetadata.SetTrailer.
+func (gws *grpcWebStream) SetTrailer(md drpcmetadata.Metadata) {
+	gws.mu.Lock()
+	defer gws.mu.Unlock()
+
+	if gws.trailer == nil {
+		gws.trailer = make(drpcmetadata.Metadata, len(md))
+	}
+	for key, values := range md {
+		gws.trailer.Append(key, values...)
+	}
+}
+
 func (gws *grpcWebStream) MsgSend(msg drpc.Message, enc drpc.Encoding) (err error) {
 	data, err := gws.gwp.marshal(msg, enc)
 	if err != nil {
@@ -109,5 +130,21 @@ func (gws *grpcWebStream) Finish(err error) {
 		write("grpc-message", err.Error())
 	}
 
+	// keys that could be confused with the status are left out.
+	gws.mu.Lock()
+	keys := make([]string, 0, len(gws.trailer))
+	for key := range gws.trailer {
+		if !strings.HasPrefix(key, "grpc-") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, value := range gws.trailer[key] {
+			write(key, value)
+		}
+	}
+	gws.mu.Unlock()
+
 	_ = gws.gwp.framedWrite(gws.rw, 128, buf.Bytes())
 }
@@ -0,0 +1,123 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpchttp
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/zeebo/assert"
+
+	"storj.io/drpc"
+	"storj.io/drpc/drpcconn"
+	"storj.io/drpc/drpcerr"
+	"storj.io/drpc/drpcmetadata"
+	"storj.io/drpc/drpcserver"
+	"storj.io/drpc/drpctest"
+)
+
+func TestGRPCWeb_Forward(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	pc, ps := net.Pipe()
+	defer func() { _ = pc.Close() }()
+
+	// the server echoes every byte of the request as its own message, and
+	// fails the "fail" rpc.
+	srv := drpcserver.New(handlerFunc(func(stream drpc.Stream, rpc string) error {
+		var in []byte
+		if err := stream.MsgRecv(&in, rawEncoding{}); err != nil {
+			return err
+		}
+		if rpc == "/fail" {
+			return drpcerr.WithCode(io.ErrUnexpectedEOF, 5)
+		}
+		md, _ := drpcmetadata.Get(stream.Context())
+		if err := drpcmetadata.SetTrailer(stream.Context(), drpcmetadata.Metadata{"key": {md["key"]}}); err != nil {
+			return err
+		}
+		for i := range in {
+			out := in[i : i+1]
+			if err := stream.MsgSend(&out, rawEncoding{}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	ctx.Run(func(ctx context.Context) { _ = srv.ServeOne(ctx, ps) })
+
+	conn := drpcconn.New(pc)
+	defer func() { _ = conn.Close() }()
+
+	hsrv := httptest.NewServer(New(Forward(conn)))
+	defer hsrv.Close()
+
+	// post sends a grpc-web request for the rpc, and returns the messages and
+	// the trailer frame of the response.
+	post := func(ct, rpc string, msg string) (msgs []string, trailer string) {
+		body := append([]byte{0, 0, 0, 0, 0}, msg...)
+		binary.BigEndian.PutUint32(body[1:5], uint32(len(msg)))
+		if strings.Contains(ct, "-text") {
+			body = []byte(base64.StdEncoding.EncodeToString(body))
+		}
+
+		req, err := http.NewRequest("POST", hsrv.URL+rpc, bytes.NewReader(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", ct)
+		req.Header.Set("X-Drpc-Metadata", "key=value")
+
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		defer func() { _ = resp.Body.Close() }()
+		assert.Equal(t, resp.Header.Get("Content-Type"), ct)
+
+		var r io.Reader = resp.Body
+		if strings.Contains(ct, "-text") {
+			r = base64.NewDecoder(base64.StdEncoding, r)
+		}
+		for {
+			var hdr [5]byte
+			_, err := io.ReadFull(r, hdr[:])
+			if errors.Is(err, io.EOF) {
+				return msgs, trailer
+			}
+			assert.NoError(t, err)
+
+			data, err := readExactly(r, uint64(binary.BigEndian.Uint32(hdr[1:5])))
+			assert.NoError(t, err)
+
+			assert.Equal(t, trailer, "") // nothing comes after the trailer
+			if hdr[0]&128 != 0 {
+				trailer = string(data)
+			} else {
+				msgs = append(msgs, string(data))
+			}
+		}
+	}
+
+	for _, ct := range []string{"application/grpc-web+proto", "application/grpc-web-text"} {
+		msgs, trailer := post(ct, "/echo", "abc")
+		assert.DeepEqual(t, msgs, []string{"a", "b", "c"})
+		assert.Equal(t, trailer, "grpc-status: 0\r\nkey: value\r\n")
+
+		msgs, trailer = post(ct, "/fail", "abc")
+		assert.Equal(t, len(msgs), 0)
+		assert.That(t, strings.HasPrefix(trailer, "grpc-status: 5\r\n"))
+		assert.That(t, strings.Contains(trailer, "grpc-message: unexpected EOF\r\n"))
+	}
+}
+
+type handlerFunc func(stream drpc.Stream, rpc string) error
+
+func (fn handlerFunc) HandleRPC(stream drpc.Stream, rpc string) error { return fn(stream, rpc) }
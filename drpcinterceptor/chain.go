@@ -0,0 +1,76 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcinterceptor
+
+import (
+	"context"
+
+	"storj.io/drpc"
+)
+
+// ChainClientInterceptors returns a ClientInterceptor that calls each of the
+// provided interceptors in order, with the first one being the outermost. Nil
+// interceptors are skipped.
+func ChainClientInterceptors(ints ...drpc.ClientInterceptor) drpc.ClientInterceptor {
+	chain := make(clientChain, 0, len(ints))
+	for _, icpt := range ints {
+		if icpt != nil {
+			chain = append(chain, icpt)
+		}
+	}
+	if len(chain) == 1 {
+		return chain[0]
+	}
+	return chain
+}
+
+// clientChain is a ClientInterceptor composed of other ClientInterceptors.
+type clientChain []drpc.ClientInterceptor
+
+// InterceptInvoke calls the chain of interceptors, ending with next.
+func (c clientChain) InterceptInvoke(ctx context.Context, rpc string, enc drpc.Encoding, in, out drpc.Message, next drpc.InvokeFunc) error {
+	for i := len(c) - 1; i >= 0; i-- {
+		icpt, inner := c[i], next
+		next = func(ctx context.Context, rpc string, enc drpc.Encoding, in, out drpc.Message) error {
+			return icpt.InterceptInvoke(ctx, rpc, enc, in, out, inner)
+		}
+	}
+	return next(ctx, rpc, enc, in, out)
+}
+
+// InterceptNewStream calls the chain of interceptors, ending with next.
+func (c clientChain) InterceptNewStream(ctx context.Context, rpc string, enc drpc.Encoding, next drpc.NewStreamFunc) (drpc.Stream, error) {
+	for i := len(c) - 1; i >= 0; i-- {
+		icpt, inner := c[i], next
+		next = func(ctx context.Context, rpc string, enc drpc.Encoding) (drpc.Stream, error) {
+			return icpt.InterceptNewStream(ctx, rpc, enc, inner)
+		}
+	}
+	return next(ctx, rpc, enc)
+}
+
+// ChainServerInterceptors returns a ServerInterceptor that calls each of the
+// provided interceptors in order, with the first one being the outermost. Nil
+// interceptors are skipped.
+func ChainServerInterceptors(ints ...drpc.ServerInterceptor) drpc.ServerInterceptor {
+	chain := make([]drpc.ServerInterceptor, 0, len(ints))
+	for _, icpt := range ints {
+		if icpt != nil {
+			chain = append(chain, icpt)
+		}
+	}
+	if len(chain) == 1 {
+		return chain[0]
+	}
+
+	return func(ctx context.Context, rpc string, in drpc.Message, stream drpc.Stream, next drpc.ServerHandler) (drpc.Message, error) {
+		for i := len(chain) - 1; i >= 0; i-- {
+			icpt, inner := chain[i], next
+			next = func(ctx context.Context, rpc string, in drpc.Message, stream drpc.Stream) (drpc.Message, error) {
+				return icpt(ctx, rpc, in, stream, inner)
+			}
+		}
+		return next(ctx, rpc, in, stream)
+	}
+}
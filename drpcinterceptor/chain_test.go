@@ -0,0 +1,130 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcinterceptor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/zeebo/assert"
+	"github.com/zeebo/errs"
+
+	"storj.io/drpc"
+)
+
+type traceKey struct{}
+
+func traceValue(ctx context.Context) string {
+	v, _ := ctx.Value(traceKey{}).(string)
+	return v
+}
+
+type testClientInterceptor struct {
+	name  string
+	trace *[]string
+	err   error
+}
+
+func (t testClientInterceptor) InterceptInvoke(ctx context.Context, rpc string, enc drpc.Encoding, in, out drpc.Message, next drpc.InvokeFunc) error {
+	*t.trace = append(*t.trace, t.name+":"+traceValue(ctx))
+	if t.err != nil {
+		return t.err
+	}
+	return next(context.WithValue(ctx, traceKey{}, traceValue(ctx)+t.name), rpc, enc, in, out)
+}
+
+func (t testClientInterceptor) InterceptNewStream(ctx context.Context, rpc string, enc drpc.Encoding, next drpc.NewStreamFunc) (drpc.Stream, error) {
+	*t.trace = append(*t.trace, t.name+":"+traceValue(ctx))
+	if t.err != nil {
+		return nil, t.err
+	}
+	return next(context.WithValue(ctx, traceKey{}, traceValue(ctx)+t.name), rpc, enc)
+}
+
+func testServerInterceptor(name string, trace *[]string, err error) drpc.ServerInterceptor {
+	return func(ctx context.Context, rpc string, in drpc.Message, stream drpc.Stream, next drpc.ServerHandler) (drpc.Message, error) {
+		*trace = append(*trace, name+":"+traceValue(ctx))
+		if err != nil {
+			return nil, err
+		}
+		return next(context.WithValue(ctx, traceKey{}, traceValue(ctx)+name), rpc, in, stream)
+	}
+}
+
+func TestChainClientInterceptors(t *testing.T) {
+	var trace []string
+	chain := ChainClientInterceptors(
+		testClientInterceptor{name: "a", trace: &trace},
+		nil,
+		testClientInterceptor{name: "b", trace: &trace},
+		testClientInterceptor{name: "c", trace: &trace},
+	)
+
+	invoke := func(ctx context.Context, rpc string, enc drpc.Encoding, in, out drpc.Message) error {
+		trace = append(trace, "invoke:"+traceValue(ctx))
+		return nil
+	}
+	assert.NoError(t, chain.InterceptInvoke(context.Background(), "rpc", nil, nil, nil, invoke))
+	assert.DeepEqual(t, trace, []string{"a:", "b:a", "c:ab", "invoke:abc"})
+
+	trace = nil
+	newStream := func(ctx context.Context, rpc string, enc drpc.Encoding) (drpc.Stream, error) {
+		trace = append(trace, "stream:"+traceValue(ctx))
+		return nil, nil
+	}
+	_, err := chain.InterceptNewStream(context.Background(), "rpc", nil, newStream)
+	assert.NoError(t, err)
+	assert.DeepEqual(t, trace, []string{"a:", "b:a", "c:ab", "stream:abc"})
+}
+
+func TestChainClientInterceptors_EarlyReturn(t *testing.T) {
+	var trace []string
+	chain := ChainClientInterceptors(
+		testClientInterceptor{name: "a", trace: &trace},
+		testClientInterceptor{name: "b", trace: &trace, err: errs.New("stop")},
+		testClientInterceptor{name: "c", trace: &trace},
+	)
+
+	invoke := func(ctx context.Context, rpc string, enc drpc.Encoding, in, out drpc.Message) error {
+		trace = append(trace, "invoke")
+		return nil
+	}
+	assert.Error(t, chain.InterceptInvoke(context.Background(), "rpc", nil, nil, nil, invoke))
+	assert.DeepEqual(t, trace, []string{"a:", "b:a"})
+}
+
+func TestChainServerInterceptors(t *testing.T) {
+	var trace []string
+	chain := ChainServerInterceptors(
+		testServerInterceptor("a", &trace, nil),
+		testServerInterceptor("b", &trace, nil),
+		nil,
+		testServerInterceptor("c", &trace, nil),
+	)
+
+	handler := func(ctx context.Context, rpc string, in drpc.Message, stream drpc.Stream) (drpc.Message, error) {
+		trace = append(trace, "handler:"+traceValue(ctx))
+		return nil, nil
+	}
+	_, err := chain(context.Background(), "rpc", nil, nil, handler)
+	assert.NoError(t, err)
+	assert.DeepEqual(t, trace, []string{"a:", "b:a", "c:ab", "handler:abc"})
+}
+
+func TestChainServerInterceptors_EarlyReturn(t *testing.T) {
+	var trace []string
+	chain := ChainServerInterceptors(
+		testServerInterceptor("a", &trace, nil),
+		testServerInterceptor("b", &trace, errs.New("stop")),
+		testServerInterceptor("c", &trace, nil),
+	)
+
+	handler := func(ctx context.Context, rpc string, in drpc.Message, stream drpc.Stream) (drpc.Message, error) {
+		trace = append(trace, "handler")
+		return nil, nil
+	}
+	_, err := chain(context.Background(), "rpc", nil, nil, handler)
+	assert.Error(t, err)
+	assert.DeepEqual(t, trace, []string{"a:", "b:a"})
+}
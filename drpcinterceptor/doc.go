@@ -0,0 +1,6 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+// Package drpcinterceptor provides helpers to compose client and server
+// interceptors.
+package drpcinterceptor
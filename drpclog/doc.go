@@ -0,0 +1,11 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+// Package drpclog provides client and server interceptors that log rpcs.
+//
+// One record is logged for every finished rpc with its name, duration, status
+// code, peer and drpcrequestid request id. Streams also log a record when they
+// start. Message payloads are only logged if enabled in the Options, because
+// they may contain sensitive data. With Go 1.21 or later, NewSlog logs to a
+// *slog.Logger.
+package drpclog
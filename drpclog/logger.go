@@ -0,0 +1,259 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpclog
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"storj.io/drpc"
+	"storj.io/drpc/drpcctx"
+	"storj.io/drpc/drpcmux"
+	"storj.io/drpc/drpcrequestid"
+	"storj.io/drpc/drpcstatus"
+)
+
+// Level is the severity of a record. The values match the levels of the
+// log/slog package.
+type Level int
+
+// These are the levels records are logged at.
+const (
+	LevelDebug Level = -4
+	LevelInfo  Level = 0
+	LevelWarn  Level = 4
+	LevelError Level = 8
+)
+
+// Attr is a key and value attached to a record.
+type Attr struct {
+	Key   string
+	Value interface{}
+}
+
+// LogFunc is called with every record.
+type LogFunc func(ctx context.Context, level Level, msg string, attrs []Attr)
+
+// Fields are the keys used for the attributes of a record. Empty keys cause
+// the attribute to be left out.
+type Fields struct {
+	Side      string // "client" or "server"
+	RPC       string // the name of the rpc
+	Duration  string // the time.Duration the rpc took
+	Code      string // the name of the drpcstatus.Code of the result
+	Error     string // the error the rpc failed with, if any
+	Peer      string // the address of the remote, if known
+	RequestID string // the drpcrequestid request id, if known
+	Request   string // the request message, if payloads are logged
+	Response  string // the response message, if payloads are logged
+}
+
+// DefaultFields are the Fields used if none are provided.
+var DefaultFields = Fields{
+	Side:      "side",
+	RPC:       "rpc",
+	Duration:  "duration",
+	Code:      "code",
+	Error:     "error",
+	Peer:      "peer",
+	RequestID: "request_id",
+	Request:   "request",
+	Response:  "response",
+}
+
+// Options controls configuration settings for a Logger.
+type Options struct {
+	// Fields are the keys used for attributes. If zero, DefaultFields is used.
+	Fields Fields
+
+	// Level returns the level that a finished rpc with the code is logged at.
+	// Stream start records are logged at the level for drpcstatus.OK. If nil,
+	// rpcs that succeed are logged at LevelInfo and rpcs that fail are logged
+	// at LevelError.
+	Level func(code drpcstatus.Code) Level
+
+	// Payloads causes the request and response messages of unitary rpcs to be
+	// logged. Messages sent on streams are never logged.
+	Payloads bool
+}
+
+// Logger logs rpcs. It implements drpc.ClientInterceptor, and its
+// InterceptServer method is a drpc.ServerInterceptor.
+type Logger struct {
+	log  LogFunc
+	opts Options
+}
+
+var _ drpc.ClientInterceptor = (*Logger)(nil)
+var _ drpc.ServerInterceptor = (*Logger)(nil).InterceptServer
+
+// New returns a Logger that calls log with every record.
+func New(log LogFunc) *Logger {
+	return NewWithOptions(log, Options{})
+}
+
+// NewWithOptions is like New but uses the provided options.
+func NewWithOptions(log LogFunc, opts Options) *Logger {
+	if opts.Fields == (Fields{}) {
+		opts.Fields = DefaultFields
+	}
+	if opts.Level == nil {
+		opts.Level = defaultLevel
+	}
+	return &Logger{log: log, opts: opts}
+}
+
+// defaultLevel logs failures at a higher level than successes.
+func defaultLevel(code drpcstatus.Code) Level {
+	if code == drpcstatus.OK {
+		return LevelInfo
+	}
+	return LevelError
+}
+
+// InterceptInvoke logs the unitary rpc once it finishes.
+func (l *Logger) InterceptInvoke(ctx context.Context, rpc string, enc drpc.Encoding, in, out drpc.Message, next drpc.InvokeFunc) (err error) {
+	start := time.Now()
+	defer func() { l.finish(ctx, "finished call", "client", rpc, start, err, in, out) }()
+
+	return next(ctx, rpc, enc, in, out)
+}
+
+// InterceptNewStream logs when the stream starts and when it finishes. The
+// stream finishes when it is closed or when sending or receiving on it fails.
+func (l *Logger) InterceptNewStream(ctx context.Context, rpc string, enc drpc.Encoding, next drpc.NewStreamFunc) (drpc.Stream, error) {
+	start := time.Now()
+
+	stream, err := next(ctx, rpc, enc)
+	if err != nil {
+		l.finish(ctx, "finished stream", "client", rpc, start, err, nil, nil)
+		return nil, err
+	}
+
+	l.started(ctx, "client", rpc)
+	return &clientStream{
+		Stream: stream,
+		finish: func(err error) { l.finish(ctx, "finished stream", "client", rpc, start, err, nil, nil) },
+	}, nil
+}
+
+// InterceptServer logs the rpc once the handler returns. Streaming rpcs, as
+// reported by drpcmux.MethodFromContext, also have a record logged before the
+// handler runs. If the kind is not known, rpcs that do not receive a single
+// request message up front are treated as streams.
+func (l *Logger) InterceptServer(ctx context.Context, rpc string, in drpc.Message, stream drpc.Stream, next drpc.ServerHandler) (out drpc.Message, err error) {
+	start := time.Now()
+	unitary := in != nil
+	if info, ok := drpcmux.MethodFromContext(ctx); ok {
+		unitary = info.Unitary
+	}
+
+	msg, req := "finished call", in
+	if !unitary {
+		msg, req = "finished stream", nil
+		l.started(ctx, "server", rpc)
+	}
+	defer func() { l.finish(ctx, msg, "server", rpc, start, err, req, out) }()
+
+	return next(ctx, rpc, in, stream)
+}
+
+// started logs the start of a stream.
+func (l *Logger) started(ctx context.Context, side, rpc string) {
+	attrs := l.attrs(ctx, nil, side, rpc)
+	l.log(ctx, l.opts.Level(drpcstatus.OK), "started stream", attrs)
+}
+
+// finish logs the end of an rpc.
+func (l *Logger) finish(ctx context.Context, msg, side, rpc string, start time.Time, err error, in, out drpc.Message) {
+	code := drpcstatus.CodeFromError(err)
+
+	attrs := l.attrs(ctx, nil, side, rpc)
+	attrs = l.add(attrs, l.opts.Fields.Duration, time.Since(start))
+	attrs = l.add(attrs, l.opts.Fields.Code, code.String())
+	if err != nil {
+		attrs = l.add(attrs, l.opts.Fields.Error, err.Error())
+	}
+	if l.opts.Payloads && in != nil {
+		attrs = l.add(attrs, l.opts.Fields.Request, in)
+		if err == nil && out != nil {
+			attrs = l.add(attrs, l.opts.Fields.Response, out)
+		}
+	}
+
+	l.log(ctx, l.opts.Level(code), msg, attrs)
+}
+
+// attrs appends the attributes common to every record.
+func (l *Logger) attrs(ctx context.Context, attrs []Attr, side, rpc string) []Attr {
+	attrs = l.add(attrs, l.opts.Fields.Side, side)
+	attrs = l.add(attrs, l.opts.Fields.RPC, rpc)
+	if peer, ok := peerAddr(ctx); ok {
+		attrs = l.add(attrs, l.opts.Fields.Peer, peer)
+	}
+	if id, ok := drpcrequestid.RequestIDFromContext(ctx); ok {
+		attrs = l.add(attrs, l.opts.Fields.RequestID, id)
+	}
+	return attrs
+}
+
+// add appends the attribute if the key is not empty.
+func (l *Logger) add(attrs []Attr, key string, value interface{}) []Attr {
+	if key == "" {
+		return attrs
+	}
+	return append(attrs, Attr{Key: key, Value: value})
+}
+
+// peerAddr returns the remote address of the transport the context is
+// associated with.
+func peerAddr(ctx context.Context) (string, bool) {
+	tr, _ := drpcctx.Transport(ctx)
+	ra, ok := tr.(interface{ RemoteAddr() net.Addr })
+	if !ok || ra.RemoteAddr() == nil {
+		return "", false
+	}
+	return ra.RemoteAddr().String(), true
+}
+
+// clientStream calls finish the first time the stream fails or is closed.
+type clientStream struct {
+	drpc.Stream
+	once   sync.Once
+	finish func(err error)
+}
+
+func (s *clientStream) done(err error) error {
+	if err != nil {
+		s.once.Do(func() {
+			if errors.Is(err, io.EOF) {
+				s.finish(nil)
+			} else {
+				s.finish(err)
+			}
+		})
+	}
+	return err
+}
+
+// MsgSend sends the message, finishing the stream if it fails.
+func (s *clientStream) MsgSend(msg drpc.Message, enc drpc.Encoding) error {
+	return s.done(s.Stream.MsgSend(msg, enc))
+}
+
+// MsgRecv receives a message, finishing the stream if it fails.
+func (s *clientStream) MsgRecv(msg drpc.Message, enc drpc.Encoding) error {
+	return s.done(s.Stream.MsgRecv(msg, enc))
+}
+
+// Close closes the stream and finishes it.
+func (s *clientStream) Close() error {
+	err := s.Stream.Close()
+	s.once.Do(func() { s.finish(err) })
+	return err
+}
@@ -0,0 +1,200 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpclog
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+
+	"storj.io/drpc/drpcconn"
+	"storj.io/drpc/drpchealth"
+	"storj.io/drpc/drpcinterceptor"
+	"storj.io/drpc/drpcmux"
+	"storj.io/drpc/drpcpipe"
+	"storj.io/drpc/drpcrequestid"
+	"storj.io/drpc/drpcstatus"
+)
+
+type record struct {
+	level Level
+	msg   string
+	attrs map[string]interface{}
+}
+
+// recorder keeps the records logged on each side.
+type recorder struct {
+	mu      sync.Mutex
+	records map[string][]record
+}
+
+func (r *recorder) log(ctx context.Context, level Level, msg string, attrs []Attr) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rec := record{level: level, msg: msg, attrs: make(map[string]interface{})}
+	for _, attr := range attrs {
+		rec.attrs[attr.Key] = attr.Value
+	}
+	if r.records == nil {
+		r.records = make(map[string][]record)
+	}
+	side, _ := rec.attrs["side"].(string)
+	r.records[side] = append(r.records[side], rec)
+}
+
+func (r *recorder) get(side string) []record {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]record(nil), r.records[side]...)
+}
+
+func newClient(t *testing.T, opts Options) (*drpchealth.Client, *recorder, func()) {
+	rec := new(recorder)
+	log := NewWithOptions(rec.log, opts)
+
+	mux := drpcmux.NewWithOptions(drpcmux.Options{Interceptor: log.InterceptServer})
+	assert.NoError(t, drpchealth.Register(mux, drpchealth.New()))
+
+	conn, cleanup := drpcpipe.NewWithOptions(mux, drpcpipe.Options{
+		Conn: drpcconn.Options{Interceptor: log},
+	})
+	return drpchealth.NewClient(conn), rec, cleanup
+}
+
+func TestLogger_Invoke(t *testing.T) {
+	ctx := context.Background()
+
+	cli, rec, cleanup := newClient(t, Options{})
+	defer cleanup()
+
+	_, err := cli.Check(ctx, "")
+	assert.NoError(t, err)
+	_, err = cli.Check(ctx, "unknown")
+	assert.Error(t, err)
+
+	for _, side := range []string{"client", "server"} {
+		records := rec.get(side)
+		assert.Equal(t, len(records), 2)
+
+		assert.Equal(t, records[0].msg, "finished call")
+		assert.Equal(t, records[0].level, LevelInfo)
+		assert.Equal(t, records[0].attrs["rpc"], drpchealth.CheckRPC)
+		assert.Equal(t, records[0].attrs["code"], "OK")
+		assert.NotNil(t, records[0].attrs["duration"])
+		assert.Nil(t, records[0].attrs["error"])
+		assert.Nil(t, records[0].attrs["request"])
+
+		assert.Equal(t, records[1].level, LevelError)
+		assert.Equal(t, records[1].attrs["code"], drpcstatus.NotFound.String())
+		assert.NotNil(t, records[1].attrs["error"])
+	}
+}
+
+func TestLogger_Stream(t *testing.T) {
+	ctx := context.Background()
+
+	cli, rec, cleanup := newClient(t, Options{})
+	defer cleanup()
+
+	stream, err := cli.Watch(ctx, "")
+	assert.NoError(t, err)
+	_, err = stream.Recv()
+	assert.NoError(t, err)
+
+	for _, side := range []string{"client", "server"} {
+		records := rec.get(side)
+		assert.Equal(t, len(records), 1)
+		assert.Equal(t, records[0].msg, "started stream")
+		assert.Equal(t, records[0].attrs["rpc"], drpchealth.WatchRPC)
+	}
+
+	assert.NoError(t, stream.Close())
+
+	records := rec.get("client")
+	assert.Equal(t, len(records), 2)
+	assert.Equal(t, records[1].msg, "finished stream")
+	assert.NotNil(t, records[1].attrs["duration"])
+
+	// the server finishes once the handler notices the stream is gone.
+	deadline := time.Now().Add(5 * time.Second)
+	for len(rec.get("server")) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the server to finish the stream")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	records = rec.get("server")
+	assert.Equal(t, records[1].msg, "finished stream")
+	assert.Equal(t, records[1].attrs["rpc"], drpchealth.WatchRPC)
+}
+
+func TestLogger_Peer(t *testing.T) {
+	ctx := context.Background()
+
+	cli, rec, cleanup := newClient(t, Options{})
+	defer cleanup()
+
+	_, err := cli.Check(ctx, "")
+	assert.NoError(t, err)
+
+	for _, side := range []string{"client", "server"} {
+		records := rec.get(side)
+		assert.Equal(t, len(records), 1)
+		assert.Equal(t, records[0].attrs["peer"], "pipe")
+	}
+}
+
+func TestLogger_RequestID(t *testing.T) {
+	ctx := context.Background()
+
+	rec := new(recorder)
+	log := New(rec.log)
+	ids := drpcrequestid.New()
+
+	mux := drpcmux.NewWithOptions(drpcmux.Options{
+		Interceptor: drpcinterceptor.ChainServerInterceptors(ids.InterceptServer, log.InterceptServer),
+	})
+	assert.NoError(t, drpchealth.Register(mux, drpchealth.New()))
+
+	conn, cleanup := drpcpipe.NewWithOptions(mux, drpcpipe.Options{
+		Conn: drpcconn.Options{Interceptor: drpcinterceptor.ChainClientInterceptors(ids, log)},
+	})
+	defer cleanup()
+
+	_, err := drpchealth.NewClient(conn).Check(ctx, "")
+	assert.NoError(t, err)
+
+	client, server := rec.get("client"), rec.get("server")
+	assert.Equal(t, len(client), 1)
+	assert.Equal(t, len(server), 1)
+	assert.That(t, client[0].attrs["request_id"] != nil)
+	assert.Equal(t, client[0].attrs["request_id"], server[0].attrs["request_id"])
+}
+
+func TestLogger_Options(t *testing.T) {
+	ctx := context.Background()
+
+	cli, rec, cleanup := newClient(t, Options{
+		Fields:   Fields{Side: "side", RPC: "method", Request: "req", Response: "resp"},
+		Level:    func(drpcstatus.Code) Level { return LevelDebug },
+		Payloads: true,
+	})
+	defer cleanup()
+
+	_, err := cli.Check(ctx, "")
+	assert.NoError(t, err)
+
+	records := rec.get("client")
+	assert.Equal(t, len(records), 1)
+	assert.Equal(t, records[0].level, LevelDebug)
+	assert.Equal(t, records[0].attrs["method"], drpchealth.CheckRPC)
+	assert.NotNil(t, records[0].attrs["req"])
+	assert.NotNil(t, records[0].attrs["resp"])
+	assert.Nil(t, records[0].attrs["code"])
+	assert.Equal(t, len(records[0].attrs), 4)
+}
@@ -0,0 +1,28 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+//go:build go1.21
+// +build go1.21
+
+package drpclog
+
+import (
+	"context"
+	"log/slog"
+)
+
+// NewSlog returns a Logger that logs records to the slog.Logger.
+func NewSlog(logger *slog.Logger) *Logger {
+	return NewSlogWithOptions(logger, Options{})
+}
+
+// NewSlogWithOptions is like NewSlog but uses the provided options.
+func NewSlogWithOptions(logger *slog.Logger, opts Options) *Logger {
+	return NewWithOptions(func(ctx context.Context, level Level, msg string, attrs []Attr) {
+		sattrs := make([]slog.Attr, 0, len(attrs))
+		for _, attr := range attrs {
+			sattrs = append(sattrs, slog.Any(attr.Key, attr.Value))
+		}
+		logger.LogAttrs(ctx, slog.Level(level), msg, sattrs...)
+	}, opts)
+}
@@ -0,0 +1,45 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+//go:build go1.21
+// +build go1.21
+
+package drpclog
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/zeebo/assert"
+
+	"storj.io/drpc/drpcconn"
+	"storj.io/drpc/drpchealth"
+	"storj.io/drpc/drpcmux"
+	"storj.io/drpc/drpcpipe"
+)
+
+func TestSlog(t *testing.T) {
+	ctx := context.Background()
+
+	var buf bytes.Buffer
+	log := NewSlog(slog.New(slog.NewTextHandler(&buf, nil)))
+
+	mux := drpcmux.New()
+	assert.NoError(t, drpchealth.Register(mux, drpchealth.New()))
+	conn, cleanup := drpcpipe.NewWithOptions(mux, drpcpipe.Options{
+		Conn: drpcconn.Options{Interceptor: log},
+	})
+	defer cleanup()
+
+	_, err := drpchealth.NewClient(conn).Check(ctx, "unknown")
+	assert.Error(t, err)
+
+	line := buf.String()
+	assert.That(t, strings.Contains(line, "level=ERROR"))
+	assert.That(t, strings.Contains(line, `msg="finished call"`))
+	assert.That(t, strings.Contains(line, "rpc="+drpchealth.CheckRPC))
+	assert.That(t, strings.Contains(line, "code=NotFound"))
+}
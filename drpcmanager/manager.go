@@ -5,20 +5,25 @@ package drpcmanager
 
 import (
 	"context"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"net"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/zeebo/errs"
 
 	"storj.io/drpc"
+	"storj.io/drpc/drpcctx"
 	"storj.io/drpc/drpcdebug"
 	"storj.io/drpc/drpcmetadata"
 	"storj.io/drpc/drpcsignal"
+	"storj.io/drpc/drpcstats"
 	"storj.io/drpc/drpcstream"
 	"storj.io/drpc/drpcwire"
 	"storj.io/drpc/internal/drpcopts"
@@ -29,10 +34,37 @@ var managerClosed = errs.Class("manager closed")
 // Options controls configuration settings for a manager.
 type Options struct {
 	// WriterBufferSize controls the size of the buffer that we will fill before
-	// flushing. Normal writes to streams typically issue a flush explicitly.
+	// flushing. Normal writes to streams typically issue a flush explicitly,
+	// but streams that coalesce writes only flush when the buffer fills. A
+	// larger buffer lets those streams coalesce many small messages into fewer
+	// writes to the transport at the cost of holding messages back longer.
+	// Zero means 4KiB.
 	WriterBufferSize int
 
-	// Reader are passed to any readers the manager creates.
+	// CoalesceWrites causes every stream the manager creates to hold sent
+	// messages in the write buffer instead of flushing after each one. They
+	// are flushed when the buffer fills, before the stream receives, and when
+	// the stream sends a CloseSend, close or error. This reduces the number of
+	// writes to the transport, but a stream that only sends does not deliver
+	// its messages until one of those happens, so it should not be used with
+	// rpcs that expect each message to arrive as soon as it is sent. It is the
+	// same as setting ManualFlush in the Stream options. RawFlush on a stream
+	// forces its messages to be flushed.
+	CoalesceWrites bool
+
+	// CoalesceDelay, if positive, is the longest a message is held in the
+	// write buffer by CoalesceWrites before it is flushed, so that it may be
+	// used with rpcs that only send. It is the same as setting FlushDelay in
+	// the Stream options.
+	CoalesceDelay time.Duration
+
+	// Reader are passed to any readers the manager creates. Its
+	// MaximumBufferSize limits the size of any message that can be received,
+	// and its BufferSize controls how much is read from the transport at once.
+	// Since the manager stops reading until each message is taken by its
+	// stream, together they also cap the inbound bytes buffered for the
+	// transport. Its Stats are replaced by the manager's own: see
+	// Manager.Stats.
 	Reader drpcwire.ReaderOptions
 
 	// Stream are passed to any streams the manager creates.
@@ -48,40 +80,126 @@ type Options struct {
 	// InactivityTimeout is the amount of time the manager will wait when creating
 	// a NewServerStream. It only includes the time it is reading packets from the
 	// remote client. In other words, it only includes the time that the client
-	// could delay before invoking an RPC. If zero or negative, no timeout is used.
+	// could delay before invoking an RPC. The timeout is reset whenever anything
+	// is read from the transport. If zero or negative, no timeout is used.
 	InactivityTimeout time.Duration
 
+	// KeepaliveInterval, if positive, causes the manager to send a ping to the
+	// remote whenever nothing has been read from the transport for this long.
+	// The remote must be using a version of drpc that responds to pings.
+	KeepaliveInterval time.Duration
+
+	// KeepaliveTimeout is the amount of time the manager will wait for anything
+	// to be read after sending a ping before closing the transport, failing any
+	// active stream. If zero or negative, the KeepaliveInterval is used.
+	KeepaliveTimeout time.Duration
+
+	// KeepaliveEnforcement limits the pings the remote may send, so that
+	// servers can protect themselves from clients that ping too eagerly.
+	KeepaliveEnforcement KeepaliveEnforcement
+
+	// Handshake causes the manager to announce the protocol version and
+	// features it supports to the remote in the metadata of its first client
+	// stream: see drpcmetadata.HelloKey. A manager always answers the
+	// announcement of a remote with its own, so only clients need to set it.
+	// Remotes using a version of drpc without the handshake never answer, and
+	// pass the announcement to the handler as metadata: see
+	// Manager.Negotiated.
+	Handshake bool
+
+	// Checksum causes the manager to announce drpcwire.FeatureChecksum in
+	// the handshake and, once the remote announces it as well, to send the
+	// checksum of every frame it writes so that the remote can detect frames
+	// corrupted by the transport. Checksums sent by the remote are always
+	// checked, and a mismatch is a ProtocolError that closes the transport.
+	// Both sides must set it, and clients must also set Handshake.
+	Checksum bool
+
+	// ReuseStreams causes the manager to reuse the memory of client streams
+	// passed to Release for later streams instead of allocating new ones. A
+	// stream is only reused once it is finished and the manager no longer
+	// refers to it, which is after the stream following it has started.
+	// drpcconn.Conn releases the streams it creates for Invoke.
+	ReuseStreams bool
+
 	// Internal contains options that are for internal use only.
 	Internal drpcopts.Manager
 }
 
+// KeepaliveEnforcement controls which pings from the remote are allowed. A
+// remote that sends a ping that is not allowed has its transport closed with a
+// drpc.ProtocolError.
+type KeepaliveEnforcement struct {
+	// MinInterval is the shortest time allowed between pings. If zero or
+	// negative, pings are not limited.
+	MinInterval time.Duration
+
+	// PermitWithoutStream allows pings while there is no active stream. It is
+	// only used if MinInterval is positive.
+	PermitWithoutStream bool
+}
+
 // Manager handles the logic of managing a transport for a drpc client or server.
 // It ensures that the connection is always being read from, that it is closed
 // in the case that the manager is and forwarding drpc protocol messages to the
 // appropriate stream.
 type Manager struct {
+	last  int64           // unix nanoseconds of the last read. first for alignment.
+	rgen  uint64          // incremented by the reader before each packet. early for alignment.
+	stats drpcstats.Stats // counters for the transport. early for alignment.
+
 	tr   drpc.Transport
 	wr   *drpcwire.Writer
 	rd   *drpcwire.Reader
 	opts Options
 
+	compress uint32 // set to 1 once the remote has sent a compressed message
+	hello    uint32 // set to 1 once a hello has been or is being announced
+
+	lastPing time.Time // when the remote last pinged, only used by manageReader
+
+	helloMu sync.Mutex      // protects remote
+	remote  *drpcwire.Hello // the hello the remote announced, if any
+
 	sem     drpcsignal.Chan      // held by the active stream
+	queue   streamQueue          // streams waiting for sem
 	sbuf    streamBuffer         // largest stream id created
 	pkts    chan drpcwire.Packet // channel for invoke packets
 	pdone   drpcsignal.Chan      // signals when a packets buffers can be reused
 	sfin    chan struct{}        // shared signal for stream finished
 	streams chan streamInfo      // channel to signal that a stream should start
 
+	reuse struct {
+		mu       sync.Mutex
+		curr     *drpcstream.Stream // the stream most recently started
+		prev     *drpcstream.Stream // the stream started before curr
+		currDone bool               // set if curr has been released
+		prevDone bool               // set if prev has been released
+		gen      uint64             // the reader generation when curr started
+	}
+
+	pingMu sync.Mutex               // protects pingID and pings
+	pingID uint64                   // the id of the last ping sent by Ping
+	pings  map[uint64]chan struct{} // closed when the pong for the id is read
+
+	ctrlMu  sync.Mutex       // protects ctrl
+	ctrl    []drpcwire.Frame // control frames waiting to be written
+	ctrlSig chan struct{}    // signals when frames are added to ctrl
+
 	sigs struct {
 		term   drpcsignal.Signal // set when the manager should start terminating
 		stream drpcsignal.Signal // set when the manage streams goroutine is done
 		read   drpcsignal.Signal // set after the goroutine reading from the transport is done
+		keep   drpcsignal.Signal // set after the goroutine sending keepalives is done
+		ctrl   drpcsignal.Signal // set after the goroutine writing control frames is done
 		tport  drpcsignal.Signal // set after the transport has been closed
+		away   drpcsignal.Signal // set when the remote has sent a go away
 	}
 }
 
 type streamInfo struct {
 	ctx    context.Context
+	cancel context.CancelFunc
 	stream *drpcstream.Stream
 }
 
@@ -95,13 +213,32 @@ func New(tr drpc.Transport) *Manager {
 func NewWithOptions(tr drpc.Transport, opts Options) *Manager {
 	m := &Manager{
 		tr:   tr,
-		wr:   drpcwire.NewWriter(tr, opts.WriterBufferSize),
-		rd:   drpcwire.NewReaderWithOptions(tr, opts.Reader),
 		opts: opts,
 
 		pkts:    make(chan drpcwire.Packet),
 		sfin:    make(chan struct{}, 1),
 		streams: make(chan streamInfo),
+		ctrlSig: make(chan struct{}, 1),
+	}
+
+	// count everything sent over the transport
+	m.opts.Reader.Stats = &m.stats
+	m.wr = drpcwire.NewWriterWithOptions(tr, drpcwire.WriterOptions{
+		Size:  opts.WriterBufferSize,
+		Stats: &m.stats,
+	})
+	m.rd = drpcwire.NewReaderWithOptions(tr, m.opts.Reader)
+
+	if m.opts.CoalesceWrites {
+		m.opts.Stream.ManualFlush = true
+		if m.opts.CoalesceDelay > 0 {
+			m.opts.Stream.FlushDelay = m.opts.CoalesceDelay
+		}
+	}
+
+	// compressed messages are limited like any other received message
+	if m.opts.Stream.MaximumDecompressedSize == 0 {
+		m.opts.Stream.MaximumDecompressedSize = m.opts.Reader.MaximumBufferSize
 	}
 
 	// initialize the stream buffer
@@ -117,9 +254,21 @@ func NewWithOptions(tr drpc.Transport, opts Options) *Manager {
 	// set the internal stream options
 	drpcopts.SetStreamTransport(&m.opts.Stream.Internal, m.tr)
 	drpcopts.SetStreamFin(&m.opts.Stream.Internal, m.sfin)
+	drpcopts.SetStreamInterrupt(&m.opts.Stream.Internal, m.terminate)
 
 	go m.manageReader()
 	go m.manageStreams()
+	go m.manageControl()
+
+	if m.tracksReads() {
+		m.last = time.Now().UnixNano()
+	}
+
+	if m.opts.KeepaliveInterval > 0 {
+		go m.manageKeepalive()
+	} else {
+		m.sigs.keep.Set(nil)
+	}
 
 	return m
 }
@@ -138,7 +287,9 @@ func (m *Manager) log(what string, cb func() string) {
 //
 
 // acquireSemaphore attempts to acquire the semaphore protecting streams. If the
-// context is canceled or the manager is terminated, it returns an error.
+// context is canceled or the manager is terminated, it returns an error. When
+// multiple callers are waiting, the one with the highest priority from the
+// context attempts to acquire it first.
 func (m *Manager) acquireSemaphore(ctx context.Context) error {
 	if err, ok := m.sigs.term.Get(); ok {
 		return err
@@ -146,19 +297,40 @@ func (m *Manager) acquireSemaphore(ctx context.Context) error {
 		return err
 	}
 
-	select {
-	case <-ctx.Done():
-		return ctx.Err()
+	w := m.queue.add(drpcctx.Priority(ctx))
+	granted := false
+	defer func() { m.queue.remove(w, granted) }()
 
-	case <-m.sigs.term.Signal():
-		return m.sigs.term.Err()
+	for {
+		// only the waiter whose turn it is attempts to acquire the semaphore,
+		// and the rest wait for the queue to change.
+		var sem chan struct{}
+		turn, changed := m.queue.turn(w)
+		if turn {
+			sem = m.sem.Get()
+		}
 
-	case m.sem.Get() <- struct{}{}:
-		if err := m.waitForPreviousStream(ctx); err != nil {
-			m.sem.Recv()
-			return err
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case <-m.sigs.term.Signal():
+			return m.sigs.term.Err()
+
+		case <-changed:
+
+		case sem <- struct{}{}:
+			granted = true
+			if err := m.waitForPreviousStream(ctx); err != nil {
+				m.sem.Recv()
+				return err
+			}
+			if m.sigs.away.IsSet() {
+				m.terminate(ErrGoAway)
+				return m.sigs.term.Err()
+			}
+			return nil
 		}
-		return nil
 	}
 }
 
@@ -192,6 +364,18 @@ func (m *Manager) waitForPreviousStream(ctx context.Context) (err error) {
 	}
 }
 
+// tracksReads returns true if the manager needs to keep track of the last time
+// anything was read from the transport.
+func (m *Manager) tracksReads() bool {
+	return m.opts.KeepaliveInterval > 0 || m.opts.InactivityTimeout > 0
+}
+
+// idle returns how long it has been since anything was read from the transport.
+// It is only valid if tracksReads returns true.
+func (m *Manager) idle() time.Duration {
+	return time.Since(time.Unix(0, atomic.LoadInt64(&m.last)))
+}
+
 // terminate puts the Manager into a terminal state and closes any resources
 // that need to be closed to signal the state change.
 func (m *Manager) terminate(err error) {
@@ -209,7 +393,9 @@ func (m *Manager) terminate(err error) {
 // manageReader is always reading a packet and dispatching it to the appropriate
 // stream or queue. It sets the read signal when it exits so that one can wait to
 // ensure that no one is reading on the reader. It sets the term signal if there is
-// any error reading packets.
+// any error reading packets. It waits for each packet to be taken by its stream
+// before reading the next one, so a slow consumer stops the reads from the
+// transport rather than causing packets to be queued.
 func (m *Manager) manageReader() {
 	defer m.sigs.read.Set(nil)
 
@@ -218,6 +404,12 @@ func (m *Manager) manageReader() {
 	var run int
 
 	for !m.sigs.term.IsSet() {
+		// let newStream know that any stream the reader was delivering to
+		// before is no longer in use.
+		if m.opts.ReuseStreams {
+			atomic.AddUint64(&m.rgen, 1)
+		}
+
 		// if we have a run of "small" packets, drop the buffer to release
 		// memory so that a burst of large packets does not cause eternally
 		// large heap usage.
@@ -235,6 +427,10 @@ func (m *Manager) manageReader() {
 			return
 		}
 
+		if m.tracksReads() {
+			atomic.StoreInt64(&m.last, time.Now().UnixNano())
+		}
+
 		if len(pkt.Data) < cap(pkt.Data)/4 {
 			run++
 		} else {
@@ -243,11 +439,45 @@ func (m *Manager) manageReader() {
 
 		m.log("READ", pkt.String)
 
+		// a remote that sends compressed messages can decompress them too.
+		if pkt.Kind == drpcwire.KindCompressedMessage {
+			atomic.StoreUint32(&m.compress, 1)
+		}
+
+		// pings, pongs and go aways are not part of any stream. we queue a
+		// pong for pings so that a remote that is not reading cannot stop
+		// the reads, and otherwise only care that something was read.
+		if pkt.ID.Stream == 0 {
+			switch pkt.Kind {
+			case drpcwire.KindPing:
+				if err := m.checkPing(); err != nil {
+					m.terminate(managerClosed.Wrap(err))
+					return
+				}
+				pong := keepaliveFrame(drpcwire.KindPong)
+				pong.Data = append([]byte(nil), pkt.Data...)
+				m.queueControl(pong)
+			case drpcwire.KindPong:
+				m.handlePong(pkt.Data)
+			case drpcwire.KindGoAway:
+				m.goAway()
+			case drpcwire.KindHello:
+				if err := m.recordHello(pkt.Data); err != nil {
+					m.terminate(managerClosed.Wrap(err))
+					return
+				}
+			}
+			continue
+		}
+
 	again:
 		switch curr := m.sbuf.Get(); {
 		// if the packet is for the current stream, deliver it.
 		case curr != nil && pkt.ID.Stream == curr.ID():
-			if err := curr.HandlePacket(pkt); err != nil {
+			m.stats.AddBuffered(uint64(len(pkt.Data)))
+			err := curr.HandlePacket(pkt)
+			m.stats.SubBuffered(uint64(len(pkt.Data)))
+			if err != nil {
 				m.terminate(managerClosed.Wrap(err))
 				return
 			}
@@ -262,9 +492,11 @@ func (m *Manager) manageReader() {
 				curr.Cancel(context.Canceled)
 			}
 
+			m.stats.AddBuffered(uint64(len(pkt.Data)))
 			select {
 			case m.pkts <- pkt:
 				m.pdone.Recv()
+				m.stats.SubBuffered(uint64(len(pkt.Data)))
 
 			case <-m.sigs.term.Signal():
 				return
@@ -286,30 +518,301 @@ func (m *Manager) manageReader() {
 	}
 }
 
+//
+// manage keepalive
+//
+
+// keepaliveFrame returns a frame for sending a ping, pong or go away.
+func keepaliveFrame(kind drpcwire.Kind) drpcwire.Frame {
+	return drpcwire.Frame{Kind: kind, Done: true, Control: true}
+}
+
+// checkPing returns an error if the ping just read from the remote is not
+// allowed by the keepalive enforcement.
+func (m *Manager) checkPing() error {
+	enf := m.opts.KeepaliveEnforcement
+	if enf.MinInterval <= 0 {
+		return nil
+	}
+
+	now := time.Now()
+	last := m.lastPing
+	m.lastPing = now
+
+	if !enf.PermitWithoutStream {
+		if curr := m.sbuf.Get(); curr == nil || curr.IsFinished() {
+			return drpc.ProtocolError.New("ping without an active stream")
+		}
+	}
+	if !last.IsZero() && now.Sub(last) < enf.MinInterval {
+		return drpc.ProtocolError.New("pings sent too frequently")
+	}
+	return nil
+}
+
+// takeHello returns the hex encoded hello for a client stream to announce in
+// the metadata of its invoke, or an empty string if it has already been
+// announced.
+func (m *Manager) takeHello() string {
+	if !atomic.CompareAndSwapUint32(&m.hello, 0, 1) {
+		return ""
+	}
+
+	m.log("HELLO", func() string { return "" })
+
+	return hex.EncodeToString(drpcwire.AppendHello(nil, m.localHello()))
+}
+
+// popHello removes the hello the remote sent along with an invoke from the
+// metadata and, if there was one, records it and answers it with our own. The
+// answer is sent before the stream is created so that the remote has it before
+// any response.
+func (m *Manager) popHello(meta map[string]string, md drpcmetadata.Metadata) error {
+	values := md.Get(drpcmetadata.HelloKey)
+	delete(meta, drpcmetadata.HelloKey)
+	delete(md, drpcmetadata.HelloKey)
+	if len(values) == 0 {
+		return nil
+	}
+
+	data, err := hex.DecodeString(values[len(values)-1])
+	if err != nil {
+		return drpc.ProtocolError.New("invalid hello: %q", values[len(values)-1])
+	}
+	if err := m.recordHello(data); err != nil {
+		return err
+	}
+	if !atomic.CompareAndSwapUint32(&m.hello, 0, 1) {
+		return nil
+	}
+
+	m.log("HELLO", func() string { return "" })
+
+	return m.wr.FlushFrame(drpcwire.Frame{
+		Data:    drpcwire.AppendHello(nil, m.localHello()),
+		Kind:    drpcwire.KindHello,
+		Done:    true,
+		Control: true,
+	})
+}
+
+// recordHello records the hello the remote announced, and starts sending
+// checksums if both sides want them.
+func (m *Manager) recordHello(data []byte) error {
+	remote, err := drpcwire.ParseHello(data)
+	if err != nil {
+		return err
+	}
+
+	m.log("REMOTE", func() string { return fmt.Sprintf("%+v", remote) })
+
+	m.helloMu.Lock()
+	m.remote = &remote
+	m.helloMu.Unlock()
+
+	if m.opts.Checksum && remote.Features.Has(drpcwire.FeatureChecksum) {
+		m.wr.EnableChecksums()
+	}
+	return nil
+}
+
+// localHello returns the hello the manager announces.
+func (m *Manager) localHello() drpcwire.Hello {
+	hello := drpcwire.LocalHello()
+	if m.opts.Checksum {
+		hello.Features |= drpcwire.FeatureChecksum
+	}
+	return hello
+}
+
+// ErrGoAway is the error the manager terminates with once it has stopped using
+// a transport that the remote asked it to go away from. Streams are only failed
+// with it before anything is sent on them, so they are safe to retry on another
+// transport.
+var ErrGoAway = managerClosed.Wrap(drpc.ClosedError.New("remote sent go away"))
+
+// goAway handles a go away from the remote. The transport is closed right away
+// if no stream is active, and otherwise once the active stream finishes.
+func (m *Manager) goAway() {
+	m.log("GOAWAY", func() string { return "" })
+	m.sigs.away.Set(nil)
+
+	select {
+	case m.sem.Get() <- struct{}{}:
+		m.terminate(ErrGoAway)
+	default:
+	}
+}
+
+// sleep waits for the duration, returning false if the manager is terminated
+// before the duration elapses.
+func (m *Manager) sleep(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-m.sigs.term.Signal():
+		return false
+	}
+}
+
+// manageKeepalive sends a ping whenever nothing has been read for the keepalive
+// interval, and terminates the manager if nothing is read within the keepalive
+// timeout after sending one. It sets the keep signal when it exits.
+func (m *Manager) manageKeepalive() {
+	defer m.sigs.keep.Set(nil)
+
+	interval, timeout := m.opts.KeepaliveInterval, m.opts.KeepaliveTimeout
+	if timeout <= 0 {
+		timeout = interval
+	}
+
+	wait := interval
+	for m.sleep(wait) {
+		wait = interval
+		if idle := m.idle(); idle < interval {
+			wait = interval - idle
+			continue
+		}
+
+		m.log("PING", func() string { return "" })
+
+		sent := time.Now().UnixNano()
+		if err := m.wr.FlushFrame(keepaliveFrame(drpcwire.KindPing)); err != nil {
+			m.terminate(managerClosed.Wrap(err))
+			return
+		}
+
+		if !m.sleep(timeout) {
+			return
+		} else if atomic.LoadInt64(&m.last) < sent {
+			m.terminate(managerClosed.Wrap(drpc.ClosedError.New("keepalive timeout")))
+			return
+		}
+	}
+}
+
+// maxQueuedControl is the most control frames that are queued to be written.
+// Any more are dropped, so a remote that sends pings without reading the pongs
+// only causes its later pings to go unanswered.
+const maxQueuedControl = 64
+
+// queueControl queues the frame to be written by manageControl, so that the
+// reader never blocks on writes to the transport.
+func (m *Manager) queueControl(fr drpcwire.Frame) {
+	m.ctrlMu.Lock()
+	if len(m.ctrl) < maxQueuedControl {
+		m.ctrl = append(m.ctrl, fr)
+	}
+	m.ctrlMu.Unlock()
+
+	select {
+	case m.ctrlSig <- struct{}{}:
+	default:
+	}
+}
+
+// manageControl writes the control frames queued by queueControl until the
+// manager is terminated. It sets the ctrl signal when it exits.
+func (m *Manager) manageControl() {
+	defer m.sigs.ctrl.Set(nil)
+
+	for {
+		select {
+		case <-m.ctrlSig:
+		case <-m.sigs.term.Signal():
+			return
+		}
+
+		m.ctrlMu.Lock()
+		frames := m.ctrl
+		m.ctrl = nil
+		m.ctrlMu.Unlock()
+
+		for _, fr := range frames {
+			if err := m.wr.FlushFrame(fr); err != nil {
+				m.terminate(managerClosed.Wrap(err))
+				return
+			}
+		}
+	}
+}
+
 //
 // manage streams
 //
 
 // newStream creates a stream value with the appropriate configuration for this manager.
-func (m *Manager) newStream(ctx context.Context, sid uint64, kind, rpc string) (*drpcstream.Stream, error) {
+// If cancel is not nil, it is called once the stream is finished or fails to
+// be created. The stream only sends compressed messages if compress is true.
+func (m *Manager) newStream(ctx context.Context, cancel context.CancelFunc, sid uint64, kind, rpc string, compress bool) (*drpcstream.Stream, error) {
 	opts := m.opts.Stream
 	drpcopts.SetStreamKind(&opts.Internal, kind)
+	drpcopts.SetStreamCompress(&opts.Internal, compress && opts.Compressor != nil)
 	if cb := drpcopts.GetManagerStatsCB(&m.opts.Internal); cb != nil {
 		drpcopts.SetStreamStats(&opts.Internal, cb(rpc))
 	}
+	if kind == "cli" && m.opts.Handshake && atomic.LoadUint32(&m.hello) == 0 {
+		drpcopts.SetStreamHello(&opts.Internal, m.takeHello)
+	}
+
+	stream := m.reusable()
+	if stream == nil || !stream.Reuse(ctx, sid, m.wr, opts) {
+		stream = drpcstream.NewWithOptions(ctx, sid, m.wr, opts)
+	}
 
-	stream := drpcstream.NewWithOptions(ctx, sid, m.wr, opts)
 	select {
-	case m.streams <- streamInfo{ctx: ctx, stream: stream}:
+	case m.streams <- streamInfo{ctx: ctx, cancel: cancel, stream: stream}:
 		m.sbuf.Set(stream)
+		m.started(stream)
 		m.log("STREAM", stream.String)
 		return stream, nil
 
 	case <-m.sigs.term.Signal():
+		if cancel != nil {
+			cancel()
+		}
 		return nil, m.sigs.term.Err()
 	}
 }
 
+// reusable returns the stream started before the current one if it has been
+// released and nothing but its user could still be using it, or nil.
+func (m *Manager) reusable() *drpcstream.Stream {
+	if !m.opts.ReuseStreams {
+		return nil
+	}
+
+	m.reuse.mu.Lock()
+	defer m.reuse.mu.Unlock()
+
+	// manageStreams is done with the previous stream because it accepted the
+	// current one, and the reader is done with it once it has moved on to
+	// another packet after the current stream started.
+	stream := m.reuse.prev
+	if stream == nil || !m.reuse.prevDone || atomic.LoadUint64(&m.rgen) <= m.reuse.gen {
+		return nil
+	}
+	m.reuse.prev, m.reuse.prevDone = nil, false
+	return stream
+}
+
+// started records that the stream is now the current one, for reusable.
+func (m *Manager) started(stream *drpcstream.Stream) {
+	if !m.opts.ReuseStreams {
+		return
+	}
+
+	m.reuse.mu.Lock()
+	defer m.reuse.mu.Unlock()
+
+	m.reuse.prev, m.reuse.prevDone = m.reuse.curr, m.reuse.currDone
+	m.reuse.curr, m.reuse.currDone = stream, false
+	m.reuse.gen = atomic.LoadUint64(&m.rgen)
+}
+
 // manageStreams reads from the streams channel for stream infos and runs the
 // manageStream function on them.
 func (m *Manager) manageStreams() {
@@ -319,6 +822,12 @@ func (m *Manager) manageStreams() {
 		select {
 		case si := <-m.streams:
 			m.manageStream(si.ctx, si.stream)
+			if si.cancel != nil {
+				si.cancel()
+			}
+			if m.sigs.away.IsSet() {
+				m.terminate(ErrGoAway)
+			}
 
 		case <-m.sigs.term.Signal():
 			return
@@ -332,7 +841,11 @@ func (m *Manager) manageStream(ctx context.Context, stream *drpcstream.Stream) {
 	select {
 	case <-m.sigs.term.Signal():
 		err := m.sigs.term.Err()
-		if errors.Is(err, io.EOF) {
+		if ctxErr := contextErr(ctx); ctxErr != nil {
+			// the remote may have hung up because of the same deadline or
+			// cancellation, so prefer to report that.
+			err = ctxErr
+		} else if errors.Is(err, io.EOF) {
 			err = context.Canceled
 		}
 		stream.Cancel(err)
@@ -383,6 +896,15 @@ func (m *Manager) manageStream(ctx context.Context, stream *drpcstream.Stream) {
 // exported interface
 //
 
+// Stats returns the number of bytes, including framing, and messages sent over
+// the transport so far, and the number of bytes of the packet waiting to be
+// taken by a stream, if any. Only one packet is read ahead, so that is bounded
+// by the MaximumBufferSize of the Reader. It is safe to call concurrently with
+// anything else.
+func (m *Manager) Stats() drpcstats.Stats {
+	return m.stats.AtomicClone()
+}
+
 // Closed returns a channel that is closed once the manager is closed.
 func (m *Manager) Closed() <-chan struct{} {
 	return m.sigs.term.Signal()
@@ -405,18 +927,155 @@ func (m *Manager) Close() error {
 
 	m.sigs.stream.Wait()
 	m.sigs.read.Wait()
+	m.sigs.keep.Wait()
+	m.sigs.ctrl.Wait()
 	m.sigs.tport.Wait()
 
 	return m.sigs.tport.Err()
 }
 
+// ErrPingUnsupported is returned by Ping if the remote has not announced
+// drpcwire.FeatureKeepalive in the handshake, so it may not answer pings.
+var ErrPingUnsupported = drpc.Error.New("remote does not support pings")
+
+// Ping sends a ping to the remote and waits for it to respond, returning the
+// round trip time. It returns ErrPingUnsupported unless both sides negotiated
+// drpcwire.FeatureKeepalive: see Negotiated. It returns an error if the context
+// is canceled or the manager is terminated before the response arrives, even
+// if the ping could not be written yet.
+func (m *Manager) Ping(ctx context.Context) (time.Duration, error) {
+	if err, ok := m.sigs.term.Get(); ok {
+		return 0, err
+	} else if err := ctx.Err(); err != nil {
+		return 0, err
+	} else if hello, ok := m.Negotiated(); !ok || !hello.Features.Has(drpcwire.FeatureKeepalive) {
+		return 0, ErrPingUnsupported
+	}
+
+	ch := make(chan struct{})
+	m.pingMu.Lock()
+	m.pingID++
+	id := m.pingID
+	if m.pings == nil {
+		m.pings = make(map[uint64]chan struct{})
+	}
+	m.pings[id] = ch
+	m.pingMu.Unlock()
+	defer m.removePing(id)
+
+	m.log("PING", func() string { return fmt.Sprint(id) })
+
+	// the ping is written by manageControl so that a write blocked on the
+	// transport does not keep us from noticing the context.
+	sent := time.Now()
+	ping := keepaliveFrame(drpcwire.KindPing)
+	ping.Data = drpcwire.AppendVarint(nil, id)
+	m.queueControl(ping)
+
+	select {
+	case <-ch:
+		return time.Since(sent), nil
+
+	case <-ctx.Done():
+		return 0, ctx.Err()
+
+	case <-m.sigs.term.Signal():
+		return 0, m.sigs.term.Err()
+	}
+}
+
+// handlePong completes the ping that the pong read from the remote answers, if
+// it is still waiting. Pongs to the pings sent for the keepalive have no body
+// and so answer none of them.
+func (m *Manager) handlePong(data []byte) {
+	rem, id, ok, err := drpcwire.ReadVarint(data)
+	if !ok || err != nil || len(rem) > 0 {
+		return
+	}
+
+	m.pingMu.Lock()
+	defer m.pingMu.Unlock()
+
+	if ch, ok := m.pings[id]; ok {
+		close(ch)
+		delete(m.pings, id)
+	}
+}
+
+// Negotiated returns the protocol version and features supported by both the
+// manager and the remote, and true, once the remote has announced them. Until
+// then, or if the remote uses a version of drpc without the handshake, it
+// returns the baseline of version 0 with no features, and false. The features
+// that existed before the handshake work with any remote without being
+// negotiated, so the result only matters to features added after it.
+func (m *Manager) Negotiated() (drpcwire.Hello, bool) {
+	m.helloMu.Lock()
+	defer m.helloMu.Unlock()
+
+	if m.remote == nil {
+		return drpcwire.Hello{}, false
+	}
+	return m.localHello().Negotiate(*m.remote), true
+}
+
+// SendGoAway asks the remote to stop using the transport. A remote that supports
+// it finishes its active stream, if any, and then closes the transport. It does
+// not stop the manager from serving streams, so the caller should still close
+// it if the remote does not.
+func (m *Manager) SendGoAway() error {
+	if err, ok := m.sigs.term.Get(); ok {
+		return err
+	}
+
+	m.log("GOAWAY", func() string { return "" })
+
+	if err := m.wr.FlushFrame(keepaliveFrame(drpcwire.KindGoAway)); err != nil {
+		m.terminate(managerClosed.Wrap(err))
+		return m.sigs.term.Err()
+	}
+	return nil
+}
+
+// removePing stops the ping with the id from being completed by a pong.
+func (m *Manager) removePing(id uint64) {
+	m.pingMu.Lock()
+	defer m.pingMu.Unlock()
+
+	delete(m.pings, id)
+}
+
+// Release tells the manager that the stream will not be used again by its
+// user, so that it may be reused for a later stream if the ReuseStreams option
+// is set. Nothing may refer to the stream or its Context once it is released,
+// so it should only be called with streams created by NewClientStream that
+// have not been handed to other code. It does nothing if the option is not set
+// or the stream is too old to be reused.
+func (m *Manager) Release(stream *drpcstream.Stream) {
+	if !m.opts.ReuseStreams {
+		return
+	}
+
+	m.reuse.mu.Lock()
+	defer m.reuse.mu.Unlock()
+
+	switch stream {
+	case m.reuse.curr:
+		m.reuse.currDone = true
+	case m.reuse.prev:
+		m.reuse.prevDone = true
+	}
+}
+
 // NewClientStream starts a stream on the managed transport for use by a client.
+// If other streams are waiting for the transport, those with a higher priority
+// set with drpcctx.WithPriority start first.
 func (m *Manager) NewClientStream(ctx context.Context, rpc string) (stream *drpcstream.Stream, err error) {
 	if err := m.acquireSemaphore(ctx); err != nil {
 		return nil, err
 	}
 
-	return m.newStream(ctx, m.sbuf.Get().ID()+1, "cli", rpc)
+	compress := atomic.LoadUint32(&m.compress) == 1
+	return m.newStream(ctx, nil, m.sbuf.Get().ID()+1, "cli", rpc, compress)
 }
 
 // NewServerStream starts a stream on the managed transport for use by a server. It does
@@ -432,12 +1091,15 @@ func (m *Manager) NewServerStream(ctx context.Context) (stream *drpcstream.Strea
 	}()
 
 	var meta map[string]string
+	var md drpcmetadata.Metadata
 	var metaID uint64
+	var timer *time.Timer
 	var timeoutCh <-chan time.Time
 
 	// set up the timeout on the context if necessary.
-	if timeout := m.opts.InactivityTimeout; timeout > 0 {
-		timer := time.NewTimer(timeout)
+	timeout := m.opts.InactivityTimeout
+	if timeout > 0 {
+		timer = time.NewTimer(timeout)
 		defer timer.Stop()
 		timeoutCh = timer.C
 	}
@@ -445,6 +1107,11 @@ func (m *Manager) NewServerStream(ctx context.Context) (stream *drpcstream.Strea
 	for {
 		select {
 		case <-timeoutCh:
+			// if anything has been read since the timer started, extend it.
+			if idle := m.idle(); idle < timeout {
+				timer.Reset(timeout - idle)
+				continue
+			}
 			return nil, "", context.DeadlineExceeded
 
 		case <-ctx.Done():
@@ -459,6 +1126,9 @@ func (m *Manager) NewServerStream(ctx context.Context) (stream *drpcstream.Strea
 			// include it if the stream id matches the eventual invoke.
 			case drpcwire.KindInvokeMetadata:
 				meta, err = drpcmetadata.Decode(pkt.Data)
+				if err == nil {
+					md, err = drpcmetadata.DecodeMetadata(pkt.Data)
+				}
 				m.pdone.Send()
 
 				if err != nil {
@@ -470,12 +1140,32 @@ func (m *Manager) NewServerStream(ctx context.Context) (stream *drpcstream.Strea
 				rpc = string(pkt.Data)
 				m.pdone.Send()
 
+				var cancel context.CancelFunc
+				var compress bool
 				if metaID == pkt.ID.Stream {
+					compress = m.popCompression(meta, md)
+					if err := m.popHello(meta, md); err != nil {
+						return nil, "", err
+					}
+					timeout, ok, err := popTimeout(meta, md)
+					if err != nil {
+						return nil, "", err
+					}
+					if ok {
+						ctx, cancel = context.WithTimeout(ctx, timeout)
+					}
 					ctx = drpcmetadata.AddPairs(ctx, meta)
+					if len(md) > 0 {
+						ctx = drpcmetadata.WithIncomingMetadata(ctx, md)
+					}
 				}
 
-				stream, err := m.newStream(ctx, pkt.ID.Stream, "srv", rpc)
-				return stream, rpc, err
+				stream, err := m.newStream(ctx, cancel, pkt.ID.Stream, "srv", rpc, compress)
+				if err != nil {
+					return nil, "", err
+				}
+				stream.OpenWindow()
+				return stream, rpc, nil
 
 			default:
 				// this should never happen, but defensive.
@@ -485,6 +1175,53 @@ func (m *Manager) NewServerStream(ctx context.Context) (stream *drpcstream.Strea
 	}
 }
 
+// contextErr returns the error of the context, treating it as having exceeded
+// its deadline as soon as the deadline has passed, even if the timer that
+// cancels it has not yet run.
+func contextErr(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok && !time.Now().Before(deadline) {
+		return context.DeadlineExceeded
+	}
+	return nil
+}
+
+// popTimeout removes the timeout the remote sent along with an invoke from the
+// metadata and returns it, if there was one.
+func popTimeout(meta map[string]string, md drpcmetadata.Metadata) (time.Duration, bool, error) {
+	values := md.Get(drpcmetadata.TimeoutKey)
+	delete(meta, drpcmetadata.TimeoutKey)
+	delete(md, drpcmetadata.TimeoutKey)
+	if len(values) == 0 {
+		return 0, false, nil
+	}
+	timeout, err := time.ParseDuration(values[len(values)-1])
+	if err != nil {
+		return 0, false, drpc.ProtocolError.New("invalid timeout: %q", values[len(values)-1])
+	}
+	return timeout, true, nil
+}
+
+// popCompression removes the names of the compressors the remote sent along
+// with an invoke from the metadata and returns true if the manager's compressor
+// is one of them.
+func (m *Manager) popCompression(meta map[string]string, md drpcmetadata.Metadata) bool {
+	values := md.Get(drpcmetadata.CompressionKey)
+	delete(meta, drpcmetadata.CompressionKey)
+	delete(md, drpcmetadata.CompressionKey)
+	if m.opts.Stream.Compressor == nil {
+		return false
+	}
+	for _, name := range values {
+		if name == m.opts.Stream.Compressor.Name() {
+			return true
+		}
+	}
+	return false
+}
+
 func isConnectionReset(err error) bool {
 	var operr *net.OpError
 	if !errors.As(err, &operr) {
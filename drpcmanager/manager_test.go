@@ -4,16 +4,26 @@
 package drpcmanager
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"net"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/zeebo/assert"
 
+	"storj.io/drpc"
+
+	"storj.io/drpc/drpccompress"
+	"storj.io/drpc/drpcctx"
+	"storj.io/drpc/drpcmetadata"
+	"storj.io/drpc/drpcstream"
 	"storj.io/drpc/drpctest"
 	"storj.io/drpc/drpcwire"
 )
@@ -81,7 +91,7 @@ func TestUnblocked_NoCancel(t *testing.T) {
 		assert.NoError(t, err)
 
 		_, err = stream.RawRecv()
-		assert.That(t, errors.Is(err, io.EOF))
+		assert.That(t, drpc.ClosedError.Has(err))
 	})
 
 	ctx.Wait()
@@ -172,3 +182,870 @@ func (b *blockedTransport) wait(p int, rw *bool) (int, error) {
 func (b *blockedTransport) Read(p []byte) (n int, err error)  { return b.wait(len(p), &b.ro) }
 func (b *blockedTransport) Write(p []byte) (n int, err error) { return b.wait(len(p), &b.wo) }
 func (b *blockedTransport) Close() error                      { return nil }
+
+type pongDropper struct {
+	net.Conn
+	pong []byte
+}
+
+func (p pongDropper) Write(b []byte) (n int, err error) {
+	if bytes.Equal(b, p.pong) {
+		return len(b), nil
+	}
+	return p.Conn.Write(b)
+}
+
+func TestKeepalive(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	cconn, sconn := net.Pipe()
+	defer func() { _ = cconn.Close() }()
+	defer func() { _ = sconn.Close() }()
+
+	cman := NewWithOptions(cconn, Options{KeepaliveInterval: time.Millisecond})
+	defer func() { _ = cman.Close() }()
+
+	sman := New(sconn)
+	defer func() { _ = sman.Close() }()
+
+	// the remote responds to pings, so the connection stays open while idle.
+	time.Sleep(50 * time.Millisecond)
+	assert.That(t, !closed(cman.Closed()))
+
+	ctx.Run(func(ctx context.Context) {
+		stream, _, err := sman.NewServerStream(ctx)
+		assert.NoError(t, err)
+		defer func() { _ = stream.Close() }()
+
+		data, err := stream.RawRecv()
+		assert.NoError(t, err)
+		assert.Equal(t, string(data), "message")
+	})
+
+	stream, err := cman.NewClientStream(ctx, "rpc")
+	assert.NoError(t, err)
+	defer func() { _ = stream.Close() }()
+
+	assert.NoError(t, stream.RawWrite(drpcwire.KindInvoke, []byte("invoke")))
+	assert.NoError(t, stream.RawWrite(drpcwire.KindMessage, []byte("message")))
+	assert.NoError(t, stream.RawFlush())
+}
+
+func TestKeepalive_Timeout(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	cconn, sconn := net.Pipe()
+	defer func() { _ = cconn.Close() }()
+	defer func() { _ = sconn.Close() }()
+
+	cman := NewWithOptions(cconn, Options{
+		KeepaliveInterval: time.Millisecond,
+		KeepaliveTimeout:  10 * time.Millisecond,
+	})
+	defer func() { _ = cman.Close() }()
+
+	pong := drpcwire.AppendFrame(nil, keepaliveFrame(drpcwire.KindPong))
+	sman := New(pongDropper{Conn: sconn, pong: pong})
+	defer func() { _ = sman.Close() }()
+
+	ctx.Run(func(ctx context.Context) {
+		stream, _, err := sman.NewServerStream(ctx)
+		assert.NoError(t, err)
+		<-stream.Context().Done()
+	})
+
+	stream, err := cman.NewClientStream(ctx, "rpc")
+	assert.NoError(t, err)
+	assert.NoError(t, stream.RawWrite(drpcwire.KindInvoke, []byte("invoke")))
+	assert.NoError(t, stream.RawFlush())
+
+	// the in flight call fails when the keepalive times out.
+	_, err = stream.RawRecv()
+	assert.Error(t, err)
+	assert.That(t, strings.Contains(err.Error(), "keepalive timeout"))
+	<-cman.Closed()
+}
+
+func TestKeepalive_RemoteNotReading(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	cconn, sconn := net.Pipe()
+	defer func() { _ = cconn.Close() }()
+	defer func() { _ = sconn.Close() }()
+
+	sman := New(sconn)
+	defer func() { _ = sman.Close() }()
+
+	// the remote pings and then sends an invoke without ever reading, so
+	// the pong can never be written.
+	ctx.Run(func(ctx context.Context) {
+		var buf []byte
+		buf = drpcwire.AppendFrame(buf, keepaliveFrame(drpcwire.KindPing))
+		buf = drpcwire.AppendFrame(buf, drpcwire.Frame{
+			Data: []byte("invoke"),
+			ID:   drpcwire.ID{Stream: 1, Message: 1},
+			Kind: drpcwire.KindInvoke,
+			Done: true,
+		})
+		_, _ = cconn.Write(buf)
+	})
+
+	// the blocked pong does not stop the invoke from being read.
+	tctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	_, rpc, err := sman.NewServerStream(tctx)
+	assert.NoError(t, err)
+	assert.Equal(t, rpc, "invoke")
+}
+
+func TestPing(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	cconn, sconn := net.Pipe()
+	defer func() { _ = cconn.Close() }()
+	defer func() { _ = sconn.Close() }()
+
+	cman := NewWithOptions(cconn, Options{Handshake: true})
+	defer func() { _ = cman.Close() }()
+
+	// the remote answers the hello, answers the first ping with pongs for
+	// other pings, echoes the second one, and then stops reading.
+	ctx.Run(func(ctx context.Context) {
+		rd, wr := drpcwire.NewReader(sconn), drpcwire.NewWriter(sconn, 0)
+		for pings := 0; pings < 2; {
+			pkt, err := rd.ReadPacket()
+			if err != nil {
+				return
+			}
+			switch pkt.Kind {
+			case drpcwire.KindInvokeMetadata:
+				_ = wr.WritePacket(drpcwire.Packet{
+					Data: drpcwire.AppendHello(nil, drpcwire.LocalHello()),
+					Kind: drpcwire.KindHello,
+				})
+			case drpcwire.KindPing:
+				data := pkt.Data
+				if pings == 0 {
+					_ = wr.WritePacket(drpcwire.Packet{Kind: drpcwire.KindPong})
+					data = append(data, 0)
+				}
+				_ = wr.WritePacket(drpcwire.Packet{Data: data, Kind: drpcwire.KindPong})
+				pings++
+			}
+			_ = wr.Flush()
+		}
+	})
+
+	// pings are not sent before the remote announces support for them.
+	_, err := cman.Ping(ctx)
+	assert.That(t, errors.Is(err, ErrPingUnsupported))
+
+	stream, err := cman.NewClientStream(ctx, "rpc")
+	assert.NoError(t, err)
+	assert.NoError(t, stream.RawWrite(drpcwire.KindInvoke, []byte("rpc")))
+	assert.NoError(t, stream.RawFlush())
+
+	for start := time.Now(); ; time.Sleep(time.Millisecond) {
+		if _, ok := cman.Negotiated(); ok {
+			break
+		} else if time.Since(start) > 5*time.Second {
+			t.Fatal("remote hello not received")
+		}
+	}
+
+	ping := func(timeout time.Duration) error {
+		pctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		_, err := cman.Ping(pctx)
+		return err
+	}
+
+	// pongs for other pings do not complete a ping.
+	assert.That(t, errors.Is(ping(50*time.Millisecond), context.DeadlineExceeded))
+
+	// the pong for the ping does.
+	assert.NoError(t, ping(5*time.Second))
+
+	// the context is respected while the ping can not be written.
+	assert.That(t, errors.Is(ping(10*time.Millisecond), context.DeadlineExceeded))
+}
+
+func BenchmarkWriterBufferSize(b *testing.B) {
+	run := func(b *testing.B, size int, coalesce bool, delay time.Duration) {
+		cconn, sconn := net.Pipe()
+		defer func() { _ = sconn.Close() }()
+		go func() { _, _ = io.Copy(io.Discard, sconn) }()
+
+		tr := &drpctest.CountingTransport{Transport: cconn}
+		man := NewWithOptions(tr, Options{
+			WriterBufferSize: size,
+			CoalesceWrites:   coalesce,
+			CoalesceDelay:    delay,
+		})
+		defer func() { _ = man.Close() }()
+
+		stream, err := man.NewClientStream(context.Background(), "rpc")
+		assert.NoError(b, err)
+
+		msg := make([]byte, 64)
+		b.SetBytes(int64(1000 * len(msg)))
+		b.ReportAllocs()
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			for j := 0; j < 1000; j++ {
+				assert.NoError(b, stream.MsgSend(&msg, drpctest.ByteEncoding{}))
+			}
+		}
+		assert.NoError(b, stream.CloseSend())
+
+		b.StopTimer()
+		b.ReportMetric(float64(tr.Writes())/float64(b.N), "writes/op")
+		_ = stream.Close()
+	}
+
+	for _, size := range []int{4 << 10, 64 << 10} {
+		name := fmt.Sprintf("%dKiB", size>>10)
+		b.Run(name+"/Default", func(b *testing.B) { run(b, size, false, 0) })
+		b.Run(name+"/Coalesce", func(b *testing.B) { run(b, size, true, 0) })
+		b.Run(name+"/CoalesceDelay", func(b *testing.B) { run(b, size, true, time.Millisecond) })
+	}
+}
+
+func TestKeepaliveEnforcement(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	// serve returns the error the server manager is closed with, if it is
+	// closed within the timeout.
+	serve := func(interval time.Duration, enf KeepaliveEnforcement, timeout time.Duration) error {
+		cconn, sconn := net.Pipe()
+		defer func() { _ = cconn.Close() }()
+		defer func() { _ = sconn.Close() }()
+
+		cman := NewWithOptions(cconn, Options{KeepaliveInterval: interval})
+		defer func() { _ = cman.Close() }()
+
+		sman := NewWithOptions(sconn, Options{KeepaliveEnforcement: enf})
+		defer func() { _ = sman.Close() }()
+
+		tctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		_, _, err := sman.NewServerStream(tctx)
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil
+		}
+		return err
+	}
+
+	{ // pinging too frequently closes the connection
+		err := serve(time.Millisecond, KeepaliveEnforcement{
+			MinInterval:         time.Second,
+			PermitWithoutStream: true,
+		}, time.Minute)
+		assert.That(t, drpc.ProtocolError.Has(err))
+	}
+
+	{ // as does pinging without a stream unless it is permitted
+		err := serve(5*time.Millisecond, KeepaliveEnforcement{
+			MinInterval: time.Millisecond,
+		}, time.Minute)
+		assert.That(t, drpc.ProtocolError.Has(err))
+	}
+
+	{ // pinging within the limits keeps the connection open
+		err := serve(5*time.Millisecond, KeepaliveEnforcement{
+			MinInterval:         time.Millisecond,
+			PermitWithoutStream: true,
+		}, 50*time.Millisecond)
+		assert.NoError(t, err)
+	}
+}
+
+// frameCounter is a transport that counts the frames written to it.
+type frameCounter struct {
+	drpc.Transport
+	mu     sync.Mutex
+	buf    []byte
+	frames int
+}
+
+func (f *frameCounter) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	f.buf = append(f.buf, p...)
+	for {
+		rem, _, ok, err := drpcwire.ParseFrame(f.buf)
+		if !ok || err != nil {
+			break
+		}
+		f.buf, f.frames = rem, f.frames+1
+	}
+	f.mu.Unlock()
+	return f.Transport.Write(p)
+}
+
+func TestSplitMessages(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	message := bytes.Repeat([]byte("0123456789"), 1000)
+
+	// send returns what the server received for the message and how many
+	// frames the client wrote.
+	send := func(max int) ([]byte, int, error) {
+		cconn, sconn := net.Pipe()
+		defer func() { _ = cconn.Close() }()
+		defer func() { _ = sconn.Close() }()
+
+		counter := &frameCounter{Transport: cconn}
+		cman := NewWithOptions(counter, Options{Stream: drpcstream.Options{SplitSize: 1024}})
+		defer func() { _ = cman.Close() }()
+
+		sman := NewWithOptions(sconn, Options{Reader: drpcwire.ReaderOptions{MaximumBufferSize: max}})
+		defer func() { _ = sman.Close() }()
+
+		stream, err := cman.NewClientStream(ctx, "rpc")
+		assert.NoError(t, err)
+		defer func() { _ = stream.Close() }()
+
+		ctx.Run(func(ctx context.Context) {
+			_ = stream.RawWrite(drpcwire.KindInvoke, []byte("invoke"))
+			_ = stream.RawWrite(drpcwire.KindMessage, message)
+			_ = stream.RawFlush()
+		})
+
+		sstream, _, err := sman.NewServerStream(ctx)
+		if err != nil {
+			return nil, 0, err
+		}
+		defer func() { _ = sstream.Close() }()
+
+		data, err := sstream.RawRecv()
+		if err != nil {
+			return nil, 0, err
+		}
+
+		counter.mu.Lock()
+		defer counter.mu.Unlock()
+		return data, counter.frames, nil
+	}
+
+	{ // the message is written in many frames and reassembled
+		data, frames, err := send(0)
+		assert.NoError(t, err)
+		assert.Equal(t, data, message)
+		assert.Equal(t, frames, 1+10)
+	}
+
+	{ // the receive limit applies to the whole message, not each frame
+		_, _, err := send(4096)
+		assert.That(t, drpc.ProtocolError.Has(err))
+	}
+}
+
+func TestSlowConsumer(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	cconn, sconn := net.Pipe()
+	defer func() { _ = cconn.Close() }()
+	defer func() { _ = sconn.Close() }()
+
+	cman := New(cconn)
+	defer func() { _ = cman.Close() }()
+
+	sman := New(sconn)
+	defer func() { _ = sman.Close() }()
+
+	const count, size = 1000, 1024
+	stream, err := cman.NewClientStream(ctx, "rpc")
+	assert.NoError(t, err)
+	defer func() { _ = stream.Close() }()
+
+	ctx.Run(func(ctx context.Context) {
+		_ = stream.RawWrite(drpcwire.KindInvoke, []byte("invoke"))
+		for i := 0; i < count; i++ {
+			if err := stream.RawWriteMessage(make([]byte, size)); err != nil {
+				return
+			}
+		}
+	})
+
+	sstream, _, err := sman.NewServerStream(ctx)
+	assert.NoError(t, err)
+	defer func() { _ = sstream.Close() }()
+
+	// while nothing is received, the reader holds a single message, and is
+	// blocked handing it to the stream, so nothing more is read and the
+	// sender is blocked instead of everything being buffered.
+	deadline := time.Now().Add(5 * time.Second)
+	for sman.Stats().Buffered != uint64(size) {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for a message to be buffered")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	assert.That(t, cman.Stats().MessagesWritten < 10)
+
+	for i := 0; i < count; i++ {
+		data, err := sstream.RawRecv()
+		assert.NoError(t, err)
+		assert.Equal(t, len(data), size)
+		assert.That(t, sman.Stats().Buffered <= size)
+	}
+}
+
+func TestCoalesceWrites(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	cconn, sconn := net.Pipe()
+	defer func() { _ = cconn.Close() }()
+	defer func() { _ = sconn.Close() }()
+
+	tr := &drpctest.CountingTransport{Transport: cconn}
+	cman := NewWithOptions(tr, Options{CoalesceWrites: true})
+	defer func() { _ = cman.Close() }()
+
+	sman := New(sconn)
+	defer func() { _ = sman.Close() }()
+
+	ctx.Run(func(ctx context.Context) {
+		stream, _, err := sman.NewServerStream(ctx)
+		assert.NoError(t, err)
+
+		var in []byte
+		for i := 0; i < 10; i++ {
+			assert.NoError(t, stream.MsgRecv(&in, drpctest.ByteEncoding{}))
+		}
+		assert.NoError(t, stream.MsgSend(&in, drpctest.ByteEncoding{}))
+	})
+
+	stream, err := cman.NewClientStream(ctx, "rpc")
+	assert.NoError(t, err)
+	defer func() { _ = stream.Close() }()
+
+	// nothing is written until the stream receives.
+	assert.NoError(t, stream.RawWrite(drpcwire.KindInvoke, []byte("rpc")))
+	msg := []byte("data")
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, stream.MsgSend(&msg, drpctest.ByteEncoding{}))
+	}
+	assert.Equal(t, tr.Writes(), 0)
+
+	var out []byte
+	assert.NoError(t, stream.MsgRecv(&out, drpctest.ByteEncoding{}))
+	assert.Equal(t, string(out), "data")
+	assert.Equal(t, tr.Writes(), 1)
+
+	// let the server finish before the managers are closed.
+	ctx.Wait()
+}
+
+func TestCoalesceWrites_Delay(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	cconn, sconn := net.Pipe()
+	defer func() { _ = cconn.Close() }()
+	defer func() { _ = sconn.Close() }()
+
+	tr := &drpctest.CountingTransport{Transport: cconn}
+	cman := NewWithOptions(tr, Options{CoalesceWrites: true, CoalesceDelay: 100 * time.Millisecond})
+	defer func() { _ = cman.Close() }()
+
+	sman := New(sconn)
+	defer func() { _ = sman.Close() }()
+
+	received := make(chan struct{})
+	ctx.Run(func(ctx context.Context) {
+		stream, _, err := sman.NewServerStream(ctx)
+		assert.NoError(t, err)
+
+		var in []byte
+		for i := 0; i < 10; i++ {
+			assert.NoError(t, stream.MsgRecv(&in, drpctest.ByteEncoding{}))
+		}
+		close(received)
+	})
+
+	stream, err := cman.NewClientStream(ctx, "rpc")
+	assert.NoError(t, err)
+	defer func() { _ = stream.Close() }()
+
+	// the messages are delivered together after the delay without the
+	// stream receiving.
+	assert.NoError(t, stream.RawWrite(drpcwire.KindInvoke, []byte("rpc")))
+	msg := []byte("data")
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, stream.MsgSend(&msg, drpctest.ByteEncoding{}))
+	}
+	assert.Equal(t, tr.Writes(), 0)
+
+	<-received
+	assert.Equal(t, tr.Writes(), 1)
+	ctx.Wait()
+}
+
+// tcpPipe returns a connected pair of loopback tcp connections. Unlike
+// net.Pipe, writes to them are buffered by the kernel.
+func tcpPipe(t *testing.T) (net.Conn, net.Conn) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer func() { _ = lis.Close() }()
+
+	cconn, err := net.Dial("tcp", lis.Addr().String())
+	assert.NoError(t, err)
+	sconn, err := lis.Accept()
+	assert.NoError(t, err)
+	return cconn, sconn
+}
+
+func TestWindow_BlocksSender(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	cconn, sconn := tcpPipe(t)
+	defer func() { _ = cconn.Close() }()
+	defer func() { _ = sconn.Close() }()
+
+	cman := New(cconn)
+	defer func() { _ = cman.Close() }()
+
+	sman := NewWithOptions(sconn, Options{Stream: drpcstream.Options{Window: 1024}})
+	defer func() { _ = sman.Close() }()
+
+	var sent int64
+	ctx.Run(func(ctx context.Context) {
+		stream, err := cman.NewClientStream(ctx, "rpc")
+		assert.NoError(t, err)
+		defer func() { _ = stream.Close() }()
+
+		// the server advertises its window before replying, so it is known
+		// once the reply is received.
+		assert.NoError(t, stream.RawWrite(drpcwire.KindInvoke, []byte("rpc")))
+		assert.NoError(t, stream.RawFlush())
+		var reply []byte
+		assert.NoError(t, stream.MsgRecv(&reply, drpctest.ByteEncoding{}))
+
+		msg := make([]byte, 256)
+		for i := 0; i < 20; i++ {
+			assert.NoError(t, stream.MsgSend(&msg, drpctest.ByteEncoding{}))
+			atomic.AddInt64(&sent, 1)
+		}
+		assert.NoError(t, stream.CloseSend())
+	})
+
+	stream, _, err := sman.NewServerStream(ctx)
+	assert.NoError(t, err)
+	defer func() { _ = stream.Close() }()
+
+	// the sender can only get 1024 bytes ahead of the receiver.
+	var in []byte
+	assert.NoError(t, stream.MsgSend(&in, drpctest.ByteEncoding{}))
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, atomic.LoadInt64(&sent), int64(4))
+
+	for i := 0; i < 20; i++ {
+		assert.NoError(t, stream.MsgRecv(&in, drpctest.ByteEncoding{}))
+		assert.Equal(t, len(in), 256)
+	}
+	assert.That(t, errors.Is(stream.MsgRecv(&in, drpctest.ByteEncoding{}), io.EOF))
+	assert.Equal(t, atomic.LoadInt64(&sent), int64(20))
+}
+
+func TestWindow_Cancel(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	cconn, sconn := net.Pipe()
+	defer func() { _ = cconn.Close() }()
+	defer func() { _ = sconn.Close() }()
+
+	cman := NewWithOptions(cconn, Options{SoftCancel: true})
+	defer func() { _ = cman.Close() }()
+
+	sman := NewWithOptions(sconn, Options{Stream: drpcstream.Options{Window: 1024}})
+	defer func() { _ = sman.Close() }()
+
+	sctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errch := make(chan error, 1)
+	ctx.Run(func(ctx context.Context) {
+		stream, err := cman.NewClientStream(sctx, "rpc")
+		assert.NoError(t, err)
+		defer func() { _ = stream.Close() }()
+
+		assert.NoError(t, stream.RawWrite(drpcwire.KindInvoke, []byte("rpc")))
+		msg := make([]byte, 256)
+		for {
+			if err := stream.MsgSend(&msg, drpctest.ByteEncoding{}); err != nil {
+				errch <- err
+				return
+			}
+		}
+	})
+
+	stream, _, err := sman.NewServerStream(ctx)
+	assert.NoError(t, err)
+	defer func() { _ = stream.Close() }()
+
+	var in []byte
+	assert.NoError(t, stream.MsgRecv(&in, drpctest.ByteEncoding{}))
+
+	// the sender is blocked on the window until its context is canceled.
+	select {
+	case err := <-errch:
+		t.Fatal("send did not block:", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	cancel()
+	assert.That(t, errors.Is(<-errch, context.Canceled))
+}
+
+func TestSendTimeout(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	// the remote end of the pipe never reads, so writes block forever.
+	cconn, sconn := net.Pipe()
+	defer func() { _ = cconn.Close() }()
+	defer func() { _ = sconn.Close() }()
+
+	cman := NewWithOptions(cconn, Options{
+		Stream: drpcstream.Options{SendTimeout: 50 * time.Millisecond},
+	})
+	defer func() { _ = cman.Close() }()
+
+	stream, err := cman.NewClientStream(ctx, "rpc")
+	assert.NoError(t, err)
+	defer func() { _ = stream.Close() }()
+
+	start := time.Now()
+	msg := []byte("data")
+	err = stream.MsgSend(&msg, drpctest.ByteEncoding{})
+	assert.That(t, time.Since(start) < time.Second)
+
+	assert.That(t, drpcstream.SendTimeoutError.Has(err))
+	assert.That(t, errors.Is(err, context.DeadlineExceeded))
+
+	// the blocked write could only be interrupted by closing the transport.
+	<-cman.Closed()
+	assert.That(t, errors.Is(stream.MsgSend(&msg, drpctest.ByteEncoding{}), io.EOF))
+}
+
+func TestReuseStreams(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	cconn, sconn := net.Pipe()
+	defer func() { _ = cconn.Close() }()
+	defer func() { _ = sconn.Close() }()
+
+	cman := NewWithOptions(cconn, Options{ReuseStreams: true})
+	defer func() { _ = cman.Close() }()
+
+	sman := New(sconn)
+	defer func() { _ = sman.Close() }()
+
+	ctx.Run(func(ctx context.Context) {
+		for {
+			stream, rpc, err := sman.NewServerStream(ctx)
+			if err != nil {
+				return
+			}
+			var in []byte
+			if err := stream.MsgRecv(&in, drpctest.ByteEncoding{}); err != nil {
+				return
+			}
+			if rpc == "fail" {
+				_ = stream.SendError(errors.New("failed"))
+			} else {
+				stream.SetTrailer(drpcmetadata.Metadata{"key": {"value"}})
+				assert.NoError(t, stream.MsgSend(&in, drpctest.ByteEncoding{}))
+				assert.NoError(t, stream.CloseSend())
+			}
+			_ = stream.Close()
+		}
+	})
+
+	seen := make(map[*drpcstream.Stream]bool)
+	reused := 0
+
+	for i := 0; i < 20; i++ {
+		rctx, cancel := context.WithCancel(ctx)
+		stream, err := cman.NewClientStream(rctx, []string{"rpc", "fail"}[i%2])
+		assert.NoError(t, err)
+
+		// a reused stream starts out like a new one.
+		if seen[stream] {
+			reused++
+		}
+		seen[stream] = true
+		assert.Equal(t, stream.ID(), uint64(i+1))
+		assert.NoError(t, stream.Err())
+		assert.NoError(t, stream.Context().Err())
+		assert.That(t, !stream.IsTerminated())
+		assert.Nil(t, stream.Trailer())
+
+		msg := []byte("data")
+		assert.NoError(t, stream.RawWrite(drpcwire.KindInvoke, []byte([]string{"rpc", "fail"}[i%2])))
+		assert.NoError(t, stream.MsgSend(&msg, drpctest.ByteEncoding{}))
+
+		var out []byte
+		err = stream.MsgRecv(&out, drpctest.ByteEncoding{})
+		if i%2 == 0 {
+			assert.NoError(t, err)
+			assert.Equal(t, string(out), "data")
+			_, err = stream.RawRecv()
+			assert.That(t, errors.Is(err, io.EOF))
+			assert.DeepEqual(t, stream.Trailer(), drpcmetadata.Metadata{"key": {"value"}})
+		} else {
+			assert.Error(t, err)
+		}
+
+		assert.NoError(t, stream.Close())
+		<-stream.Finished()
+		cancel()
+		cman.Release(stream)
+	}
+
+	assert.That(t, reused > 0)
+}
+
+func TestRecoverDecodeErrors(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	cconn, sconn := net.Pipe()
+	defer func() { _ = cconn.Close() }()
+	defer func() { _ = sconn.Close() }()
+	ctx.Run(func(ctx context.Context) { _, _ = io.Copy(io.Discard, sconn) })
+
+	cman := NewWithOptions(cconn, Options{
+		Stream: drpcstream.Options{
+			Compressor:          drpccompress.Gzip{},
+			RecoverDecodeErrors: true,
+		},
+	})
+	defer func() { _ = cman.Close() }()
+
+	stream, err := cman.NewClientStream(ctx, "rpc")
+	assert.NoError(t, err)
+	defer func() { _ = stream.Close() }()
+
+	data, err := drpccompress.Gzip{}.Compress([]byte("data"))
+	assert.NoError(t, err)
+
+	wr := drpcwire.NewWriter(sconn, 0)
+	ctx.Run(func(ctx context.Context) {
+		for i, data := range [][]byte{[]byte("garbage"), data} {
+			assert.NoError(t, wr.WritePacket(drpcwire.Packet{
+				ID:   drpcwire.ID{Stream: stream.ID(), Message: uint64(i + 1)},
+				Kind: drpcwire.KindCompressedMessage,
+				Data: data,
+			}))
+			assert.NoError(t, wr.Flush())
+		}
+
+		// a frame whose stream id varint never ends is a framing error.
+		_, _ = sconn.Write(append([]byte{byte(drpcwire.KindMessage << 1)}, bytes.Repeat([]byte{0xff}, 16)...))
+	})
+
+	// a message that fails to decode is reported without affecting the
+	// stream or the transport.
+	var out []byte
+	assert.That(t, drpc.ProtocolError.Has(stream.MsgRecv(&out, drpctest.ByteEncoding{})))
+	assert.That(t, !stream.IsTerminated())
+
+	assert.NoError(t, stream.MsgRecv(&out, drpctest.ByteEncoding{}))
+	assert.Equal(t, string(out), "data")
+
+	// but a framing error closes the transport.
+	assert.Error(t, stream.MsgRecv(&out, drpctest.ByteEncoding{}))
+	<-cman.Closed()
+}
+
+func TestPriority(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	cconn, sconn := net.Pipe()
+	defer func() { _ = cconn.Close() }()
+	defer func() { _ = sconn.Close() }()
+	ctx.Run(func(ctx context.Context) { _, _ = io.Copy(io.Discard, sconn) })
+
+	man := New(cconn)
+	defer func() { _ = man.Close() }()
+
+	waiting := func(n int) {
+		for {
+			man.queue.mu.Lock()
+			got := len(man.queue.waiters)
+			man.queue.mu.Unlock()
+			if got == n {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	// hold the transport so that every other stream has to wait.
+	active, err := man.NewClientStream(ctx, "active")
+	assert.NoError(t, err)
+
+	order := make(chan string, 10)
+	start := func(rpc string, priority int) {
+		ctx.Run(func(ctx context.Context) {
+			stream, err := man.NewClientStream(drpcctx.WithPriority(ctx, priority), rpc)
+			assert.NoError(t, err)
+			// close before reporting so the manager is not closed first.
+			assert.NoError(t, stream.Close())
+			order <- rpc
+		})
+	}
+
+	for i := 0; i < 3; i++ {
+		start(fmt.Sprint("bulk", i), 0)
+		waiting(i + 1)
+	}
+	start("interactive", 1)
+	waiting(4)
+
+	// the interactive stream goes ahead of the bulk streams waiting before it,
+	// which then go in the order they started.
+	assert.NoError(t, active.Close())
+	for _, exp := range []string{"interactive", "bulk0", "bulk1", "bulk2"} {
+		assert.Equal(t, <-order, exp)
+	}
+}
+
+func TestStreamQueue_NoStarvation(t *testing.T) {
+	var q streamQueue
+
+	bulk := q.add(0)
+	for i := 0; i < 2; i++ {
+		// a higher priority waiter goes first while the bulk waiter ages.
+		high := q.add(2)
+		turn, _ := q.turn(high)
+		assert.That(t, turn)
+		q.remove(high, true)
+	}
+
+	// after being skipped twice, the bulk waiter goes ahead of new waiters of
+	// the same higher priority.
+	high := q.add(2)
+	turn, _ := q.turn(bulk)
+	assert.That(t, turn)
+	q.remove(bulk, true)
+
+	turn, _ = q.turn(high)
+	assert.That(t, turn)
+}
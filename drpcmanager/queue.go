@@ -0,0 +1,81 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcmanager
+
+import "sync"
+
+// streamQueue orders the streams waiting for the semaphore by priority. Only
+// the waiter with the highest effective priority attempts to acquire it. The
+// effective priority of a waiter is its priority plus the number of waiters
+// that were let through ahead of it, so that low priority streams are only
+// delayed, and never starved.
+type streamQueue struct {
+	mu      sync.Mutex
+	waiters []*streamWaiter
+	changed chan struct{} // closed and replaced when waiters changes
+}
+
+type streamWaiter struct {
+	prio    int
+	skipped int
+}
+
+func (w *streamWaiter) effective() int { return w.prio + w.skipped }
+
+// add enqueues a waiter with the priority.
+func (q *streamQueue) add(prio int) *streamWaiter {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	w := &streamWaiter{prio: prio}
+	q.waiters = append(q.waiters, w)
+	q.notifyLocked()
+	return w
+}
+
+// remove dequeues the waiter. If granted is true, the waiter acquired the
+// semaphore, and every other waiter is aged.
+func (q *streamQueue) remove(w *streamWaiter, granted bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i, x := range q.waiters {
+		if x == w {
+			q.waiters = append(q.waiters[:i], q.waiters[i+1:]...)
+			break
+		}
+	}
+	if granted {
+		for _, x := range q.waiters {
+			x.skipped++
+		}
+	}
+	q.notifyLocked()
+}
+
+// turn returns true if the waiter should attempt to acquire the semaphore,
+// and a channel that is closed when that may have changed.
+func (q *streamQueue) turn(w *streamWaiter) (bool, <-chan struct{}) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.changed == nil {
+		q.changed = make(chan struct{})
+	}
+
+	var best *streamWaiter
+	for _, x := range q.waiters {
+		if best == nil || x.effective() > best.effective() {
+			best = x
+		}
+	}
+	return best == w, q.changed
+}
+
+func (q *streamQueue) notifyLocked() {
+	if q.changed != nil {
+		close(q.changed)
+		q.changed = nil
+	}
+}
@@ -14,6 +14,7 @@ import (
 
 	"github.com/zeebo/assert"
 
+	"storj.io/drpc"
 	"storj.io/drpc/drpcstream"
 	"storj.io/drpc/drpctest"
 	"storj.io/drpc/drpcwire"
@@ -233,6 +234,7 @@ func runRandomized(t *testing.T, prog []byte, r runner) {
 func expectedError(err error) bool {
 	return errors.Is(err, io.EOF) ||
 		errors.Is(err, context.Canceled) ||
+		drpc.ClosedError.Has(err) ||
 		(err != nil && err.Error() == "")
 }
 
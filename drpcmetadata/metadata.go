@@ -5,6 +5,7 @@ package drpcmetadata
 
 import (
 	"context"
+	"strings"
 
 	"github.com/zeebo/errs"
 )
@@ -48,6 +49,42 @@ func Decode(buf []byte) (map[string]string, error) {
 	return out, nil
 }
 
+// EncodeMetadata generates the byte form of the metadata and appends it onto the
+// passed in buffer. Keys with multiple values are encoded as multiple entries.
+func EncodeMetadata(buf []byte, md Metadata) ([]byte, error) {
+	for key, values := range md {
+		key = strings.ToLower(key)
+		for _, value := range values {
+			buf = appendEntry(buf, key, value)
+		}
+	}
+	return buf, nil
+}
+
+// DecodeMetadata translates the byte form of metadata into Metadata. Unlike
+// Decode, all of the values for repeated keys are kept.
+func DecodeMetadata(buf []byte) (Metadata, error) {
+	var out Metadata
+	var key, value []byte
+	var ok bool
+	var err error
+
+	for len(buf) > 0 {
+		buf, key, value, ok, err = readEntry(buf)
+		if err != nil {
+			return nil, err
+		} else if !ok {
+			return nil, errs.New("invalid data")
+		}
+		if out == nil {
+			out = make(Metadata)
+		}
+		out.Append(string(key), string(value))
+	}
+
+	return out, nil
+}
+
 type metadataKey struct{}
 
 // Add associates a key/value pair on the context.
@@ -66,3 +103,127 @@ func Get(ctx context.Context) (map[string]string, bool) {
 	metadata, ok := ctx.Value(metadataKey{}).(map[string]string)
 	return metadata, ok
 }
+
+// Metadata is a set of key/value pairs. Keys are case-insensitive, and are
+// stored in lower case, and each key may be associated with multiple values.
+type Metadata map[string][]string
+
+// Get returns the values associated with the key.
+func (md Metadata) Get(key string) []string {
+	return md[strings.ToLower(key)]
+}
+
+// Set replaces the values associated with the key.
+func (md Metadata) Set(key string, values ...string) {
+	md[strings.ToLower(key)] = values
+}
+
+// Append adds the values to the list associated with the key.
+func (md Metadata) Append(key string, values ...string) {
+	key = strings.ToLower(key)
+	md[key] = append(md[key], values...)
+}
+
+// Clone returns a deep copy of the metadata.
+func (md Metadata) Clone() Metadata {
+	out := make(Metadata, len(md))
+	for key, values := range md {
+		out[key] = append([]string(nil), values...)
+	}
+	return out
+}
+
+type outgoingKey struct{}
+
+// WithOutgoingMetadata returns a context that will send the metadata along
+// with any RPCs issued with it. The metadata is added to any outgoing metadata
+// already associated with the context.
+func WithOutgoingMetadata(ctx context.Context, md Metadata) context.Context {
+	out, ok := OutgoingMetadataFromContext(ctx)
+	if ok {
+		out = out.Clone()
+	} else {
+		out = make(Metadata, len(md))
+	}
+	for key, values := range md {
+		out.Append(key, values...)
+	}
+	return context.WithValue(ctx, outgoingKey{}, out)
+}
+
+// OutgoingMetadataFromContext returns the metadata that will be sent along with
+// any RPCs issued with the context. It should not be modified.
+func OutgoingMetadataFromContext(ctx context.Context) (Metadata, bool) {
+	md, ok := ctx.Value(outgoingKey{}).(Metadata)
+	return md, ok
+}
+
+// TimeoutKey is the reserved metadata key used to send the time remaining
+// before the deadline of an RPC's context to the remote, which applies it to
+// the context of the stream it creates. It is removed from the received
+// metadata.
+const TimeoutKey = "drpc-timeout"
+
+// CompressionKey is the reserved metadata key used to tell the remote the name
+// of the drpc.Compressor that can decompress messages sent back on the stream.
+// Servers only compress messages for clients that send it, and clients only
+// compress messages once the server has sent them a compressed message. It is
+// removed from the received metadata.
+const CompressionKey = "drpc-compression"
+
+// EncodingKey is the reserved metadata key used by clients to list the names
+// of the encodings they can use for the messages of an rpc, in order of
+// preference, and by servers in the header of the stream to tell the client
+// the one they selected. See drpcenc.Named.
+const EncodingKey = "drpc-encoding"
+
+// HelloKey is the reserved metadata key used by clients to announce their
+// protocol version and features to the server along with their first invoke.
+// Its value is the hex encoding of the drpcwire.Hello. Servers that support the
+// handshake answer it with a drpcwire.KindHello, and it is removed from the
+// received metadata.
+const HelloKey = "drpc-hello"
+
+type incomingKey struct{}
+
+// WithIncomingMetadata returns a context that has the metadata associated with
+// it as if it were received from a remote.
+func WithIncomingMetadata(ctx context.Context, md Metadata) context.Context {
+	return context.WithValue(ctx, incomingKey{}, md)
+}
+
+// MetadataFromContext returns the metadata received from the remote for the
+// RPC the context belongs to. It should not be modified.
+func MetadataFromContext(ctx context.Context) (Metadata, bool) {
+	md, ok := ctx.Value(incomingKey{}).(Metadata)
+	return md, ok
+}
+
+// StreamKey is used by the context of a stream to return the stream, so that
+// SendHeader and SetTrailer can reach it.
+type StreamKey struct{}
+
+// SendHeader sends the metadata to the remote right away as the header of the
+// stream the context belongs to, such as the stream of an rpc being handled by
+// a server. It returns an error if the stream does not support headers, or if
+// a header or a message has already been sent on it.
+func SendHeader(ctx context.Context, md Metadata) error {
+	st, ok := ctx.Value(StreamKey{}).(interface{ SendHeader(Metadata) error })
+	if !ok {
+		return errs.New("context does not belong to a stream that supports headers")
+	}
+	return st.SendHeader(md)
+}
+
+// SetTrailer adds the metadata to the trailer that is sent to the remote after
+// the last message of the stream the context belongs to, such as the stream of
+// an rpc being handled by a server. It returns an error if the stream does not
+// support trailers.
+func SetTrailer(ctx context.Context, md Metadata) error {
+	st, ok := ctx.Value(StreamKey{}).(interface{ SetTrailer(Metadata) })
+	if !ok {
+		return errs.New("context does not belong to a stream that supports trailers")
+	}
+	st.SetTrailer(md)
+	return nil
+}
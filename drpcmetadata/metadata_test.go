@@ -77,3 +77,72 @@ func TestDecode(t *testing.T) {
 		assert.DeepEqual(t, metadata, map[string]string{"test": "a"})
 	})
 }
+
+func TestMetadata(t *testing.T) {
+	md := make(Metadata)
+	md.Append("Foo", "a")
+	md.Append("FOO", "b")
+	md.Set("Bar", "c")
+
+	assert.DeepEqual(t, md.Get("foo"), []string{"a", "b"})
+	assert.DeepEqual(t, md.Get("fOo"), []string{"a", "b"})
+	assert.DeepEqual(t, md.Get("bar"), []string{"c"})
+	assert.Nil(t, md.Get("baz"))
+
+	clone := md.Clone()
+	clone.Append("foo", "c")
+	assert.DeepEqual(t, md.Get("foo"), []string{"a", "b"})
+}
+
+func TestOutgoingMetadata(t *testing.T) {
+	ctx := context.Background()
+
+	_, ok := OutgoingMetadataFromContext(ctx)
+	assert.That(t, !ok)
+
+	ctx1 := WithOutgoingMetadata(ctx, Metadata{"Foo": {"a"}})
+	ctx2 := WithOutgoingMetadata(ctx1, Metadata{"foo": {"b"}, "bar": {"c"}})
+
+	md, ok := OutgoingMetadataFromContext(ctx1)
+	assert.That(t, ok)
+	assert.DeepEqual(t, md, Metadata{"foo": {"a"}})
+
+	md, ok = OutgoingMetadataFromContext(ctx2)
+	assert.That(t, ok)
+	assert.DeepEqual(t, md, Metadata{"foo": {"a", "b"}, "bar": {"c"}})
+
+	_, ok = MetadataFromContext(ctx2)
+	assert.That(t, !ok)
+}
+
+func TestEncodeMetadata(t *testing.T) {
+	t.Run("Empty Metadata", func(t *testing.T) {
+		buf, err := EncodeMetadata(nil, nil)
+		assert.Nil(t, buf)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Round Trip", func(t *testing.T) {
+		buf, err := EncodeMetadata(nil, Metadata{
+			"Key": {"a", "b"},
+			"foo": {"c"},
+		})
+		assert.NoError(t, err)
+
+		md, err := DecodeMetadata(buf)
+		assert.NoError(t, err)
+		assert.DeepEqual(t, md, Metadata{
+			"key": {"a", "b"},
+			"foo": {"c"},
+		})
+	})
+
+	t.Run("Compatible With Decode", func(t *testing.T) {
+		buf, err := Encode(nil, map[string]string{"test": "a"})
+		assert.NoError(t, err)
+
+		md, err := DecodeMetadata(buf)
+		assert.NoError(t, err)
+		assert.DeepEqual(t, md, Metadata{"test": {"a"}})
+	})
+}
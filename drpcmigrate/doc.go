@@ -4,4 +4,7 @@
 // Package drpcmigrate provides tools to support drpc concurrently alongside gRPC
 // on the same ports.
 // See the grpc_and_drpc example in the examples folder for expected usage.
+//
+// Clients that do not send a header can still share a port with HTTP by
+// routing on what they send first with RouteFunc, IsDRPC and IsHTTP.
 package drpcmigrate
@@ -0,0 +1,52 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcmigrate
+
+import (
+	"bytes"
+
+	"storj.io/drpc/drpcwire"
+)
+
+// httpMethods are the starts of the request lines HTTP clients send, including
+// the connection preface of HTTP/2 with prior knowledge.
+var httpMethods = [][]byte{
+	[]byte("GET "), []byte("HEAD "), []byte("POST "), []byte("PUT "),
+	[]byte("DELETE "), []byte("CONNECT "), []byte("OPTIONS "), []byte("TRACE "),
+	[]byte("PATCH "), []byte("PRI "),
+}
+
+// IsHTTP returns true if the prefix is the start of an HTTP request. It is
+// meant to be passed to RouteFunc of a ListenMux with a prefix length of at
+// least 4 bytes, which is enough to tell the methods apart.
+func IsHTTP(prefix []byte) bool {
+	if len(prefix) == 0 {
+		return false
+	}
+	for _, method := range httpMethods {
+		if len(prefix) <= len(method) && bytes.HasPrefix(method, prefix) {
+			return true
+		} else if bytes.HasPrefix(prefix, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsDRPC returns true if the prefix is the start of what a drpc client sends
+// on a new connection without a header like DRPCHeader. It only looks at the
+// first byte, which can not be the start of an HTTP request, a TLS handshake
+// or DRPCHeader, so it may be passed to RouteFunc of a ListenMux with any
+// prefix length.
+func IsDRPC(prefix []byte) bool {
+	if len(prefix) == 0 {
+		return false
+	}
+	switch drpcwire.Kind((prefix[0] & 0b01111110) >> 1) {
+	case drpcwire.KindInvoke, drpcwire.KindInvokeMetadata, drpcwire.KindPing:
+		return true
+	default:
+		return false
+	}
+}
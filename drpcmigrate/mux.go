@@ -26,12 +26,19 @@ type ListenMux struct {
 
 	mu     sync.Mutex
 	routes map[string]*listener
+	funcs  []funcRoute
 
 	once sync.Once
 	done chan struct{}
 	err  error
 }
 
+// funcRoute is a route added by RouteFunc.
+type funcRoute struct {
+	match func(prefix []byte) bool
+	lis   *listener
+}
+
 // NewListenMux creates a ListenMux that reads the prefixLen bytes from any connections
 // Accepted by the passed in listener and dispatches to the appropriate route.
 func NewListenMux(base net.Listener, prefixLen int) *ListenMux {
@@ -52,7 +59,9 @@ func NewListenMux(base net.Listener, prefixLen int) *ListenMux {
 // set up the routes
 //
 
-// Default returns the net.Listener that is used if no route matches.
+// Default returns the net.Listener that is used if no route matches. The
+// connections it returns still have the prefix to be read. Closing it causes
+// connections that match no route to be closed.
 func (m *ListenMux) Default() net.Listener { return m.def }
 
 // Route returns a listener that will be used if the first bytes are the given prefix. The
@@ -74,6 +83,21 @@ func (m *ListenMux) Route(prefix string) net.Listener {
 	return lis
 }
 
+// RouteFunc returns a listener that will be used if match returns true for
+// the first bytes and they are not a prefix passed to Route. Unlike with
+// Route, the connections it returns still have the prefix to be read, so it
+// can be used to detect protocols like HTTP that have no header of their own:
+// see IsHTTP and IsDRPC. The funcs are checked in the order they were added.
+func (m *ListenMux) RouteFunc(match func(prefix []byte) bool) net.Listener {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	lis := newListener(m.addr)
+	m.funcs = append(m.funcs, funcRoute{match: match, lis: lis})
+	go m.monitorFuncListener(lis)
+	return lis
+}
+
 //
 // run the muxer
 //
@@ -95,6 +119,9 @@ func (m *ListenMux) Run(ctx context.Context) error {
 	for _, lis := range m.routes {
 		<-lis.done
 	}
+	for _, route := range m.funcs {
+		<-route.lis.done
+	}
 
 	_ = m.def.Close()
 	<-m.def.done
@@ -125,7 +152,9 @@ func (m *ListenMux) monitorBase() {
 	}
 }
 
-func (m *ListenMux) monitorListener(prefix string, lis *listener) {
+// waitListener waits for the listener to be closed, closing it if the mux is
+// done first.
+func (m *ListenMux) waitListener(lis *listener) {
 	select {
 	case <-m.done:
 		lis.once.Do(func() {
@@ -138,11 +167,27 @@ func (m *ListenMux) monitorListener(prefix string, lis *listener) {
 		})
 	case <-lis.done:
 	}
+}
+
+func (m *ListenMux) monitorListener(prefix string, lis *listener) {
+	m.waitListener(lis)
 	m.mu.Lock()
 	delete(m.routes, prefix)
 	m.mu.Unlock()
 }
 
+func (m *ListenMux) monitorFuncListener(lis *listener) {
+	m.waitListener(lis)
+	m.mu.Lock()
+	for i, route := range m.funcs {
+		if route.lis == lis {
+			m.funcs = append(m.funcs[:i:i], m.funcs[i+1:]...)
+			break
+		}
+	}
+	m.mu.Unlock()
+}
+
 func (m *ListenMux) routeConn(conn net.Conn) {
 	buf := make([]byte, m.prefixLen)
 	if _, err := io.ReadFull(conn, buf); err != nil {
@@ -155,6 +200,12 @@ func (m *ListenMux) routeConn(conn net.Conn) {
 	lis, ok := m.routes[string(buf)]
 	if !ok {
 		lis = m.def
+		for _, route := range m.funcs {
+			if route.match(buf) {
+				lis = route.lis
+				break
+			}
+		}
 		conn = newPrefixConn(buf, conn)
 	}
 	m.mu.Unlock()
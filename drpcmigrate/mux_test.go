@@ -7,11 +7,18 @@ import (
 	"context"
 	"io"
 	"net"
+	"net/http"
 	"testing"
 	"time"
 
 	"github.com/zeebo/assert"
 	"github.com/zeebo/errs"
+
+	"storj.io/drpc"
+	"storj.io/drpc/drpcconn"
+	"storj.io/drpc/drpcserver"
+	"storj.io/drpc/drpctest"
+	"storj.io/drpc/drpcwire"
 )
 
 func TestMux(t *testing.T) {
@@ -134,6 +141,95 @@ func TestMuxAcceptError(t *testing.T) {
 	assert.Equal(t, mux.Run(context.Background()), err)
 }
 
+func TestMux_HTTPAndDRPC(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	mux := NewListenMux(lis, 4)
+	drpcLis := mux.RouteFunc(IsDRPC)
+	httpLis := mux.RouteFunc(IsHTTP)
+	assert.NoError(t, mux.Default().Close())
+
+	muxErrs := make(chan error, 1)
+	go func() { muxErrs <- mux.Run(ctx) }()
+
+	srv := drpcserver.New(handlerFunc(func(stream drpc.Stream, rpc string) error {
+		var in []byte
+		if err := stream.MsgRecv(&in, drpctest.ByteEncoding{}); err != nil {
+			return err
+		}
+		out := append([]byte(rpc+":"), in...)
+		return stream.MsgSend(&out, drpctest.ByteEncoding{})
+	}))
+	go func() { _ = srv.Serve(ctx, drpcLis) }()
+
+	hsrv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = io.WriteString(w, "healthy")
+	})}
+	go func() { _ = hsrv.Serve(httpLis) }()
+	defer func() { _ = hsrv.Close() }()
+
+	{ // drpc clients reach the drpc server
+		raw, err := net.Dial("tcp", lis.Addr().String())
+		assert.NoError(t, err)
+		conn := drpcconn.New(raw)
+
+		in, out := []byte("data"), []byte(nil)
+		assert.NoError(t, conn.Invoke(ctx, "rpc", drpctest.ByteEncoding{}, &in, &out))
+		assert.Equal(t, string(out), "rpc:data")
+		assert.NoError(t, conn.Close())
+	}
+
+	{ // http clients reach the http server
+		resp, err := http.Get("http://" + lis.Addr().String() + "/health")
+		assert.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		assert.NoError(t, resp.Body.Close())
+		assert.Equal(t, string(body), "healthy")
+	}
+
+	{ // anything else is closed
+		raw, err := net.Dial("tcp", lis.Addr().String())
+		assert.NoError(t, err)
+		_, err = raw.Write([]byte("\x16\x03\x01\x00"))
+		assert.NoError(t, err)
+		_, err = raw.Read(make([]byte, 1))
+		assert.Equal(t, err, io.EOF)
+		assert.NoError(t, raw.Close())
+	}
+
+	cancel()
+	assert.NoError(t, <-muxErrs)
+}
+
+func TestIsHTTPAndIsDRPC(t *testing.T) {
+	for _, prefix := range []string{"GET ", "POST", "PUT ", "HEAD", "DELE", "PRI "} {
+		assert.That(t, IsHTTP([]byte(prefix)))
+		assert.That(t, !IsDRPC([]byte(prefix)))
+	}
+	for _, prefix := range []string{"", "GETS", "DRPC", "\x16\x03\x01\x00", "\x03\x01\x01\x03"} {
+		assert.That(t, !IsHTTP([]byte(prefix)))
+	}
+	for _, prefix := range []string{"", DRPCHeader, "\x16\x03\x01\x00"} {
+		assert.That(t, !IsDRPC([]byte(prefix)))
+	}
+
+	// the first frames a drpc client may send.
+	for _, kind := range []drpcwire.Kind{drpcwire.KindInvoke, drpcwire.KindInvokeMetadata, drpcwire.KindPing} {
+		frame := drpcwire.AppendFrame(nil, drpcwire.Frame{Kind: kind, ID: drpcwire.ID{Stream: 1, Message: 1}, Data: []byte("rpc")})
+		assert.That(t, IsDRPC(frame))
+		assert.That(t, !IsHTTP(frame))
+	}
+}
+
+type handlerFunc func(stream drpc.Stream, rpc string) error
+
+func (fn handlerFunc) HandleRPC(stream drpc.Stream, rpc string) error { return fn(stream, rpc) }
+
 //
 // fake listener
 //
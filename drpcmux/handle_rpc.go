@@ -4,21 +4,28 @@
 package drpcmux
 
 import (
+	"context"
 	"reflect"
 
 	"github.com/zeebo/errs"
 
 	"storj.io/drpc"
+	"storj.io/drpc/drpcerr"
+	"storj.io/drpc/drpcinterceptor"
 )
 
-// HandleRPC handles the rpc that has been requested by the stream.
+// HandleRPC handles the rpc that has been requested by the stream. If the rpc
+// is not registered and there is no UnknownHandler, it fails with an error
+// that has the drpcerr.Unimplemented code.
 func (m *Mux) HandleRPC(stream drpc.Stream, rpc string) (err error) {
-	data, ok := m.rpcs[rpc]
-	if !ok {
-		return drpc.ProtocolError.New("unknown rpc: %q", rpc)
+	data, icpt, ok := m.lookup(rpc)
+	if !ok && m.opts.UnknownHandler != nil {
+		return m.opts.UnknownHandler(stream.Context(), rpc, stream)
+	} else if !ok {
+		return drpcerr.WithCode(drpc.ProtocolError.New("unknown rpc: %q", rpc), drpcerr.Unimplemented)
 	}
 
-	in := interface{}(stream)
+	var in drpc.Message
 	if data.in1 != streamType {
 		msg, ok := reflect.New(data.in1.Elem()).Interface().(drpc.Message)
 		if !ok {
@@ -30,7 +37,21 @@ func (m *Mux) HandleRPC(stream drpc.Stream, rpc string) (err error) {
 		in = msg
 	}
 
-	out, err := data.receiver(data.srv, stream.Context(), in, stream)
+	switch {
+	case icpt == nil:
+		icpt = m.opts.Interceptor
+	case m.opts.Interceptor != nil:
+		icpt = drpcinterceptor.ChainServerInterceptors(m.opts.Interceptor, icpt)
+	}
+
+	var out drpc.Message
+	if icpt != nil {
+		ctx := context.WithValue(stream.Context(), methodKey{}, data.info(rpc))
+		out, err = icpt(ctx, rpc, in, stream, data.handle)
+	} else {
+		out, err = data.handle(stream.Context(), rpc, in, stream)
+	}
+
 	switch {
 	case err != nil:
 		return errs.Wrap(err)
@@ -40,3 +61,28 @@ func (m *Mux) HandleRPC(stream drpc.Stream, rpc string) (err error) {
 		return stream.CloseSend()
 	}
 }
+
+// handle invokes the receiver with the provided context and arguments. If the
+// context is not the stream's context, the stream is wrapped so that anything
+// using the stream observes the provided context.
+func (data rpcData) handle(ctx context.Context, rpc string, in drpc.Message, stream drpc.Stream) (drpc.Message, error) {
+	if ctx != stream.Context() {
+		stream = &contextStream{Stream: stream, ctx: ctx}
+	}
+
+	in1 := interface{}(stream)
+	if data.in1 != streamType {
+		in1 = in
+	}
+
+	return data.receiver(data.srv, ctx, in1, stream)
+}
+
+// contextStream is a drpc.Stream that overrides the context it returns.
+type contextStream struct {
+	drpc.Stream
+	ctx context.Context
+}
+
+// Context returns the overridden context.
+func (c *contextStream) Context() context.Context { return c.ctx }
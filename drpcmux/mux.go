@@ -4,23 +4,56 @@
 package drpcmux
 
 import (
+	"context"
 	"reflect"
+	"sort"
+	"sync"
 
 	"github.com/zeebo/errs"
 
 	"storj.io/drpc"
+	"storj.io/drpc/drpcinterceptor"
 )
 
+// Options controls configuration settings for a mux.
+type Options struct {
+	// Interceptor is called around every RPC the mux dispatches, after any
+	// input message has been decoded. It is not called if nil. The context it
+	// is passed carries the MethodInfo of the rpc: see MethodFromContext. It
+	// runs outside of any interceptors added for the rpc with Intercept.
+	Interceptor drpc.ServerInterceptor
+
+	// UnknownHandler, if set, handles any rpc that is not registered with
+	// the mux instead of it failing. It is passed the stream without any
+	// message having been received from it, so it can, for example, forward
+	// the raw messages to another server. The Interceptor is not called
+	// around it.
+	UnknownHandler func(ctx context.Context, rpc string, stream drpc.Stream) error
+}
+
 // Mux is an implementation of Handler to serve drpc connections to the
-// appropriate Receivers registered by Descriptions.
+// appropriate Receivers registered by Descriptions. Descriptions may be
+// registered and unregistered while it is serving.
 type Mux struct {
+	opts Options
+
+	mu   sync.RWMutex
 	rpcs map[string]rpcData
+	ints map[string]drpc.ServerInterceptor
 }
 
 // New constructs a new Mux.
 func New() *Mux {
+	return NewWithOptions(Options{})
+}
+
+// NewWithOptions constructs a new Mux using the provided options to tune
+// how RPCs are dispatched.
+func NewWithOptions(opts Options) *Mux {
 	return &Mux{
+		opts: opts,
 		rpcs: make(map[string]rpcData),
+		ints: make(map[string]drpc.ServerInterceptor),
 	}
 }
 
@@ -36,26 +69,94 @@ type rpcData struct {
 	in1      reflect.Type
 	in2      reflect.Type
 	unitary  bool
+	meta     map[string]interface{}
 }
 
 // Register associates the RPCs described by the description in the server.
-// It returns an error if there was a problem registering it.
+// If the description is a drpc.MetadataDescription, the metadata of each rpc
+// is kept in its MethodInfo. It returns an error if there was a problem
+// registering it, in which case none of the RPCs are registered.
 func (m *Mux) Register(srv interface{}, desc drpc.Description) error {
+	mdesc, _ := desc.(drpc.MetadataDescription)
 	n := desc.NumMethods()
+	rpcs := make(map[string]rpcData, n)
 	for i := 0; i < n; i++ {
 		rpc, enc, receiver, method, ok := desc.Method(i)
 		if !ok {
 			return errs.New("Description returned invalid method for index %d", i)
 		}
-		if err := m.registerOne(srv, rpc, enc, receiver, method); err != nil {
+		data, err := newRPCData(srv, enc, receiver, method)
+		if err != nil {
 			return err
 		}
+		if mdesc != nil {
+			data.meta = mdesc.MethodMetadata(i)
+		}
+		rpcs[rpc] = data
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for rpc, data := range rpcs {
+		m.rpcs[rpc] = data
 	}
 	return nil
 }
 
-// registerOne does the work to register a single rpc.
-func (m *Mux) registerOne(srv interface{}, rpc string, enc drpc.Encoding, receiver drpc.Receiver, method interface{}) error {
+// Unregister removes the RPCs described by the description from the server.
+// Calls to them that are already running are allowed to finish, and later
+// calls fail with an error that has the drpcerr.Unimplemented code.
+func (m *Mux) Unregister(desc drpc.Description) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n := desc.NumMethods()
+	for i := 0; i < n; i++ {
+		rpc, _, _, _, ok := desc.Method(i)
+		if ok {
+			delete(m.rpcs, rpc)
+		}
+	}
+}
+
+// Intercept adds the interceptor around the rpc, inside of the Interceptor in
+// the options and of any interceptors added for the rpc before it. It does not
+// matter if the rpc is registered yet, and the interceptor is kept if the rpc
+// is unregistered and registered again.
+func (m *Mux) Intercept(rpc string, interceptor drpc.ServerInterceptor) {
+	if interceptor == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.ints[rpc] = drpcinterceptor.ChainServerInterceptors(m.ints[rpc], interceptor)
+}
+
+// InterceptDescription is like Intercept for every rpc described by the
+// description.
+func (m *Mux) InterceptDescription(desc drpc.Description, interceptor drpc.ServerInterceptor) {
+	n := desc.NumMethods()
+	for i := 0; i < n; i++ {
+		if rpc, _, _, _, ok := desc.Method(i); ok {
+			m.Intercept(rpc, interceptor)
+		}
+	}
+}
+
+// lookup returns the data and interceptor for the rpc and if it is registered.
+func (m *Mux) lookup(rpc string) (data rpcData, icpt drpc.ServerInterceptor, ok bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	data, ok = m.rpcs[rpc]
+	return data, m.ints[rpc], ok
+}
+
+// newRPCData does the work to check and describe a single rpc.
+func newRPCData(srv interface{}, enc drpc.Encoding, receiver drpc.Receiver, method interface{}) (rpcData, error) {
 	data := rpcData{srv: srv, enc: enc, receiver: receiver}
 
 	switch mt := reflect.TypeOf(method); {
@@ -64,14 +165,14 @@ func (m *Mux) registerOne(srv interface{}, rpc string, enc drpc.Encoding, receiv
 		data.unitary = true
 		data.in1 = mt.In(2)
 		if !data.in1.Implements(messageType) {
-			return errs.New("input argument not a drpc message: %v", data.in1)
+			return data, errs.New("input argument not a drpc message: %v", data.in1)
 		}
 
 	// unitary input, stream output
 	case mt.NumIn() == 3:
 		data.in1 = mt.In(1)
 		if !data.in1.Implements(messageType) {
-			return errs.New("input argument not a drpc message: %v", data.in1)
+			return data, errs.New("input argument not a drpc message: %v", data.in1)
 		}
 		data.in2 = streamType
 
@@ -81,9 +182,59 @@ func (m *Mux) registerOne(srv interface{}, rpc string, enc drpc.Encoding, receiv
 
 	// code gen bug?
 	default:
-		return errs.New("unknown method type: %v", mt)
+		return data, errs.New("unknown method type: %v", mt)
 	}
 
-	m.rpcs[rpc] = data
-	return nil
+	return data, nil
+}
+
+// MethodInfo describes an rpc registered with a Mux.
+type MethodInfo struct {
+	// RPC is the name of the rpc.
+	RPC string
+
+	// Unitary is true if the rpc takes and returns a single message rather
+	// than using a stream.
+	Unitary bool
+
+	// Metadata is the metadata of the rpc from the drpc.MetadataDescription
+	// it was registered with. It is nil if the rpc has none, and must not be
+	// modified.
+	Metadata map[string]interface{}
+}
+
+type methodKey struct{}
+
+// MethodFromContext returns the MethodInfo of the rpc being served with the
+// context passed to the Interceptor of a Mux. Interceptors use it to tell
+// streams from unitary rpcs, which they cannot do from the input message:
+// rpcs that stream their output still have a single input message.
+func MethodFromContext(ctx context.Context) (MethodInfo, bool) {
+	info, ok := ctx.Value(methodKey{}).(MethodInfo)
+	return info, ok
+}
+
+// Method returns information about the rpc if it is registered with the mux.
+func (m *Mux) Method(rpc string) (MethodInfo, bool) {
+	data, _, ok := m.lookup(rpc)
+	return data.info(rpc), ok
+}
+
+// Methods returns information about every rpc registered with the mux, sorted
+// by name.
+func (m *Mux) Methods() []MethodInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	methods := make([]MethodInfo, 0, len(m.rpcs))
+	for rpc, data := range m.rpcs {
+		methods = append(methods, data.info(rpc))
+	}
+	sort.Slice(methods, func(i, j int) bool { return methods[i].RPC < methods[j].RPC })
+	return methods
+}
+
+// info returns the MethodInfo of the rpc.
+func (data rpcData) info(rpc string) MethodInfo {
+	return MethodInfo{RPC: rpc, Unitary: data.unitary, Metadata: data.meta}
 }
@@ -0,0 +1,258 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcmux_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/zeebo/assert"
+
+	"storj.io/drpc"
+	"storj.io/drpc/drpcerr"
+	"storj.io/drpc/drpcmetadata"
+	"storj.io/drpc/drpcmux"
+	"storj.io/drpc/drpcpipe"
+	"storj.io/drpc/drpcstatus"
+	"storj.io/drpc/drpctest"
+)
+
+func TestMux_Unregister(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	started, release := make(chan struct{}), make(chan struct{})
+	mux := drpcmux.NewWithOptions(drpcmux.Options{
+		Interceptor: func(ctx context.Context, rpc string, in drpc.Message, stream drpc.Stream, next drpc.ServerHandler) (drpc.Message, error) {
+			if string(*in.(*[]byte)) == "block" {
+				close(started)
+				<-release
+			}
+			return next(ctx, rpc, in, stream)
+		},
+	})
+	desc := nameDescription("test.Service")
+	assert.NoError(t, mux.Register(nameServer("a"), desc))
+
+	conn, cleanup := drpcpipe.New(mux)
+	defer cleanup()
+
+	call := func(in string) (string, error) {
+		msg, out := []byte(in), []byte(nil)
+		err := conn.Invoke(ctx, "/test.Service/Name", drpctest.ByteEncoding{}, &msg, &out)
+		return string(out), err
+	}
+
+	// a call that is running when the rpc is unregistered finishes.
+	errch := make(chan error, 1)
+	ctx.Run(func(ctx context.Context) {
+		_, err := call("block")
+		errch <- err
+	})
+	<-started
+	mux.Unregister(desc)
+	close(release)
+	assert.NoError(t, <-errch)
+
+	// later calls are unimplemented until it is registered again.
+	_, err := call("")
+	assert.Equal(t, drpcerr.Code(err), uint64(drpcerr.Unimplemented))
+	assert.Equal(t, len(mux.Methods()), 0)
+
+	// like rpcs that were never registered.
+	var in, out []byte
+	err = conn.Invoke(ctx, "/test.Other/Name", drpctest.ByteEncoding{}, &in, &out)
+	assert.Equal(t, drpcerr.Code(err), uint64(drpcerr.Unimplemented))
+	_, ok := mux.Method("/test.Service/Name")
+	assert.That(t, !ok)
+
+	assert.NoError(t, mux.Register(nameServer("b"), desc))
+	info, ok := mux.Method("/test.Service/Name")
+	assert.That(t, ok && info.Unitary)
+	name, err := call("")
+	assert.NoError(t, err)
+	assert.Equal(t, name, "b")
+}
+
+func TestMux_RegisterConcurrent(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	mux := drpcmux.New()
+	desc := nameDescription("test.Service")
+	assert.NoError(t, mux.Register(nameServer("a"), desc))
+
+	conn, cleanup := drpcpipe.New(mux)
+	defer cleanup()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		ctx.Run(func(ctx context.Context) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				var in, out []byte
+				err := conn.Invoke(ctx, "/test.Service/Name", drpctest.ByteEncoding{}, &in, &out)
+				if err != nil {
+					assert.Equal(t, drpcerr.Code(err), uint64(drpcerr.Unimplemented))
+				} else {
+					assert.Equal(t, string(out), "a")
+				}
+			}
+		})
+	}
+
+	done := make(chan struct{})
+	ctx.Run(func(ctx context.Context) {
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			mux.Unregister(desc)
+			_ = mux.Methods()
+			assert.NoError(t, mux.Register(nameServer("a"), desc))
+		}
+	})
+
+	wg.Wait()
+	close(done)
+}
+
+func TestMux_UnknownHandler(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	mux := drpcmux.NewWithOptions(drpcmux.Options{
+		UnknownHandler: func(ctx context.Context, rpc string, stream drpc.Stream) error {
+			// the raw message is available to forward.
+			data, err := stream.(interface{ RawRecv() ([]byte, error) }).RawRecv()
+			if err != nil {
+				return err
+			}
+			out := []byte(rpc + " " + string(data))
+			return stream.MsgSend(&out, drpctest.ByteEncoding{})
+		},
+	})
+	assert.NoError(t, mux.Register(nameServer("a"), nameDescription("test.Service")))
+
+	conn, cleanup := drpcpipe.New(mux)
+	defer cleanup()
+
+	call := func(rpc string) string {
+		in, out := []byte("in"), []byte(nil)
+		assert.NoError(t, conn.Invoke(ctx, rpc, drpctest.ByteEncoding{}, &in, &out))
+		return string(out)
+	}
+
+	assert.Equal(t, call("/test.Service/Name"), "a")
+	assert.Equal(t, call("/test.Unknown/Method"), "/test.Unknown/Method in")
+}
+
+func TestMux_Intercept(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	var trace []string
+	record := func(name string) drpc.ServerInterceptor {
+		return func(ctx context.Context, rpc string, in drpc.Message, stream drpc.Stream, next drpc.ServerHandler) (drpc.Message, error) {
+			trace = append(trace, name+" "+rpc)
+			return next(ctx, rpc, in, stream)
+		}
+	}
+
+	mux := drpcmux.NewWithOptions(drpcmux.Options{Interceptor: record("global")})
+	assert.NoError(t, mux.Register(nameServer("a"), nameDescription("test.Service")))
+	assert.NoError(t, mux.Register(nameServer("b"), nameDescription("test.Other")))
+	mux.InterceptDescription(nameDescription("test.Service"), record("first"))
+	mux.Intercept("/test.Service/Name", record("second"))
+
+	conn, cleanup := drpcpipe.New(mux)
+	defer cleanup()
+
+	call := func(rpc string) string {
+		var in, out []byte
+		assert.NoError(t, conn.Invoke(ctx, rpc, drpctest.ByteEncoding{}, &in, &out))
+		return string(out)
+	}
+
+	// the method interceptors run inside of the global one, in order.
+	assert.Equal(t, call("/test.Service/Name"), "a")
+	assert.DeepEqual(t, trace, []string{
+		"global /test.Service/Name",
+		"first /test.Service/Name",
+		"second /test.Service/Name",
+	})
+
+	// and only for their method.
+	trace = nil
+	assert.Equal(t, call("/test.Other/Name"), "b")
+	assert.DeepEqual(t, trace, []string{"global /test.Other/Name"})
+}
+
+// authDescription describes a service with a "Public" rpc that is marked as
+// public in its metadata, and a "Private" rpc that has no metadata, both
+// served by a nameServer.
+type authDescription struct{}
+
+func (authDescription) NumMethods() int { return 2 }
+
+func (authDescription) Method(n int) (string, drpc.Encoding, drpc.Receiver, interface{}, bool) {
+	if n < 0 || n > 1 {
+		return "", nil, nil, nil, false
+	}
+	return [...]string{"/test.Auth/Public", "/test.Auth/Private"}[n], drpctest.ByteEncoding{},
+		func(srv interface{}, ctx context.Context, in1, in2 interface{}) (drpc.Message, error) {
+			return srv.(nameServer).Name(ctx, in1.(*[]byte))
+		}, nameServer.Name, true
+}
+
+func (authDescription) MethodMetadata(n int) map[string]interface{} {
+	if n == 0 {
+		return map[string]interface{}{"public": true}
+	}
+	return nil
+}
+
+func TestMux_MethodMetadata(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	mux := drpcmux.NewWithOptions(drpcmux.Options{
+		Interceptor: func(ctx context.Context, rpc string, in drpc.Message, stream drpc.Stream, next drpc.ServerHandler) (drpc.Message, error) {
+			info, _ := drpcmux.MethodFromContext(ctx)
+			if public, _ := info.Metadata["public"].(bool); !public {
+				if md, _ := drpcmetadata.Get(ctx); md["token"] != "secret" {
+					return nil, drpcstatus.Errorf(drpcstatus.Unauthenticated, "missing token")
+				}
+			}
+			return next(ctx, rpc, in, stream)
+		},
+	})
+	assert.NoError(t, mux.Register(nameServer("a"), authDescription{}))
+
+	info, ok := mux.Method("/test.Auth/Public")
+	assert.That(t, ok)
+	assert.DeepEqual(t, info.Metadata, map[string]interface{}{"public": true})
+	info, ok = mux.Method("/test.Auth/Private")
+	assert.That(t, ok)
+	assert.Equal(t, len(info.Metadata), 0)
+
+	conn, cleanup := drpcpipe.New(mux)
+	defer cleanup()
+
+	call := func(ctx context.Context, rpc string) error {
+		var in, out []byte
+		return conn.Invoke(ctx, rpc, drpctest.ByteEncoding{}, &in, &out)
+	}
+
+	// the public rpc needs no token, but the private one does.
+	assert.NoError(t, call(ctx, "/test.Auth/Public"))
+	err := call(ctx, "/test.Auth/Private")
+	assert.Error(t, err)
+	assert.Equal(t, drpcstatus.CodeFromError(err), drpcstatus.Unauthenticated)
+	assert.NoError(t, call(drpcmetadata.Add(ctx, "token", "secret"), "/test.Auth/Private"))
+}
@@ -0,0 +1,118 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcmux
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"storj.io/drpc"
+	"storj.io/drpc/drpcstatus"
+)
+
+// PrefixMux is an implementation of Handler that dispatches rpcs to other
+// handlers by the prefix of their name, so that separate sets of services,
+// each with their own Options, can be served together. An rpc named
+// "/a/pkg.Service/Method" is handled as "/pkg.Service/Method" by the handler
+// for the "/a" prefix.
+type PrefixMux struct {
+	mu     sync.RWMutex
+	routes []prefixRoute // sorted by decreasing prefix length
+}
+
+type prefixRoute struct {
+	prefix  string
+	handler drpc.Handler
+}
+
+// NewPrefixMux constructs a new PrefixMux.
+func NewPrefixMux() *PrefixMux {
+	return &PrefixMux{}
+}
+
+// Handle dispatches rpcs with the prefix to the handler, replacing any handler
+// previously set for it. A trailing slash on the prefix is ignored, and when
+// prefixes are nested, the longest one that matches is used. It is safe to
+// call while the PrefixMux is serving.
+func (p *PrefixMux) Handle(prefix string, handler drpc.Handler) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.handleLocked(strings.TrimSuffix(prefix, "/"), handler)
+}
+
+// handleLocked sets the handler for the prefix. It must be called with the
+// mutex held.
+func (p *PrefixMux) handleLocked(prefix string, handler drpc.Handler) {
+	for i := range p.routes {
+		if p.routes[i].prefix == prefix {
+			p.routes[i].handler = handler
+			return
+		}
+	}
+
+	p.routes = append(p.routes, prefixRoute{prefix: prefix, handler: handler})
+	sort.SliceStable(p.routes, func(i, j int) bool {
+		return len(p.routes[i].prefix) > len(p.routes[j].prefix)
+	})
+}
+
+// Register associates the RPCs described by the description with the prefix.
+// They are registered with a Mux that is created with default Options by the
+// first call for the prefix. It returns an error if there was a problem
+// registering it, including if a handler that is not such a Mux was already
+// set for the prefix with Handle.
+func (p *PrefixMux) Register(prefix string, srv interface{}, desc drpc.Description) error {
+	mux, err := p.prefixMux(strings.TrimSuffix(prefix, "/"))
+	if err != nil {
+		return err
+	}
+	return mux.Register(srv, desc)
+}
+
+// prefixMux returns the Mux that handles exactly the prefix, creating it if
+// the prefix has no handler.
+func (p *PrefixMux) prefixMux(prefix string) (*Mux, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, route := range p.routes {
+		if route.prefix != prefix {
+			continue
+		}
+		mux, ok := route.handler.(*Mux)
+		if !ok {
+			return nil, drpc.Error.New("prefix %q has a handler that is not a Mux", prefix)
+		}
+		return mux, nil
+	}
+
+	mux := New()
+	p.handleLocked(prefix, mux)
+	return mux, nil
+}
+
+// route returns the handler for the longest prefix of the rpc and the rest of
+// the rpc's name.
+func (p *PrefixMux) route(rpc string) (drpc.Handler, string, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for _, route := range p.routes {
+		if strings.HasPrefix(rpc, route.prefix+"/") {
+			return route.handler, rpc[len(route.prefix):], true
+		}
+	}
+	return nil, "", false
+}
+
+// HandleRPC handles the rpc with the handler for the longest matching prefix.
+func (p *PrefixMux) HandleRPC(stream drpc.Stream, rpc string) (err error) {
+	handler, rest, ok := p.route(rpc)
+	if !ok {
+		return drpcstatus.Errorf(drpcstatus.NotFound, "unknown rpc prefix: %q", rpc)
+	}
+	return handler.HandleRPC(stream, rest)
+}
@@ -0,0 +1,103 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcmux_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/zeebo/assert"
+
+	"storj.io/drpc"
+	"storj.io/drpc/drpcmux"
+	"storj.io/drpc/drpcpipe"
+	"storj.io/drpc/drpcstatus"
+	"storj.io/drpc/drpctest"
+)
+
+// nameServer responds to every rpc with its name.
+type nameServer string
+
+func (s nameServer) Name(ctx context.Context, in *[]byte) (*[]byte, error) {
+	out := []byte(s)
+	return &out, nil
+}
+
+// nameDescription describes a service with a single rpc served by a
+// nameServer.
+type nameDescription string
+
+func (nameDescription) NumMethods() int { return 1 }
+
+func (d nameDescription) Method(n int) (string, drpc.Encoding, drpc.Receiver, interface{}, bool) {
+	if n != 0 {
+		return "", nil, nil, nil, false
+	}
+	return "/" + string(d) + "/Name", drpctest.ByteEncoding{},
+		func(srv interface{}, ctx context.Context, in1, in2 interface{}) (drpc.Message, error) {
+			return srv.(nameServer).Name(ctx, in1.(*[]byte))
+		}, nameServer.Name, true
+}
+
+func TestPrefixMux(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	var intercepted []string
+	b := drpcmux.NewWithOptions(drpcmux.Options{
+		Interceptor: func(ctx context.Context, rpc string, in drpc.Message, stream drpc.Stream, next drpc.ServerHandler) (drpc.Message, error) {
+			intercepted = append(intercepted, rpc)
+			return next(ctx, rpc, in, stream)
+		},
+	})
+
+	mux := drpcmux.NewPrefixMux()
+	assert.NoError(t, mux.Register("/a/", nameServer("a"), nameDescription("test.Service")))
+	assert.NoError(t, mux.Register("/a/", nameServer("a other"), nameDescription("test.Other")))
+	assert.NoError(t, b.Register(nameServer("b"), nameDescription("test.Service")))
+	mux.Handle("/b/", b)
+
+	// a prefix with a handler that is not a Mux cannot be registered to.
+	mux.Handle("/c", drpcmux.NewPrefixMux())
+	assert.Error(t, mux.Register("/c", nameServer("c"), nameDescription("test.Service")))
+
+	conn, cleanup := drpcpipe.New(mux)
+	defer cleanup()
+
+	call := func(rpc string) (string, error) {
+		var in, out []byte
+		err := conn.Invoke(ctx, rpc, drpctest.ByteEncoding{}, &in, &out)
+		return string(out), err
+	}
+
+	{ // rpcs are dispatched to the services registered for their prefix
+		name, err := call("/a/test.Service/Name")
+		assert.NoError(t, err)
+		assert.Equal(t, name, "a")
+
+		name, err = call("/a/test.Other/Name")
+		assert.NoError(t, err)
+		assert.Equal(t, name, "a other")
+
+		name, err = call("/b/test.Service/Name")
+		assert.NoError(t, err)
+		assert.Equal(t, name, "b")
+	}
+
+	{ // only the mux for the prefix intercepts its rpcs
+		assert.DeepEqual(t, intercepted, []string{"/test.Service/Name"})
+	}
+
+	{ // services are not reachable from other prefixes
+		_, err := call("/b/test.Other/Name")
+		assert.Error(t, err)
+	}
+
+	{ // rpcs without a matching prefix are not found
+		for _, rpc := range []string{"/test.Service/Name", "/ab/test.Service/Name", "/d/test.Service/Name"} {
+			_, err := call(rpc)
+			assert.Equal(t, drpcstatus.CodeFromError(err), drpcstatus.NotFound)
+		}
+	}
+}
@@ -0,0 +1,9 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+// Package drpcotel provides OpenTelemetry tracing for drpc clients and servers.
+//
+// It is a separate module so that drpc itself does not depend on
+// OpenTelemetry. The trace context is sent to the server in the outgoing
+// metadata of the rpc.
+package drpcotel
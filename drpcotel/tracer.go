@@ -0,0 +1,157 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcotel
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"storj.io/drpc"
+	"storj.io/drpc/drpcmetadata"
+)
+
+// instrumentationName is the name of the tracer used to create spans.
+const instrumentationName = "storj.io/drpc/drpcotel"
+
+// Options controls configuration settings for a Tracer.
+type Options struct {
+	// TracerProvider is used to create spans. If nil, the global provider is
+	// used.
+	TracerProvider trace.TracerProvider
+
+	// Propagator is used to send the trace context to the server and to
+	// receive it from the client. If nil, the global propagator is used.
+	Propagator propagation.TextMapPropagator
+}
+
+// Tracer creates spans around rpcs. It implements drpc.ClientInterceptor, and
+// its InterceptServer method is a drpc.ServerInterceptor.
+type Tracer struct {
+	tracer trace.Tracer
+	prop   propagation.TextMapPropagator
+}
+
+var _ drpc.ClientInterceptor = (*Tracer)(nil)
+var _ drpc.ServerInterceptor = (*Tracer)(nil).InterceptServer
+
+// New returns a Tracer that uses the global tracer provider and propagator.
+func New() *Tracer {
+	return NewWithOptions(Options{})
+}
+
+// NewWithOptions returns a Tracer using the provided options.
+func NewWithOptions(opts Options) *Tracer {
+	if opts.TracerProvider == nil {
+		opts.TracerProvider = otel.GetTracerProvider()
+	}
+	if opts.Propagator == nil {
+		opts.Propagator = otel.GetTextMapPropagator()
+	}
+	return &Tracer{
+		tracer: opts.TracerProvider.Tracer(instrumentationName),
+		prop:   opts.Propagator,
+	}
+}
+
+// InterceptInvoke starts a client span around the unitary rpc and sends the
+// trace context to the server.
+func (t *Tracer) InterceptInvoke(ctx context.Context, rpc string, enc drpc.Encoding, in, out drpc.Message, next drpc.InvokeFunc) (err error) {
+	ctx, span := t.start(ctx, rpc, trace.SpanKindClient)
+	defer func() { finish(span, err) }()
+
+	return next(t.inject(ctx), rpc, enc, in, out)
+}
+
+// InterceptNewStream starts a client span that lasts until the stream is
+// finished and sends the trace context to the server.
+func (t *Tracer) InterceptNewStream(ctx context.Context, rpc string, enc drpc.Encoding, next drpc.NewStreamFunc) (drpc.Stream, error) {
+	ctx, span := t.start(ctx, rpc, trace.SpanKindClient)
+
+	stream, err := next(t.inject(ctx), rpc, enc)
+	if err != nil {
+		finish(span, err)
+		return nil, err
+	}
+
+	go func() {
+		<-stream.Context().Done()
+		span.End()
+	}()
+	return stream, nil
+}
+
+// InterceptServer starts a server span around the rpc that is a child of any
+// trace context sent by the client.
+func (t *Tracer) InterceptServer(ctx context.Context, rpc string, in drpc.Message, stream drpc.Stream, next drpc.ServerHandler) (out drpc.Message, err error) {
+	if md, ok := drpcmetadata.MetadataFromContext(ctx); ok {
+		ctx = t.prop.Extract(ctx, carrier(md))
+	}
+
+	ctx, span := t.start(ctx, rpc, trace.SpanKindServer)
+	defer func() { finish(span, err) }()
+
+	return next(ctx, rpc, in, stream)
+}
+
+// start starts a span for the rpc.
+func (t *Tracer) start(ctx context.Context, rpc string, kind trace.SpanKind) (context.Context, trace.Span) {
+	name := strings.TrimPrefix(rpc, "/")
+
+	attrs := []attribute.KeyValue{
+		attribute.String("rpc.system", "drpc"),
+	}
+	if service, method, ok := strings.Cut(name, "/"); ok {
+		attrs = append(attrs,
+			attribute.String("rpc.service", service),
+			attribute.String("rpc.method", method),
+		)
+	}
+
+	return t.tracer.Start(ctx, name, trace.WithSpanKind(kind), trace.WithAttributes(attrs...))
+}
+
+// inject adds the trace context to the outgoing metadata of the context.
+func (t *Tracer) inject(ctx context.Context) context.Context {
+	md := make(drpcmetadata.Metadata)
+	t.prop.Inject(ctx, carrier(md))
+	if len(md) == 0 {
+		return ctx
+	}
+	return drpcmetadata.WithOutgoingMetadata(ctx, md)
+}
+
+// finish records any error on the span and ends it.
+func finish(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// carrier adapts metadata to be used by a propagator.
+type carrier drpcmetadata.Metadata
+
+func (c carrier) Get(key string) string {
+	if values := drpcmetadata.Metadata(c).Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func (c carrier) Set(key, value string) { drpcmetadata.Metadata(c).Set(key, value) }
+
+func (c carrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
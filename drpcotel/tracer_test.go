@@ -0,0 +1,100 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcotel
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"storj.io/drpc/drpcconn"
+	"storj.io/drpc/drpchealth"
+	"storj.io/drpc/drpcmux"
+	"storj.io/drpc/drpcpipe"
+)
+
+func TestTracer(t *testing.T) {
+	ctx := context.Background()
+
+	rec := tracetest.NewSpanRecorder()
+	tracer := NewWithOptions(Options{
+		TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)),
+		Propagator:     propagation.TraceContext{},
+	})
+
+	checker := drpchealth.New()
+	mux := drpcmux.NewWithOptions(drpcmux.Options{Interceptor: tracer.InterceptServer})
+	assert.NoError(t, drpchealth.Register(mux, checker))
+
+	conn, cleanup := drpcpipe.NewWithOptions(mux, drpcpipe.Options{
+		Conn: drpcconn.Options{Interceptor: tracer},
+	})
+	defer cleanup()
+	cli := drpchealth.NewClient(conn)
+
+	// spans waits for the client and server spans to end and returns them,
+	// in that order, resetting the recorder.
+	spans := func() (client, server sdktrace.ReadOnlySpan) {
+		t.Helper()
+		ended := rec.Ended()
+		for start := time.Now(); len(ended) < 2 && time.Since(start) < 5*time.Second; {
+			time.Sleep(time.Millisecond)
+			ended = rec.Ended()
+		}
+		assert.Equal(t, len(ended), 2)
+		rec.Reset()
+		for _, span := range ended {
+			if span.SpanKind() == trace.SpanKindClient {
+				client = span
+			} else {
+				server = span
+			}
+		}
+		assert.NotNil(t, client)
+		assert.NotNil(t, server)
+		return client, server
+	}
+
+	{ // the server span is a child of the client span
+		_, err := cli.Check(ctx, "")
+		assert.NoError(t, err)
+
+		client, server := spans()
+		assert.Equal(t, client.Name(), "grpc.health.v1.Health/Check")
+		assert.Equal(t, server.Name(), "grpc.health.v1.Health/Check")
+		assert.Equal(t, server.Parent().SpanID(), client.SpanContext().SpanID())
+		assert.Equal(t, server.SpanContext().TraceID(), client.SpanContext().TraceID())
+		assert.Equal(t, client.Status().Code, codes.Unset)
+		assert.Equal(t, server.Status().Code, codes.Unset)
+	}
+
+	{ // errors set the status of both spans
+		_, err := cli.Check(ctx, "unknown")
+		assert.Error(t, err)
+
+		client, server := spans()
+		assert.Equal(t, client.Status().Code, codes.Error)
+		assert.Equal(t, server.Status().Code, codes.Error)
+	}
+
+	{ // stream spans last until the stream is finished
+		stream, err := cli.Watch(ctx, "")
+		assert.NoError(t, err)
+		_, err = stream.Recv()
+		assert.NoError(t, err)
+		assert.Equal(t, len(rec.Ended()), 0)
+		assert.NoError(t, stream.Close())
+
+		client, server := spans()
+		assert.Equal(t, client.Name(), "grpc.health.v1.Health/Watch")
+		assert.Equal(t, server.Parent().SpanID(), client.SpanContext().SpanID())
+	}
+}
@@ -0,0 +1,12 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+// Package drpcpipe provides in-process connections to drpc handlers.
+//
+// It is intended for tests that want to exercise rpcs without binding to any
+// ports. It is a separate package from drpctest so that the tests of the
+// packages it uses can still use drpctest.
+//
+// Run registers a service implementation with a new server and returns a conn
+// to it that is closed when the test completes.
+package drpcpipe
@@ -0,0 +1,111 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcpipe
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+
+	"storj.io/drpc"
+	"storj.io/drpc/drpcconn"
+	"storj.io/drpc/drpcmux"
+	"storj.io/drpc/drpcpool"
+	"storj.io/drpc/drpcserver"
+)
+
+// Transports returns a pair of connected in-memory transports. Writes to one
+// block until they are read from the other, and closing either end causes
+// operations on both ends to fail.
+func Transports() (client, server drpc.Transport) {
+	return net.Pipe()
+}
+
+// Options controls configuration settings for the pipe.
+type Options struct {
+	// Server is passed to the server handling rpcs.
+	Server drpcserver.Options
+
+	// Conn is passed to every conn created to the server.
+	Conn drpcconn.Options
+
+	// Mux is passed to the mux that Run and RunWithOptions register the
+	// implementation with. Its Interceptor is called around every rpc.
+	Mux drpcmux.Options
+}
+
+// New returns a conn to a server for the handler running in the same process,
+// and a function that closes the conn and waits for the server to exit. The
+// conn may be used concurrently: a new pair of transports is created whenever
+// a concurrent rpc needs one.
+func New(handler drpc.Handler) (conn drpc.Conn, cleanup func()) {
+	return NewWithOptions(handler, Options{})
+}
+
+// NewWithOptions is like New but uses the provided options.
+func NewWithOptions(handler drpc.Handler, opts Options) (conn drpc.Conn, cleanup func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	srv := drpcserver.NewWithOptions(handler, opts.Server)
+	pool := drpcpool.New[struct{}, *drpcconn.Conn](drpcpool.Options{})
+
+	var wg sync.WaitGroup
+	pc := pool.Get(ctx, struct{}{}, func(context.Context, struct{}) (*drpcconn.Conn, error) {
+		client, server := Transports()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = srv.ServeOne(ctx, server)
+		}()
+
+		return drpcconn.NewWithOptions(client, opts.Conn), nil
+	})
+
+	conn = &pipeConn{Conn: pc}
+	return conn, func() {
+		_ = conn.Close()
+		_ = pool.Close()
+		cancel()
+		wg.Wait()
+	}
+}
+
+// Run returns a conn to a server for the implementation of the description
+// running in the same process. The conn and server are closed when the test
+// and all of its subtests complete, and the test fails immediately if the
+// implementation cannot be registered. Every call creates its own server, so
+// it is safe to use from parallel tests.
+func Run(tb testing.TB, desc drpc.Description, impl interface{}) drpc.Conn {
+	tb.Helper()
+	return RunWithOptions(tb, desc, impl, Options{})
+}
+
+// RunWithOptions is like Run but uses the provided options.
+func RunWithOptions(tb testing.TB, desc drpc.Description, impl interface{}, opts Options) drpc.Conn {
+	tb.Helper()
+
+	mux := drpcmux.NewWithOptions(opts.Mux)
+	if err := mux.Register(impl, desc); err != nil {
+		tb.Fatalf("registering implementation: %v", err)
+	}
+
+	conn, cleanup := NewWithOptions(mux, opts)
+	tb.Cleanup(cleanup)
+	return conn
+}
+
+// pipeConn allows closing the conn from the pool more than once so that the
+// conn can be closed both by the caller and by the cleanup function.
+type pipeConn struct {
+	drpc.Conn
+	once sync.Once
+	err  error
+}
+
+// Close closes the conn the first time it is called.
+func (p *pipeConn) Close() error {
+	p.once.Do(func() { p.err = p.Conn.Close() })
+	return p.err
+}
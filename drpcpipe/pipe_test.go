@@ -0,0 +1,168 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcpipe
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/zeebo/assert"
+
+	"storj.io/drpc"
+	"storj.io/drpc/drpcmetadata"
+	"storj.io/drpc/drpcmux"
+	"storj.io/drpc/drpctest"
+)
+
+type handlerFunc func(stream drpc.Stream, rpc string) error
+
+func (fn handlerFunc) HandleRPC(stream drpc.Stream, rpc string) error { return fn(stream, rpc) }
+
+// echo sends back every message it receives prefixed with the rpc name.
+var echo = handlerFunc(func(stream drpc.Stream, rpc string) error {
+	for {
+		var in []byte
+		if err := stream.MsgRecv(&in, drpctest.ByteEncoding{}); err != nil {
+			return nil
+		}
+		out := append([]byte(rpc), in...)
+		if err := stream.MsgSend(&out, drpctest.ByteEncoding{}); err != nil {
+			return err
+		}
+	}
+})
+
+func TestPipe_Invoke(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	conn, cleanup := New(echo)
+	defer cleanup()
+
+	in, out := []byte("data"), []byte(nil)
+	assert.NoError(t, conn.Invoke(ctx, "/rpc", drpctest.ByteEncoding{}, &in, &out))
+	assert.Equal(t, string(out), "/rpcdata")
+}
+
+func TestPipe_Concurrent(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	conn, cleanup := New(echo)
+	defer cleanup()
+
+	// open all of the streams before using any of them so that they must be
+	// open concurrently.
+	const n = 10
+	streams := make([]drpc.Stream, n)
+	for i := range streams {
+		stream, err := conn.NewStream(ctx, "/rpc", drpctest.ByteEncoding{})
+		assert.NoError(t, err)
+		streams[i] = stream
+	}
+
+	var wg sync.WaitGroup
+	for i := range streams {
+		wg.Add(1)
+		go func(stream drpc.Stream) {
+			defer wg.Done()
+
+			in, out := []byte("data"), []byte(nil)
+			assert.NoError(t, stream.MsgSend(&in, drpctest.ByteEncoding{}))
+			assert.NoError(t, stream.MsgRecv(&out, drpctest.ByteEncoding{}))
+			assert.Equal(t, string(out), "/rpcdata")
+			assert.NoError(t, stream.Close())
+		}(streams[i])
+	}
+	wg.Wait()
+}
+
+func TestPipe_Close(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	{ // a closed conn can not issue rpcs
+		conn, cleanup := New(echo)
+		defer cleanup()
+
+		assert.NoError(t, conn.Close())
+
+		in, out := []byte("data"), []byte(nil)
+		assert.Error(t, conn.Invoke(ctx, "/rpc", drpctest.ByteEncoding{}, &in, &out))
+	}
+
+	{ // cleaning up stops the server, failing any active streams
+		conn, cleanup := New(echo)
+
+		stream, err := conn.NewStream(ctx, "/rpc", drpctest.ByteEncoding{})
+		assert.NoError(t, err)
+		in, out := []byte("data"), []byte(nil)
+		assert.NoError(t, stream.MsgSend(&in, drpctest.ByteEncoding{}))
+		assert.NoError(t, stream.MsgRecv(&out, drpctest.ByteEncoding{}))
+
+		cleanup()
+		assert.Error(t, stream.MsgRecv(&out, drpctest.ByteEncoding{}))
+	}
+}
+
+func TestTransports(t *testing.T) {
+	client, server := Transports()
+	assert.NoError(t, server.Close())
+
+	_, err := client.Write([]byte("data"))
+	assert.Error(t, err)
+}
+
+// echoServer echoes its input prefixed with the "prefix" metadata value.
+type echoServer struct{}
+
+func (echoServer) Echo(ctx context.Context, in *[]byte) (*[]byte, error) {
+	md, _ := drpcmetadata.Get(ctx)
+	out := append([]byte(md["prefix"]), *in...)
+	return &out, nil
+}
+
+// echoDescription describes echoServer to a mux.
+type echoDescription struct{}
+
+func (echoDescription) NumMethods() int { return 1 }
+
+func (echoDescription) Method(n int) (string, drpc.Encoding, drpc.Receiver, interface{}, bool) {
+	if n != 0 {
+		return "", nil, nil, nil, false
+	}
+	return "/echo", drpctest.ByteEncoding{},
+		func(srv interface{}, ctx context.Context, in1, in2 interface{}) (drpc.Message, error) {
+			return srv.(echoServer).Echo(ctx, in1.(*[]byte))
+		}, echoServer.Echo, true
+}
+
+func TestRun(t *testing.T) {
+	for i := 0; i < 10; i++ {
+		prefix := fmt.Sprint(i)
+		t.Run(prefix, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := drpctest.NewTracker(t)
+			defer ctx.Close()
+
+			var rpcs []string
+			conn := RunWithOptions(t, echoDescription{}, echoServer{}, Options{
+				Mux: drpcmux.Options{
+					Interceptor: func(ctx context.Context, rpc string, in drpc.Message, stream drpc.Stream, next drpc.ServerHandler) (drpc.Message, error) {
+						rpcs = append(rpcs, rpc)
+						return next(ctx, rpc, in, stream)
+					},
+				},
+			})
+
+			in, out := []byte("data"), []byte(nil)
+			assert.NoError(t, conn.Invoke(drpcmetadata.Add(ctx, "prefix", prefix), "/echo", drpctest.ByteEncoding{}, &in, &out))
+			assert.Equal(t, string(out), prefix+"data")
+			assert.DeepEqual(t, rpcs, []string{"/echo"})
+		})
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcpool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+
+	"storj.io/drpc"
+	"storj.io/drpc/drpcconn"
+	"storj.io/drpc/drpcserver"
+	"storj.io/drpc/drpcstatus"
+	"storj.io/drpc/drpctest"
+)
+
+type echoStreamHandler struct{}
+
+func (echoStreamHandler) HandleRPC(stream drpc.Stream, rpc string) error {
+	for {
+		var in []byte
+		if err := stream.MsgRecv(&in, drpctest.ByteEncoding{}); errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := stream.MsgSend(&in, drpctest.ByteEncoding{}); err != nil {
+			return err
+		}
+	}
+}
+
+func TestPool_KeyConcurrency(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	srv := drpcserver.New(echoStreamHandler{})
+	dial := func(_ context.Context, key string) (Conn, error) {
+		pc, ps := net.Pipe()
+		ctx.Run(func(ctx context.Context) { _ = srv.ServeOne(ctx, ps) })
+		return drpcconn.New(pc), nil
+	}
+
+	pool := New[string, Conn](Options{KeyConcurrency: 3})
+	defer func() { _ = pool.Close() }()
+
+	conn := pool.Get(ctx, "key", dial)
+
+	// open streams up to the limit and exchange messages on them independently.
+	var streams []drpc.Stream
+	for i := 0; i < 3; i++ {
+		stream, err := conn.NewStream(ctx, "echo", drpctest.ByteEncoding{})
+		assert.NoError(t, err)
+		streams = append(streams, stream)
+	}
+
+	errch := make(chan error, len(streams))
+	for i, stream := range streams {
+		i, stream := i, stream
+		go func() {
+			for j := 0; j < 10; j++ {
+				out := []byte(fmt.Sprintf("stream %d message %d", i, j))
+				if err := stream.MsgSend(&out, drpctest.ByteEncoding{}); err != nil {
+					errch <- err
+					return
+				}
+				var in []byte
+				if err := stream.MsgRecv(&in, drpctest.ByteEncoding{}); err != nil {
+					errch <- err
+					return
+				}
+				if string(in) != string(out) {
+					errch <- fmt.Errorf("got %q expected %q", in, out)
+					return
+				}
+			}
+			errch <- nil
+		}()
+	}
+	for range streams {
+		assert.NoError(t, <-errch)
+	}
+
+	// another stream waits until the context is done.
+	{
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		_, err := conn.NewStream(ctx, "echo", drpctest.ByteEncoding{})
+		cancel()
+		assert.That(t, errors.Is(err, context.DeadlineExceeded))
+	}
+
+	// a waiting stream starts once another one finishes.
+	started := make(chan drpc.Stream, 1)
+	go func() {
+		stream, _ := conn.NewStream(ctx, "echo", drpctest.ByteEncoding{})
+		started <- stream
+	}()
+	assert.NoError(t, streams[0].Close())
+
+	select {
+	case stream := <-started:
+		assert.NotNil(t, stream)
+		assert.NoError(t, stream.Close())
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream did not start after a slot was released")
+	}
+
+	for _, stream := range streams[1:] {
+		assert.NoError(t, stream.Close())
+	}
+}
+
+func TestPool_KeyConcurrency_NoWait(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	pool := New[string, Conn](Options{KeyConcurrency: 1, NoWait: true})
+	defer func() { _ = pool.Close() }()
+
+	dial := func(ctx context.Context, key string) (Conn, error) {
+		return new(callbackConn), nil
+	}
+	conn1 := pool.Get(ctx, "key1", dial)
+	conn2 := pool.Get(ctx, "key2", dial)
+
+	stream, err := conn1.NewStream(ctx, "", nil)
+	assert.NoError(t, err)
+
+	// the limit is per key.
+	_, err = conn1.NewStream(ctx, "", nil)
+	assert.Equal(t, drpcstatus.CodeFromError(err), drpcstatus.ResourceExhausted)
+	assert.Equal(t, drpcstatus.CodeFromError(conn1.Invoke(ctx, "", nil, nil, nil)), drpcstatus.ResourceExhausted)
+
+	stream2, err := conn2.NewStream(ctx, "", nil)
+	assert.NoError(t, err)
+	assert.NoError(t, stream2.Close())
+
+	// closing the stream frees the slot once the pool notices.
+	assert.NoError(t, stream.Close())
+	for {
+		stream, err = conn1.NewStream(ctx, "", nil)
+		if err == nil {
+			break
+		}
+		assert.Equal(t, drpcstatus.CodeFromError(err), drpcstatus.ResourceExhausted)
+		time.Sleep(time.Millisecond)
+	}
+	assert.NoError(t, stream.Close())
+}
@@ -55,14 +55,16 @@ func (p *poolConn[K, V]) Invoke(ctx context.Context, rpc string, enc drpc.Encodi
 		return errs.New("connection closed")
 	}
 
-	conn, ok := p.pool.Take(p.key)
-	if !ok {
-		conn, err = p.dial(ctx, p.key)
-		if err != nil {
-			return err
-		}
+	if err := p.pool.acquire(ctx, p.key); err != nil {
+		return err
 	}
-	defer p.pool.Put(p.key, conn)
+	defer p.pool.release(p.key)
+
+	conn, err := p.pool.get(ctx, p.key, p.dial)
+	if err != nil {
+		return err
+	}
+	defer p.pool.put(p.key, conn)
 
 	return conn.Invoke(ctx, rpc, enc, in, out)
 }
@@ -77,17 +79,20 @@ func (p *poolConn[K, V]) NewStream(ctx context.Context, rpc string, enc drpc.Enc
 		return nil, errs.New("connection closed")
 	}
 
-	conn, ok := p.pool.Take(p.key)
-	if !ok {
-		conn, err = p.dial(ctx, p.key)
-		if err != nil {
-			return nil, err
-		}
+	if err := p.pool.acquire(ctx, p.key); err != nil {
+		return nil, err
+	}
+
+	conn, err := p.pool.get(ctx, p.key, p.dial)
+	if err != nil {
+		p.pool.release(p.key)
+		return nil, err
 	}
 
 	stream, err := conn.NewStream(ctx, rpc, enc)
 	if err != nil {
-		p.pool.Put(p.key, conn)
+		p.pool.put(p.key, conn)
+		p.pool.release(p.key)
 		return nil, err
 	}
 
@@ -98,7 +103,8 @@ func (p *poolConn[K, V]) NewStream(ctx context.Context, rpc string, enc drpc.Enc
 
 func (p *poolConn[K, V]) monitorStream(stream drpc.Stream, conn V, done *drpcsignal.Chan) {
 	<-stream.Context().Done()
-	p.pool.Put(p.key, conn)
+	p.pool.put(p.key, conn)
+	p.pool.release(p.key)
 	done.Close()
 }
 
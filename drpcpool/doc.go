@@ -7,6 +7,16 @@
 // maximum size on both the total and per key basis. It also
 // can expire cached connections if they have been inactive in
 // the pool for long enough.
+//
+// A drpc connection only runs one rpc at a time, so the conns
+// returned by the pool run concurrent rpcs by using a separate
+// pooled connection for each. The KeyConcurrency option bounds
+// how many can be active at once for a key, either waiting for
+// one to finish or failing with a ResourceExhausted error.
+//
+// The pool can also periodically check its cached connections,
+// evicting the ones that are closed or that fail a ping, and back
+// off from dialing a key whose last dial or connection failed.
 package drpcpool
 
 // closed is a helper to check if a notification channel has been closed.
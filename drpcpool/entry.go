@@ -9,11 +9,12 @@ import (
 )
 
 type entry[K comparable, V Conn] struct {
-	key    K
-	val    V
-	exp    *time.Timer
-	global node[K, V]
-	local  node[K, V]
+	key     K
+	val     V
+	exp     *time.Timer
+	removed bool // set once the entry is removed from the cache
+	global  node[K, V]
+	local   node[K, V]
 }
 
 func (e *entry[K, V]) String() string {
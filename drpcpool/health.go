@@ -0,0 +1,162 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcpool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"storj.io/drpc/drpcmanager"
+)
+
+// pinger is implemented by conns like drpcconn.Conn that can check that the
+// remote is responsive.
+type pinger interface {
+	Ping(ctx context.Context) (time.Duration, error)
+}
+
+// get returns a cached connection for the key, or dials one if there are none,
+// waiting for any backoff for the key first. The connection is counted as in
+// use until it is passed to put.
+func (p *Pool[K, V]) get(ctx context.Context, key K, dial func(context.Context, K) (V, error)) (V, error) {
+	conn, ok := p.Take(key)
+	if !ok {
+		if err := p.waitBackoff(ctx, key); err != nil {
+			return *new(V), err
+		}
+
+		var err error
+		conn, err = dial(ctx, key)
+		if err != nil {
+			p.startBackoff(key)
+			return *new(V), err
+		}
+	}
+
+	p.mu.Lock()
+	p.inUse++
+	p.mu.Unlock()
+
+	return conn, nil
+}
+
+// put places the connection returned by get back in the cache.
+func (p *Pool[K, V]) put(key K, conn V) {
+	p.mu.Lock()
+	p.inUse--
+	p.mu.Unlock()
+
+	p.Put(key, conn)
+}
+
+// startBackoff causes dials for the key to wait for the DialBackoff.
+func (p *Pool[K, V]) startBackoff(key K) {
+	if p.opts.DialBackoff <= 0 {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.backoff[key] = time.Now().Add(p.opts.DialBackoff)
+}
+
+// waitBackoff waits until dials for the key may be attempted, returning an
+// error if the context is done first.
+func (p *Pool[K, V]) waitBackoff(ctx context.Context, key K) error {
+	p.mu.Lock()
+	until, ok := p.backoff[key]
+	wait := time.Until(until)
+	if ok && wait <= 0 {
+		delete(p.backoff, key)
+	}
+	p.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+
+	p.log("BACKOFF", func() string { return wait.String() })
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// healthCheck checks the cached connections every HealthCheckInterval until the
+// Pool is closed.
+func (p *Pool[K, V]) healthCheck() {
+	ticker := time.NewTicker(p.opts.HealthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop.Signal():
+			return
+		case <-ticker.C:
+		}
+		p.checkEntries()
+	}
+}
+
+// checkEntries evicts the cached connections that are closed or fail a ping.
+// Connections whose remote does not support pings are not evicted for it. The
+// connections stay in the cache while they are pinged, so they may be taken by
+// an rpc before they are evicted, in which case they are left alone.
+func (p *Pool[K, V]) checkEntries() {
+	p.mu.Lock()
+	ents := make([]*entry[K, V], 0, p.order.count)
+	for ent := p.order.head; ent != nil; ent = ent.global.next {
+		ents = append(ents, ent)
+	}
+	p.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.opts.HealthCheckInterval)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, ent := range ents {
+		if closed(ent.val.Closed()) {
+			p.evict(ent)
+			continue
+		}
+
+		pinger, ok := any(ent.val).(pinger)
+		if !ok {
+			continue
+		}
+
+		wg.Add(1)
+		go func(ent *entry[K, V]) {
+			defer wg.Done()
+
+			if _, err := pinger.Ping(ctx); err != nil && !errors.Is(err, drpcmanager.ErrPingUnsupported) {
+				p.evict(ent)
+			}
+		}(ent)
+	}
+	wg.Wait()
+}
+
+// evict closes the connection of the entry and starts a backoff for its key if
+// it is still in the cache.
+func (p *Pool[K, V]) evict(ent *entry[K, V]) {
+	p.mu.Lock()
+	removed := p.unlink(ent)
+	p.mu.Unlock()
+
+	if removed {
+		p.log("EVICT", ent.String)
+		_ = p.closeEntry(ent)
+		p.startBackoff(ent.key)
+	}
+}
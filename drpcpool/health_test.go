@@ -0,0 +1,187 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcpool
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+
+	"storj.io/drpc"
+	"storj.io/drpc/drpcconn"
+	"storj.io/drpc/drpcmanager"
+	"storj.io/drpc/drpcserver"
+	"storj.io/drpc/drpctest"
+)
+
+type handlerFunc func(stream drpc.Stream, rpc string) error
+
+func (fn handlerFunc) HandleRPC(stream drpc.Stream, rpc string) error { return fn(stream, rpc) }
+
+type pingConn struct {
+	callbackConn
+	PingFn func(ctx context.Context) (time.Duration, error)
+}
+
+func (pc *pingConn) Ping(ctx context.Context) (time.Duration, error) { return pc.PingFn(ctx) }
+
+// waitIdle waits for the pool to have the expected number of idle connections.
+func waitIdle(t *testing.T, pool *Pool[string, Conn], expected int) {
+	t.Helper()
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(time.Millisecond) {
+		if pool.Stats().Idle == expected {
+			return
+		}
+	}
+	assert.Equal(t, pool.Stats().Idle, expected)
+}
+
+func TestPool_HealthCheck_KilledBackend(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	pool := New[string, Conn](Options{HealthCheckInterval: time.Millisecond})
+	defer func() { _ = pool.Close() }()
+
+	srv := drpcserver.New(handlerFunc(func(stream drpc.Stream, rpc string) error {
+		var in []byte
+		if err := stream.MsgRecv(&in, drpctest.ByteEncoding{}); err != nil {
+			return err
+		}
+		return stream.MsgSend(&in, drpctest.ByteEncoding{})
+	}))
+
+	sctx, kill := context.WithCancel(ctx)
+	conn := pool.Get(ctx, "key", func(context.Context, string) (Conn, error) {
+		pc, ps := net.Pipe()
+		ctx.Run(func(context.Context) { _ = srv.ServeOne(sctx, ps) })
+		return drpcconn.New(pc), nil
+	})
+	assert.NoError(t, conn.Invoke(ctx, "rpc", drpctest.ByteEncoding{}, new([]byte), new([]byte)))
+	assert.Equal(t, pool.Stats(), Stats{Idle: 1, Total: 1})
+
+	// the health check evicts the connection once the backend is killed.
+	kill()
+	waitIdle(t, pool, 0)
+	assert.Equal(t, pool.Stats(), Stats{})
+}
+
+func TestPool_HealthCheck_FailedPing(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	pool := New[string, Conn](Options{
+		HealthCheckInterval: time.Millisecond,
+		DialBackoff:         time.Hour,
+	})
+	defer func() { _ = pool.Close() }()
+
+	pings, closes := make(chan struct{}), make(chan struct{}, 1)
+	dials := 0
+	conn := pool.Get(ctx, "key", func(context.Context, string) (Conn, error) {
+		dials++
+		return &pingConn{
+			callbackConn: callbackConn{CloseFn: func() error { closes <- struct{}{}; return nil }},
+			PingFn: func(ctx context.Context) (time.Duration, error) {
+				<-pings
+				return 0, errors.New("unresponsive")
+			},
+		}, nil
+	})
+	invoke(ctx, conn)
+	assert.Equal(t, pool.Stats().Idle, 1)
+
+	// the connection is closed and evicted once a ping fails.
+	pings <- struct{}{}
+	<-closes
+	waitIdle(t, pool, 0)
+
+	// and a new connection is not dialed until the backoff passes.
+	tctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	assert.That(t, errors.Is(conn.Invoke(tctx, "", nil, nil, nil), context.DeadlineExceeded))
+	assert.Equal(t, dials, 1)
+}
+
+func TestPool_HealthCheck_PingUnsupported(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	pool := New[string, Conn](Options{HealthCheckInterval: time.Millisecond})
+	defer func() { _ = pool.Close() }()
+
+	pings, closes := make(chan struct{}), make(chan struct{}, 1)
+	conn := pool.Get(ctx, "key", func(context.Context, string) (Conn, error) {
+		return &pingConn{
+			callbackConn: callbackConn{CloseFn: func() error { closes <- struct{}{}; return nil }},
+			PingFn: func(ctx context.Context) (time.Duration, error) {
+				pings <- struct{}{}
+				return 0, drpcmanager.ErrPingUnsupported
+			},
+		}, nil
+	})
+	invoke(ctx, conn)
+
+	// the connection is kept by health checks that can not ping it, so it
+	// is pinged again.
+	for i := 0; i < 3; i++ {
+		select {
+		case <-pings:
+		case <-time.After(5 * time.Second):
+			t.Fatal("connection was not pinged again")
+		}
+	}
+	assert.Equal(t, pool.Stats().Idle, 1)
+	assert.That(t, len(closes) == 0)
+}
+
+func TestPool_DialBackoff(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	pool := New[string, Conn](Options{DialBackoff: 50 * time.Millisecond})
+	defer func() { _ = pool.Close() }()
+
+	var dialed []time.Time
+	conn := pool.Get(ctx, "key", func(context.Context, string) (Conn, error) {
+		dialed = append(dialed, time.Now())
+		if len(dialed) == 1 {
+			return nil, errors.New("dial failed")
+		}
+		return new(callbackConn), nil
+	})
+
+	assert.Error(t, conn.Invoke(ctx, "", nil, nil, nil))
+	assert.NoError(t, conn.Invoke(ctx, "", nil, nil, nil))
+	assert.Equal(t, len(dialed), 2)
+	assert.That(t, dialed[1].Sub(dialed[0]) >= 50*time.Millisecond)
+}
+
+func TestPool_Stats(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	pool := New[string, Conn](Options{})
+	defer func() { _ = pool.Close() }()
+
+	conn := getConn(ctx, pool, make(chan string, 2), "key")
+
+	stream1, err := conn.NewStream(ctx, "", nil)
+	assert.NoError(t, err)
+	stream2, err := conn.NewStream(ctx, "", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, pool.Stats(), Stats{InUse: 2, Total: 2})
+
+	assert.NoError(t, stream1.Close())
+	<-stream1.Context().Done()
+	assert.Equal(t, pool.Stats(), Stats{Idle: 1, InUse: 1, Total: 2})
+
+	assert.NoError(t, stream2.Close())
+	<-stream2.Context().Done()
+	assert.Equal(t, pool.Stats(), Stats{Idle: 2, Total: 2})
+}
@@ -12,12 +12,15 @@ import (
 	"github.com/zeebo/errs"
 
 	"storj.io/drpc/drpcdebug"
+	"storj.io/drpc/drpcsignal"
+	"storj.io/drpc/drpcstatus"
 )
 
 // Options contains the options to configure a pool.
 type Options struct {
 	// Expiration will remove any values from the Pool after the
-	// value passes. Zero means no expiration.
+	// value passes. Zero means no expiration. Only idle connections
+	// are in the cache, so it is the longest a connection stays idle.
 	Expiration time.Duration
 
 	// Capacity is the maximum number of values the Pool can store.
@@ -28,25 +31,80 @@ type Options struct {
 	// the Pool holds unlimited for any single key. Negative means
 	// no values for any single key.
 	KeyCapacity int
+
+	// KeyConcurrency limits how many rpcs can be active at once on the conns
+	// returned by Get with the same key. Each active rpc uses its own
+	// connection, because a drpc connection only runs one rpc at a time, so
+	// this also limits the connections in use for the key. Rpcs over the
+	// limit wait for another to finish. Zero means unlimited.
+	KeyConcurrency int
+
+	// NoWait causes rpcs over the KeyConcurrency limit to fail right away
+	// with a drpcstatus.ResourceExhausted error instead of waiting.
+	NoWait bool
+
+	// HealthCheckInterval, if positive, is how often the cached connections
+	// are checked. Those that are closed, or that have a Ping method like
+	// drpcconn.Conn that fails within the interval, are evicted and closed.
+	// A ping that fails with drpcmanager.ErrPingUnsupported, such as for a
+	// drpcconn.Conn without the Handshake manager option, does not evict.
+	HealthCheckInterval time.Duration
+
+	// DialBackoff, if positive, is how long rpcs for a key wait before
+	// dialing again after a dial for the key failed or one of its connections
+	// was evicted by the health check. They fail with the context error if it
+	// is done first.
+	DialBackoff time.Duration
+}
+
+// Stats is a snapshot of the connections of a Pool.
+type Stats struct {
+	// Idle is the number of connections in the cache.
+	Idle int
+
+	// InUse is the number of connections being used by an rpc on a conn
+	// returned by Get.
+	InUse int
+
+	// Total is the sum of Idle and InUse.
+	Total int
 }
 
 // Pool is a connection pool with key type K. It maintains a cache of connections
 // per key and ensures the total number of connections in the cache is bounded by
-// configurable values. It does not limit the maximum concurrency of the number
-// of connections either in total or per key.
+// configurable values. It only limits the number of connections in use if the
+// KeyConcurrency option is set.
 type Pool[K comparable, V Conn] struct {
 	opts    Options
 	mu      sync.Mutex
 	entries map[K]*list[K, V]
 	order   list[K, V]
+	sems    map[K]*keySem
+	inUse   int
+	backoff map[K]time.Time   // when dials for the key may be attempted again
+	stop    drpcsignal.Signal // set to stop the health check
+}
+
+// keySem limits the concurrency of rpcs for a key.
+type keySem struct {
+	ch   chan struct{} // holds a value for each active rpc
+	refs int           // the number of rpcs acquiring or holding the semaphore
 }
 
 // New constructs a new Pool with the provided Options.
+// If the HealthCheckInterval is positive, the health check runs until the Pool
+// is closed.
 func New[K comparable, V Conn](opts Options) *Pool[K, V] {
-	return &Pool[K, V]{
+	p := &Pool[K, V]{
 		opts:    opts,
 		entries: make(map[K]*list[K, V]),
+		sems:    make(map[K]*keySem),
+		backoff: make(map[K]time.Time),
+	}
+	if opts.HealthCheckInterval > 0 {
+		go p.healthCheck()
 	}
+	return p
 }
 
 func (p *Pool[K, V]) log(what string, cb func() string) {
@@ -56,13 +114,16 @@ func (p *Pool[K, V]) log(what string, cb func() string) {
 }
 
 // Close evicts all entries from the Pool's cache, closing them and returning all
-// of the combined errors from closing.
+// of the combined errors from closing. It also stops the health check.
 func (p *Pool[K, V]) Close() (err error) {
+	p.stop.Set(nil)
+
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
 	var eg errs.Group
 	for ent := p.order.head; ent != nil; ent = ent.global.next {
+		ent.removed = true
 		eg.Add(p.closeEntry(ent))
 	}
 
@@ -72,6 +133,18 @@ func (p *Pool[K, V]) Close() (err error) {
 	return eg.Err()
 }
 
+// Stats returns a snapshot of the connections of the Pool.
+func (p *Pool[K, V]) Stats() Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return Stats{
+		Idle:  p.order.count,
+		InUse: p.inUse,
+		Total: p.order.count + p.inUse,
+	}
+}
+
 // Get returns a new Conn that will use the provided dial function to create an
 // underlying conn to be cached by the Pool when Conn methods are invoked. It will
 // share any cached connections with other conns that use the same key.
@@ -88,14 +161,82 @@ func (p *Pool[K, V]) Get(ctx context.Context, key K,
 // helpers
 //
 
+// acquire waits until an rpc for the key may start, returning an error if the
+// context is done first or if the pool does not wait and the limit is reached.
+// If it returns nil, release must be called once the rpc is finished.
+func (p *Pool[K, V]) acquire(ctx context.Context, key K) error {
+	if p.opts.KeyConcurrency <= 0 {
+		return nil
+	}
+
+	p.mu.Lock()
+	sem := p.sems[key]
+	if sem == nil {
+		sem = &keySem{ch: make(chan struct{}, p.opts.KeyConcurrency)}
+		p.sems[key] = sem
+	}
+	sem.refs++
+	p.mu.Unlock()
+
+	if p.opts.NoWait {
+		select {
+		case sem.ch <- struct{}{}:
+			return nil
+		default:
+			p.unref(key, sem)
+			return drpcstatus.Errorf(drpcstatus.ResourceExhausted, "too many concurrent rpcs")
+		}
+	}
+
+	select {
+	case sem.ch <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		p.unref(key, sem)
+		return ctx.Err()
+	}
+}
+
+// release allows another rpc for the key to start.
+func (p *Pool[K, V]) release(key K) {
+	if p.opts.KeyConcurrency <= 0 {
+		return
+	}
+
+	p.mu.Lock()
+	sem := p.sems[key]
+	p.mu.Unlock()
+
+	<-sem.ch
+	p.unref(key, sem)
+}
+
+// unref removes a reference to the semaphore, forgetting it once nothing is
+// using it.
+func (p *Pool[K, V]) unref(key K, sem *keySem) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if sem.refs--; sem.refs == 0 {
+		delete(p.sems, key)
+	}
+}
+
 func (p *Pool[K, V]) removeEntry(ent *entry[K, V]) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	p.unlink(ent)
+}
+
+// unlink removes the entry from the cache, returning false if it was already
+// removed. It must be called with the mutex held.
+func (p *Pool[K, V]) unlink(ent *entry[K, V]) bool {
 	local := p.entries[ent.key]
-	if local == nil {
-		return
+	if ent.removed || local == nil {
+		return false
 	}
+	ent.removed = true
 
 	local.removeEntry(ent, (*entry[K, V]).localList)
 	p.order.removeEntry(ent, (*entry[K, V]).globalList)
@@ -103,6 +244,7 @@ func (p *Pool[K, V]) removeEntry(ent *entry[K, V]) {
 	if local.count == 0 {
 		delete(p.entries, ent.key)
 	}
+	return true
 }
 
 // closeEntry ensures the timer and connection are closed, returning any errors.
@@ -135,8 +277,7 @@ func (p *Pool[K, V]) Take(key K) (V, bool) {
 			continue
 		}
 
-		local.removeEntry(ent, (*entry[K, V]).localList)
-		p.order.removeEntry(ent, (*entry[K, V]).globalList)
+		p.unlink(ent)
 
 		if ent.exp != nil && !ent.exp.Stop() {
 			continue
@@ -164,33 +305,28 @@ func (p *Pool[K, V]) Put(key K, val V) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	local := p.entries[key]
-	if local == nil {
-		local = new(list[K, V])
-		p.entries[key] = local
-	}
-
-	for p.opts.KeyCapacity != 0 && local.count >= p.opts.KeyCapacity {
+	for p.opts.KeyCapacity != 0 {
+		local := p.entries[key]
+		if local == nil || local.count < p.opts.KeyCapacity {
+			break
+		}
 		ent := local.head
 
 		_ = p.closeEntry(ent)
-
-		local.removeEntry(ent, (*entry[K, V]).localList)
-		p.order.removeEntry(ent, (*entry[K, V]).globalList)
+		p.unlink(ent)
 	}
 
 	for p.opts.Capacity != 0 && p.order.count >= p.opts.Capacity {
 		ent := p.order.head
-		local := p.entries[ent.key]
 
 		_ = p.closeEntry(ent)
+		p.unlink(ent)
+	}
 
-		local.removeEntry(ent, (*entry[K, V]).localList)
-		p.order.removeEntry(ent, (*entry[K, V]).globalList)
-
-		if local.count == 0 {
-			delete(p.entries, ent.key)
-		}
+	local := p.entries[key]
+	if local == nil {
+		local = new(list[K, V])
+		p.entries[key] = local
 	}
 
 	ent := &entry[K, V]{key: key, val: val}
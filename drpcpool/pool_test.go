@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/zeebo/assert"
+	"github.com/zeebo/errs"
 
 	"storj.io/drpc"
 	"storj.io/drpc/drpctest"
@@ -154,6 +155,44 @@ func TestPool_Stale(t *testing.T) {
 	assert.Equal(t, calls, 2)
 }
 
+// TestPool_Broken checks that a conn that breaks during an rpc is not reused.
+func TestPool_Broken(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	pool := New[string, Conn](Options{})
+	defer func() { _ = pool.Close() }()
+
+	calls := 0
+	conn := pool.Get(ctx, "key", func(ctx context.Context, key string) (Conn, error) {
+		calls++
+		done := make(chan struct{})
+		return &callbackConn{
+			ClosedFn: func() <-chan struct{} { return done },
+			InvokeFn: func(ctx context.Context, rpc string, enc drpc.Encoding, in, out drpc.Message) error {
+				if rpc == "break" {
+					close(done)
+					return errs.New("broken")
+				}
+				return nil
+			},
+		}, nil
+	})
+
+	// the first invoke dials and the second reuses the conn
+	invoke(ctx, conn)
+	invoke(ctx, conn)
+	assert.Equal(t, calls, 1)
+
+	// the conn breaks during the rpc and is not placed back into the pool
+	assert.Error(t, conn.Invoke(ctx, "break", nil, nil, nil))
+	assert.Equal(t, calls, 1)
+
+	// so the next invoke transparently dials a replacement
+	invoke(ctx, conn)
+	assert.Equal(t, calls, 2)
+}
+
 // TestPool_Capacity checks that total capacity limits are enforced.
 func TestPool_Capacity(t *testing.T) {
 	ctx := drpctest.NewTracker(t)
@@ -0,0 +1,518 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcprom
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"storj.io/drpc"
+	"storj.io/drpc/drpcenc"
+	"storj.io/drpc/drpcmux"
+	"storj.io/drpc/drpcserver"
+	"storj.io/drpc/drpcstatus"
+)
+
+// Options controls configuration settings for a Collector.
+type Options struct {
+	// Namespace is prefixed to the name of every metric. If empty, "drpc" is
+	// used.
+	Namespace string
+
+	// Buckets are the buckets, in seconds, of the latency histograms. If nil,
+	// prometheus.DefBuckets is used.
+	Buckets []float64
+
+	// SizeBuckets are the buckets, in bytes, of the message size histograms.
+	// If nil, exponential buckets from 64B to 1MiB are used.
+	SizeBuckets []float64
+}
+
+// Collector is a prometheus.Collector of metrics about rpcs. It implements
+// drpc.ClientInterceptor to collect metrics about the rpcs a client issues,
+// and its InterceptServer method is a drpc.ServerInterceptor that collects
+// metrics about the rpcs a server handles.
+//
+// The messages sent and received on streams are counted as they are, so that
+// long lived streams are visible before they finish. Like every other metric,
+// they are only labeled by the rpc, and the direction for sizes, to keep the
+// cardinality bounded by the number of rpcs.
+type Collector struct {
+	client metrics
+	server metrics
+
+	conns    *prometheus.Desc
+	buffered *prometheus.Desc
+	inflight *prometheus.Desc
+	draining *prometheus.Desc
+
+	mu      sync.Mutex
+	servers []*drpcserver.Server
+}
+
+var _ prometheus.Collector = (*Collector)(nil)
+var _ drpc.ClientInterceptor = (*Collector)(nil)
+var _ drpc.ServerInterceptor = (*Collector)(nil).InterceptServer
+
+// metrics are the metrics for one side of rpcs.
+type metrics struct {
+	requests *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+	active   prometheus.Gauge
+	sent     *prometheus.CounterVec
+	received *prometheus.CounterVec
+	sizes    *prometheus.HistogramVec
+}
+
+// New returns a new Collector.
+func New() *Collector {
+	return NewWithOptions(Options{})
+}
+
+// NewWithOptions returns a new Collector using the provided options.
+func NewWithOptions(opts Options) *Collector {
+	if opts.Namespace == "" {
+		opts.Namespace = "drpc"
+	}
+	if opts.Buckets == nil {
+		opts.Buckets = prometheus.DefBuckets
+	}
+	if opts.SizeBuckets == nil {
+		opts.SizeBuckets = prometheus.ExponentialBuckets(64, 4, 8)
+	}
+	return &Collector{
+		client: newMetrics(opts, "client"),
+		server: newMetrics(opts, "server"),
+
+		conns: prometheus.NewDesc(
+			prometheus.BuildFQName(opts.Namespace, "server", "active_connections"),
+			"Number of connections being served by the observed servers.",
+			nil, nil),
+		buffered: prometheus.NewDesc(
+			prometheus.BuildFQName(opts.Namespace, "server", "buffered_bytes"),
+			"Number of bytes read from the observed servers' connections and not yet taken by a stream.",
+			nil, nil),
+		inflight: prometheus.NewDesc(
+			prometheus.BuildFQName(opts.Namespace, "server", "in_flight"),
+			"Number of rpcs with a running handler on the observed servers.",
+			nil, nil),
+		draining: prometheus.NewDesc(
+			prometheus.BuildFQName(opts.Namespace, "server", "draining"),
+			"Number of the observed servers that are draining.",
+			nil, nil),
+	}
+}
+
+// ObserveServer adds the server to the servers whose active connections,
+// buffered bytes, rpcs in flight and draining state are reported. It is
+// separate from InterceptServer because those are known only to the server
+// itself.
+func (c *Collector) ObserveServer(srv *drpcserver.Server) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.servers = append(c.servers, srv)
+}
+
+// newMetrics constructs the metrics for the side.
+func newMetrics(opts Options, side string) metrics {
+	return metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: opts.Namespace,
+			Subsystem: side,
+			Name:      "requests_total",
+			Help:      "Number of rpcs started by the " + side + ".",
+		}, []string{"rpc", "stream"}),
+
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: opts.Namespace,
+			Subsystem: side,
+			Name:      "errors_total",
+			Help:      "Number of rpcs that failed on the " + side + " by status code.",
+		}, []string{"rpc", "stream", "code"}),
+
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: opts.Namespace,
+			Subsystem: side,
+			Name:      "latency_seconds",
+			Help:      "Duration of rpcs on the " + side + ".",
+			Buckets:   opts.Buckets,
+		}, []string{"rpc", "stream"}),
+
+		active: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: opts.Namespace,
+			Subsystem: side,
+			Name:      "active_streams",
+			Help:      "Number of rpcs, unitary or stream, currently active on the " + side + ".",
+		}),
+
+		sent: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: opts.Namespace,
+			Subsystem: side,
+			Name:      "stream_messages_sent_total",
+			Help:      "Number of messages sent on streams by the " + side + ".",
+		}, []string{"rpc"}),
+
+		received: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: opts.Namespace,
+			Subsystem: side,
+			Name:      "stream_messages_received_total",
+			Help:      "Number of messages received on streams by the " + side + ".",
+		}, []string{"rpc"}),
+
+		sizes: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: opts.Namespace,
+			Subsystem: side,
+			Name:      "stream_message_size_bytes",
+			Help:      "Encoded size of messages sent and received on streams by the " + side + ".",
+			Buckets:   opts.SizeBuckets,
+		}, []string{"rpc", "direction"}),
+	}
+}
+
+// Describe sends the descriptors of the metrics to ch.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, m := range []*metrics{&c.client, &c.server} {
+		m.requests.Describe(ch)
+		m.errors.Describe(ch)
+		m.latency.Describe(ch)
+		m.active.Describe(ch)
+		m.sent.Describe(ch)
+		m.received.Describe(ch)
+		m.sizes.Describe(ch)
+	}
+	ch <- c.conns
+	ch <- c.buffered
+	ch <- c.inflight
+	ch <- c.draining
+}
+
+// Collect sends the metrics to ch.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	for _, m := range []*metrics{&c.client, &c.server} {
+		m.requests.Collect(ch)
+		m.errors.Collect(ch)
+		m.latency.Collect(ch)
+		m.active.Collect(ch)
+		m.sent.Collect(ch)
+		m.received.Collect(ch)
+		m.sizes.Collect(ch)
+	}
+
+	c.mu.Lock()
+	servers := append([]*drpcserver.Server(nil), c.servers...)
+	c.mu.Unlock()
+
+	var conns, inflight, draining int
+	var buffered uint64
+	for _, srv := range servers {
+		inflight += srv.InFlight()
+		if srv.Draining() {
+			draining++
+		}
+		for _, cs := range srv.ConnStats() {
+			conns++
+			buffered += cs.Stats.Buffered
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(c.conns, prometheus.GaugeValue, float64(conns))
+	ch <- prometheus.MustNewConstMetric(c.buffered, prometheus.GaugeValue, float64(buffered))
+	ch <- prometheus.MustNewConstMetric(c.inflight, prometheus.GaugeValue, float64(inflight))
+	ch <- prometheus.MustNewConstMetric(c.draining, prometheus.GaugeValue, float64(draining))
+}
+
+// observe records an rpc that started at start and finished with err.
+func (m *metrics) observe(rpc string, stream bool, start time.Time, err error) {
+	label := strconv.FormatBool(stream)
+	m.requests.WithLabelValues(rpc, label).Inc()
+	m.latency.WithLabelValues(rpc, label).Observe(time.Since(start).Seconds())
+	if err != nil {
+		m.errors.WithLabelValues(rpc, label, drpcstatus.CodeFromError(err).String()).Inc()
+	}
+}
+
+// send sends the message on the stream of the rpc, counting it if it is sent.
+// Its size is only known if it is encoded by enc.
+func (m *metrics) send(stream drpc.Stream, rpc string, msg drpc.Message, enc drpc.Encoding) error {
+	cenc, ce := countEncoding(enc)
+	if err := stream.MsgSend(msg, cenc); err != nil {
+		return err
+	}
+	m.sent.WithLabelValues(rpc).Inc()
+	ce.observe(m.sizes.WithLabelValues(rpc, "sent"), 1)
+	return nil
+}
+
+// sendBatch sends the messages on the stream of the rpc, counting the ones that
+// are sent. It uses the MsgSendBatch method of the stream if it has one, and
+// otherwise sends them one at a time.
+func (m *metrics) sendBatch(stream drpc.Stream, rpc string, msgs []drpc.Message, enc drpc.Encoding) (int, error) {
+	bs, ok := stream.(batchSender)
+	if !ok {
+		for i, msg := range msgs {
+			if err := m.send(stream, rpc, msg, enc); err != nil {
+				return i, err
+			}
+		}
+		return len(msgs), nil
+	}
+
+	cenc, ce := countEncoding(enc)
+	n, err := bs.MsgSendBatch(msgs, cenc)
+	m.sent.WithLabelValues(rpc).Add(float64(n))
+	ce.observe(m.sizes.WithLabelValues(rpc, "sent"), n)
+	return n, err
+}
+
+// recv receives a message on the stream of the rpc, counting it if one is
+// received. Its size is only known if it is decoded by enc.
+func (m *metrics) recv(stream drpc.Stream, rpc string, msg drpc.Message, enc drpc.Encoding) error {
+	cenc, ce := countEncoding(enc)
+	if err := stream.MsgRecv(msg, cenc); err != nil {
+		return err
+	}
+	m.received.WithLabelValues(rpc).Inc()
+	ce.observe(m.sizes.WithLabelValues(rpc, "received"), 1)
+	return nil
+}
+
+// batchSender is implemented by streams that can send many messages at once,
+// like *drpcstream.Stream.
+type batchSender interface {
+	MsgSendBatch(msgs []drpc.Message, enc drpc.Encoding) (int, error)
+}
+
+// InterceptInvoke records metrics about the unitary rpc.
+func (c *Collector) InterceptInvoke(ctx context.Context, rpc string, enc drpc.Encoding, in, out drpc.Message, next drpc.InvokeFunc) (err error) {
+	start := time.Now()
+	c.client.active.Inc()
+	defer c.client.active.Dec()
+	defer func() { c.client.observe(rpc, false, start, err) }()
+
+	return next(ctx, rpc, enc, in, out)
+}
+
+// InterceptNewStream records metrics about the stream. It is recorded when it
+// is closed, when sending or receiving on it fails, or when its context is
+// done, with the error it failed with, and its latency is the time until then.
+// It is counted as active until it is closed or its context is done.
+func (c *Collector) InterceptNewStream(ctx context.Context, rpc string, enc drpc.Encoding, next drpc.NewStreamFunc) (drpc.Stream, error) {
+	start := time.Now()
+
+	stream, err := next(ctx, rpc, enc)
+	if err != nil {
+		c.client.observe(rpc, true, start, err)
+		return nil, err
+	}
+
+	c.client.active.Inc()
+	cs := &clientStream{
+		Stream:   stream,
+		rpc:      rpc,
+		m:        &c.client,
+		observe:  func(err error) { c.client.observe(rpc, true, start, err) },
+		finished: c.client.active.Dec,
+	}
+	go func() {
+		<-stream.Context().Done()
+		cs.once.Do(func() { cs.observe(streamErr(stream)) })
+		cs.finish()
+	}()
+	return cs, nil
+}
+
+// InterceptServer records metrics about the rpc being handled. The kind of rpc
+// comes from drpcmux.MethodFromContext. Only the messages of stream rpcs sent
+// and received by the handler are counted, so the input of rpcs that have a
+// stream of output only, which is received before, is not.
+//
+// To count the messages of stream rpcs, the stream passed to the handler is
+// wrapped, so that methods other than those of drpc.Stream, like MsgSendBatch,
+// are not available to the handler through type assertions.
+func (c *Collector) InterceptServer(ctx context.Context, rpc string, in drpc.Message, stream drpc.Stream, next drpc.ServerHandler) (out drpc.Message, err error) {
+	start := time.Now()
+	info, ok := drpcmux.MethodFromContext(ctx)
+	isStream := ok && !info.Unitary
+	c.server.active.Inc()
+	defer c.server.active.Dec()
+	defer func() { c.server.observe(rpc, isStream, start, err) }()
+
+	if isStream {
+		stream = &serverStream{Stream: stream, rpc: rpc, m: &c.server}
+	}
+	return next(ctx, rpc, in, stream)
+}
+
+// serverStream counts the messages sent and received by a handler. Handlers
+// can still send headers and trailers with drpcmetadata, which finds the
+// underlying stream through the context.
+type serverStream struct {
+	drpc.Stream
+	rpc string
+	m   *metrics
+}
+
+// MsgSend sends the message, counting it.
+func (s *serverStream) MsgSend(msg drpc.Message, enc drpc.Encoding) error {
+	return s.m.send(s.Stream, s.rpc, msg, enc)
+}
+
+// MsgRecv receives a message, counting it.
+func (s *serverStream) MsgRecv(msg drpc.Message, enc drpc.Encoding) error {
+	return s.m.recv(s.Stream, s.rpc, msg, enc)
+}
+
+// clientStream counts the messages sent and received on it, calls observe the
+// first time the stream fails or is closed, and finished the first time it is
+// closed or its context is done.
+type clientStream struct {
+	drpc.Stream
+	rpc      string
+	m        *metrics
+	once     sync.Once
+	observe  func(err error)
+	fin      sync.Once
+	finished func()
+}
+
+func (s *clientStream) finish() { s.fin.Do(s.finished) }
+
+func (s *clientStream) done(err error) error {
+	if err != nil {
+		s.once.Do(func() {
+			if errors.Is(err, io.EOF) {
+				s.observe(nil)
+			} else {
+				s.observe(err)
+			}
+		})
+	}
+	return err
+}
+
+// MsgSend sends the message, counting it, or recording the stream if it fails.
+func (s *clientStream) MsgSend(msg drpc.Message, enc drpc.Encoding) error {
+	return s.done(s.m.send(s.Stream, s.rpc, msg, enc))
+}
+
+// MsgRecv receives a message, counting it, or recording the stream if it
+// fails.
+func (s *clientStream) MsgRecv(msg drpc.Message, enc drpc.Encoding) error {
+	return s.done(s.m.recv(s.Stream, s.rpc, msg, enc))
+}
+
+// MsgSendBatch sends the messages, counting the ones that are sent, or
+// recording the stream if it fails.
+func (s *clientStream) MsgSendBatch(msgs []drpc.Message, enc drpc.Encoding) (int, error) {
+	n, err := s.m.sendBatch(s.Stream, s.rpc, msgs, enc)
+	return n, s.done(err)
+}
+
+// Close closes the stream and records it.
+func (s *clientStream) Close() error {
+	err := s.Stream.Close()
+	s.once.Do(func() { s.observe(err) })
+	s.finish()
+	return err
+}
+
+// streamErr returns why the stream, whose context is done, ended. Streams that
+// do not report it are considered to have been canceled.
+func streamErr(stream drpc.Stream) error {
+	if st, ok := stream.(interface{ Err() error }); ok {
+		return st.Err()
+	}
+	return stream.Context().Err()
+}
+
+// countingEncoding records the sizes of the messages it encodes or decodes.
+type countingEncoding struct {
+	drpc.Encoding
+	sizes []int
+}
+
+// countEncoding returns an encoding that records the sizes of the messages enc
+// encodes or decodes into the returned countingEncoding. The JSONMarshal and
+// JSONUnmarshal methods drpchttp looks for are kept, and if enc has only one of
+// them, it is returned unchanged so that neither is hidden, and no sizes are
+// recorded.
+func countEncoding(enc drpc.Encoding) (drpc.Encoding, *countingEncoding) {
+	ce := &countingEncoding{Encoding: enc}
+	_, jm := enc.(jsonMarshaler)
+	_, ju := enc.(jsonUnmarshaler)
+	switch {
+	case jm && ju:
+		return countingJSONEncoding{ce}, ce
+	case jm || ju:
+		return enc, ce
+	default:
+		return ce, ce
+	}
+}
+
+// observe adds the sizes of the first n messages to the histogram.
+func (e *countingEncoding) observe(h prometheus.Observer, n int) {
+	for i := 0; i < n && i < len(e.sizes); i++ {
+		h.Observe(float64(e.sizes[i]))
+	}
+}
+
+// Marshal encodes the message, recording its size.
+func (e *countingEncoding) Marshal(msg drpc.Message) ([]byte, error) {
+	data, err := e.Encoding.Marshal(msg)
+	if err == nil {
+		e.sizes = append(e.sizes, len(data))
+	}
+	return data, err
+}
+
+// MarshalAppend encodes the message onto buf, recording its size.
+func (e *countingEncoding) MarshalAppend(buf []byte, msg drpc.Message) ([]byte, error) {
+	data, err := drpcenc.MarshalAppend(msg, e.Encoding, buf)
+	if err == nil {
+		e.sizes = append(e.sizes, len(data)-len(buf))
+	}
+	return data, err
+}
+
+// Unmarshal decodes the message, recording its size.
+func (e *countingEncoding) Unmarshal(buf []byte, msg drpc.Message) error {
+	e.sizes = append(e.sizes, len(buf))
+	return e.Encoding.Unmarshal(buf, msg)
+}
+
+type (
+	jsonMarshaler = interface {
+		JSONMarshal(msg drpc.Message) ([]byte, error)
+	}
+	jsonUnmarshaler = interface {
+		JSONUnmarshal(buf []byte, msg drpc.Message) error
+	}
+)
+
+// countingJSONEncoding is a countingEncoding for encodings with the JSON
+// methods, which records the sizes of the JSON messages too.
+type countingJSONEncoding struct{ *countingEncoding }
+
+// JSONMarshal encodes the message as JSON, recording its size.
+func (e countingJSONEncoding) JSONMarshal(msg drpc.Message) ([]byte, error) {
+	data, err := e.Encoding.(jsonMarshaler).JSONMarshal(msg)
+	if err == nil {
+		e.sizes = append(e.sizes, len(data))
+	}
+	return data, err
+}
+
+// JSONUnmarshal decodes the message from JSON, recording its size.
+func (e countingJSONEncoding) JSONUnmarshal(buf []byte, msg drpc.Message) error {
+	e.sizes = append(e.sizes, len(buf))
+	return e.Encoding.(jsonUnmarshaler).JSONUnmarshal(buf, msg)
+}
@@ -0,0 +1,410 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcprom
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/zeebo/assert"
+
+	"storj.io/drpc"
+	"storj.io/drpc/drpcconn"
+	"storj.io/drpc/drpchealth"
+	"storj.io/drpc/drpchttp"
+	"storj.io/drpc/drpcmux"
+	"storj.io/drpc/drpcpipe"
+	"storj.io/drpc/drpcserver"
+)
+
+// value returns the value of the counter or gauge, or the count of the
+// histogram, with the name and labels in the registry, and false if there is
+// none.
+func value(t *testing.T, reg *prometheus.Registry, name string, labels map[string]string) (float64, bool) {
+	t.Helper()
+
+	families, err := reg.Gather()
+	assert.NoError(t, err)
+
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, metric := range family.GetMetric() {
+			if len(metric.GetLabel()) != len(labels) {
+				continue
+			}
+			for _, label := range metric.GetLabel() {
+				if labels[label.GetName()] != label.GetValue() {
+					continue metrics
+				}
+			}
+			if metric.GetHistogram() != nil {
+				return float64(metric.GetHistogram().GetSampleCount()), true
+			}
+			if metric.GetGauge() != nil {
+				return metric.GetGauge().GetValue(), true
+			}
+			return metric.GetCounter().GetValue(), true
+		}
+	}
+	return 0, false
+}
+
+func TestCollector(t *testing.T) {
+	ctx := context.Background()
+
+	col := New()
+	reg := prometheus.NewRegistry()
+	assert.NoError(t, reg.Register(col))
+
+	mux := drpcmux.NewWithOptions(drpcmux.Options{Interceptor: col.InterceptServer})
+	assert.NoError(t, drpchealth.Register(mux, drpchealth.New()))
+
+	conn, cleanup := drpcpipe.NewWithOptions(mux, drpcpipe.Options{
+		Conn: drpcconn.Options{Interceptor: col},
+	})
+	defer cleanup()
+	cli := drpchealth.NewClient(conn)
+
+	for i := 0; i < 3; i++ {
+		_, err := cli.Check(ctx, "")
+		assert.NoError(t, err)
+	}
+	_, err := cli.Check(ctx, "unknown")
+	assert.Error(t, err)
+
+	stream, err := cli.Watch(ctx, "")
+	assert.NoError(t, err)
+	_, err = stream.Recv()
+	assert.NoError(t, err)
+	assert.NoError(t, stream.Close())
+
+	// failed streams are recorded with their error.
+	wctx, cancel := context.WithCancel(ctx)
+	stream, err = cli.Watch(wctx, "")
+	assert.NoError(t, err)
+	_, err = stream.Recv()
+	assert.NoError(t, err)
+	cancel()
+	_, err = stream.Recv()
+	assert.Error(t, err)
+
+	check := map[string]string{"rpc": drpchealth.CheckRPC, "stream": "false"}
+	watch := map[string]string{"rpc": drpchealth.WatchRPC, "stream": "true"}
+	notFound := map[string]string{"rpc": drpchealth.CheckRPC, "stream": "false", "code": "NotFound"}
+
+	for _, side := range []string{"client", "server"} {
+		v, _ := value(t, reg, "drpc_"+side+"_requests_total", check)
+		assert.Equal(t, v, 4.0)
+		v, _ = value(t, reg, "drpc_"+side+"_errors_total", notFound)
+		assert.Equal(t, v, 1.0)
+		v, _ = value(t, reg, "drpc_"+side+"_latency_seconds", check)
+		assert.Equal(t, v, 4.0)
+
+		// the watch is recorded once it is finished, which happens
+		// asynchronously after it is closed.
+		for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(time.Millisecond) {
+			if v, _ = value(t, reg, "drpc_"+side+"_requests_total", watch); v == 2 {
+				break
+			}
+		}
+		assert.Equal(t, v, 2.0)
+
+		_, ok := value(t, reg, "drpc_"+side+"_errors_total", map[string]string{
+			"rpc": drpchealth.WatchRPC, "stream": "true", "code": "OK",
+		})
+		assert.That(t, !ok)
+	}
+
+	// the server's handler returns without an error once the client cancels.
+	v, _ := value(t, reg, "drpc_client_errors_total", map[string]string{
+		"rpc": drpchealth.WatchRPC, "stream": "true", "code": "Canceled",
+	})
+	assert.Equal(t, v, 1.0)
+}
+
+func TestCollector_StreamContextDone(t *testing.T) {
+	ctx := context.Background()
+
+	col := New()
+	reg := prometheus.NewRegistry()
+	assert.NoError(t, reg.Register(col))
+
+	mux := drpcmux.NewWithOptions(drpcmux.Options{Interceptor: col.InterceptServer})
+	assert.NoError(t, drpchealth.Register(mux, drpchealth.New()))
+
+	conn, cleanup := drpcpipe.NewWithOptions(mux, drpcpipe.Options{
+		Conn: drpcconn.Options{Interceptor: col},
+	})
+	defer cleanup()
+	cli := drpchealth.NewClient(conn)
+
+	// a stream that is never used again after its context is canceled is
+	// still recorded, with the error it ended with.
+	wctx, cancel := context.WithCancel(ctx)
+	_, err := cli.Watch(wctx, "")
+	assert.NoError(t, err)
+	cancel()
+
+	watch := map[string]string{"rpc": drpchealth.WatchRPC, "stream": "true"}
+	canceled := map[string]string{"rpc": drpchealth.WatchRPC, "stream": "true", "code": "Canceled"}
+
+	var v float64
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(time.Millisecond) {
+		if v, _ = value(t, reg, "drpc_client_requests_total", watch); v == 1 {
+			break
+		}
+	}
+	assert.Equal(t, v, 1.0)
+	v, _ = value(t, reg, "drpc_client_latency_seconds", watch)
+	assert.Equal(t, v, 1.0)
+	v, _ = value(t, reg, "drpc_client_errors_total", canceled)
+	assert.Equal(t, v, 1.0)
+}
+
+func TestCollector_Gauges(t *testing.T) {
+	ctx := context.Background()
+
+	col := New()
+	reg := prometheus.NewRegistry()
+	assert.NoError(t, reg.Register(col))
+
+	mux := drpcmux.NewWithOptions(drpcmux.Options{Interceptor: col.InterceptServer})
+	assert.NoError(t, drpchealth.Register(mux, drpchealth.New()))
+
+	srv := drpcserver.New(mux)
+	col.ObserveServer(srv)
+
+	ctr, str := drpcpipe.Transports()
+	done := make(chan struct{})
+	go func() { _ = srv.ServeOne(ctx, str); close(done) }()
+
+	conn := drpcconn.NewWithOptions(ctr, drpcconn.Options{Interceptor: col})
+	cli := drpchealth.NewClient(conn)
+
+	// wait waits for the gauge to have the expected value, which happens
+	// asynchronously on the server and for streams that are not closed.
+	wait := func(name string, expected float64) {
+		t.Helper()
+		var v float64
+		for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(time.Millisecond) {
+			if v, _ = value(t, reg, name, nil); v == expected {
+				return
+			}
+		}
+		assert.Equal(t, v, expected)
+	}
+	active := func(expected float64) {
+		t.Helper()
+		wait("drpc_client_active_streams", expected)
+		wait("drpc_server_active_streams", expected)
+	}
+
+	wait("drpc_server_active_connections", 1)
+
+	wait("drpc_server_draining", 0)
+	srv.SetDraining(true)
+	wait("drpc_server_draining", 1)
+	srv.SetDraining(false)
+
+	// a stream closed by the client.
+	stream, err := cli.Watch(ctx, "")
+	assert.NoError(t, err)
+	_, err = stream.Recv()
+	assert.NoError(t, err)
+	active(1)
+	wait("drpc_server_in_flight", 1)
+	assert.NoError(t, stream.Close())
+	active(0)
+	wait("drpc_server_in_flight", 0)
+
+	// a stream whose context is canceled without it being closed.
+	wctx, cancel := context.WithCancel(ctx)
+	stream, err = cli.Watch(wctx, "")
+	assert.NoError(t, err)
+	_, err = stream.Recv()
+	assert.NoError(t, err)
+	active(1)
+	cancel()
+	active(0)
+
+	// a unitary rpc that fails.
+	_, err = cli.Check(ctx, "unknown")
+	assert.Error(t, err)
+	active(0)
+
+	wait("drpc_server_buffered_bytes", 0)
+
+	assert.NoError(t, conn.Close())
+	<-done
+	wait("drpc_server_active_connections", 0)
+}
+
+func TestCollector_StreamMessages(t *testing.T) {
+	ctx := context.Background()
+
+	col := New()
+	reg := prometheus.NewRegistry()
+	assert.NoError(t, reg.Register(col))
+
+	checker := drpchealth.New()
+	mux := drpcmux.NewWithOptions(drpcmux.Options{Interceptor: col.InterceptServer})
+	assert.NoError(t, drpchealth.Register(mux, checker))
+
+	conn, cleanup := drpcpipe.NewWithOptions(mux, drpcpipe.Options{
+		Conn: drpcconn.Options{Interceptor: col},
+	})
+	defer cleanup()
+	cli := drpchealth.NewClient(conn)
+
+	// wait waits for the metric to have the expected value, which happens
+	// asynchronously for the server.
+	wait := func(name string, labels map[string]string, expected float64) {
+		t.Helper()
+		var v float64
+		for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(time.Millisecond) {
+			if v, _ = value(t, reg, name, labels); v == expected {
+				return
+			}
+		}
+		assert.Equal(t, v, expected)
+	}
+
+	rpc := map[string]string{"rpc": drpchealth.WatchRPC}
+	sent := map[string]string{"rpc": drpchealth.WatchRPC, "direction": "sent"}
+	received := map[string]string{"rpc": drpchealth.WatchRPC, "direction": "received"}
+
+	stream, err := cli.Watch(ctx, "")
+	assert.NoError(t, err)
+	defer func() { _ = stream.Close() }()
+
+	// the counters advance with every message while the stream is open.
+	for i := 1; i <= 5; i++ {
+		if i > 1 {
+			checker.SetStatus("", drpchealth.Status(2-i%2))
+		}
+		_, err := stream.Recv()
+		assert.NoError(t, err)
+
+		wait("drpc_client_stream_messages_sent_total", rpc, 1)
+		wait("drpc_client_stream_messages_received_total", rpc, float64(i))
+		wait("drpc_client_stream_message_size_bytes", sent, 1)
+		wait("drpc_client_stream_message_size_bytes", received, float64(i))
+		wait("drpc_server_stream_messages_sent_total", rpc, float64(i))
+		wait("drpc_server_stream_message_size_bytes", sent, float64(i))
+	}
+
+	// the input of the watch is received before the interceptor is called.
+	_, ok := value(t, reg, "drpc_server_stream_messages_received_total", rpc)
+	assert.That(t, !ok)
+}
+
+// item is a message with a JSON encoding that differs from its binary one.
+type item struct{ Value string }
+
+type itemEncoding struct{}
+
+func (itemEncoding) Marshal(msg drpc.Message) ([]byte, error) {
+	return []byte("binary:" + msg.(*item).Value), nil
+}
+
+func (itemEncoding) Unmarshal(buf []byte, msg drpc.Message) error {
+	msg.(*item).Value = strings.TrimPrefix(string(buf), "binary:")
+	return nil
+}
+
+func (itemEncoding) JSONMarshal(msg drpc.Message) ([]byte, error) { return json.Marshal(msg) }
+
+func (itemEncoding) JSONUnmarshal(buf []byte, msg drpc.Message) error {
+	return json.Unmarshal(buf, msg)
+}
+
+// itemServer streams back every byte of the value of its input as an item.
+type itemServer struct{}
+
+func (itemServer) Split(in *item, stream drpc.Stream) error {
+	for i := range in.Value {
+		if err := stream.MsgSend(&item{Value: in.Value[i : i+1]}, itemEncoding{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type itemDescription struct{}
+
+func (itemDescription) NumMethods() int { return 1 }
+
+func (itemDescription) Method(n int) (string, drpc.Encoding, drpc.Receiver, interface{}, bool) {
+	if n != 0 {
+		return "", nil, nil, nil, false
+	}
+	return "/items/Split", itemEncoding{},
+		func(srv interface{}, ctx context.Context, in1, in2 interface{}) (drpc.Message, error) {
+			return nil, srv.(itemServer).Split(in1.(*item), in2.(drpc.Stream))
+		}, itemServer.Split, true
+}
+
+func TestCollector_HTTPJSON(t *testing.T) {
+	col := New()
+	reg := prometheus.NewRegistry()
+	assert.NoError(t, reg.Register(col))
+
+	mux := drpcmux.NewWithOptions(drpcmux.Options{Interceptor: col.InterceptServer})
+	assert.NoError(t, mux.Register(itemServer{}, itemDescription{}))
+
+	hsrv := httptest.NewServer(drpchttp.New(mux))
+	defer hsrv.Close()
+
+	msg := `{"Value":"abc"}`
+	body := append([]byte{0, 0, 0, 0, 0}, msg...)
+	binary.BigEndian.PutUint32(body[1:5], uint32(len(msg)))
+
+	req, err := http.NewRequest("POST", hsrv.URL+"/items/Split", bytes.NewReader(body))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/grpc-web+json")
+
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	// the messages are still sent with the JSON encoding of the messages.
+	var msgs []string
+	for {
+		var hdr [5]byte
+		if _, err := io.ReadFull(resp.Body, hdr[:]); errors.Is(err, io.EOF) {
+			break
+		} else {
+			assert.NoError(t, err)
+		}
+		data := make([]byte, binary.BigEndian.Uint32(hdr[1:5]))
+		_, err := io.ReadFull(resp.Body, data)
+		assert.NoError(t, err)
+		if hdr[0]&128 == 0 {
+			msgs = append(msgs, string(data))
+		}
+	}
+	assert.DeepEqual(t, msgs, []string{`{"Value":"a"}`, `{"Value":"b"}`, `{"Value":"c"}`})
+
+	// and counted, along with their sizes.
+	rpc := map[string]string{"rpc": "/items/Split"}
+	v, _ := value(t, reg, "drpc_server_stream_messages_sent_total", rpc)
+	assert.Equal(t, v, 3.0)
+	v, _ = value(t, reg, "drpc_server_stream_message_size_bytes", map[string]string{
+		"rpc": "/items/Split", "direction": "sent",
+	})
+	assert.Equal(t, v, 3.0)
+}
@@ -0,0 +1,14 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+// Package drpcprom provides Prometheus metrics for drpc clients and servers.
+//
+// It is a separate module so that drpc itself does not depend on Prometheus.
+// The metrics are labeled by rpc and whether it is a stream, and errors are
+// additionally labeled by their drpcstatus code. Peer addresses are not used
+// as labels to keep the cardinality of the metrics low.
+//
+// Gauges report the rpcs active on each side, and for servers passed to
+// ObserveServer, the connections being served and the bytes read from them
+// that are waiting to be taken by a stream.
+package drpcprom
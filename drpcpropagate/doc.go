@@ -0,0 +1,16 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+// Package drpcpropagate provides interceptors that carry registered context
+// values from the context of a client's rpc to the context of the server's
+// handler.
+//
+// Each value is described by a Key, which has a name and the functions that
+// encode its values to and decode them from a string. The client interceptor
+// sends the values of its keys that are associated with the context in the
+// outgoing metadata, and the server interceptor associates the received values
+// with the handler's context, where they are returned by the Value method of
+// the same Key. Values of keys that are not passed to New are never sent.
+// Because the values are associated with the handler's context, they are
+// propagated again by any rpc the handler issues with a client interceptor.
+package drpcpropagate
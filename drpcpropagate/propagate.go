@@ -0,0 +1,165 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcpropagate
+
+import (
+	"context"
+
+	"github.com/zeebo/errs"
+
+	"storj.io/drpc"
+	"storj.io/drpc/drpcmetadata"
+	"storj.io/drpc/drpcstatus"
+)
+
+// Error is the class of errors returned when a value can not be encoded or
+// decoded.
+var Error = errs.Class("drpcpropagate")
+
+// Prefix is prepended to the name of a Key to form the metadata key its values
+// are sent with.
+const Prefix = "drpc-value-"
+
+// Propagated is implemented by every Key so that keys with values of different
+// types can be passed to New.
+type Propagated interface {
+	// Name returns the name of the key.
+	Name() string
+
+	encode(ctx context.Context) (string, bool, error)
+	decode(ctx context.Context, value string) (context.Context, error)
+}
+
+// Key describes a context value of type T that can be propagated.
+type Key[T any] struct {
+	name string
+	enc  func(T) (string, error)
+	dec  func(string) (T, error)
+}
+
+var _ Propagated = (*Key[string])(nil)
+
+// NewKey returns a Key with the name, which should be lower case and unique
+// among the keys of an Interceptor, whose values are sent as the strings
+// returned by enc and decoded with dec.
+func NewKey[T any](name string, enc func(T) (string, error), dec func(string) (T, error)) *Key[T] {
+	return &Key[T]{name: name, enc: enc, dec: dec}
+}
+
+// NewStringKey returns a Key with the name whose string values are sent as
+// they are.
+func NewStringKey(name string) *Key[string] {
+	same := func(v string) (string, error) { return v, nil }
+	return NewKey(name, same, same)
+}
+
+// Name returns the name of the key.
+func (k *Key[T]) Name() string { return k.name }
+
+// WithValue returns a context associated with the value for the key.
+func (k *Key[T]) WithValue(ctx context.Context, value T) context.Context {
+	return context.WithValue(ctx, k, value)
+}
+
+// Value returns the value for the key associated with the context, if any.
+func (k *Key[T]) Value(ctx context.Context) (value T, ok bool) {
+	value, ok = ctx.Value(k).(T)
+	return value, ok
+}
+
+// encode returns the encoded value associated with the context, if any.
+func (k *Key[T]) encode(ctx context.Context) (string, bool, error) {
+	value, ok := k.Value(ctx)
+	if !ok {
+		return "", false, nil
+	}
+	enc, err := k.enc(value)
+	if err != nil {
+		return "", false, Error.New("encoding %q: %v", k.name, err)
+	}
+	return enc, true, nil
+}
+
+// decode returns a context associated with the decoded value.
+func (k *Key[T]) decode(ctx context.Context, value string) (context.Context, error) {
+	dec, err := k.dec(value)
+	if err != nil {
+		return nil, Error.New("decoding %q: %v", k.name, err)
+	}
+	return k.WithValue(ctx, dec), nil
+}
+
+// Interceptor propagates the values of a set of keys. It implements
+// drpc.ClientInterceptor, and its InterceptServer method is a
+// drpc.ServerInterceptor.
+type Interceptor struct {
+	keys []Propagated
+}
+
+var _ drpc.ClientInterceptor = (*Interceptor)(nil)
+var _ drpc.ServerInterceptor = (*Interceptor)(nil).InterceptServer
+
+// New returns an Interceptor that propagates the values of the keys.
+func New(keys ...Propagated) *Interceptor {
+	return &Interceptor{keys: append([]Propagated(nil), keys...)}
+}
+
+// InterceptInvoke issues the rpc with the values associated with the context.
+func (i *Interceptor) InterceptInvoke(ctx context.Context, rpc string, enc drpc.Encoding, in, out drpc.Message, next drpc.InvokeFunc) error {
+	ctx, err := i.outgoing(ctx)
+	if err != nil {
+		return err
+	}
+	return next(ctx, rpc, enc, in, out)
+}
+
+// InterceptNewStream starts the stream with the values associated with the
+// context.
+func (i *Interceptor) InterceptNewStream(ctx context.Context, rpc string, enc drpc.Encoding, next drpc.NewStreamFunc) (drpc.Stream, error) {
+	ctx, err := i.outgoing(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return next(ctx, rpc, enc)
+}
+
+// InterceptServer associates the values sent by the client with the context
+// passed to next. If a value can not be decoded, the rpc fails with a
+// drpcstatus.InvalidArgument status without calling next.
+func (i *Interceptor) InterceptServer(ctx context.Context, rpc string, in drpc.Message, stream drpc.Stream, next drpc.ServerHandler) (drpc.Message, error) {
+	md, _ := drpcmetadata.MetadataFromContext(ctx)
+	for _, key := range i.keys {
+		values := md.Get(Prefix + key.Name())
+		if len(values) == 0 {
+			continue
+		}
+		var err error
+		if ctx, err = key.decode(ctx, values[0]); err != nil {
+			return nil, drpcstatus.Errorf(drpcstatus.InvalidArgument, "%v", err)
+		}
+	}
+	return next(ctx, rpc, in, stream)
+}
+
+// outgoing returns a context that sends the values of the keys associated
+// with ctx.
+func (i *Interceptor) outgoing(ctx context.Context) (context.Context, error) {
+	var md drpcmetadata.Metadata
+	for _, key := range i.keys {
+		value, ok, err := key.encode(ctx)
+		if err != nil {
+			return nil, err
+		} else if !ok {
+			continue
+		}
+		if md == nil {
+			md = make(drpcmetadata.Metadata, len(i.keys))
+		}
+		md.Set(Prefix+key.Name(), value)
+	}
+	if md == nil {
+		return ctx, nil
+	}
+	return drpcmetadata.WithOutgoingMetadata(ctx, md), nil
+}
@@ -0,0 +1,86 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcpropagate
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/zeebo/assert"
+
+	"storj.io/drpc"
+	"storj.io/drpc/drpcmetadata"
+	"storj.io/drpc/drpcstatus"
+)
+
+type tenantID int64
+
+var tenantKey = NewKey("tenant-id",
+	func(id tenantID) (string, error) { return strconv.FormatInt(int64(id), 10), nil },
+	func(v string) (tenantID, error) {
+		id, err := strconv.ParseInt(v, 10, 64)
+		return tenantID(id), err
+	})
+
+// roundTrip issues an rpc with the context through the client interceptor and
+// handles it with the metadata it sent through the server interceptor,
+// returning the metadata and the context passed to the handler.
+func roundTrip(t *testing.T, ctx context.Context, i *Interceptor) (sent drpcmetadata.Metadata, handled context.Context) {
+	assert.NoError(t, i.InterceptInvoke(ctx, "/rpc", nil, nil, nil,
+		func(ctx context.Context, rpc string, enc drpc.Encoding, in, out drpc.Message) error {
+			sent, _ = drpcmetadata.OutgoingMetadataFromContext(ctx)
+			return nil
+		}))
+
+	ctx = drpcmetadata.WithIncomingMetadata(context.Background(), sent)
+	_, err := i.InterceptServer(ctx, "/rpc", nil, nil,
+		func(ctx context.Context, rpc string, in drpc.Message, stream drpc.Stream) (drpc.Message, error) {
+			handled = ctx
+			return nil, nil
+		})
+	assert.NoError(t, err)
+	return sent, handled
+}
+
+func TestInterceptor(t *testing.T) {
+	other := NewStringKey("other")
+	i := New(tenantKey)
+
+	{ // registered values are propagated
+		ctx := tenantKey.WithValue(context.Background(), 42)
+		ctx = other.WithValue(ctx, "secret")
+
+		sent, handled := roundTrip(t, ctx, i)
+		assert.DeepEqual(t, sent, drpcmetadata.Metadata{Prefix + "tenant-id": {"42"}})
+
+		id, ok := tenantKey.Value(handled)
+		assert.That(t, ok)
+		assert.Equal(t, id, tenantID(42))
+
+		// but values of keys that are not registered are not
+		_, ok = other.Value(handled)
+		assert.That(t, !ok)
+	}
+
+	{ // nothing is sent without values
+		sent, handled := roundTrip(t, context.Background(), i)
+		assert.Nil(t, sent)
+
+		_, ok := tenantKey.Value(handled)
+		assert.That(t, !ok)
+	}
+}
+
+func TestInterceptor_DecodeError(t *testing.T) {
+	ctx := drpcmetadata.WithIncomingMetadata(context.Background(),
+		drpcmetadata.Metadata{Prefix + "tenant-id": {"bad"}})
+
+	_, err := New(tenantKey).InterceptServer(ctx, "/rpc", nil, nil,
+		func(ctx context.Context, rpc string, in drpc.Message, stream drpc.Stream) (drpc.Message, error) {
+			t.Fatal("handler called")
+			return nil, nil
+		})
+	assert.Equal(t, drpcstatus.CodeFromError(err), drpcstatus.InvalidArgument)
+}
@@ -0,0 +1,208 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcproxy
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	v1Signature = []byte("PROXY ")
+	v2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+)
+
+// v1MaxLength is the length of the longest valid version 1 header.
+const v1MaxLength = 107
+
+// conn is a net.Conn that reads the PROXY protocol header before any data.
+type conn struct {
+	net.Conn
+	opts Options
+
+	once   sync.Once
+	br     *bufio.Reader
+	remote net.Addr
+	local  net.Addr
+	err    error
+}
+
+func newConn(c net.Conn, opts Options) *conn {
+	return &conn{Conn: c, opts: opts}
+}
+
+// init reads the header the first time it is called.
+func (c *conn) init() {
+	c.once.Do(func() { c.err = c.readHeader() })
+}
+
+// Read reads data sent after the header.
+func (c *conn) Read(p []byte) (int, error) {
+	c.init()
+	if c.err != nil {
+		return 0, c.err
+	}
+	// the buffered reader is only needed until what it peeked has been read.
+	if c.br.Buffered() > 0 {
+		return c.br.Read(p)
+	}
+	return c.Conn.Read(p)
+}
+
+// RemoteAddr returns the client address from the header, or the address of
+// the socket if the header does not have one.
+func (c *conn) RemoteAddr() net.Addr {
+	c.init()
+	if c.remote != nil {
+		return c.remote
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// LocalAddr returns the address the client connected to from the header, or
+// the address of the socket if the header does not have one.
+func (c *conn) LocalAddr() net.Addr {
+	c.init()
+	if c.local != nil {
+		return c.local
+	}
+	return c.Conn.LocalAddr()
+}
+
+// readHeader reads the header and records the addresses it contains.
+func (c *conn) readHeader() error {
+	c.br = bufio.NewReader(c.Conn)
+
+	sig, err := c.signature()
+	if err != nil {
+		return Error.Wrap(err)
+	}
+
+	switch {
+	case bytes.Equal(sig, v1Signature):
+		return c.readV1()
+	case bytes.Equal(sig, v2Signature):
+		return c.readV2()
+	case c.opts.AllowMissing:
+		return nil
+	default:
+		return Error.New("missing proxy protocol header")
+	}
+}
+
+// signature peeks at the start of the connection until it either matches the
+// signature of one of the versions or cannot, returning what matched. It only
+// peeks one byte at a time so that it does not wait for data that a client
+// without a header may never send.
+func (c *conn) signature() ([]byte, error) {
+	for n := 1; ; n++ {
+		buf, err := c.br.Peek(n)
+		if err != nil {
+			return nil, err
+		}
+		v1 := bytes.HasPrefix(v1Signature, buf)
+		v2 := bytes.HasPrefix(v2Signature, buf)
+		if !v1 && !v2 || (v1 && n == len(v1Signature)) || (v2 && n == len(v2Signature)) {
+			return buf, nil
+		}
+	}
+}
+
+// readV1 reads a version 1 header, which is a line like
+// "PROXY TCP4 192.0.2.1 192.0.2.2 56324 443\r\n".
+func (c *conn) readV1() error {
+	line, err := c.br.ReadSlice('\n')
+	if err != nil && err != bufio.ErrBufferFull {
+		return Error.Wrap(err)
+	} else if err != nil || len(line) > v1MaxLength || !bytes.HasSuffix(line, []byte("\r\n")) {
+		return Error.New("malformed proxy protocol v1 header")
+	}
+
+	fields := strings.Split(string(line[:len(line)-2]), " ")
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil
+	} else if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return Error.New("malformed proxy protocol v1 header: %q", line)
+	}
+
+	remote, err := parseV1Addr(fields[1], fields[2], fields[4])
+	if err != nil {
+		return err
+	}
+	local, err := parseV1Addr(fields[1], fields[3], fields[5])
+	if err != nil {
+		return err
+	}
+	c.remote, c.local = remote, local
+	return nil
+}
+
+// parseV1Addr parses the address and port of a version 1 header.
+func parseV1Addr(proto, addr, port string) (*net.TCPAddr, error) {
+	ip := net.ParseIP(addr)
+	if ip == nil || (ip.To4() != nil) != (proto == "TCP4") {
+		return nil, Error.New("invalid proxy protocol v1 address: %q", addr)
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, Error.New("invalid proxy protocol v1 port: %q", port)
+	}
+	return &net.TCPAddr{IP: ip, Port: int(p)}, nil
+}
+
+// readV2 reads a version 2 header, which is the signature followed by the
+// version and command, the address family, the length of the rest of the
+// header, and the addresses.
+func (c *conn) readV2() error {
+	var hdr [16]byte
+	if _, err := io.ReadFull(c.br, hdr[:]); err != nil {
+		return Error.Wrap(err)
+	}
+	if hdr[12]>>4 != 2 {
+		return Error.New("unsupported proxy protocol v2 version: %d", hdr[12]>>4)
+	}
+
+	body := make([]byte, binary.BigEndian.Uint16(hdr[14:16]))
+	if _, err := io.ReadFull(c.br, body); err != nil {
+		return Error.Wrap(err)
+	}
+
+	switch cmd := hdr[12] & 0xf; cmd {
+	case 0: // LOCAL: health checks from the load balancer itself.
+		return nil
+	case 1: // PROXY
+	default:
+		return Error.New("unsupported proxy protocol v2 command: %d", cmd)
+	}
+
+	// any type-length-value fields after the addresses are ignored.
+	var size int
+	switch fam := hdr[13] >> 4; fam {
+	case 1: // AF_INET
+		size = net.IPv4len
+	case 2: // AF_INET6
+		size = net.IPv6len
+	default: // AF_UNSPEC and AF_UNIX do not have an ip address.
+		return nil
+	}
+	if len(body) < 2*size+4 {
+		return Error.New("short proxy protocol v2 addresses: %d bytes", len(body))
+	}
+
+	c.remote = &net.TCPAddr{
+		IP:   net.IP(body[:size]),
+		Port: int(binary.BigEndian.Uint16(body[2*size:])),
+	}
+	c.local = &net.TCPAddr{
+		IP:   net.IP(body[size : 2*size]),
+		Port: int(binary.BigEndian.Uint16(body[2*size+2:])),
+	}
+	return nil
+}
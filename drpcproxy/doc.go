@@ -0,0 +1,6 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+// Package drpcproxy provides a listener that understands the PROXY protocol
+// headers sent by load balancers in front of a drpc server.
+package drpcproxy
@@ -0,0 +1,53 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcproxy
+
+import (
+	"net"
+
+	"github.com/zeebo/errs"
+)
+
+// Error wraps all of the errors returned by this package.
+var Error = errs.Class("drpcproxy")
+
+// Options controls configuration settings for a listener.
+type Options struct {
+	// AllowMissing causes connections that do not start with a PROXY
+	// protocol header to be served as is, with the address of the socket.
+	// By default their reads fail so that clients cannot bypass the load
+	// balancer and choose their own address.
+	AllowMissing bool
+}
+
+// Listener wraps a net.Listener so that the PROXY protocol header the
+// connections it accepts start with is removed before it is read by drpc.
+// The connections report the client address from the header as their
+// RemoteAddr, which makes it available to handlers with
+// drpcserver.PeerFromContext.
+type Listener struct {
+	net.Listener
+	opts Options
+}
+
+// New returns a Listener that accepts connections from lis.
+func New(lis net.Listener) *Listener {
+	return NewWithOptions(lis, Options{})
+}
+
+// NewWithOptions is like New but uses the provided options.
+func NewWithOptions(lis net.Listener, opts Options) *Listener {
+	return &Listener{Listener: lis, opts: opts}
+}
+
+// Accept returns the next connection. The header is read by the first call to
+// Read or RemoteAddr on the connection so that a slow client does not delay
+// accepting others.
+func (l *Listener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return newConn(conn, l.opts), nil
+}
@@ -0,0 +1,151 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcproxy
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/zeebo/assert"
+
+	"storj.io/drpc"
+	"storj.io/drpc/drpcconn"
+	"storj.io/drpc/drpcserver"
+	"storj.io/drpc/drpctest"
+)
+
+// v2Header returns a version 2 header with the command, family and body.
+func v2Header(cmd, fam byte, body ...byte) []byte {
+	hdr := append([]byte(nil), v2Signature...)
+	hdr = append(hdr, 0x20|cmd, fam, 0, 0)
+	binary.BigEndian.PutUint16(hdr[14:], uint16(len(body)))
+	return append(hdr, body...)
+}
+
+// accept returns a conn wrapped like the listener does for a client that
+// sends data and then closes.
+func accept(data []byte, opts Options) net.Conn {
+	pc, ps := net.Pipe()
+	go func() {
+		_, _ = pc.Write(data)
+		_ = pc.Close()
+	}()
+	return newConn(ps, opts)
+}
+
+func TestConn(t *testing.T) {
+	v4 := []byte{192, 0, 2, 1, 192, 0, 2, 2, 0xdc, 0x04, 0x01, 0xbb}
+	v6 := append(append(net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::2")...), 0xdc, 0x04, 0x01, 0xbb)
+
+	for _, test := range []struct {
+		name   string
+		header []byte
+		remote string
+		local  string
+	}{
+		{"V1_TCP4", []byte("PROXY TCP4 192.0.2.1 192.0.2.2 56324 443\r\n"), "192.0.2.1:56324", "192.0.2.2:443"},
+		{"V1_TCP6", []byte("PROXY TCP6 2001:db8::1 2001:db8::2 56324 443\r\n"), "[2001:db8::1]:56324", "[2001:db8::2]:443"},
+		{"V1_Unknown", []byte("PROXY UNKNOWN\r\n"), "pipe", "pipe"},
+		{"V2_INET", v2Header(1, 0x11, v4...), "192.0.2.1:56324", "192.0.2.2:443"},
+		{"V2_INET6", v2Header(1, 0x21, v6...), "[2001:db8::1]:56324", "[2001:db8::2]:443"},
+		{"V2_TLV", v2Header(1, 0x11, append(v4, 0x04, 0x00, 0x01, 0xff)...), "192.0.2.1:56324", "192.0.2.2:443"},
+		{"V2_Local", v2Header(0, 0x00), "pipe", "pipe"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			conn := accept(append(test.header, "data"...), Options{})
+			defer func() { _ = conn.Close() }()
+
+			assert.Equal(t, conn.RemoteAddr().String(), test.remote)
+			assert.Equal(t, conn.LocalAddr().String(), test.local)
+
+			data, err := io.ReadAll(conn)
+			assert.NoError(t, err)
+			assert.Equal(t, string(data), "data")
+		})
+	}
+}
+
+func TestConn_Missing(t *testing.T) {
+	{ // connections without a header are rejected by default
+		conn := accept([]byte("data"), Options{})
+		_, err := io.ReadAll(conn)
+		assert.That(t, Error.Has(err))
+		assert.Equal(t, conn.RemoteAddr().String(), "pipe")
+	}
+
+	{ // or are passed through as is
+		conn := accept([]byte("\r\ndata"), Options{AllowMissing: true})
+		data, err := io.ReadAll(conn)
+		assert.NoError(t, err)
+		assert.Equal(t, string(data), "\r\ndata")
+		assert.Equal(t, conn.RemoteAddr().String(), "pipe")
+	}
+}
+
+func TestConn_Malformed(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		header []byte
+	}{
+		{"V1_Fields", []byte("PROXY TCP4 192.0.2.1\r\n")},
+		{"V1_Protocol", []byte("PROXY UDP4 192.0.2.1 192.0.2.2 56324 443\r\n")},
+		{"V1_Address", []byte("PROXY TCP4 2001:db8::1 192.0.2.2 56324 443\r\n")},
+		{"V1_Port", []byte("PROXY TCP4 192.0.2.1 192.0.2.2 65536 443\r\n")},
+		{"V1_Newline", []byte("PROXY TCP4 192.0.2.1 192.0.2.2 56324 443\n")},
+		{"V1_Length", []byte("PROXY UNKNOWN " + strings.Repeat(" ", 100) + "\r\n")},
+		{"V1_Truncated", []byte("PROXY TCP4 192.0.2.1")},
+		{"V2_Version", func() []byte { hdr := v2Header(1, 0x11); hdr[12] = 0x11; return hdr }()},
+		{"V2_Command", v2Header(2, 0x11)},
+		{"V2_Short", v2Header(1, 0x11, 192, 0, 2, 1)},
+		{"V2_Truncated", v2Header(1, 0x11, 192, 0, 2, 1)[:20]},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			conn := accept(test.header, Options{AllowMissing: true})
+			defer func() { _ = conn.Close() }()
+
+			_, err := io.ReadAll(conn)
+			assert.That(t, Error.Has(err))
+		})
+	}
+}
+
+func TestListener(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	srv := drpcserver.New(handlerFunc(func(stream drpc.Stream, rpc string) error {
+		var in, peer []byte
+		if err := stream.MsgRecv(&in, drpctest.ByteEncoding{}); err != nil {
+			return err
+		}
+		if addr, ok := drpcserver.PeerFromContext(stream.Context()); ok {
+			peer = []byte(addr.String())
+		}
+		return stream.MsgSend(&peer, drpctest.ByteEncoding{})
+	}))
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	ctx.Run(func(ctx context.Context) { _ = srv.Serve(ctx, New(lis)) })
+
+	rawconn, err := net.Dial("tcp", lis.Addr().String())
+	assert.NoError(t, err)
+	_, err = rawconn.Write([]byte("PROXY TCP4 192.0.2.1 192.0.2.2 56324 443\r\n"))
+	assert.NoError(t, err)
+
+	conn := drpcconn.New(rawconn)
+	defer func() { _ = conn.Close() }()
+
+	var peer []byte
+	assert.NoError(t, conn.Invoke(ctx, "rpc", drpctest.ByteEncoding{}, &peer, &peer))
+	assert.Equal(t, string(peer), "192.0.2.1:56324")
+}
+
+type handlerFunc func(stream drpc.Stream, rpc string) error
+
+func (fn handlerFunc) HandleRPC(stream drpc.Stream, rpc string) error { return fn(stream, rpc) }
@@ -0,0 +1,39 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+)
+
+func TestBucket(t *testing.T) {
+	now := time.Now()
+	b := NewBucket(10, 2)
+
+	// the bucket starts full.
+	assert.That(t, b.allow(now))
+	assert.That(t, b.allow(now))
+	assert.That(t, !b.allow(now))
+
+	// tokens are added at the rate.
+	now = now.Add(50 * time.Millisecond)
+	assert.That(t, !b.allow(now))
+	now = now.Add(50 * time.Millisecond)
+	assert.That(t, b.allow(now))
+	assert.That(t, !b.allow(now))
+
+	// tokens never exceed the burst.
+	now = now.Add(time.Hour)
+	assert.That(t, b.full(now))
+	assert.That(t, b.allow(now))
+	assert.That(t, b.allow(now))
+	assert.That(t, !b.allow(now))
+
+	// time going backwards does not add or remove tokens.
+	assert.That(t, !b.allow(now.Add(-time.Hour)))
+	assert.That(t, b.allow(now.Add(100*time.Millisecond)))
+}
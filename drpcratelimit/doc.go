@@ -0,0 +1,11 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+// Package drpcratelimit provides a server interceptor that limits the rate at
+// which rpcs are accepted.
+//
+// Rpcs are grouped by a key, and every key has its own token bucket. Rpcs that
+// arrive when their bucket is empty are rejected with a ResourceExhausted
+// status before the handler runs. Streams take a token when they start and are
+// not limited per message.
+package drpcratelimit
@@ -0,0 +1,125 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"storj.io/drpc"
+	"storj.io/drpc/drpcmetadata"
+	"storj.io/drpc/drpcstatus"
+)
+
+// KeyFunc returns the key that the rpc is limited by. Rpcs with the same key
+// share a token bucket.
+type KeyFunc func(ctx context.Context, rpc string) string
+
+// Global limits all rpcs together.
+func Global(ctx context.Context, rpc string) string { return "" }
+
+// PerMethod limits each rpc name separately.
+func PerMethod(ctx context.Context, rpc string) string { return rpc }
+
+// ByMetadata returns a KeyFunc that limits rpcs by the first value of the
+// incoming metadata key, such as an api key. Rpcs without the key share a
+// bucket.
+func ByMetadata(key string) KeyFunc {
+	return func(ctx context.Context, rpc string) string {
+		md, _ := drpcmetadata.MetadataFromContext(ctx)
+		if values := md.Get(key); len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+}
+
+// Options controls configuration settings for a limiter.
+type Options struct {
+	// Rate is how many rpcs per second are accepted for each key.
+	Rate float64
+
+	// Burst is how many rpcs can be accepted at once for each key. Zero means 1.
+	Burst int
+
+	// Key groups rpcs into buckets. If nil, Global is used.
+	Key KeyFunc
+}
+
+// Limiter rejects rpcs that arrive faster than the configured rate. Its
+// InterceptServer method is a drpc.ServerInterceptor.
+type Limiter struct {
+	opts Options
+
+	mu      sync.Mutex
+	buckets map[string]*Bucket
+	sweep   int
+}
+
+var _ drpc.ServerInterceptor = (*Limiter)(nil).InterceptServer
+
+// minSweep is the number of buckets a limiter holds before it starts removing
+// buckets for keys that have been idle long enough to refill.
+const minSweep = 1024
+
+// New returns a Limiter with the provided options.
+func New(opts Options) *Limiter {
+	if opts.Burst == 0 {
+		opts.Burst = 1
+	}
+	if opts.Key == nil {
+		opts.Key = Global
+	}
+	return &Limiter{
+		opts:    opts,
+		buckets: make(map[string]*Bucket),
+		sweep:   minSweep,
+	}
+}
+
+// Allow takes a token for the rpc and returns true if one was available.
+func (l *Limiter) Allow(ctx context.Context, rpc string) bool {
+	return l.bucket(l.opts.Key(ctx, rpc)).Allow()
+}
+
+// InterceptServer rejects the rpc with a ResourceExhausted status if it is
+// over the limit, and otherwise calls next.
+func (l *Limiter) InterceptServer(ctx context.Context, rpc string, in drpc.Message, stream drpc.Stream, next drpc.ServerHandler) (out drpc.Message, err error) {
+	if !l.Allow(ctx, rpc) {
+		return nil, drpcstatus.Errorf(drpcstatus.ResourceExhausted, "rate limit exceeded for %s", rpc)
+	}
+	return next(ctx, rpc, in, stream)
+}
+
+// bucket returns the bucket for the key, creating it if necessary.
+func (l *Limiter) bucket(key string) *Bucket {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= l.sweep {
+			l.sweepLocked(time.Now())
+		}
+		b = NewBucket(l.opts.Rate, l.opts.Burst)
+		l.buckets[key] = b
+	}
+	return b
+}
+
+// sweepLocked removes full buckets, which behave the same as new buckets, so
+// that the number of buckets stays bounded by the number of active keys. It
+// must be called with the mutex held.
+func (l *Limiter) sweepLocked(now time.Time) {
+	for key, b := range l.buckets {
+		if b.full(now) {
+			delete(l.buckets, key)
+		}
+	}
+	l.sweep = 2 * len(l.buckets)
+	if l.sweep < minSweep {
+		l.sweep = minSweep
+	}
+}
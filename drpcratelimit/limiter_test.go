@@ -0,0 +1,118 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcratelimit
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+
+	"storj.io/drpc/drpchealth"
+	"storj.io/drpc/drpcmetadata"
+	"storj.io/drpc/drpcmux"
+	"storj.io/drpc/drpcpipe"
+	"storj.io/drpc/drpcstatus"
+)
+
+func newClient(t *testing.T, opts Options) (*drpchealth.Client, func()) {
+	mux := drpcmux.NewWithOptions(drpcmux.Options{Interceptor: New(opts).InterceptServer})
+	assert.NoError(t, drpchealth.Register(mux, drpchealth.New()))
+
+	conn, cleanup := drpcpipe.New(mux)
+	return drpchealth.NewClient(conn), cleanup
+}
+
+func TestLimiter_Global(t *testing.T) {
+	ctx := context.Background()
+
+	cli, cleanup := newClient(t, Options{Burst: 2})
+	defer cleanup()
+
+	for i := 0; i < 2; i++ {
+		_, err := cli.Check(ctx, "")
+		assert.NoError(t, err)
+	}
+
+	_, err := cli.Check(ctx, "")
+	assert.Equal(t, drpcstatus.CodeFromError(err), drpcstatus.ResourceExhausted)
+
+	// streams are limited when they start.
+	stream, err := cli.Watch(ctx, "")
+	assert.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, drpcstatus.CodeFromError(err), drpcstatus.ResourceExhausted)
+	assert.NoError(t, stream.Close())
+}
+
+func TestLimiter_PerMethod(t *testing.T) {
+	ctx := context.Background()
+
+	cli, cleanup := newClient(t, Options{Key: PerMethod})
+	defer cleanup()
+
+	_, err := cli.Check(ctx, "")
+	assert.NoError(t, err)
+	_, err = cli.Check(ctx, "")
+	assert.Equal(t, drpcstatus.CodeFromError(err), drpcstatus.ResourceExhausted)
+
+	// the stream has its own bucket and is not limited per message.
+	stream, err := cli.Watch(ctx, "")
+	assert.NoError(t, err)
+	status, err := stream.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, status, drpchealth.Serving)
+	assert.NoError(t, stream.Close())
+}
+
+func TestLimiter_ByMetadata(t *testing.T) {
+	ctx := context.Background()
+
+	cli, cleanup := newClient(t, Options{Key: ByMetadata("api-key")})
+	defer cleanup()
+
+	withKey := func(key string) context.Context {
+		return drpcmetadata.WithOutgoingMetadata(ctx, drpcmetadata.Metadata{"api-key": {key}})
+	}
+
+	_, err := cli.Check(withKey("a"), "")
+	assert.NoError(t, err)
+	_, err = cli.Check(withKey("b"), "")
+	assert.NoError(t, err)
+	_, err = cli.Check(withKey("a"), "")
+	assert.Equal(t, drpcstatus.CodeFromError(err), drpcstatus.ResourceExhausted)
+}
+
+func TestLimiter_Refill(t *testing.T) {
+	ctx := context.Background()
+
+	cli, cleanup := newClient(t, Options{Rate: 20})
+	defer cleanup()
+
+	_, err := cli.Check(ctx, "")
+	assert.NoError(t, err)
+	_, err = cli.Check(ctx, "")
+	assert.Equal(t, drpcstatus.CodeFromError(err), drpcstatus.ResourceExhausted)
+
+	time.Sleep(100 * time.Millisecond)
+	_, err = cli.Check(ctx, "")
+	assert.NoError(t, err)
+}
+
+func TestLimiter_Sweep(t *testing.T) {
+	ctx := context.Background()
+	l := New(Options{Rate: 1, Key: PerMethod})
+
+	// idle buckets are removed once there are enough of them.
+	for i := 0; i < 2*minSweep; i++ {
+		assert.That(t, l.Allow(ctx, fmt.Sprint(i)))
+	}
+	l.mu.Lock()
+	l.sweepLocked(time.Now().Add(time.Second))
+	count := len(l.buckets)
+	l.mu.Unlock()
+	assert.Equal(t, count, 0)
+}
@@ -0,0 +1,79 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcreconnect
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"storj.io/drpc"
+)
+
+// BackoffOptions controls how long Backoff waits between failed dials.
+type BackoffOptions struct {
+	// Base is the wait after the first failed dial. Zero means 100ms.
+	Base time.Duration
+
+	// Max limits the wait between dials. Zero means 10s.
+	Max time.Duration
+
+	// Multiplier is how much the wait grows after each failed dial. Values
+	// less than or equal to 1 mean 2.
+	Multiplier float64
+}
+
+// Backoff returns a DialFunc that calls dial until it succeeds, waiting
+// between failed attempts for a delay that grows exponentially. Each wait is
+// randomly chosen between half and all of the delay so that many clients do
+// not dial in lockstep. If the context is done before a dial succeeds, it
+// returns the error from the last dial.
+func Backoff(dial DialFunc, opts BackoffOptions) DialFunc {
+	if opts.Base <= 0 {
+		opts.Base = 100 * time.Millisecond
+	}
+	if opts.Max <= 0 {
+		opts.Max = 10 * time.Second
+	}
+	if opts.Multiplier <= 1 {
+		opts.Multiplier = 2
+	}
+
+	return func(ctx context.Context) (drpc.Conn, error) {
+		delay := opts.Base
+		for {
+			conn, err := dial(ctx)
+			if err == nil {
+				return conn, nil
+			}
+
+			if !sleep(ctx, jitter(delay)) {
+				return nil, err
+			}
+
+			if delay = time.Duration(float64(delay) * opts.Multiplier); delay > opts.Max {
+				delay = opts.Max
+			}
+		}
+	}
+}
+
+// jitter returns a random duration between half and all of d.
+func jitter(d time.Duration) time.Duration {
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(d-half)+1))
+}
+
+// sleep waits for the duration, returning false if the context is done first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
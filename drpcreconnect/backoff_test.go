@@ -0,0 +1,63 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcreconnect
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+
+	"storj.io/drpc"
+	"storj.io/drpc/drpcpipe"
+)
+
+func TestBackoff(t *testing.T) {
+	errDial := errors.New("dial failed")
+
+	var dials []time.Time
+	dial := Backoff(func(ctx context.Context) (drpc.Conn, error) {
+		if dials = append(dials, time.Now()); len(dials) < 4 {
+			return nil, errDial
+		}
+		conn, _ := drpcpipe.New(echo{})
+		return conn, nil
+	}, BackoffOptions{Base: 10 * time.Millisecond, Multiplier: 4})
+
+	conn, err := dial(context.Background())
+	assert.NoError(t, err)
+	assert.NoError(t, conn.Close())
+
+	// each wait is at least half of a delay that grows by 4x, so it is
+	// always longer than the wait before it.
+	assert.Equal(t, len(dials), 4)
+	prev := time.Duration(0)
+	for i := 1; i < len(dials); i++ {
+		wait := dials[i].Sub(dials[i-1])
+		assert.That(t, wait > prev)
+		prev = wait
+	}
+}
+
+func TestBackoff_Canceled(t *testing.T) {
+	errDial := errors.New("dial failed")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dials := 0
+	dial := Backoff(func(ctx context.Context) (drpc.Conn, error) {
+		dials++
+		cancel()
+		return nil, errDial
+	}, BackoffOptions{Base: time.Hour})
+
+	start := time.Now()
+	_, err := dial(ctx)
+	assert.That(t, errors.Is(err, errDial))
+	assert.Equal(t, dials, 1)
+	assert.That(t, time.Since(start) < time.Minute)
+}
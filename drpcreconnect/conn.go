@@ -0,0 +1,254 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcreconnect
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/zeebo/errs"
+
+	"storj.io/drpc"
+	"storj.io/drpc/drpcmanager"
+	"storj.io/drpc/drpcsignal"
+)
+
+// Error wraps the errors returned by the reconnecting conn itself.
+var Error = errs.Class("drpcreconnect")
+
+// Options controls configuration settings for a conn.
+type Options struct {
+	// Backoff is how long to wait before dialing again after a dial fails. It
+	// doubles for each failure in a row. Zero means 100ms.
+	Backoff time.Duration
+
+	// MaxBackoff limits how long to wait before dialing again. Zero means 10s.
+	MaxBackoff time.Duration
+
+	// Idempotent reports if the unitary rpc can safely be issued again if the
+	// connection is lost while it is running. If nil, no rpcs are retried.
+	Idempotent func(rpc string) bool
+
+	// WaitForReady causes rpcs to wait while dials fail, dialing again after
+	// each backoff, until one succeeds or the context of the rpc is done.
+	// Otherwise, an rpc fails with the error of the first dial that fails.
+	WaitForReady bool
+}
+
+// DialFunc returns a new conn to the remote.
+type DialFunc func(ctx context.Context) (drpc.Conn, error)
+
+// Conn is a drpc.Conn that dials a new conn whenever the current one is
+// closed.
+type Conn struct {
+	opts Options
+	dial DialFunc
+	done drpcsignal.Chan
+	sem  chan struct{} // held while dialing
+
+	mu      sync.Mutex
+	closed  bool
+	conn    drpc.Conn     // nil until dialed
+	retry   time.Time     // no dial is attempted until this time
+	backoff time.Duration // the wait after the next failed dial
+}
+
+var _ drpc.Conn = (*Conn)(nil)
+
+// New returns a conn that uses the dial function to connect to the remote the
+// first time it is used and every time its conn is closed.
+func New(dial DialFunc) *Conn {
+	return NewWithOptions(dial, Options{})
+}
+
+// NewWithOptions is like New but uses the provided options.
+func NewWithOptions(dial DialFunc, opts Options) *Conn {
+	if opts.Backoff == 0 {
+		opts.Backoff = 100 * time.Millisecond
+	}
+	if opts.MaxBackoff == 0 {
+		opts.MaxBackoff = 10 * time.Second
+	}
+
+	return &Conn{
+		opts:    opts,
+		dial:    dial,
+		sem:     make(chan struct{}, 1),
+		backoff: opts.Backoff,
+	}
+}
+
+// Close closes the conn and the current connection.
+func (c *Conn) Close() (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	c.done.Close()
+
+	if c.conn != nil {
+		err = c.conn.Close()
+		c.conn = nil
+	}
+	return err
+}
+
+// Closed returns a channel that is closed once the conn is closed. It is not
+// closed when the current connection is lost.
+func (c *Conn) Closed() <-chan struct{} { return c.done.Get() }
+
+// Invoke issues the rpc on the current connection. If the connection is lost
+// while the rpc is running and the rpc is idempotent, it is issued once more
+// on a new connection. It is also issued again if the server sent a go away
+// asking for a new connection before the rpc started.
+func (c *Conn) Invoke(ctx context.Context, rpc string, enc drpc.Encoding, in, out drpc.Message) (err error) {
+	conn, err := c.get(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = conn.Invoke(ctx, rpc, enc, in, out)
+	if err == nil || !lost(conn) || ctx.Err() != nil || !c.retryable(rpc, err) {
+		return err
+	}
+
+	conn, err = c.get(ctx)
+	if err != nil {
+		return err
+	}
+	return conn.Invoke(ctx, rpc, enc, in, out)
+}
+
+// NewStream starts a stream on the current connection. The stream is not
+// resumed if the connection is lost.
+func (c *Conn) NewStream(ctx context.Context, rpc string, enc drpc.Encoding) (_ drpc.Stream, err error) {
+	conn, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := conn.NewStream(ctx, rpc, enc)
+	if err == nil || !lost(conn) || ctx.Err() != nil {
+		return stream, err
+	}
+
+	// the connection was lost before the stream could start, so no messages
+	// have been sent on it and it can be started on a new connection.
+	conn, err = c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return conn.NewStream(ctx, rpc, enc)
+}
+
+//
+// helpers
+//
+
+// current returns the current connection if it is still open.
+func (c *Conn) current() (drpc.Conn, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil, Error.New("connection closed")
+	}
+	if c.conn != nil && lost(c.conn) {
+		_ = c.conn.Close()
+		c.conn = nil
+	}
+	return c.conn, nil
+}
+
+// get returns the current connection, dialing a new one if it has been lost.
+// If the WaitForReady option is set, it keeps dialing until a dial succeeds,
+// the conn is closed or the context is done, in which case it returns the
+// context error.
+func (c *Conn) get(ctx context.Context) (drpc.Conn, error) {
+	for {
+		conn, err := c.getOnce(ctx)
+		if err == nil || !c.opts.WaitForReady || lost(c) {
+			return conn, err
+		} else if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// getOnce returns the current connection, dialing a new one if it has been
+// lost. Only one dial happens at a time, and dials wait for the backoff after
+// a failed dial to pass.
+func (c *Conn) getOnce(ctx context.Context) (drpc.Conn, error) {
+	if conn, err := c.current(); conn != nil || err != nil {
+		return conn, err
+	}
+
+	select {
+	case c.sem <- struct{}{}:
+		defer func() { <-c.sem }()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	// someone else may have dialed while we were waiting.
+	if conn, err := c.current(); conn != nil || err != nil {
+		return conn, err
+	}
+
+	c.mu.Lock()
+	wait := time.Until(c.retry)
+	c.mu.Unlock()
+
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	conn, err := c.dial(ctx)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err != nil {
+		c.retry = time.Now().Add(c.backoff)
+		if c.backoff *= 2; c.backoff > c.opts.MaxBackoff {
+			c.backoff = c.opts.MaxBackoff
+		}
+		return nil, Error.Wrap(err)
+	}
+	if c.closed {
+		_ = conn.Close()
+		return nil, Error.New("connection closed")
+	}
+
+	c.conn, c.retry, c.backoff = conn, time.Time{}, c.opts.Backoff
+	return conn, nil
+}
+
+// retryable returns true if the rpc can be issued again after failing with err
+// because the connection was lost.
+func (c *Conn) retryable(rpc string, err error) bool {
+	return errors.Is(err, drpcmanager.ErrGoAway) || (c.opts.Idempotent != nil && c.opts.Idempotent(rpc))
+}
+
+// lost returns true if the conn has been closed.
+func lost(conn drpc.Conn) bool {
+	select {
+	case <-conn.Closed():
+		return true
+	default:
+		return false
+	}
+}
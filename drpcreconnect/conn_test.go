@@ -0,0 +1,251 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcreconnect
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+
+	"storj.io/drpc"
+	"storj.io/drpc/drpcconn"
+	"storj.io/drpc/drpcctx"
+	"storj.io/drpc/drpcpipe"
+	"storj.io/drpc/drpcserver"
+	"storj.io/drpc/drpctest"
+)
+
+// echo responds to every message it receives with the same message.
+type echo struct{}
+
+func (echo) HandleRPC(stream drpc.Stream, rpc string) error {
+	for {
+		var in []byte
+		if err := stream.MsgRecv(&in, drpctest.ByteEncoding{}); err != nil {
+			return nil
+		}
+		if err := stream.MsgSend(&in, drpctest.ByteEncoding{}); err != nil {
+			return err
+		}
+	}
+}
+
+// serve runs a server with the handler on the address until the returned
+// function is called.
+func serve(t *testing.T, addr string, handler drpc.Handler) (string, func()) {
+	lis, err := net.Listen("tcp", addr)
+	assert.NoError(t, err)
+
+	ctx := drpctest.NewTracker(t)
+	ctx.Run(func(ctx context.Context) { _ = drpcserver.New(handler).Serve(ctx, lis) })
+	return lis.Addr().String(), ctx.Close
+}
+
+func invoke(ctx context.Context, conn drpc.Conn, rpc string) (string, error) {
+	in, out := []byte("data"), []byte(nil)
+	err := conn.Invoke(ctx, rpc, drpctest.ByteEncoding{}, &in, &out)
+	return string(out), err
+}
+
+func TestConn_Restart(t *testing.T) {
+	ctx := context.Background()
+	addr, stop := serve(t, "127.0.0.1:0", echo{})
+
+	var mu sync.Mutex
+	dials := 0
+	conn := NewWithOptions(func(ctx context.Context) (drpc.Conn, error) {
+		mu.Lock()
+		dials++
+		mu.Unlock()
+
+		rawconn, err := new(net.Dialer).DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return nil, err
+		}
+		return drpcconn.New(rawconn), nil
+	}, Options{Backoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond})
+	defer func() { _ = conn.Close() }()
+
+	out, err := invoke(ctx, conn, "/rpc")
+	assert.NoError(t, err)
+	assert.Equal(t, out, "data")
+
+	// while the server is down, rpcs fail.
+	stop()
+	_, err = invoke(ctx, conn, "/rpc")
+	assert.Error(t, err)
+
+	// once it is back, the conn recovers with a new connection.
+	_, stop = serve(t, addr, echo{})
+	defer stop()
+
+	for {
+		out, err = invoke(ctx, conn, "/rpc")
+		if err == nil {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, out, "data")
+
+	stream, err := conn.NewStream(ctx, "/rpc", drpctest.ByteEncoding{})
+	assert.NoError(t, err)
+	in, reply := []byte("stream"), []byte(nil)
+	assert.NoError(t, stream.MsgSend(&in, drpctest.ByteEncoding{}))
+	assert.NoError(t, stream.MsgRecv(&reply, drpctest.ByteEncoding{}))
+	assert.Equal(t, string(reply), "stream")
+	assert.NoError(t, stream.Close())
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.That(t, dials >= 2)
+}
+
+func TestConn_WaitForReady(t *testing.T) {
+	ctx := context.Background()
+
+	// reserve an address for a server that is not running yet.
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := lis.Addr().String()
+	assert.NoError(t, lis.Close())
+
+	conn := NewWithOptions(func(ctx context.Context) (drpc.Conn, error) {
+		rawconn, err := new(net.Dialer).DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return nil, err
+		}
+		return drpcconn.New(rawconn), nil
+	}, Options{Backoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond, WaitForReady: true})
+	defer func() { _ = conn.Close() }()
+
+	{ // waiting rpcs can be canceled.
+		ctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+
+		_, err := invoke(ctx, conn, "/rpc")
+		assert.That(t, errors.Is(err, context.DeadlineExceeded))
+	}
+
+	{ // and otherwise succeed once the server starts.
+		type result struct {
+			out string
+			err error
+		}
+		done := make(chan result, 1)
+		go func() {
+			out, err := invoke(ctx, conn, "/rpc")
+			done <- result{out, err}
+		}()
+
+		time.Sleep(20 * time.Millisecond)
+		_, stop := serve(t, addr, echo{})
+		defer stop()
+
+		res := <-done
+		assert.NoError(t, res.err)
+		assert.Equal(t, res.out, "data")
+	}
+}
+
+// dropFirst closes the connection of the first rpc it handles and echoes
+// every later one.
+type dropFirst struct {
+	mu    sync.Mutex
+	calls int
+}
+
+func (d *dropFirst) HandleRPC(stream drpc.Stream, rpc string) error {
+	d.mu.Lock()
+	d.calls++
+	first := d.calls == 1
+	d.mu.Unlock()
+
+	if first {
+		tr, _ := drpcctx.Transport(stream.Context())
+		return tr.Close()
+	}
+	return echo{}.HandleRPC(stream, rpc)
+}
+
+func TestConn_Idempotent(t *testing.T) {
+	ctx := context.Background()
+
+	run := func(rpc string) (string, int, error) {
+		tracker := drpctest.NewTracker(t)
+		defer tracker.Close()
+
+		handler := new(dropFirst)
+		srv := drpcserver.New(handler)
+		dials := 0
+
+		// each dial serves a single connection over an in-memory pipe.
+		conn := NewWithOptions(func(ctx context.Context) (drpc.Conn, error) {
+			dials++
+			client, server := net.Pipe()
+			tracker.Run(func(ctx context.Context) { _ = srv.ServeOne(ctx, server) })
+			return drpcconn.New(client), nil
+		}, Options{Idempotent: func(rpc string) bool { return rpc == "/get" }})
+		defer func() { _ = conn.Close() }()
+
+		out, err := invoke(ctx, conn, rpc)
+		return out, dials, err
+	}
+
+	{ // idempotent rpcs are retried on a new connection
+		out, dials, err := run("/get")
+		assert.NoError(t, err)
+		assert.Equal(t, out, "data")
+		assert.Equal(t, dials, 2)
+	}
+
+	{ // other rpcs are not
+		_, dials, err := run("/put")
+		assert.Error(t, err)
+		assert.Equal(t, dials, 1)
+	}
+}
+
+func TestConn_Closed(t *testing.T) {
+	conn := New(func(ctx context.Context) (drpc.Conn, error) {
+		conn, _ := drpcpipe.New(echo{})
+		return conn, nil
+	})
+	assert.NoError(t, conn.Close())
+
+	_, err := invoke(context.Background(), conn, "/rpc")
+	assert.That(t, Error.Has(err))
+	<-conn.Closed()
+}
+
+func TestConn_GoAway(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	srv := drpcserver.NewWithOptions(echo{}, drpcserver.Options{
+		MaxConnectionAge: 10 * time.Millisecond,
+	})
+
+	dials := 0
+	conn := New(func(_ context.Context) (drpc.Conn, error) {
+		dials++
+		client, server := net.Pipe()
+		ctx.Run(func(ctx context.Context) { _ = srv.ServeOne(ctx, server) })
+		return drpcconn.New(client), nil
+	})
+	defer func() { _ = conn.Close() }()
+
+	// rpcs keep working as the server asks for new connections.
+	for start := time.Now(); time.Since(start) < 100*time.Millisecond; {
+		out, err := invoke(ctx, conn, "/rpc")
+		assert.NoError(t, err)
+		assert.Equal(t, out, "data")
+	}
+	assert.That(t, dials > 1)
+}
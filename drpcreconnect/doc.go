@@ -0,0 +1,21 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+// Package drpcreconnect provides a conn that redials when its connection is
+// lost.
+//
+// Every rpc uses the current connection, dialing a new one first if it has
+// been closed. Unitary rpcs that fail because the connection was lost while
+// they were running are retried once on a new connection, but only if they are
+// idempotent, because the remote may have processed them already. Streams are
+// never resumed: a stream that loses its connection fails, and the next rpc
+// uses a new connection.
+//
+// A server that sends a go away, like one with a MaxConnectionAge, has its
+// connection closed once the active rpc finishes. Rpcs that had not started
+// yet are issued on a new connection whether or not they are idempotent.
+//
+// Backoff wraps a dial function so that a single dial keeps trying, with
+// growing and jittered waits between failures, until it succeeds or its
+// context is done.
+package drpcreconnect
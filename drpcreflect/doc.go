@@ -0,0 +1,8 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+// Package drpcreflect provides a service that lists the rpcs a server hosts.
+//
+// The service is not registered by default so that servers do not expose
+// their api surface unless they opt in by calling Register.
+package drpcreflect
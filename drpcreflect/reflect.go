@@ -0,0 +1,180 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcreflect
+
+import (
+	"context"
+
+	"github.com/zeebo/errs"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"storj.io/drpc"
+	"storj.io/drpc/drpcmux"
+)
+
+// ListMethodsRPC is the name of the rpc that lists the methods of a server.
+const ListMethodsRPC = "/drpc.reflection.Reflection/ListMethods"
+
+// Method describes an rpc hosted by a server.
+type Method struct {
+	// Name is the rpc string used to invoke the method.
+	Name string
+
+	// Unitary is true if the method takes and returns a single message
+	// rather than using a stream.
+	Unitary bool
+}
+
+// ListMethodsRequest is the message sent to list the methods of a server.
+type ListMethodsRequest struct{}
+
+// ListMethodsResponse is the message sent in response to listing the methods
+// of a server.
+type ListMethodsResponse struct {
+	Methods []Method
+}
+
+// encoding marshals the reflection messages in the protobuf wire format.
+type encoding struct{}
+
+func (encoding) Marshal(msg drpc.Message) ([]byte, error) {
+	switch msg := msg.(type) {
+	case *ListMethodsRequest:
+		return nil, nil
+	case *ListMethodsResponse:
+		var buf []byte
+		for _, method := range msg.Methods {
+			var mbuf []byte
+			mbuf = protowire.AppendTag(mbuf, 1, protowire.BytesType)
+			mbuf = protowire.AppendString(mbuf, method.Name)
+			if method.Unitary {
+				mbuf = protowire.AppendTag(mbuf, 2, protowire.VarintType)
+				mbuf = protowire.AppendVarint(mbuf, 1)
+			}
+			buf = protowire.AppendTag(buf, 1, protowire.BytesType)
+			buf = protowire.AppendBytes(buf, mbuf)
+		}
+		return buf, nil
+	default:
+		return nil, errs.New("unknown reflection message: %T", msg)
+	}
+}
+
+func (encoding) Unmarshal(buf []byte, msg drpc.Message) error {
+	switch msg := msg.(type) {
+	case *ListMethodsRequest:
+		return unmarshalFields(buf, func(num protowire.Number, typ protowire.Type, buf []byte) (int, error) {
+			return protowire.ConsumeFieldValue(num, typ, buf), nil
+		})
+	case *ListMethodsResponse:
+		*msg = ListMethodsResponse{}
+		return unmarshalFields(buf, func(num protowire.Number, typ protowire.Type, buf []byte) (int, error) {
+			if num != 1 || typ != protowire.BytesType {
+				return protowire.ConsumeFieldValue(num, typ, buf), nil
+			}
+			mbuf, n := protowire.ConsumeBytes(buf)
+			if n < 0 {
+				return n, nil
+			}
+			method, err := unmarshalMethod(mbuf)
+			msg.Methods = append(msg.Methods, method)
+			return n, err
+		})
+	default:
+		return errs.New("unknown reflection message: %T", msg)
+	}
+}
+
+// unmarshalMethod parses a single method from buf.
+func unmarshalMethod(buf []byte) (method Method, err error) {
+	err = unmarshalFields(buf, func(num protowire.Number, typ protowire.Type, buf []byte) (int, error) {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			name, n := protowire.ConsumeString(buf)
+			method.Name = name
+			return n, nil
+		case num == 2 && typ == protowire.VarintType:
+			unitary, n := protowire.ConsumeVarint(buf)
+			method.Unitary = unitary != 0
+			return n, nil
+		default:
+			return protowire.ConsumeFieldValue(num, typ, buf), nil
+		}
+	})
+	return method, err
+}
+
+// unmarshalFields calls field with the value of every field in buf.
+func unmarshalFields(buf []byte, field func(num protowire.Number, typ protowire.Type, buf []byte) (int, error)) error {
+	for len(buf) > 0 {
+		num, typ, n := protowire.ConsumeTag(buf)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		buf = buf[n:]
+
+		n, err := field(num, typ, buf)
+		if err != nil {
+			return err
+		} else if n < 0 {
+			return protowire.ParseError(n)
+		}
+		buf = buf[n:]
+	}
+	return nil
+}
+
+//
+// server
+//
+
+// Register registers the reflection service with the mux. The service lists
+// every rpc registered with the mux at the time it is called, including its
+// own.
+func Register(mux *drpcmux.Mux) error {
+	return mux.Register(server{mux: mux}, description{})
+}
+
+// server implements the rpcs of the reflection service.
+type server struct{ mux *drpcmux.Mux }
+
+func (s server) ListMethods(ctx context.Context, req *ListMethodsRequest) (*ListMethodsResponse, error) {
+	infos := s.mux.Methods()
+	methods := make([]Method, 0, len(infos))
+	for _, info := range infos {
+		methods = append(methods, Method{Name: info.RPC, Unitary: info.Unitary})
+	}
+	return &ListMethodsResponse{Methods: methods}, nil
+}
+
+// description describes the reflection service to a mux.
+type description struct{}
+
+func (description) NumMethods() int { return 1 }
+
+func (description) Method(n int) (string, drpc.Encoding, drpc.Receiver, interface{}, bool) {
+	switch n {
+	case 0:
+		return ListMethodsRPC, encoding{},
+			func(srv interface{}, ctx context.Context, in1, in2 interface{}) (drpc.Message, error) {
+				return srv.(server).ListMethods(ctx, in1.(*ListMethodsRequest))
+			}, server.ListMethods, true
+	default:
+		return "", nil, nil, nil, false
+	}
+}
+
+//
+// client
+//
+
+// ListMethods asks the remote for the methods it hosts. It returns an error if
+// the remote has not registered the reflection service.
+func ListMethods(ctx context.Context, cc drpc.Conn) ([]Method, error) {
+	var out ListMethodsResponse
+	if err := cc.Invoke(ctx, ListMethodsRPC, encoding{}, &ListMethodsRequest{}, &out); err != nil {
+		return nil, err
+	}
+	return out.Methods, nil
+}
@@ -0,0 +1,60 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcreflect
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/zeebo/assert"
+
+	"storj.io/drpc/drpcconn"
+	"storj.io/drpc/drpchealth"
+	"storj.io/drpc/drpcmux"
+	"storj.io/drpc/drpcserver"
+	"storj.io/drpc/drpctest"
+)
+
+func serve(t *testing.T, ctx *drpctest.Tracker, mux *drpcmux.Mux) *drpcconn.Conn {
+	pc, ps := net.Pipe()
+	t.Cleanup(func() { _ = pc.Close() })
+	t.Cleanup(func() { _ = ps.Close() })
+
+	srv := drpcserver.New(mux)
+	ctx.Run(func(ctx context.Context) { _ = srv.ServeOne(ctx, ps) })
+
+	return drpcconn.New(pc)
+}
+
+func TestListMethods(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	mux := drpcmux.New()
+	assert.NoError(t, drpchealth.Register(mux, drpchealth.New()))
+
+	{ // reflection is opt in
+		conn := serve(t, ctx, mux)
+		defer func() { _ = conn.Close() }()
+
+		_, err := ListMethods(ctx, conn)
+		assert.Error(t, err)
+	}
+
+	assert.NoError(t, Register(mux))
+
+	{ // every registered rpc is listed once registered
+		conn := serve(t, ctx, mux)
+		defer func() { _ = conn.Close() }()
+
+		methods, err := ListMethods(ctx, conn)
+		assert.NoError(t, err)
+		assert.DeepEqual(t, methods, []Method{
+			{Name: ListMethodsRPC, Unitary: true},
+			{Name: drpchealth.CheckRPC, Unitary: true},
+			{Name: drpchealth.WatchRPC, Unitary: false},
+		})
+	}
+}
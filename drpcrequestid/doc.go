@@ -0,0 +1,12 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+// Package drpcrequestid provides interceptors that give every rpc a request id
+// so that logs from the client and the server can be correlated.
+//
+// The client interceptor sends the id in the outgoing metadata. It keeps an id
+// that is already there, and reuses the id of the rpc being handled if the
+// context came from a server, so that the id flows across hops. Otherwise it
+// generates a new one. The server interceptor makes the received id available
+// to RequestIDFromContext, which drpclog uses to add it to its records.
+package drpcrequestid
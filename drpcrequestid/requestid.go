@@ -0,0 +1,110 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcrequestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"storj.io/drpc"
+	"storj.io/drpc/drpcmetadata"
+)
+
+// Key is the metadata key the request id is sent with.
+const Key = "drpc-request-id"
+
+type requestIDKey struct{}
+
+// WithRequestID returns a context associated with the request id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request id associated with the context. If
+// none was associated with WithRequestID, it returns the id received in the
+// incoming metadata, if any.
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	if id, ok := ctx.Value(requestIDKey{}).(string); ok {
+		return id, true
+	}
+	md, _ := drpcmetadata.MetadataFromContext(ctx)
+	if values := md.Get(Key); len(values) > 0 {
+		return values[0], true
+	}
+	return "", false
+}
+
+// Options controls configuration settings for an Interceptor.
+type Options struct {
+	// Generate returns a new request id. If nil, ids are 16 random bytes
+	// encoded in hex.
+	Generate func() string
+}
+
+// Interceptor propagates request ids. It implements drpc.ClientInterceptor,
+// and its InterceptServer method is a drpc.ServerInterceptor. It should run
+// before any interceptors that log the request id.
+type Interceptor struct {
+	opts Options
+}
+
+var _ drpc.ClientInterceptor = (*Interceptor)(nil)
+var _ drpc.ServerInterceptor = (*Interceptor)(nil).InterceptServer
+
+// New returns an Interceptor that generates random request ids.
+func New() *Interceptor {
+	return NewWithOptions(Options{})
+}
+
+// NewWithOptions is like New but uses the provided options.
+func NewWithOptions(opts Options) *Interceptor {
+	if opts.Generate == nil {
+		opts.Generate = generate
+	}
+	return &Interceptor{opts: opts}
+}
+
+// InterceptInvoke issues the rpc with a request id.
+func (i *Interceptor) InterceptInvoke(ctx context.Context, rpc string, enc drpc.Encoding, in, out drpc.Message, next drpc.InvokeFunc) error {
+	return next(i.outgoing(ctx), rpc, enc, in, out)
+}
+
+// InterceptNewStream starts the stream with a request id.
+func (i *Interceptor) InterceptNewStream(ctx context.Context, rpc string, enc drpc.Encoding, next drpc.NewStreamFunc) (drpc.Stream, error) {
+	return next(i.outgoing(ctx), rpc, enc)
+}
+
+// InterceptServer associates the request id sent by the client, if any, with
+// the context passed to next.
+func (i *Interceptor) InterceptServer(ctx context.Context, rpc string, in drpc.Message, stream drpc.Stream, next drpc.ServerHandler) (drpc.Message, error) {
+	md, _ := drpcmetadata.MetadataFromContext(ctx)
+	if values := md.Get(Key); len(values) > 0 {
+		ctx = WithRequestID(ctx, values[0])
+	}
+	return next(ctx, rpc, in, stream)
+}
+
+// outgoing returns a context that sends a request id and is associated with
+// it.
+func (i *Interceptor) outgoing(ctx context.Context) context.Context {
+	md, _ := drpcmetadata.OutgoingMetadataFromContext(ctx)
+	if values := md.Get(Key); len(values) > 0 {
+		return WithRequestID(ctx, values[0])
+	}
+
+	id, ok := RequestIDFromContext(ctx)
+	if !ok {
+		id = i.opts.Generate()
+	}
+	ctx = drpcmetadata.WithOutgoingMetadata(ctx, drpcmetadata.Metadata{Key: {id}})
+	return WithRequestID(ctx, id)
+}
+
+// generate returns a random request id.
+func generate() string {
+	var buf [16]byte
+	_, _ = rand.Read(buf[:])
+	return hex.EncodeToString(buf[:])
+}
@@ -0,0 +1,76 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcrequestid
+
+import (
+	"context"
+	"testing"
+
+	"github.com/zeebo/assert"
+
+	"storj.io/drpc"
+	"storj.io/drpc/drpcmetadata"
+)
+
+// invoke returns the request id that is sent and the one associated with the
+// context passed to the next invoker.
+func invoke(t *testing.T, ctx context.Context, i *Interceptor) (sent, seen string) {
+	_ = i.InterceptInvoke(ctx, "/rpc", nil, nil, nil,
+		func(ctx context.Context, rpc string, enc drpc.Encoding, in, out drpc.Message) error {
+			md, _ := drpcmetadata.OutgoingMetadataFromContext(ctx)
+			assert.Equal(t, len(md.Get(Key)), 1)
+			sent = md.Get(Key)[0]
+			seen, _ = RequestIDFromContext(ctx)
+			return nil
+		})
+	return sent, seen
+}
+
+func TestInterceptor_Client(t *testing.T) {
+	ctx := context.Background()
+	i := NewWithOptions(Options{Generate: func() string { return "generated" }})
+
+	{ // a new id is generated
+		sent, seen := invoke(t, ctx, i)
+		assert.Equal(t, sent, "generated")
+		assert.Equal(t, seen, "generated")
+	}
+
+	{ // an id already in the outgoing metadata is kept
+		ctx := drpcmetadata.WithOutgoingMetadata(ctx, drpcmetadata.Metadata{Key: {"outgoing"}})
+		sent, seen := invoke(t, ctx, i)
+		assert.Equal(t, sent, "outgoing")
+		assert.Equal(t, seen, "outgoing")
+	}
+
+	{ // the id of the rpc being handled is reused
+		ctx := drpcmetadata.WithIncomingMetadata(ctx, drpcmetadata.Metadata{Key: {"incoming"}})
+		sent, seen := invoke(t, ctx, i)
+		assert.Equal(t, sent, "incoming")
+		assert.Equal(t, seen, "incoming")
+	}
+
+	{ // the default ids are unique
+		first, _ := invoke(t, ctx, New())
+		second, _ := invoke(t, ctx, New())
+		assert.Equal(t, len(first), 32)
+		assert.That(t, first != second)
+	}
+}
+
+func TestInterceptor_Server(t *testing.T) {
+	ctx := drpcmetadata.WithIncomingMetadata(context.Background(), drpcmetadata.Metadata{Key: {"id"}})
+
+	var seen string
+	_, err := New().InterceptServer(ctx, "/rpc", nil, nil,
+		func(ctx context.Context, rpc string, in drpc.Message, stream drpc.Stream) (drpc.Message, error) {
+			seen, _ = RequestIDFromContext(ctx)
+			return nil, nil
+		})
+	assert.NoError(t, err)
+	assert.Equal(t, seen, "id")
+
+	_, ok := RequestIDFromContext(context.Background())
+	assert.That(t, !ok)
+}
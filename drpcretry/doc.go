@@ -0,0 +1,10 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+// Package drpcretry provides a client interceptor that retries unitary rpcs.
+//
+// The drpc protocol does not acknowledge requests, so a request that failed
+// because of a broken transport may have been processed by the server. To keep
+// retries safe, only errors the server sent with a retryable drpcstatus code
+// are retried, and streams are never retried.
+package drpcretry
@@ -0,0 +1,104 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcretry
+
+import (
+	"context"
+	"time"
+
+	"storj.io/drpc"
+	"storj.io/drpc/drpcerr"
+	"storj.io/drpc/drpcstatus"
+)
+
+// Policy controls how rpcs are retried.
+type Policy struct {
+	// MaxAttempts is the maximum number of times the rpc is issued, including
+	// the first attempt. Zero means 3.
+	MaxAttempts int
+
+	// Backoff is how long to wait before the first retry. It doubles for
+	// each subsequent retry. Zero means 100ms.
+	Backoff time.Duration
+
+	// MaxBackoff limits how long to wait before any retry. Zero means no
+	// limit.
+	MaxBackoff time.Duration
+
+	// Codes are the status codes that are retried. If nil, only
+	// drpcstatus.Unavailable is retried.
+	Codes []drpcstatus.Code
+}
+
+// Retrier is a drpc.ClientInterceptor that retries unitary rpcs that fail
+// with a retryable status code.
+type Retrier struct {
+	policy Policy
+}
+
+var _ drpc.ClientInterceptor = (*Retrier)(nil)
+
+// New returns a Retrier that uses the policy.
+func New(policy Policy) *Retrier {
+	if policy.MaxAttempts == 0 {
+		policy.MaxAttempts = 3
+	}
+	if policy.Backoff == 0 {
+		policy.Backoff = 100 * time.Millisecond
+	}
+	if policy.Codes == nil {
+		policy.Codes = []drpcstatus.Code{drpcstatus.Unavailable}
+	}
+	return &Retrier{policy: policy}
+}
+
+// InterceptInvoke issues the rpc, retrying it according to the policy. The
+// deadline of the context applies to all of the attempts together, and no
+// retry is attempted if waiting for it would pass the deadline.
+func (r *Retrier) InterceptInvoke(ctx context.Context, rpc string, enc drpc.Encoding, in, out drpc.Message, next drpc.InvokeFunc) (err error) {
+	backoff := r.policy.Backoff
+	for attempt := 1; ; attempt++ {
+		err = next(ctx, rpc, enc, in, out)
+		if err == nil || attempt >= r.policy.MaxAttempts || !r.retryable(err) {
+			return err
+		}
+
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < backoff {
+			return err
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+
+		backoff *= 2
+		if r.policy.MaxBackoff > 0 && backoff > r.policy.MaxBackoff {
+			backoff = r.policy.MaxBackoff
+		}
+	}
+}
+
+// InterceptNewStream starts the stream without any retries.
+func (r *Retrier) InterceptNewStream(ctx context.Context, rpc string, enc drpc.Encoding, next drpc.NewStreamFunc) (drpc.Stream, error) {
+	return next(ctx, rpc, enc)
+}
+
+// retryable returns true if the error was sent by the server with one of the
+// retryable codes.
+func (r *Retrier) retryable(err error) bool {
+	code := drpcerr.Code(err)
+	if code == 0 {
+		return false
+	}
+	for _, retry := range r.policy.Codes {
+		if drpcstatus.Code(code) == retry {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcretry
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+	"github.com/zeebo/errs"
+
+	"storj.io/drpc"
+	"storj.io/drpc/drpcconn"
+	"storj.io/drpc/drpcpipe"
+	"storj.io/drpc/drpcstatus"
+	"storj.io/drpc/drpctest"
+)
+
+// flakyHandler fails with the error for the first failures calls and then
+// echoes the input message.
+type flakyHandler struct {
+	calls    int64
+	failures int64
+	err      error
+}
+
+func (h *flakyHandler) HandleRPC(stream drpc.Stream, rpc string) error {
+	var in []byte
+	if err := stream.MsgRecv(&in, drpctest.ByteEncoding{}); err != nil {
+		return err
+	}
+	if atomic.AddInt64(&h.calls, 1) <= h.failures {
+		return h.err
+	}
+	return stream.MsgSend(&in, drpctest.ByteEncoding{})
+}
+
+func invoke(ctx context.Context, h *flakyHandler, policy Policy) error {
+	conn, cleanup := drpcpipe.NewWithOptions(h, drpcpipe.Options{
+		Conn: drpcconn.Options{Interceptor: New(policy)},
+	})
+	defer cleanup()
+
+	in, out := []byte("data"), []byte(nil)
+	return conn.Invoke(ctx, "/rpc", drpctest.ByteEncoding{}, &in, &out)
+}
+
+func TestRetrier(t *testing.T) {
+	ctx := context.Background()
+	unavailable := drpcstatus.Errorf(drpcstatus.Unavailable, "unavailable")
+
+	{ // retryable errors are retried until the rpc succeeds
+		h := &flakyHandler{failures: 2, err: unavailable}
+		assert.NoError(t, invoke(ctx, h, Policy{Backoff: time.Millisecond}))
+		assert.Equal(t, h.calls, int64(3))
+	}
+
+	{ // rpcs are attempted at most MaxAttempts times
+		h := &flakyHandler{failures: 2, err: unavailable}
+		err := invoke(ctx, h, Policy{MaxAttempts: 2, Backoff: time.Millisecond})
+		assert.Equal(t, drpcstatus.CodeFromError(err), drpcstatus.Unavailable)
+		assert.Equal(t, h.calls, int64(2))
+	}
+
+	{ // errors without a retryable code are not retried
+		h := &flakyHandler{failures: 2, err: errs.New("permanent")}
+		assert.Error(t, invoke(ctx, h, Policy{Backoff: time.Millisecond}))
+		assert.Equal(t, h.calls, int64(1))
+	}
+
+	{ // the codes that are retried can be configured
+		h := &flakyHandler{failures: 1, err: drpcstatus.Errorf(drpcstatus.Aborted, "aborted")}
+		assert.NoError(t, invoke(ctx, h, Policy{
+			Backoff: time.Millisecond,
+			Codes:   []drpcstatus.Code{drpcstatus.Aborted},
+		}))
+		assert.Equal(t, h.calls, int64(2))
+	}
+
+	{ // no retry is attempted if it would pass the deadline
+		ctx, cancel := context.WithTimeout(ctx, time.Second)
+		defer cancel()
+
+		h := &flakyHandler{failures: 2, err: unavailable}
+		err := invoke(ctx, h, Policy{Backoff: time.Hour})
+		assert.Equal(t, drpcstatus.CodeFromError(err), drpcstatus.Unavailable)
+		assert.Equal(t, h.calls, int64(1))
+	}
+}
+
+func TestRetrier_Stream(t *testing.T) {
+	calls := 0
+	next := func(ctx context.Context, rpc string, enc drpc.Encoding) (drpc.Stream, error) {
+		calls++
+		return nil, drpcstatus.Errorf(drpcstatus.Unavailable, "unavailable")
+	}
+
+	_, err := New(Policy{}).InterceptNewStream(context.Background(), "/rpc", drpctest.ByteEncoding{}, next)
+	assert.Error(t, err)
+	assert.Equal(t, calls, 1)
+}
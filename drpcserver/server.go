@@ -5,8 +5,12 @@ package drpcserver
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/zeebo/errs"
@@ -14,15 +18,24 @@ import (
 	"storj.io/drpc"
 	"storj.io/drpc/drpccache"
 	"storj.io/drpc/drpcctx"
+	"storj.io/drpc/drpcenc"
+	"storj.io/drpc/drpcerr"
 	"storj.io/drpc/drpcmanager"
+	"storj.io/drpc/drpcmetadata"
+	"storj.io/drpc/drpcmux"
+	"storj.io/drpc/drpcsignal"
 	"storj.io/drpc/drpcstats"
+	"storj.io/drpc/drpcstatus"
 	"storj.io/drpc/drpcstream"
+	"storj.io/drpc/internal/drpcnet"
 	"storj.io/drpc/internal/drpcopts"
 )
 
 // Options controls configuration settings for a server.
 type Options struct {
 	// Manager controls the options we pass to the managers this server creates.
+	// An Encoding set in the stream options is used for every message sent or
+	// received by the handler.
 	Manager drpcmanager.Options
 
 	// Log is called when errors happen that can not be returned up, like
@@ -33,6 +46,87 @@ type Options struct {
 	// CollectStats controls whether the server should collect stats on the
 	// rpcs it serves.
 	CollectStats bool
+
+	// IdleClosed is called with the transport of any connection that is closed
+	// because nothing was read from it for the InactivityTimeout in the manager
+	// options while no rpc was active. It is not called if nil.
+	IdleClosed func(tr drpc.Transport)
+
+	// PanicHandler is called with the rpc and recovered value whenever the
+	// handler panics. It is called before the stack is unwound, so it may
+	// use runtime/debug.Stack to log it. If it returns a non-nil error, that
+	// error is sent to the client instead of the default InternalError, which
+	// does not include any details about the panic.
+	PanicHandler func(rpc string, recovered interface{}) error
+
+	// MaxConnections limits how many connections accepted by Serve are served
+	// at once. Once the limit is reached, Serve stops accepting connections,
+	// leaving them in the listener's backlog, until one closes. Zero means
+	// unlimited.
+	MaxConnections int
+
+	// MaxConnectionAge, if positive, is how long a connection is served before
+	// the client is sent a go away asking it to close the connection once any
+	// active rpc finishes, so that it reconnects, possibly to another server.
+	MaxConnectionAge time.Duration
+
+	// MaxConnectionAgeGrace is how long the client has after the go away before
+	// the connection is forcibly closed, canceling any rpc that is still
+	// active. If zero or negative, the client is given as long as it needs.
+	MaxConnectionAgeGrace time.Duration
+
+	// MaxInFlight limits how many rpcs have their handler running at once
+	// across every connection, counting a stream once for its whole duration.
+	// Rpcs over the limit fail right away with a drpcstatus.ResourceExhausted
+	// status without calling the handler. Zero means unlimited.
+	MaxInFlight int
+
+	// MaxInFlightWait causes rpcs over the MaxInFlight limit to wait for
+	// another to finish, or for their context to be canceled, instead of
+	// failing.
+	MaxInFlightWait bool
+
+	// RPCDone, if set, is called once for every rpc when it finishes, with
+	// the error sent to the client, if any. Whether the rpc is a stream is
+	// only known for handlers that have a Method method like drpcmux.Mux, and
+	// it is reported as true for other handlers.
+	RPCDone func(info drpcstats.RPCInfo)
+
+	// Nagle leaves Nagle's algorithm enabled on served transports that are
+	// TCP connections, either directly or wrapped like by a *tls.Conn. By
+	// default, TCP_NODELAY is set on them so that small frames are not
+	// delayed. It has no effect on other transports.
+	Nagle bool
+
+	// AcceptError, if set, is called with the errors Serve gets accepting
+	// connections, other than those from a closed listener, and decides
+	// whether Serve keeps going. If it returns true, Serve tries again after a
+	// short sleep, and otherwise it returns the error. If nil, Serve logs and
+	// retries temporary errors and returns any others.
+	AcceptError func(err error) bool
+
+	// Encodings are the encodings, besides drpcenc.Baseline, that rpcs can
+	// use if their client lists them with drpcmetadata.EncodingKey. The first
+	// one the client lists that the server supports is used for every message
+	// of the rpc, and sent to the client as the header of the stream, so the
+	// handlers of those rpcs can not send their own header. Rpcs whose client
+	// lists none the server supports fail without calling the handler.
+	Encodings []drpcenc.Named
+
+	// ConnLog, if set, is called with the id and remote address, if known, of
+	// every connection when its serving starts, and returns the function that
+	// the events of the connection are logged with: it opening and closing,
+	// the handshake, and each rpc starting and finishing, including errors.
+	// The id is unique to the connection and available to its handlers with
+	// ConnIDFromContext, so a logger can tag every record with it. It is more
+	// granular than a logging interceptor like drpclog.Logger.
+	ConnLog func(connID string, peer net.Addr) ConnLogger
+
+	// CancelOnStop causes GracefulStop to cancel the context of every running
+	// handler as soon as it begins, so that handlers that watch it stop their
+	// work and the stop completes sooner. Otherwise, the handlers only notice
+	// the stop if their connections are forcibly closed.
+	CancelOnStop bool
 }
 
 // Server is an implementation of drpc.Server to serve drpc connections.
@@ -42,6 +136,38 @@ type Server struct {
 
 	mu    sync.Mutex
 	stats map[string]*drpcstats.Stats
+
+	cmu   sync.Mutex // protects lis and conns
+	lis   map[*serverListener]struct{}
+	conns map[*serverConn]struct{}
+	wg    sync.WaitGroup // counts the entries in conns
+
+	slots chan struct{} // holds a value for each connection served by Serve
+
+	inflight int64         // number of rpcs with a running handler. atomic.
+	handlers chan struct{} // holds a value for each rpc under MaxInFlight
+	draining int32         // 1 while new rpcs are rejected. atomic.
+
+	sigs struct {
+		stop    drpcsignal.Signal // set when a graceful stop begins
+		stopped drpcsignal.Signal // set when a graceful stop is complete
+	}
+}
+
+// serverListener keeps track of a listener being served. Listeners are not
+// always comparable, so they are tracked by the address of one of these.
+type serverListener struct {
+	lis net.Listener
+}
+
+// serverConn keeps track of a connection being served.
+type serverConn struct {
+	tr     drpc.Transport
+	man    *drpcmanager.Manager
+	log    ConnLogger // from the ConnLog option, if set
+	cancel func()     // cancels the handler contexts if CancelOnStop is set
+	active bool       // protected by the server's cmu
+	away   bool       // protected by the server's cmu
 }
 
 // New constructs a new Server.
@@ -57,6 +183,15 @@ func NewWithOptions(handler drpc.Handler, opts Options) *Server {
 		handler: handler,
 
 		stats: make(map[string]*drpcstats.Stats),
+		lis:   make(map[*serverListener]struct{}),
+		conns: make(map[*serverConn]struct{}),
+	}
+
+	if s.opts.MaxConnections > 0 {
+		s.slots = make(chan struct{}, s.opts.MaxConnections)
+	}
+	if s.opts.MaxInFlight > 0 {
+		s.handlers = make(chan struct{}, s.opts.MaxInFlight)
 	}
 
 	if s.opts.CollectStats {
@@ -78,6 +213,62 @@ func (s *Server) Stats() map[string]drpcstats.Stats {
 	return stats
 }
 
+// ConnStats is the stats of a connection being served.
+type ConnStats struct {
+	Transport drpc.Transport
+	Stats     drpcstats.Stats
+}
+
+// ConnStats returns the number of bytes, including framing, and messages sent
+// over every connection currently being served. It is collected regardless of
+// CollectStats.
+func (s *Server) ConnStats() []ConnStats {
+	s.cmu.Lock()
+	defer s.cmu.Unlock()
+
+	stats := make([]ConnStats, 0, len(s.conns))
+	for sc := range s.conns {
+		stats = append(stats, ConnStats{Transport: sc.tr, Stats: sc.man.Stats()})
+	}
+	return stats
+}
+
+// ActiveConnections returns the number of connections currently being served.
+func (s *Server) ActiveConnections() int {
+	s.cmu.Lock()
+	defer s.cmu.Unlock()
+
+	return len(s.conns)
+}
+
+// InFlight returns the number of rpcs that currently have their handler
+// running.
+func (s *Server) InFlight() int {
+	return int(atomic.LoadInt64(&s.inflight))
+}
+
+// DrainingKey is the trailer key set on every rpc that finishes while the
+// server is draining, so that clients can move to another server before their
+// next rpc is rejected.
+const DrainingKey = "drpc-draining"
+
+// SetDraining sets whether the server is draining. While it is, every new rpc
+// fails with a drpcstatus.Unavailable status without calling the handler, but
+// the connections are kept open and rpcs that are already running finish
+// normally. Unlike GracefulStop, it can be undone.
+func (s *Server) SetDraining(draining bool) {
+	var v int32
+	if draining {
+		v = 1
+	}
+	atomic.StoreInt32(&s.draining, v)
+}
+
+// Draining returns true if the server is draining.
+func (s *Server) Draining() bool {
+	return atomic.LoadInt32(&s.draining) == 1
+}
+
 // getStats returns the drpcopts.Stats struct for the given rpc.
 func (s *Server) getStats(rpc string) *drpcstats.Stats {
 	s.mu.Lock()
@@ -91,35 +282,328 @@ func (s *Server) getStats(rpc string) *drpcstats.Stats {
 	return stats
 }
 
+//
+// connection tracking
+//
+
+// trackConn adds the connection to the set of tracked connections. It returns
+// false if a graceful stop has begun, in which case it is not tracked.
+func (s *Server) trackConn(sc *serverConn) bool {
+	s.cmu.Lock()
+	defer s.cmu.Unlock()
+
+	if s.sigs.stop.IsSet() {
+		return false
+	}
+	s.conns[sc] = struct{}{}
+	s.wg.Add(1)
+	return true
+}
+
+// untrackConn removes the connection from the set of tracked connections.
+func (s *Server) untrackConn(sc *serverConn) {
+	s.cmu.Lock()
+	defer s.cmu.Unlock()
+
+	delete(s.conns, sc)
+	s.wg.Done()
+}
+
+// setActive marks if the connection is serving an rpc. It returns false if a
+// graceful stop has begun, in which case the connection should stop serving.
+func (s *Server) setActive(sc *serverConn, active bool) bool {
+	s.cmu.Lock()
+	defer s.cmu.Unlock()
+
+	sc.active = active
+	return !s.sigs.stop.IsSet()
+}
+
+// isAway returns true if the connection has reached the MaxConnectionAge.
+func (s *Server) isAway(sc *serverConn) bool {
+	s.cmu.Lock()
+	defer s.cmu.Unlock()
+
+	return sc.away
+}
+
+// manageAge sends the connection a go away once it reaches the MaxConnectionAge,
+// and closes it once the MaxConnectionAgeGrace has passed after that. The client
+// is expected to close it first, and any rpcs it started before reading the go
+// away are still served. It returns a function that stops the timers.
+func (s *Server) manageAge(sc *serverConn) (stop func()) {
+	var mu sync.Mutex
+	var grace *time.Timer
+
+	age := time.AfterFunc(s.opts.MaxConnectionAge, func() {
+		s.cmu.Lock()
+		sc.away = true
+		s.cmu.Unlock()
+
+		_ = sc.man.SendGoAway()
+
+		if s.opts.MaxConnectionAgeGrace > 0 {
+			mu.Lock()
+			grace = time.AfterFunc(s.opts.MaxConnectionAgeGrace, func() { _ = sc.man.Close() })
+			mu.Unlock()
+		}
+	})
+
+	return func() {
+		age.Stop()
+		mu.Lock()
+		if grace != nil {
+			grace.Stop()
+		}
+		mu.Unlock()
+	}
+}
+
+// trackListener adds the listener to the set of listeners that are closed when
+// a graceful stop begins. It returns false if one has already begun.
+func (s *Server) trackListener(sl *serverListener) bool {
+	s.cmu.Lock()
+	defer s.cmu.Unlock()
+
+	if s.sigs.stop.IsSet() {
+		return false
+	}
+	s.lis[sl] = struct{}{}
+	return true
+}
+
+// untrackListener removes the listener from the set of tracked listeners.
+func (s *Server) untrackListener(sl *serverListener) {
+	s.cmu.Lock()
+	defer s.cmu.Unlock()
+
+	delete(s.lis, sl)
+}
+
+// closeConns closes the managers of all of the tracked connections, or only the
+// idle ones if idle is true. It returns how many were closed.
+func (s *Server) closeConns(idle bool) int {
+	s.cmu.Lock()
+	var mans []*drpcmanager.Manager
+	for sc := range s.conns {
+		if !idle || !sc.active {
+			mans = append(mans, sc.man)
+		}
+	}
+	s.cmu.Unlock()
+
+	for _, man := range mans {
+		_ = man.Close()
+	}
+	return len(mans)
+}
+
+// GracefulStop stops the server from accepting new connections or rpcs, and
+// waits for any active rpcs to finish before closing their connections. Any
+// calls to Serve return once it begins. If the context is done before all of
+// the active rpcs finish, the remaining connections are closed and an error
+// wrapping the context error is returned. If the CancelOnStop option is set,
+// the contexts of the active rpcs are canceled when it begins.
+func (s *Server) GracefulStop(ctx context.Context) error {
+	_, err := s.gracefulStop(ctx)
+	return err
+}
+
+// gracefulStop implements GracefulStop, also returning how many connections
+// were forcibly closed.
+func (s *Server) gracefulStop(ctx context.Context) (int, error) {
+	s.cmu.Lock()
+	s.sigs.stop.Set(nil)
+	for sl := range s.lis {
+		_ = sl.lis.Close()
+	}
+	if s.opts.CancelOnStop {
+		for sc := range s.conns {
+			sc.cancel()
+		}
+	}
+	s.cmu.Unlock()
+
+	s.closeConns(true)
+
+	done := make(chan struct{})
+	go func() { s.wg.Wait(); close(done) }()
+
+	select {
+	case <-done:
+		s.sigs.stopped.Set(nil)
+		return 0, nil
+
+	case <-ctx.Done():
+		n := s.closeConns(false)
+		s.sigs.stopped.Set(nil)
+		return n, errs.New("graceful stop forcibly closed %d connections: %w", n, ctx.Err())
+	}
+}
+
+//
+// serving
+//
+
 // ServeOne serves a single set of rpcs on the provided transport.
 func (s *Server) ServeOne(ctx context.Context, tr drpc.Transport) (err error) {
+	_ = drpcnet.SetNoDelay(tr, !s.opts.Nagle)
+
 	man := drpcmanager.NewWithOptions(tr, s.opts.Manager)
 	defer func() { err = errs.Combine(err, man.Close()) }()
 
+	id := newConnID()
+	peer, _ := transportPeer(tr)
+	sc := &serverConn{tr: tr, man: man}
+	if s.opts.ConnLog != nil {
+		sc.log = s.opts.ConnLog(id, peer)
+	}
+	if s.opts.CancelOnStop {
+		ctx, sc.cancel = context.WithCancel(ctx)
+		defer sc.cancel()
+	}
+	if !s.trackConn(sc) {
+		return nil
+	}
+	defer s.untrackConn(sc)
+
+	if s.opts.MaxConnectionAge > 0 {
+		defer s.manageAge(sc)()
+	}
+
 	cache := drpccache.New()
 	defer cache.Clear()
 
 	ctx = drpccache.WithContext(ctx, cache)
+	ctx = context.WithValue(ctx, connIDKey{}, id)
+	if peer != nil {
+		ctx = context.WithValue(ctx, peerKey{}, peer)
+	}
+
+	sc.logf(ctx, "connection opened", nil)
+	defer func() { sc.logf(ctx, "connection closed", err) }()
 
+	handshake := false
 	for {
 		stream, rpc, err := man.NewServerStream(ctx)
 		if err != nil {
+			if s.sigs.stop.IsSet() || s.isAway(sc) {
+				return nil
+			}
+			if s.isIdleTimeout(ctx, err) && s.opts.IdleClosed != nil {
+				s.opts.IdleClosed(tr)
+			}
 			return errs.Wrap(err)
 		}
-		if err := s.handleRPC(stream, rpc); err != nil {
+		if !s.setActive(sc, true) {
+			return nil
+		}
+		if hello, ok := man.Negotiated(); ok && !handshake {
+			handshake = true
+			sc.logf(ctx, "handshake", nil, "version", hello.Version, "features", hello.Features)
+		}
+		if err := s.handleRPC(sc, stream, rpc); err != nil {
+			// a client sent a go away may close the connection as soon as
+			// it has the response.
+			if s.isAway(sc) {
+				return nil
+			}
 			return errs.Wrap(err)
 		}
+		if !s.setActive(sc, false) {
+			return nil
+		}
+	}
+}
+
+// ConnLogger logs an event of a connection with the error of the event, if
+// any, and attributes as alternating keys and values, like the arguments of
+// log/slog.Logger.Log.
+type ConnLogger func(ctx context.Context, msg string, err error, kvs ...interface{})
+
+// logf logs the event of the connection if it has a ConnLogger.
+func (sc *serverConn) logf(ctx context.Context, msg string, err error, kvs ...interface{}) {
+	if sc.log != nil {
+		sc.log(ctx, msg, err, kvs...)
+	}
+}
+
+type connIDKey struct{}
+
+// ConnIDFromContext returns the id of the connection serving the rpc with the
+// context passed to the handler or available from the stream. It is the id
+// passed to the ConnLog option.
+func ConnIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(connIDKey{}).(string)
+	return id, ok
+}
+
+// newConnID returns a new random connection id.
+func newConnID() string {
+	var buf [8]byte
+	_, _ = rand.Read(buf[:])
+	return hex.EncodeToString(buf[:])
+}
+
+type peerKey struct{}
+
+// PeerFromContext returns the remote address of the connection serving the rpc
+// with the context passed to the handler or available from the stream. It only
+// exists for transports that are a net.Conn other than an in-memory pipe.
+func PeerFromContext(ctx context.Context) (net.Addr, bool) {
+	addr, ok := ctx.Value(peerKey{}).(net.Addr)
+	return addr, ok
+}
+
+// transportPeer returns the remote address of the transport if it has one.
+func transportPeer(tr drpc.Transport) (net.Addr, bool) {
+	conn, ok := tr.(net.Conn)
+	if !ok {
+		return nil, false
 	}
+	addr := conn.RemoteAddr()
+	if addr == nil || addr.Network() == "pipe" {
+		return nil, false
+	}
+	return addr, true
+}
+
+// isIdleTimeout returns true if the error from creating a server stream is due
+// to the InactivityTimeout rather than the context.
+func (s *Server) isIdleTimeout(ctx context.Context, err error) bool {
+	return s.opts.Manager.InactivityTimeout > 0 &&
+		errors.Is(err, context.DeadlineExceeded) &&
+		ctx.Err() == nil
 }
 
 var temporarySleep = 500 * time.Millisecond
 
 // Serve listens for connections on the listener and serves the drpc request
-// on new connections.
+// on new connections. It returns once the context is canceled or a graceful
+// stop begins.
 func (s *Server) Serve(ctx context.Context, lis net.Listener) (err error) {
+	sl := &serverListener{lis: lis}
+	if !s.trackListener(sl) {
+		_ = lis.Close()
+		return nil
+	}
+	defer s.untrackListener(sl)
+
 	tracker := drpcctx.NewTracker(ctx)
-	defer tracker.Wait()
-	defer tracker.Cancel()
+	defer func() {
+		// during a graceful stop, the connections are left to finish their
+		// active rpcs so that we can return promptly.
+		if s.sigs.stop.IsSet() {
+			go func() {
+				s.sigs.stopped.Wait()
+				tracker.Cancel()
+				tracker.Wait()
+			}()
+			return
+		}
+		tracker.Cancel()
+		tracker.Wait()
+	}()
 
 	tracker.Run(func(ctx context.Context) {
 		<-ctx.Done()
@@ -127,33 +611,36 @@ func (s *Server) Serve(ctx context.Context, lis net.Listener) (err error) {
 	})
 
 	for {
+		if !s.acquireSlot(ctx) {
+			return nil
+		}
+
 		conn, err := lis.Accept()
 		if err != nil {
-			if ctx.Err() != nil {
+			s.releaseSlot()
+
+			if ctx.Err() != nil || s.sigs.stop.IsSet() {
 				return nil
 			}
 
-			if isTemporary(err) {
-				if s.opts.Log != nil {
-					s.opts.Log(err)
-				}
-
-				t := time.NewTimer(temporarySleep)
-				select {
-				case <-t.C:
-				case <-ctx.Done():
-					t.Stop()
-					return nil
-				}
+			if !s.retryAccept(err) {
+				return errs.Wrap(err)
+			}
 
-				continue
+			t := time.NewTimer(temporarySleep)
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				t.Stop()
+				return nil
 			}
 
-			return errs.Wrap(err)
+			continue
 		}
 
-		// TODO(jeff): connection limits?
 		tracker.Run(func(ctx context.Context) {
+			defer s.releaseSlot()
+
 			err := s.ServeOne(ctx, conn)
 			if err != nil && s.opts.Log != nil {
 				s.opts.Log(err)
@@ -162,11 +649,176 @@ func (s *Server) Serve(ctx context.Context, lis net.Listener) (err error) {
 	}
 }
 
-// handleRPC handles the rpc that has been requested by the stream.
-func (s *Server) handleRPC(stream *drpcstream.Stream, rpc string) (err error) {
-	err = s.handler.HandleRPC(stream, rpc)
-	if err != nil {
-		return errs.Wrap(stream.SendError(err))
+// retryAccept returns true if Serve should keep accepting connections after
+// the error from the listener.
+func (s *Server) retryAccept(err error) bool {
+	if errors.Is(err, net.ErrClosed) {
+		return false
+	} else if s.opts.AcceptError != nil {
+		return s.opts.AcceptError(err)
+	} else if !isTemporary(err) {
+		return false
+	}
+
+	if s.opts.Log != nil {
+		s.opts.Log(err)
+	}
+	return true
+}
+
+// acquireSlot waits until another connection may be accepted under the
+// MaxConnections limit. It returns false if the context is done or a graceful
+// stop begins first.
+func (s *Server) acquireSlot(ctx context.Context) bool {
+	if s.slots == nil {
+		return true
+	}
+
+	select {
+	case s.slots <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	case <-s.sigs.stop.Signal():
+		return false
+	}
+}
+
+// releaseSlot allows another connection to be accepted.
+func (s *Server) releaseSlot() {
+	if s.slots != nil {
+		<-s.slots
+	}
+}
+
+// acquireHandler waits until the handler for an rpc on the stream may run under
+// the MaxInFlight limit, returning an error if it may not.
+func (s *Server) acquireHandler(stream *drpcstream.Stream) error {
+	if s.handlers == nil {
+		return nil
+	}
+
+	select {
+	case s.handlers <- struct{}{}:
+		return nil
+	default:
+	}
+	if !s.opts.MaxInFlightWait {
+		return drpcstatus.Errorf(drpcstatus.ResourceExhausted, "too many rpcs in flight")
+	}
+
+	select {
+	case s.handlers <- struct{}{}:
+		return nil
+	case <-stream.Context().Done():
+		return stream.Context().Err()
+	}
+}
+
+// releaseHandler allows another handler to run.
+func (s *Server) releaseHandler() {
+	if s.handlers != nil {
+		<-s.handlers
+	}
+}
+
+// handleRPC handles the rpc that has been requested by the stream on the
+// connection.
+func (s *Server) handleRPC(sc *serverConn, stream *drpcstream.Stream, rpc string) (err error) {
+	start := time.Now()
+	sc.logf(stream.Context(), "rpc started", nil, "rpc", rpc, "stream", stream.ID())
+
+	var herr error
+	if s.Draining() {
+		herr = drpcstatus.Errorf(drpcstatus.Unavailable, "server is draining")
+	} else if herr = s.selectEncoding(stream); herr == nil {
+		herr = s.acquireHandler(stream)
+	}
+	if herr == nil {
+		atomic.AddInt64(&s.inflight, 1)
+		herr = s.callHandler(stream, rpc)
+		atomic.AddInt64(&s.inflight, -1)
+		s.releaseHandler()
+	}
+
+	if s.Draining() {
+		stream.SetTrailer(drpcmetadata.Metadata{DrainingKey: {"true"}})
+	}
+
+	if herr != nil {
+		// errors from the context package have no code, so send them with the
+		// one drpcstatus uses so that the client can tell them apart.
+		if drpcerr.Code(herr) == 0 {
+			if code := drpcstatus.CodeFromError(herr); code != drpcstatus.Unknown {
+				herr = drpcerr.WithCode(herr, uint64(code))
+			}
+		}
+		err = errs.Wrap(stream.SendError(herr))
+	} else {
+		err = errs.Wrap(stream.CloseSend())
+	}
+
+	sc.logf(stream.Context(), "rpc finished", errs.Combine(herr, err),
+		"rpc", rpc, "stream", stream.ID(), "duration", time.Since(start))
+
+	if s.opts.RPCDone != nil {
+		s.opts.RPCDone(drpcstats.RPCInfo{
+			RPC:      rpc,
+			Stream:   s.isStream(rpc),
+			Duration: time.Since(start),
+			Stats:    stream.Stats(),
+			Err:      errs.Combine(herr, err),
+		})
+	}
+	return err
+}
+
+// selectEncoding selects the encoding of the rpc on the stream if the client
+// listed the ones it can use, and tells the client which in the header of the
+// stream. It returns an error if none are supported.
+func (s *Server) selectEncoding(stream *drpcstream.Stream) error {
+	md, _ := drpcmetadata.MetadataFromContext(stream.Context())
+	names := md.Get(drpcmetadata.EncodingKey)
+	if len(names) == 0 {
+		return nil
+	}
+
+	name, enc, ok := drpcenc.Select(names, s.opts.Encodings)
+	if !ok {
+		return drpcerr.WithCode(
+			drpc.ProtocolError.New("no common encoding: client offered %q", names),
+			uint64(drpcstatus.Unimplemented))
+	}
+	if enc != nil {
+		stream.SetEncoding(enc)
+	}
+	return stream.SendHeader(drpcmetadata.Metadata{drpcmetadata.EncodingKey: {name}})
+}
+
+// isStream returns true unless the handler knows that the rpc is unitary.
+func (s *Server) isStream(rpc string) bool {
+	if h, ok := s.handler.(interface {
+		Method(rpc string) (drpcmux.MethodInfo, bool)
+	}); ok {
+		if info, ok := h.Method(rpc); ok {
+			return !info.Unitary
+		}
 	}
-	return errs.Wrap(stream.CloseSend())
+	return true
+}
+
+// callHandler calls the handler for the rpc, converting any panic into an error.
+func (s *Server) callHandler(stream *drpcstream.Stream, rpc string) (err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = drpc.InternalError.New("panic handling rpc")
+			if s.opts.PanicHandler != nil {
+				if perr := s.opts.PanicHandler(rpc, rec); perr != nil {
+					err = perr
+				}
+			}
+		}
+	}()
+
+	return s.handler.HandleRPC(stream, rpc)
 }
@@ -4,11 +4,22 @@
 package drpcserver
 
 import (
+	"context"
+	"errors"
+	"io"
 	"net"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/zeebo/assert"
 
+	"storj.io/drpc"
+	"storj.io/drpc/drpcconn"
+	"storj.io/drpc/drpcmanager"
+	"storj.io/drpc/drpcstats"
+	"storj.io/drpc/drpcstatus"
 	"storj.io/drpc/drpctest"
 )
 
@@ -35,6 +46,43 @@ func TestServerTemporarySleep(t *testing.T) {
 	assert.NoError(t, New(nil).Serve(ctx, l))
 }
 
+func TestServerAcceptError(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	permanent := errors.New("permanent error")
+
+	calls := 0
+	l := listener(func() (net.Conn, error) {
+		calls++
+		switch calls {
+		case 1:
+			return nil, new(temporaryError)
+		case 2:
+			return nil, permanent
+		default:
+			return nil, net.ErrClosed
+		}
+	})
+
+	// the temporary error is retried and the permanent one ends Serve
+	var seen []error
+	srv := NewWithOptions(nil, Options{AcceptError: func(err error) bool {
+		seen = append(seen, err)
+		return err != permanent
+	}})
+	err := srv.Serve(ctx, l)
+	assert.That(t, errors.Is(err, permanent))
+	assert.Equal(t, calls, 2)
+	assert.DeepEqual(t, seen, []error{new(temporaryError), permanent})
+
+	// even if the handler asks to retry, a closed listener ends Serve
+	calls = 2
+	srv = NewWithOptions(nil, Options{AcceptError: func(err error) bool { return true }})
+	err = srv.Serve(ctx, l)
+	assert.That(t, errors.Is(err, net.ErrClosed))
+}
+
 type listener func() (net.Conn, error)
 
 func (l listener) Accept() (net.Conn, error) { return l() }
@@ -46,3 +94,756 @@ type temporaryError struct{}
 func (temporaryError) Error() string   { return "temporary error" }
 func (temporaryError) Timeout() bool   { return false }
 func (temporaryError) Temporary() bool { return true }
+
+type handlerFunc func(stream drpc.Stream, rpc string) error
+
+func (fn handlerFunc) HandleRPC(stream drpc.Stream, rpc string) error { return fn(stream, rpc) }
+
+func TestServerIdleTimeout(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	var closed int64
+	srv := NewWithOptions(handlerFunc(func(stream drpc.Stream, rpc string) error {
+		// a long running rpc that is quiet for longer than the timeout.
+		time.Sleep(100 * time.Millisecond)
+		return nil
+	}), Options{
+		Manager:    drpcmanager.Options{InactivityTimeout: 20 * time.Millisecond},
+		IdleClosed: func(drpc.Transport) { atomic.AddInt64(&closed, 1) },
+	})
+
+	serve := func(copts drpcconn.Options) (*drpcconn.Conn, chan error) {
+		pc, ps := net.Pipe()
+		errch := make(chan error, 1)
+		ctx.Run(func(ctx context.Context) { errch <- srv.ServeOne(ctx, ps) })
+		return drpcconn.NewWithOptions(pc, copts), errch
+	}
+
+	{ // an idle connection is closed
+		conn, errch := serve(drpcconn.Options{})
+		assert.That(t, errors.Is(<-errch, context.DeadlineExceeded))
+		assert.Equal(t, atomic.LoadInt64(&closed), 1)
+		assert.NoError(t, conn.Close())
+	}
+
+	{ // a connection with an active stream is not closed
+		conn, errch := serve(drpcconn.Options{})
+		stream, err := conn.NewStream(ctx, "rpc", nil)
+		assert.NoError(t, err)
+		assert.NoError(t, stream.CloseSend())
+		assert.Equal(t, stream.MsgRecv(nil, nil), io.EOF)
+		assert.NoError(t, conn.Close())
+		<-errch
+	}
+
+	{ // the timeout is reset by anything being read
+		before := atomic.LoadInt64(&closed)
+		conn, errch := serve(drpcconn.Options{
+			Manager: drpcmanager.Options{KeepaliveInterval: 5 * time.Millisecond},
+		})
+		time.Sleep(100 * time.Millisecond)
+		assert.That(t, !closedCh(conn.Closed()))
+		assert.NoError(t, conn.Close())
+		<-errch
+		assert.Equal(t, atomic.LoadInt64(&closed), before)
+	}
+}
+
+func TestServerGracefulStop(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	started, release := make(chan struct{}), make(chan struct{})
+	srv := New(handlerFunc(func(stream drpc.Stream, rpc string) error {
+		close(started)
+		select {
+		case <-release:
+		case <-stream.Context().Done():
+		}
+		return nil
+	}))
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	serveErr := make(chan error, 1)
+	ctx.Run(func(ctx context.Context) { serveErr <- srv.Serve(ctx, lis) })
+
+	dial := func() *drpcconn.Conn {
+		rawconn, err := net.Dial("tcp", lis.Addr().String())
+		assert.NoError(t, err)
+		return drpcconn.New(rawconn)
+	}
+
+	active, idle := dial(), dial()
+	defer func() { _ = active.Close() }()
+	defer func() { _ = idle.Close() }()
+
+	stream, err := active.NewStream(ctx, "rpc", nil)
+	assert.NoError(t, err)
+	assert.NoError(t, stream.CloseSend())
+	<-started
+
+	stopErr := make(chan error, 1)
+	ctx.Run(func(ctx context.Context) { stopErr <- srv.GracefulStop(ctx) })
+
+	// serve returns and idle connections are closed while the rpc is active.
+	assert.NoError(t, <-serveErr)
+	<-idle.Closed()
+	assert.That(t, !closedCh(active.Closed()))
+
+	// the active rpc finishes and then the stop completes.
+	close(release)
+	assert.Equal(t, stream.MsgRecv(nil, nil), io.EOF)
+	assert.NoError(t, <-stopErr)
+	<-active.Closed()
+}
+
+func TestServerGracefulStop_Timeout(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	started := make(chan struct{})
+	srv := New(handlerFunc(func(stream drpc.Stream, rpc string) error {
+		close(started)
+		<-stream.Context().Done()
+		return nil
+	}))
+
+	pc, ps := net.Pipe()
+	ctx.Run(func(ctx context.Context) { _ = srv.ServeOne(ctx, ps) })
+
+	conn := drpcconn.New(pc)
+	defer func() { _ = conn.Close() }()
+
+	stream, err := conn.NewStream(ctx, "rpc", nil)
+	assert.NoError(t, err)
+	assert.NoError(t, stream.CloseSend())
+	<-started
+
+	stopCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+
+	err = srv.GracefulStop(stopCtx)
+	assert.That(t, errors.Is(err, context.DeadlineExceeded))
+	assert.That(t, strings.Contains(err.Error(), "forcibly closed 1 connections"))
+	<-conn.Closed()
+
+	// new connections are not served after a stop.
+	pc, ps = net.Pipe()
+	defer func() { _ = pc.Close() }()
+	assert.NoError(t, srv.ServeOne(ctx, ps))
+}
+
+func TestServerGracefulStop_CancelOnStop(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	started := make(chan struct{})
+	srv := NewWithOptions(handlerFunc(func(stream drpc.Stream, rpc string) error {
+		close(started)
+		<-stream.Context().Done()
+		return stream.Context().Err()
+	}), Options{CancelOnStop: true})
+
+	pc, ps := net.Pipe()
+	ctx.Run(func(ctx context.Context) { _ = srv.ServeOne(ctx, ps) })
+
+	conn := drpcconn.New(pc)
+	defer func() { _ = conn.Close() }()
+
+	stream, err := conn.NewStream(ctx, "rpc", nil)
+	assert.NoError(t, err)
+	assert.NoError(t, stream.CloseSend())
+	<-started
+
+	// the handler returns once its context is canceled by the stop, which
+	// then completes without closing the connection from under it.
+	assert.NoError(t, srv.GracefulStop(ctx))
+	assert.Equal(t, drpcstatus.CodeFromError(stream.MsgRecv(nil, nil)), drpcstatus.Canceled)
+}
+
+func TestServeWithGracefulShutdown(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	// run starts an rpc, cancels the serving context while it is in flight
+	// and returns the result of the shutdown and of the rpc.
+	run := func(drainTimeout time.Duration, release <-chan struct{}) (int, error, error) {
+		started := make(chan struct{})
+		srv := New(handlerFunc(func(stream drpc.Stream, rpc string) error {
+			close(started)
+			select {
+			case <-release:
+				return nil
+			case <-stream.Context().Done():
+				return stream.Context().Err()
+			}
+		}))
+
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+
+		serveCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		type result struct {
+			forced int
+			err    error
+		}
+		done := make(chan result, 1)
+		ctx.Run(func(context.Context) {
+			forced, err := ServeWithGracefulShutdown(serveCtx, srv, lis, drainTimeout)
+			done <- result{forced, err}
+		})
+
+		rawconn, err := net.Dial("tcp", lis.Addr().String())
+		assert.NoError(t, err)
+		conn := drpcconn.New(rawconn)
+		defer func() { _ = conn.Close() }()
+
+		stream, err := conn.NewStream(ctx, "rpc", nil)
+		assert.NoError(t, err)
+		assert.NoError(t, stream.CloseSend())
+		<-started
+
+		cancel()
+		res := <-done
+		return res.forced, res.err, stream.MsgRecv(nil, nil)
+	}
+
+	{ // rpcs that finish within the drain timeout complete normally
+		release := make(chan struct{})
+		time.AfterFunc(10*time.Millisecond, func() { close(release) })
+
+		forced, err, rpcErr := run(time.Minute, release)
+		assert.NoError(t, err)
+		assert.Equal(t, forced, 0)
+		assert.Equal(t, rpcErr, io.EOF)
+	}
+
+	{ // rpcs that take longer have their connections closed
+		forced, err, rpcErr := run(10*time.Millisecond, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, forced, 1)
+		assert.Error(t, rpcErr)
+	}
+}
+
+func closedCh(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestServerPanic(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	handler := handlerFunc(func(stream drpc.Stream, rpc string) error {
+		if rpc == "panic" {
+			panic("secret details")
+		}
+		return nil
+	})
+
+	// run calls an rpc that panics followed by one that does not on the same
+	// connection, returning the error from the one that panics.
+	run := func(srv *Server) error {
+		pc, ps := net.Pipe()
+		ctx.Run(func(ctx context.Context) { _ = srv.ServeOne(ctx, ps) })
+
+		conn := drpcconn.New(pc)
+		defer func() { _ = conn.Close() }()
+
+		call := func(rpc string) error {
+			stream, err := conn.NewStream(ctx, rpc, nil)
+			assert.NoError(t, err)
+			defer func() { _ = stream.Close() }()
+
+			assert.NoError(t, stream.CloseSend())
+			return stream.MsgRecv(nil, nil)
+		}
+
+		err := call("panic")
+		assert.Equal(t, call("ok"), io.EOF)
+		return err
+	}
+
+	{ // by default the client gets an error without any details
+		err := run(New(handler))
+		assert.Error(t, err)
+		assert.That(t, !strings.Contains(err.Error(), "secret details"))
+		assert.That(t, !strings.Contains(err.Error(), "goroutine"))
+	}
+
+	{ // the panic handler can customize the error
+		var recovered interface{}
+		err := run(NewWithOptions(handler, Options{
+			PanicHandler: func(rpc string, rec interface{}) error {
+				recovered = rec
+				return errors.New("custom: " + rpc)
+			},
+		}))
+		assert.Equal(t, recovered, "secret details")
+		assert.Equal(t, err.Error(), "custom: panic")
+	}
+}
+
+func TestPeerFromContext(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	srv := New(handlerFunc(func(stream drpc.Stream, rpc string) error {
+		var in, peer []byte
+		if err := stream.MsgRecv(&in, drpctest.ByteEncoding{}); err != nil {
+			return err
+		}
+		if addr, ok := PeerFromContext(stream.Context()); ok {
+			peer = []byte(addr.String())
+		}
+		return stream.MsgSend(&peer, drpctest.ByteEncoding{})
+	}))
+
+	call := func(tr drpc.Transport) string {
+		conn := drpcconn.New(tr)
+		defer func() { _ = conn.Close() }()
+
+		var peer []byte
+		assert.NoError(t, conn.Invoke(ctx, "rpc", drpctest.ByteEncoding{}, &peer, &peer))
+		return string(peer)
+	}
+
+	{ // tcp connections have the address of the dialing socket
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+		ctx.Run(func(ctx context.Context) { _ = srv.Serve(ctx, lis) })
+
+		rawconn, err := net.Dial("tcp", lis.Addr().String())
+		assert.NoError(t, err)
+		assert.Equal(t, call(rawconn), rawconn.LocalAddr().String())
+	}
+
+	{ // in-memory pipes have no address
+		pc, ps := net.Pipe()
+		ctx.Run(func(ctx context.Context) { _ = srv.ServeOne(ctx, ps) })
+		assert.Equal(t, call(pc), "")
+	}
+}
+
+func TestServerDeadlineExceeded(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	srv := New(handlerFunc(func(stream drpc.Stream, rpc string) error {
+		var in []byte
+		if err := stream.MsgRecv(&in, drpctest.ByteEncoding{}); err != nil {
+			return err
+		}
+		if rpc == "server" {
+			// the handler runs out of time on its own deadline.
+			hctx, cancel := context.WithTimeout(stream.Context(), time.Millisecond)
+			defer cancel()
+			<-hctx.Done()
+			return hctx.Err()
+		}
+		<-stream.Context().Done()
+		return stream.Context().Err()
+	}))
+
+	invoke := func(ctx context.Context, rpc string) error {
+		pc, ps := net.Pipe()
+		defer func() { _ = pc.Close() }()
+		go func() { _ = srv.ServeOne(ctx, ps) }()
+
+		conn := drpcconn.New(pc)
+		defer func() { _ = conn.Close() }()
+
+		in := []byte("data")
+		return conn.Invoke(ctx, rpc, drpctest.ByteEncoding{}, &in, &in)
+	}
+
+	{ // the deadline of the client passes
+		cctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+
+		err := invoke(cctx, "client")
+		assert.That(t, drpcstatus.IsDeadlineExceeded(err))
+	}
+
+	{ // the server reports that a deadline passed
+		err := invoke(ctx, "server")
+		assert.That(t, !errors.Is(err, context.DeadlineExceeded))
+		assert.That(t, drpcstatus.IsDeadlineExceeded(err))
+	}
+
+	{ // canceled contexts are distinct
+		cctx, cancel := context.WithCancel(ctx)
+		cancel()
+
+		err := invoke(cctx, "client")
+		assert.Error(t, err)
+		assert.That(t, !drpcstatus.IsDeadlineExceeded(err))
+	}
+}
+
+func TestServerMaxConnections(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	srv := NewWithOptions(handlerFunc(func(stream drpc.Stream, rpc string) error {
+		var in []byte
+		if err := stream.MsgRecv(&in, drpctest.ByteEncoding{}); err != nil {
+			return err
+		}
+		return stream.MsgSend(&in, drpctest.ByteEncoding{})
+	}), Options{MaxConnections: 1})
+	ctx.Run(func(ctx context.Context) { _ = srv.Serve(ctx, lis) })
+
+	dial := func() *drpcconn.Conn {
+		rawconn, err := net.Dial("tcp", lis.Addr().String())
+		assert.NoError(t, err)
+		return drpcconn.New(rawconn)
+	}
+
+	conn1 := dial()
+	assert.NoError(t, conn1.Invoke(ctx, "rpc", drpctest.ByteEncoding{}, new([]byte), new([]byte)))
+	assert.Equal(t, srv.ActiveConnections(), 1)
+
+	// the second connection sits in the backlog until the first closes.
+	conn2 := dial()
+	defer func() { _ = conn2.Close() }()
+
+	errch := make(chan error, 1)
+	go func() { errch <- conn2.Invoke(ctx, "rpc", drpctest.ByteEncoding{}, new([]byte), new([]byte)) }()
+
+	select {
+	case err := <-errch:
+		t.Fatal("rpc completed on connection over the limit:", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	assert.Equal(t, srv.ActiveConnections(), 1)
+
+	assert.NoError(t, conn1.Close())
+	assert.NoError(t, <-errch)
+	assert.Equal(t, srv.ActiveConnections(), 1)
+}
+
+func TestServerMaxConnectionAge(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	srv := NewWithOptions(handlerFunc(func(stream drpc.Stream, rpc string) error {
+		var in []byte
+		if err := stream.MsgRecv(&in, drpctest.ByteEncoding{}); err != nil {
+			return err
+		}
+		switch rpc {
+		case "slow":
+			time.Sleep(200 * time.Millisecond)
+		case "block":
+			<-stream.Context().Done()
+			return stream.Context().Err()
+		}
+		return stream.MsgSend(&in, drpctest.ByteEncoding{})
+	}), Options{
+		MaxConnectionAge:      100 * time.Millisecond,
+		MaxConnectionAgeGrace: 300 * time.Millisecond,
+	})
+
+	serve := func() (*drpcconn.Conn, chan error) {
+		pc, ps := net.Pipe()
+		errch := make(chan error, 1)
+		ctx.Run(func(ctx context.Context) { errch <- srv.ServeOne(ctx, ps) })
+		return drpcconn.New(pc), errch
+	}
+	invoke := func(conn *drpcconn.Conn, rpc string) error {
+		in, out := []byte("data"), []byte(nil)
+		return conn.Invoke(ctx, rpc, drpctest.ByteEncoding{}, &in, &out)
+	}
+
+	{ // an idle connection is closed once it is old enough
+		conn, errch := serve()
+		assert.NoError(t, invoke(conn, "fast"))
+		assert.NoError(t, <-errch)
+		<-conn.Closed()
+	}
+
+	{ // an rpc active at the age finishes before the connection is closed
+		conn, errch := serve()
+		assert.NoError(t, invoke(conn, "slow"))
+		assert.NoError(t, <-errch)
+		<-conn.Closed()
+	}
+
+	{ // an rpc that does not finish within the grace is canceled
+		conn, errch := serve()
+		start := time.Now()
+		assert.Error(t, invoke(conn, "block"))
+		assert.That(t, time.Since(start) >= 400*time.Millisecond)
+		<-errch
+		<-conn.Closed()
+	}
+}
+
+func TestServerMaxInFlight(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	handler := handlerFunc(func(stream drpc.Stream, rpc string) error {
+		var in []byte
+		if err := stream.MsgRecv(&in, drpctest.ByteEncoding{}); err != nil {
+			return err
+		}
+		if rpc == "block" {
+			started <- struct{}{}
+			<-release
+		}
+		return stream.MsgSend(&in, drpctest.ByteEncoding{})
+	})
+
+	dial := func(srv *Server) *drpcconn.Conn {
+		pc, ps := net.Pipe()
+		ctx.Run(func(ctx context.Context) { _ = srv.ServeOne(ctx, ps) })
+		return drpcconn.New(pc)
+	}
+
+	// block starts a stream that is in flight until release is closed.
+	block := func(srv *Server) drpc.Stream {
+		stream, err := dial(srv).NewStream(ctx, "block", drpctest.ByteEncoding{})
+		assert.NoError(t, err)
+		assert.NoError(t, stream.MsgSend(new([]byte), drpctest.ByteEncoding{}))
+		<-started
+		return stream
+	}
+
+	{ // rpcs over the limit are rejected
+		srv := NewWithOptions(handler, Options{MaxInFlight: 1})
+		stream := block(srv)
+		assert.Equal(t, srv.InFlight(), 1)
+
+		conn := dial(srv)
+		err := conn.Invoke(ctx, "rpc", drpctest.ByteEncoding{}, new([]byte), new([]byte))
+		assert.Equal(t, drpcstatus.CodeFromError(err), drpcstatus.ResourceExhausted)
+
+		release <- struct{}{}
+		assert.NoError(t, stream.MsgRecv(new([]byte), drpctest.ByteEncoding{}))
+		assert.NoError(t, stream.Close())
+	}
+
+	{ // or wait for the limit if configured to
+		srv := NewWithOptions(handler, Options{MaxInFlight: 1, MaxInFlightWait: true})
+		stream := block(srv)
+
+		errch := make(chan error, 1)
+		conn := dial(srv)
+		go func() { errch <- conn.Invoke(ctx, "rpc", drpctest.ByteEncoding{}, new([]byte), new([]byte)) }()
+
+		select {
+		case err := <-errch:
+			t.Fatal("rpc completed over the limit:", err)
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		release <- struct{}{}
+		assert.NoError(t, stream.MsgRecv(new([]byte), drpctest.ByteEncoding{}))
+		assert.NoError(t, stream.Close())
+		assert.NoError(t, <-errch)
+	}
+}
+
+func TestServerRPCDone(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	infos := make(chan drpcstats.RPCInfo, 10)
+	srv := NewWithOptions(handlerFunc(func(stream drpc.Stream, rpc string) error {
+		var in []byte
+		if err := stream.MsgRecv(&in, drpctest.ByteEncoding{}); err != nil {
+			return err
+		}
+		if rpc == "fail" {
+			return drpcstatus.Errorf(drpcstatus.NotFound, "missing")
+		}
+		return stream.MsgSend(&in, drpctest.ByteEncoding{})
+	}), Options{RPCDone: func(info drpcstats.RPCInfo) { infos <- info }})
+
+	pc, ps := net.Pipe()
+	ctx.Run(func(ctx context.Context) { _ = srv.ServeOne(ctx, ps) })
+
+	conn := drpcconn.New(pc)
+	defer func() { _ = conn.Close() }()
+
+	in := []byte("abc")
+	assert.NoError(t, conn.Invoke(ctx, "rpc", drpctest.ByteEncoding{}, &in, new([]byte)))
+
+	info := <-infos
+	assert.Equal(t, info.RPC, "rpc")
+	assert.That(t, info.Duration > 0 && info.Err == nil)
+	assert.Equal(t, info.Stats.Read, uint64(3))
+	assert.Equal(t, info.Stats.MessagesWritten, uint64(1))
+
+	assert.Error(t, conn.Invoke(ctx, "fail", drpctest.ByteEncoding{}, &in, new([]byte)))
+
+	info = <-infos
+	assert.Equal(t, info.RPC, "fail")
+	assert.That(t, info.Duration > 0)
+	assert.Equal(t, drpcstatus.CodeFromError(info.Err), drpcstatus.NotFound)
+}
+
+func TestServerDraining(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	srv := New(handlerFunc(func(stream drpc.Stream, rpc string) error {
+		for {
+			var in []byte
+			if err := stream.MsgRecv(&in, drpctest.ByteEncoding{}); errors.Is(err, io.EOF) {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			if err := stream.MsgSend(&in, drpctest.ByteEncoding{}); err != nil {
+				return err
+			}
+		}
+	}))
+
+	dial := func() *drpcconn.Conn {
+		pc, ps := net.Pipe()
+		ctx.Run(func(ctx context.Context) { _ = srv.ServeOne(ctx, ps) })
+		return drpcconn.New(pc)
+	}
+
+	echo := func(stream drpc.Stream) {
+		in, out := []byte("abc"), []byte(nil)
+		assert.NoError(t, stream.MsgSend(&in, drpctest.ByteEncoding{}))
+		assert.NoError(t, stream.MsgRecv(&out, drpctest.ByteEncoding{}))
+		assert.Equal(t, string(out), "abc")
+	}
+
+	open := dial()
+	defer func() { _ = open.Close() }()
+
+	stream, err := open.NewStream(ctx, "rpc", drpctest.ByteEncoding{})
+	assert.NoError(t, err)
+	echo(stream)
+
+	srv.SetDraining(true)
+	assert.That(t, srv.Draining())
+
+	// new rpcs are rejected and told that the server is draining
+	conn := dial()
+	defer func() { _ = conn.Close() }()
+
+	trailer, err := conn.InvokeWithTrailer(ctx, "rpc", drpctest.ByteEncoding{}, new([]byte), new([]byte))
+	assert.Equal(t, drpcstatus.CodeFromError(err), drpcstatus.Unavailable)
+	assert.DeepEqual(t, trailer.Get(DrainingKey), []string{"true"})
+
+	// but the stream that is already open keeps working
+	echo(stream)
+	assert.NoError(t, stream.CloseSend())
+	assert.That(t, errors.Is(stream.MsgRecv(new([]byte), drpctest.ByteEncoding{}), io.EOF))
+	assert.NoError(t, stream.Close())
+
+	// and rpcs work again on the same connection once draining stops
+	srv.SetDraining(false)
+	assert.NoError(t, conn.Invoke(ctx, "rpc", drpctest.ByteEncoding{}, new([]byte), new([]byte)))
+}
+
+func TestServerAbortWithStatus(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	srv := New(handlerFunc(func(stream drpc.Stream, rpc string) error {
+		for i := 0; i < 2; i++ {
+			msg := []byte("data")
+			if err := stream.MsgSend(&msg, drpctest.ByteEncoding{}); err != nil {
+				return err
+			}
+		}
+		return drpcstatus.Errorf(drpcstatus.PermissionDenied, "not allowed")
+	}))
+
+	pc, ps := net.Pipe()
+	ctx.Run(func(ctx context.Context) { _ = srv.ServeOne(ctx, ps) })
+
+	conn := drpcconn.New(pc)
+	defer func() { _ = conn.Close() }()
+
+	stream, err := conn.NewStream(ctx, "rpc", drpctest.ByteEncoding{})
+	assert.NoError(t, err)
+	assert.NoError(t, stream.CloseSend())
+
+	// the messages sent before the handler aborted are received first
+	for i := 0; i < 2; i++ {
+		var out []byte
+		assert.NoError(t, stream.MsgRecv(&out, drpctest.ByteEncoding{}))
+		assert.Equal(t, string(out), "data")
+	}
+
+	// and then the status instead of io.EOF
+	err = stream.MsgRecv(new([]byte), drpctest.ByteEncoding{})
+	assert.That(t, !errors.Is(err, io.EOF))
+	st := drpcstatus.FromError(err)
+	assert.Equal(t, st.StatusCode(), drpcstatus.PermissionDenied)
+	assert.Equal(t, st.Message(), "not allowed")
+}
+
+func TestServerConnLog(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	type record struct {
+		id, peer, msg string
+	}
+	records := make(chan record, 100)
+
+	srv := NewWithOptions(handlerFunc(func(stream drpc.Stream, rpc string) error {
+		var in []byte
+		if err := stream.MsgRecv(&in, drpctest.ByteEncoding{}); err != nil {
+			return err
+		}
+		id, _ := ConnIDFromContext(stream.Context())
+		out := []byte(id)
+		return stream.MsgSend(&out, drpctest.ByteEncoding{})
+	}), Options{
+		ConnLog: func(connID string, peer net.Addr) ConnLogger {
+			return func(ctx context.Context, msg string, err error, kvs ...interface{}) {
+				records <- record{id: connID, peer: peer.String(), msg: msg}
+			}
+		},
+	})
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	ctx.Run(func(ctx context.Context) { _ = srv.Serve(ctx, lis) })
+
+	// each connection logs its lifecycle with its own id, which its handlers
+	// also see.
+	ids := make(map[string]bool)
+	for i := 0; i < 2; i++ {
+		rawconn, err := net.Dial("tcp", lis.Addr().String())
+		assert.NoError(t, err)
+		conn := drpcconn.New(rawconn)
+
+		var id []byte
+		assert.NoError(t, conn.Invoke(ctx, "rpc", drpctest.ByteEncoding{}, new([]byte), &id))
+		assert.NoError(t, conn.Close())
+		assert.That(t, !ids[string(id)])
+		ids[string(id)] = true
+
+		for _, msg := range []string{"connection opened", "rpc started", "rpc finished", "connection closed"} {
+			rec := <-records
+			assert.Equal(t, rec, record{id: string(id), peer: rawconn.LocalAddr().String(), msg: msg})
+		}
+	}
+}
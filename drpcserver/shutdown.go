@@ -0,0 +1,43 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcserver
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// ServeWithGracefulShutdown serves rpcs on the listener until the context is
+// canceled, for example when the process receives a signal, and then
+// gracefully stops the server. Active rpcs are given up to drainTimeout to
+// finish before their connections are closed. It returns how many connections
+// had to be closed that way, along with any error from Serve.
+//
+// The rpcs are not served with the context itself so that canceling it does
+// not cancel them, but they still have access to its values.
+func ServeWithGracefulShutdown(ctx context.Context, srv *Server, lis net.Listener, drainTimeout time.Duration) (forced int, err error) {
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- srv.Serve(detachedContext{ctx}, lis) }()
+
+	select {
+	case err := <-serveErr:
+		return 0, err
+	case <-ctx.Done():
+	}
+
+	stopCtx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+
+	forced, _ = srv.gracefulStop(stopCtx)
+	return forced, <-serveErr
+}
+
+// detachedContext has the values of the context it wraps but is never
+// canceled.
+type detachedContext struct{ context.Context }
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
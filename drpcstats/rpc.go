@@ -0,0 +1,26 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcstats
+
+import "time"
+
+// RPCInfo describes an rpc that has finished.
+type RPCInfo struct {
+	// RPC is the name of the rpc.
+	RPC string
+
+	// Stream is true if the rpc used a stream rather than taking and
+	// returning a single message.
+	Stream bool
+
+	// Duration is how long the rpc took.
+	Duration time.Duration
+
+	// Stats counts the bytes, not including framing, and messages sent and
+	// received by the rpc.
+	Stats Stats
+
+	// Err is the error the rpc failed with, or nil if it succeeded.
+	Err error
+}
@@ -7,10 +7,17 @@ import (
 	"sync/atomic"
 )
 
-// Stats keeps counters of read and written bytes.
+// Stats keeps counters of read and written bytes and messages.
 type Stats struct {
 	Read    uint64
 	Written uint64
+
+	MessagesRead    uint64
+	MessagesWritten uint64
+
+	// Buffered is the number of bytes that have been read but not yet
+	// processed. Unlike the other fields, it goes down as well as up.
+	Buffered uint64
 }
 
 // AddRead atomically adds n bytes to the Read counter.
@@ -27,10 +34,43 @@ func (s *Stats) AddWritten(n uint64) {
 	}
 }
 
+// AddMessagesRead atomically adds n messages to the MessagesRead counter.
+func (s *Stats) AddMessagesRead(n uint64) {
+	if s != nil {
+		atomic.AddUint64(&s.MessagesRead, n)
+	}
+}
+
+// AddMessagesWritten atomically adds n messages to the MessagesWritten counter.
+func (s *Stats) AddMessagesWritten(n uint64) {
+	if s != nil {
+		atomic.AddUint64(&s.MessagesWritten, n)
+	}
+}
+
+// AddBuffered atomically adds n bytes to the Buffered count.
+func (s *Stats) AddBuffered(n uint64) {
+	if s != nil {
+		atomic.AddUint64(&s.Buffered, n)
+	}
+}
+
+// SubBuffered atomically removes n bytes from the Buffered count.
+func (s *Stats) SubBuffered(n uint64) {
+	if s != nil {
+		atomic.AddUint64(&s.Buffered, -n)
+	}
+}
+
 // AtomicClone returns a copy of the stats that is safe to use concurrently with Add methods.
 func (s *Stats) AtomicClone() Stats {
 	return Stats{
 		Read:    atomic.LoadUint64(&s.Read),
 		Written: atomic.LoadUint64(&s.Written),
+
+		MessagesRead:    atomic.LoadUint64(&s.MessagesRead),
+		MessagesWritten: atomic.LoadUint64(&s.MessagesWritten),
+
+		Buffered: atomic.LoadUint64(&s.Buffered),
 	}
 }
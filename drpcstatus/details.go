@@ -0,0 +1,126 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcstatus
+
+import (
+	"reflect"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"storj.io/drpc"
+	"storj.io/drpc/drpcerr"
+)
+
+// typeURLPrefix is the prefix of the type urls of details, which are encoded
+// like the google.protobuf.Any messages gRPC uses for them.
+const typeURLPrefix = "type.googleapis.com/"
+
+// WithDetails returns a copy of the status that also carries the details,
+// marshaled with the encoding, like the details of a gRPC status. They are
+// sent to the remote with the error, where Details returns them. Each detail
+// is tagged with the full name of its type if it is a protobuf message, and
+// with its Go type name otherwise.
+func (s *Status) WithDetails(enc drpc.Encoding, details ...drpc.Message) (*Status, error) {
+	out := &Status{code: s.code, err: s.err}
+	out.details = append(out.details, s.details...)
+
+	for _, detail := range details {
+		data, err := enc.Marshal(detail)
+		if err != nil {
+			return nil, err
+		}
+		out.details = append(out.details, appendAny(nil, detailName(detail), data))
+	}
+	return out, nil
+}
+
+// Details returns the encoded details of the status, so that drpcerr.Details
+// finds them when the status is sent.
+func (s *Status) Details() [][]byte {
+	if s.details != nil {
+		return s.details
+	}
+	return drpcerr.Details(s.err)
+}
+
+// Details returns the details of the error, in the order they were attached,
+// unmarshaled with the encoding into new values of the types that are passed
+// in, which must be pointers. Details of other types are skipped, so an empty
+// slice is returned if the error has none that are expected.
+func Details(err error, enc drpc.Encoding, types ...drpc.Message) []drpc.Message {
+	out := []drpc.Message{}
+	for _, data := range drpcerr.Details(err) {
+		name, value, ok := parseAny(data)
+		if !ok {
+			continue
+		}
+		for _, typ := range types {
+			if detailName(typ) != name {
+				continue
+			}
+			msg := reflect.New(reflect.TypeOf(typ).Elem()).Interface()
+			if enc.Unmarshal(value, msg) == nil {
+				out = append(out, msg)
+			}
+			break
+		}
+	}
+	return out
+}
+
+// detailName returns the name a detail is tagged with.
+func detailName(msg drpc.Message) string {
+	if pm, ok := msg.(interface{ ProtoReflect() protoreflect.Message }); ok {
+		return string(pm.ProtoReflect().Descriptor().FullName())
+	}
+	typ := reflect.TypeOf(msg)
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return typ.PkgPath() + "." + typ.Name()
+}
+
+// appendAny appends the google.protobuf.Any encoding of the detail.
+func appendAny(buf []byte, name string, value []byte) []byte {
+	buf = protowire.AppendTag(buf, 1, protowire.BytesType)
+	buf = protowire.AppendString(buf, typeURLPrefix+name)
+	buf = protowire.AppendTag(buf, 2, protowire.BytesType)
+	buf = protowire.AppendBytes(buf, value)
+	return buf
+}
+
+// parseAny returns the name and value of the google.protobuf.Any encoding of
+// a detail.
+func parseAny(buf []byte) (name string, value []byte, ok bool) {
+	var url string
+	for len(buf) > 0 {
+		num, typ, n := protowire.ConsumeTag(buf)
+		if n < 0 {
+			return "", nil, false
+		}
+		buf = buf[n:]
+
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			url, n = protowire.ConsumeString(buf)
+		case num == 2 && typ == protowire.BytesType:
+			value, n = protowire.ConsumeBytes(buf)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, buf)
+		}
+		if n < 0 {
+			return "", nil, false
+		}
+		buf = buf[n:]
+	}
+
+	// the type url may have any prefix ending in a slash.
+	for i := len(url) - 1; i >= 0; i-- {
+		if url[i] == '/' {
+			return url[i+1:], value, true
+		}
+	}
+	return url, value, url != ""
+}
@@ -0,0 +1,92 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcstatus_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"storj.io/drpc"
+	"storj.io/drpc/drpcconn"
+	"storj.io/drpc/drpcserver"
+	"storj.io/drpc/drpcstatus"
+	"storj.io/drpc/drpctest"
+)
+
+type protoEncoding struct{}
+
+func (protoEncoding) Marshal(msg drpc.Message) ([]byte, error) {
+	return proto.Marshal(msg.(proto.Message))
+}
+
+func (protoEncoding) Unmarshal(buf []byte, msg drpc.Message) error {
+	return proto.Unmarshal(buf, msg.(proto.Message))
+}
+
+type handlerFunc func(stream drpc.Stream, rpc string) error
+
+func (fn handlerFunc) HandleRPC(stream drpc.Stream, rpc string) error { return fn(stream, rpc) }
+
+func TestDetails(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	pc, ps := net.Pipe()
+	defer func() { _ = pc.Close() }()
+
+	srv := drpcserver.New(handlerFunc(func(stream drpc.Stream, rpc string) error {
+		var in []byte
+		if err := stream.MsgRecv(&in, drpctest.ByteEncoding{}); err != nil {
+			return err
+		}
+		if rpc == "plain" {
+			return drpcstatus.Errorf(drpcstatus.NotFound, "missing")
+		}
+		st, err := drpcstatus.FromError(drpcstatus.Errorf(drpcstatus.InvalidArgument, "invalid")).
+			WithDetails(protoEncoding{}, wrapperspb.String("field: name"), durationpb.New(5), wrapperspb.String("field: age"))
+		if err != nil {
+			return err
+		}
+		return st
+	}))
+	ctx.Run(func(ctx context.Context) { _ = srv.ServeOne(ctx, ps) })
+
+	conn := drpcconn.New(pc)
+	defer func() { _ = conn.Close() }()
+
+	invoke := func(rpc string) error {
+		in, out := []byte("in"), []byte(nil)
+		return conn.Invoke(ctx, rpc, drpctest.ByteEncoding{}, &in, &out)
+	}
+
+	{ // the details are received in order, skipping types that are not asked for
+		err := invoke("details")
+		assert.Equal(t, drpcstatus.CodeFromError(err), drpcstatus.InvalidArgument)
+		assert.Equal(t, err.Error(), "invalid")
+
+		details := drpcstatus.Details(err, protoEncoding{}, &wrapperspb.StringValue{})
+		assert.Equal(t, len(details), 2)
+		assert.Equal(t, details[0].(*wrapperspb.StringValue).GetValue(), "field: name")
+		assert.Equal(t, details[1].(*wrapperspb.StringValue).GetValue(), "field: age")
+
+		details = drpcstatus.Details(err, protoEncoding{}, &durationpb.Duration{}, &wrapperspb.StringValue{})
+		assert.Equal(t, len(details), 3)
+		assert.Equal(t, details[1].(*durationpb.Duration).AsDuration(), time.Duration(5))
+	}
+
+	{ // errors without details have none
+		err := invoke("plain")
+		assert.Equal(t, drpcstatus.CodeFromError(err), drpcstatus.NotFound)
+		details := drpcstatus.Details(err, protoEncoding{}, &wrapperspb.StringValue{})
+		assert.NotNil(t, details)
+		assert.Equal(t, len(details), 0)
+	}
+}
@@ -0,0 +1,8 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+// Package drpcstatus provides gRPC style status codes for errors.
+//
+// The codes are sent to the remote using the error codes from drpcerr, so
+// they can be recovered from any error returned by a drpc client.
+package drpcstatus
@@ -0,0 +1,134 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcstatus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"storj.io/drpc/drpcerr"
+)
+
+// Code is a status code. The values mirror the codes used by gRPC.
+type Code uint64
+
+// These are the status codes.
+const (
+	OK                 Code = 0
+	Canceled           Code = 1
+	Unknown            Code = 2
+	InvalidArgument    Code = 3
+	DeadlineExceeded   Code = 4
+	NotFound           Code = 5
+	AlreadyExists      Code = 6
+	PermissionDenied   Code = 7
+	ResourceExhausted  Code = 8
+	FailedPrecondition Code = 9
+	Aborted            Code = 10
+	OutOfRange         Code = 11
+	Unimplemented      Code = drpcerr.Unimplemented
+	Internal           Code = 13
+	Unavailable        Code = 14
+	DataLoss           Code = 15
+	Unauthenticated    Code = 16
+)
+
+var codeNames = [...]string{
+	OK:                 "OK",
+	Canceled:           "Canceled",
+	Unknown:            "Unknown",
+	InvalidArgument:    "InvalidArgument",
+	DeadlineExceeded:   "DeadlineExceeded",
+	NotFound:           "NotFound",
+	AlreadyExists:      "AlreadyExists",
+	PermissionDenied:   "PermissionDenied",
+	ResourceExhausted:  "ResourceExhausted",
+	FailedPrecondition: "FailedPrecondition",
+	Aborted:            "Aborted",
+	OutOfRange:         "OutOfRange",
+	Unimplemented:      "Unimplemented",
+	Internal:           "Internal",
+	Unavailable:        "Unavailable",
+	DataLoss:           "DataLoss",
+	Unauthenticated:    "Unauthenticated",
+}
+
+// String returns a human readable form of the code.
+func (c Code) String() string {
+	if c < Code(len(codeNames)) {
+		return codeNames[c]
+	}
+	return "Code(" + strconv.FormatUint(uint64(c), 10) + ")"
+}
+
+// Status is an error with a status code, and possibly details: see
+// WithDetails.
+type Status struct {
+	code    Code
+	err     error
+	details [][]byte
+}
+
+// Errorf returns a Status error with the code and a message formatted like
+// fmt.Errorf, so any error wrapped with %w can still be unwrapped from it.
+func Errorf(code Code, format string, args ...interface{}) error {
+	return &Status{code: code, err: fmt.Errorf(format, args...)}
+}
+
+// FromError returns the Status associated with the error. If the error has
+// no code, the returned Status has the code from CodeFromError and the
+// error's message. It returns nil if the error is nil.
+func FromError(err error) *Status {
+	if err == nil {
+		return nil
+	}
+	var st *Status
+	if errors.As(err, &st) {
+		return st
+	}
+	return &Status{code: CodeFromError(err), err: err}
+}
+
+// CodeFromError returns the status code associated with the error. Errors
+// without a code are Unknown, except for errors from the context package,
+// which are Canceled or DeadlineExceeded. A nil error is OK.
+func CodeFromError(err error) Code {
+	switch code := drpcerr.Code(err); {
+	case err == nil:
+		return OK
+	case code != 0:
+		return Code(code)
+	case errors.Is(err, context.Canceled):
+		return Canceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return DeadlineExceeded
+	default:
+		return Unknown
+	}
+}
+
+// IsDeadlineExceeded returns true if the error has the DeadlineExceeded code,
+// either because a context deadline passed locally or because the remote sent
+// the error with that code. It is false for canceled contexts.
+func IsDeadlineExceeded(err error) bool {
+	return CodeFromError(err) == DeadlineExceeded
+}
+
+// Error returns the message of the status.
+func (s *Status) Error() string { return s.err.Error() }
+
+// Unwrap returns the error the status was created with.
+func (s *Status) Unwrap() error { return s.err }
+
+// Code returns the status code as a uint64 so that it is used by drpcerr when
+// sending the error to the remote. Use StatusCode for the typed value.
+func (s *Status) Code() uint64 { return uint64(s.code) }
+
+// StatusCode returns the status code.
+func (s *Status) StatusCode() Code { return s.code }
+
+// Message returns the message of the status.
+func (s *Status) Message() string { return s.err.Error() }
@@ -0,0 +1,65 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcstatus
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/zeebo/assert"
+	"github.com/zeebo/errs"
+
+	"storj.io/drpc/drpcerr"
+	"storj.io/drpc/drpcwire"
+)
+
+func TestCodeFromError(t *testing.T) {
+	assert.Equal(t, CodeFromError(nil), OK)
+	assert.Equal(t, CodeFromError(errors.New("test")), Unknown)
+	assert.Equal(t, CodeFromError(context.Canceled), Canceled)
+	assert.Equal(t, CodeFromError(errs.Wrap(context.DeadlineExceeded)), DeadlineExceeded)
+	assert.Equal(t, CodeFromError(Errorf(NotFound, "test")), NotFound)
+	assert.Equal(t, CodeFromError(drpcerr.WithCode(errors.New("test"), 7)), PermissionDenied)
+
+	// wrapping in an error class keeps the code
+	class := errs.Class("class")
+	assert.Equal(t, CodeFromError(class.Wrap(Errorf(Unavailable, "test"))), Unavailable)
+
+	// the code survives being sent over the wire
+	err := drpcwire.UnmarshalError(drpcwire.MarshalError(Errorf(InvalidArgument, "bad %d", 5)))
+	assert.Equal(t, CodeFromError(err), InvalidArgument)
+	assert.Equal(t, err.Error(), "bad 5")
+
+	// deadlines match whether they passed locally or remotely
+	assert.That(t, IsDeadlineExceeded(errs.Wrap(context.DeadlineExceeded)))
+	assert.That(t, IsDeadlineExceeded(drpcwire.UnmarshalError(drpcwire.MarshalError(Errorf(DeadlineExceeded, "late")))))
+	assert.That(t, !IsDeadlineExceeded(context.Canceled))
+}
+
+func TestStatus(t *testing.T) {
+	class := errs.Class("class")
+	cause := class.New("cause")
+
+	err := Errorf(Aborted, "wrapped: %w", cause)
+	assert.Equal(t, err.Error(), "wrapped: class: cause")
+	assert.That(t, errs.IsFunc(err, class.Has))
+	assert.That(t, errors.Is(err, cause))
+
+	st := FromError(err)
+	assert.Equal(t, st.StatusCode(), Aborted)
+	assert.Equal(t, st.Message(), "wrapped: class: cause")
+
+	st = FromError(errors.New("plain"))
+	assert.Equal(t, st.StatusCode(), Unknown)
+	assert.Equal(t, st.Message(), "plain")
+
+	assert.Nil(t, FromError(nil))
+}
+
+func TestCodeString(t *testing.T) {
+	assert.Equal(t, NotFound.String(), "NotFound")
+	assert.Equal(t, Unauthenticated.String(), "Unauthenticated")
+	assert.Equal(t, Code(100).String(), "Code(100)")
+}
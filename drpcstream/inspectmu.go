@@ -18,6 +18,14 @@ func (m *inspectMutex) Lock() {
 	atomic.StoreUint32(&m.held, 1)
 }
 
+func (m *inspectMutex) TryLock() bool {
+	if !m.mu.TryLock() {
+		return false
+	}
+	atomic.StoreUint32(&m.held, 1)
+	return true
+}
+
 func (m *inspectMutex) Unlock() {
 	atomic.StoreUint32(&m.held, 0)
 	m.mu.Unlock()
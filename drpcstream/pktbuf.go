@@ -4,6 +4,7 @@
 package drpcstream
 
 import (
+	"context"
 	"sync"
 )
 
@@ -14,8 +15,19 @@ type packetBuffer struct {
 	data []byte
 	set  bool
 	held bool
+	bad  *badPacket // set instead of data for a packet that failed to decode
 }
 
+// badPacket is returned by Get in place of a packet that could not be
+// decoded. It has already been consumed when it is returned.
+type badPacket struct {
+	err  error
+	size int // the number of bytes of messages to count the packet as
+}
+
+func (b *badPacket) Error() string { return b.err.Error() }
+func (b *badPacket) Unwrap() error { return b.err }
+
 func (pb *packetBuffer) init() {
 	pb.cond.L = &pb.mu
 }
@@ -30,13 +42,20 @@ func (pb *packetBuffer) Close(err error) {
 
 	if pb.err == nil {
 		pb.data = nil
+		pb.bad = nil
 		pb.set = false
 		pb.err = err
 		pb.cond.Broadcast()
 	}
 }
 
-func (pb *packetBuffer) Put(data []byte) {
+func (pb *packetBuffer) Put(data []byte) { pb.put(data, nil) }
+
+// PutBad is like Put but for a packet that could not be decoded, so that the
+// next Get returns the error instead of data.
+func (pb *packetBuffer) PutBad(err error, size int) { pb.put(nil, &badPacket{err: err, size: size}) }
+
+func (pb *packetBuffer) put(data []byte, bad *badPacket) {
 	pb.mu.Lock()
 	defer pb.mu.Unlock()
 
@@ -48,6 +67,7 @@ func (pb *packetBuffer) Put(data []byte) {
 	}
 
 	pb.data = data
+	pb.bad = bad
 	pb.set = true
 	pb.held = false
 	pb.cond.Broadcast()
@@ -58,15 +78,44 @@ func (pb *packetBuffer) Put(data []byte) {
 }
 
 func (pb *packetBuffer) Get() ([]byte, error) {
+	return pb.GetContext(context.Background())
+}
+
+// GetContext is like Get but returns the context's error if it is done before
+// any data is available.
+func (pb *packetBuffer) GetContext(ctx context.Context) ([]byte, error) {
+	if done := ctx.Done(); done != nil {
+		stop := make(chan struct{})
+		defer close(stop)
+		go func() {
+			select {
+			case <-done:
+				pb.mu.Lock()
+				pb.cond.Broadcast()
+				pb.mu.Unlock()
+			case <-stop:
+			}
+		}()
+	}
+
 	pb.mu.Lock()
 	defer pb.mu.Unlock()
 
 	for !pb.set && pb.err == nil {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		pb.cond.Wait()
 	}
 	if pb.err != nil {
 		return nil, pb.err
 	}
+	if bad := pb.bad; bad != nil {
+		pb.bad = nil
+		pb.set = false
+		pb.cond.Broadcast()
+		return nil, bad
+	}
 
 	pb.held = true
 	pb.cond.Broadcast()
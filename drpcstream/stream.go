@@ -8,6 +8,8 @@ import (
 	"fmt"
 	"io"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/zeebo/errs"
 
@@ -15,14 +17,24 @@ import (
 	"storj.io/drpc/drpcctx"
 	"storj.io/drpc/drpcdebug"
 	"storj.io/drpc/drpcenc"
+	"storj.io/drpc/drpcerr"
+	"storj.io/drpc/drpcmetadata"
 	"storj.io/drpc/drpcsignal"
+	"storj.io/drpc/drpcstats"
 	"storj.io/drpc/drpcwire"
+	"storj.io/drpc/internal/drpcbuffer"
 	"storj.io/drpc/internal/drpcopts"
 )
 
 // Options controls configuration settings for a stream.
 type Options struct {
-	// SplitSize controls the default size we split packets into frames.
+	// SplitSize controls the size of the frames that sent packets are split
+	// into, so that a message may be larger than a single frame. The remote
+	// reassembles the frames before delivering the message, and limits its
+	// total size rather than the size of each frame. Messages are compressed
+	// before they are split, and windows count the size of messages before
+	// compression. Zero means 64KiB, and a negative value means packets are
+	// never split.
 	SplitSize int
 
 	// ManualFlush controls if the stream will automatically flush after every
@@ -31,15 +43,88 @@ type Options struct {
 	// call RawFlush dynamically.
 	ManualFlush bool
 
-	// MaximumBufferSize causes the Stream to drop any internal buffers that
-	// are larger than this amount to control maximum memory usage at the
-	// expense of more allocations. 0 is unlimited.
+	// FlushDelay, if positive, is the longest that a message held in the write
+	// buffer because of ManualFlush waits before the stream flushes it, so that
+	// a stream that only sends still delivers its messages. Calling RawFlush
+	// flushes them right away.
+	FlushDelay time.Duration
+
+	// MaximumBufferSize causes the Stream to not reuse any encoding buffers
+	// that are larger than this amount to control maximum memory usage at the
+	// expense of more allocations. 0 is unlimited, though buffers larger than
+	// 4MiB are never reused.
 	MaximumBufferSize int
 
+	// Encoding, if set, is used to marshal and unmarshal every message instead
+	// of the encoding passed to MsgSend and MsgRecv. This allows a different
+	// codec than the generated code uses, like JSON, to be used by all of the
+	// streams on a connection.
+	Encoding drpc.Encoding
+
+	// Compressor, if set, is used to decompress compressed messages received on
+	// the stream, and to compress messages sent on the stream that are at least
+	// CompressThreshold bytes if the remote accepts them. Compressed messages
+	// are flagged so that the remote knows to decompress them. Managers decide
+	// if the remote accepts them: see drpcmetadata.CompressionKey.
+	Compressor drpc.Compressor
+
+	// CompressThreshold is the size in bytes of the smallest encoded message
+	// that will be compressed. 0 means a default of 1024 bytes.
+	CompressThreshold int
+
+	// MaximumDecompressedSize limits the size of compressed messages after
+	// they are decompressed. Messages that are larger cause a ProtocolError.
+	// 0 means 4MiB, the default limit on the size of received messages.
+	MaximumDecompressedSize int
+
+	// RecoverDecodeErrors causes a compressed message that fails to
+	// decompress, including because it is larger than the
+	// MaximumDecompressedSize, to fail only the MsgRecv or RawRecv that
+	// receives it instead of terminating the stream and the transport. The
+	// whole message has been read by then, so the stream can keep receiving.
+	// Errors from the Unmarshal of an encoding never terminate the stream, and
+	// errors in the framing of the transport always close it.
+	RecoverDecodeErrors bool
+
+	// MaximumSendSize causes MsgSend to return a drpc.MessageSizeError without
+	// writing anything if the marshaled message is larger than this amount.
+	// 0 is unlimited.
+	MaximumSendSize int
+
+	// SendTimeout, if positive, is how long MsgSend waits for a message to be
+	// written before it fails with a SendTimeoutError and cancels the stream.
+	// A write to the transport that has started can not be stopped partway,
+	// so if one is blocked, a stream created by a manager closes the
+	// transport, failing anything else using it.
+	SendTimeout time.Duration
+
+	// RecvTimeout, if positive, is how long MsgRecv and RawRecv wait for a
+	// message before they fail with a RecvTimeoutError, so that a remote that
+	// stops sending is noticed. The wait starts over with every receive, and
+	// unlike a SendTimeout, the stream is not canceled, so the caller decides
+	// whether to keep receiving or to close it.
+	RecvTimeout time.Duration
+
+	// Window, if positive, is the number of bytes of messages the remote may
+	// send ahead of what has been received on the stream. It is advertised to
+	// the remote by OpenWindow or the first time the stream receives, and
+	// again as messages are received. A remote that has used up the window
+	// blocks in MsgSend until more of it is advertised, and remotes that do
+	// not support windows ignore it. 0 means the remote may send without limit.
+	Window int
+
 	// Internal contains options that are for internal use only.
 	Internal drpcopts.Stream
 }
 
+// SendTimeoutError is returned by MsgSend if the message could not be sent within
+// the SendTimeout. The errors it wraps match context.DeadlineExceeded.
+var SendTimeoutError = errs.Class("send timeout")
+
+// RecvTimeoutError is returned by MsgRecv and RawRecv if no message was received
+// within the RecvTimeout. The errors it wraps match context.DeadlineExceeded.
+var RecvTimeoutError = errs.Class("recv timeout")
+
 // Stream represents an rpc actively happening on a transport.
 type Stream struct {
 	ctx  streamCtx
@@ -50,10 +135,10 @@ type Stream struct {
 	read  inspectMutex
 	flush sync.Once
 
-	id   drpcwire.ID
-	wr   *drpcwire.Writer
-	pbuf packetBuffer
-	wbuf []byte
+	id    drpcwire.ID
+	wr    *drpcwire.Writer
+	pbuf  packetBuffer
+	stats drpcstats.Stats // counts only this stream, unlike the options stats
 
 	mu   sync.Mutex // protects state transitions
 	sigs struct {
@@ -62,6 +147,28 @@ type Stream struct {
 		term   drpcsignal.Signal // set when the stream is terminating and no new ops should begin
 		fin    drpcsignal.Signal // set when the stream is finished and all ops are complete
 		cancel drpcsignal.Signal // set when externally canceled
+		header drpcsignal.Signal // set when no more header can be received
+	}
+
+	flow struct { // protected by mu
+		sent     uint64        // bytes of messages sent
+		limit    uint64        // bytes of messages the remote allows, 0 if unlimited
+		update   chan struct{} // closed when the limit increases, if anyone is waiting
+		received uint64        // bytes of messages received
+		window   uint64        // the limit most recently advertised to the remote
+		pending  bool          // set if the window has not been sent yet
+	}
+
+	header struct {
+		done bool                  // set once no header can be sent, protected by write
+		recv drpcmetadata.Metadata // received from the remote, set before the header signal
+	}
+
+	flushing bool // set while a delayed flush is scheduled, protected by mu
+
+	trailer struct { // protected by mu
+		send drpcmetadata.Metadata // sent before the stream stops sending
+		recv drpcmetadata.Metadata // received from the remote
 	}
 }
 
@@ -79,7 +186,39 @@ func New(ctx context.Context, sid uint64, wr *drpcwire.Writer) *Stream {
 // stream ids within a single transport. The options are used to control details of how
 // the Stream operates.
 func NewWithOptions(ctx context.Context, sid uint64, wr *drpcwire.Writer, opts Options) *Stream {
-	s := &Stream{
+	s := new(Stream)
+	s.init(ctx, sid, wr, opts)
+	return s
+}
+
+// Reuse resets the stream to the state NewWithOptions returns for the
+// arguments, keeping the memory of the stream so that a new one does not have
+// to be allocated. Nothing from the previous rpc, like its context, metadata
+// or error, is kept. It returns false without changing the stream if the
+// stream is not finished or still has a delayed flush scheduled.
+//
+// It is only safe to call once nothing else will use the stream or its
+// Context, including goroutines started by MsgSendContext or MsgRecvContext.
+func (s *Stream) Reuse(ctx context.Context, sid uint64, wr *drpcwire.Writer, opts Options) bool {
+	if !s.sigs.fin.IsSet() {
+		return false
+	}
+
+	s.mu.Lock()
+	flushing := s.flushing
+	s.mu.Unlock()
+
+	if flushing {
+		return false
+	}
+
+	s.init(ctx, sid, wr, opts)
+	return true
+}
+
+// init sets the stream to a new stream for the arguments.
+func (s *Stream) init(ctx context.Context, sid uint64, wr *drpcwire.Writer, opts Options) {
+	*s = Stream{
 		ctx: streamCtx{
 			Context: ctx,
 			tr:      drpcopts.GetStreamTransport(&opts.Internal),
@@ -90,11 +229,10 @@ func NewWithOptions(ctx context.Context, sid uint64, wr *drpcwire.Writer, opts O
 		id: drpcwire.ID{Stream: sid},
 		wr: wr.Reset(),
 	}
+	s.ctx.stream = s
 
 	// initialize the packet buffer
 	s.pbuf.init()
-
-	return s
 }
 
 // String returns a string representation of the stream.
@@ -117,17 +255,21 @@ func (s *Stream) log(what string, cb func() string) {
 // streamCtx avoids having to allocate a Done channel until it is requested.
 type streamCtx struct {
 	context.Context
-	tr  drpc.Transport
-	sig drpcsignal.Signal
+	tr     drpc.Transport
+	sig    drpcsignal.Signal
+	stream *Stream
 }
 
-// Value checks for the drpc.Transport key and forwards if necessary.
-// We do this because using drpcctx to make a new context would cause
-// an extra allocation.
+// Value checks for the drpc.Transport and stream keys and forwards if
+// necessary. We do this because using drpcctx to make a new context would
+// cause an extra allocation.
 func (s *streamCtx) Value(key interface{}) interface{} {
 	if s.tr != nil && key == (drpcctx.TransportKey{}) {
 		return s.tr
 	}
+	if key == (drpcmetadata.StreamKey{}) {
+		return s.stream
+	}
 	return s.Context.Value(key)
 }
 
@@ -159,6 +301,28 @@ func (s *Stream) Terminated() <-chan struct{} { return s.sigs.term.Signal() }
 // IsTerminated returns true if the stream has been terminated.
 func (s *Stream) IsTerminated() bool { return s.sigs.term.IsSet() }
 
+// Err returns the error the stream was terminated with, or nil if it has not
+// been terminated or if it ended cleanly: by both sides sending a CloseSend,
+// by this side closing it, or by the remote closing it after its CloseSend.
+// Terminated can be used to wait for it to be set.
+func (s *Stream) Err() error {
+	err, ok := s.sigs.term.Get()
+	if !ok || err == termBothClosed || err == termClosed {
+		return nil
+	}
+	if recv, _ := s.sigs.recv.Get(); recv == io.EOF && drpc.ClosedError.Has(err) {
+		return nil
+	}
+	return err
+}
+
+// Stats returns the number of bytes, not including framing, and messages sent
+// and received on the stream so far. It is safe to call concurrently with
+// anything else.
+func (s *Stream) Stats() drpcstats.Stats {
+	return s.stats.AtomicClone()
+}
+
 // Finished returns a channel that is closed when the stream is fully finished
 // and will no longer issue any writes or reads.
 func (s *Stream) Finished() <-chan struct{} { return s.sigs.fin.Signal() }
@@ -204,7 +368,7 @@ func (s *Stream) HandlePacket(pkt drpcwire.Packet) (err error) {
 		return nil
 	}
 
-	drpcopts.GetStreamStats(&s.opts.Internal).AddRead(uint64(len(pkt.Data)))
+	s.addRead(uint64(len(pkt.Data)))
 
 	if s.sigs.term.IsSet() {
 		return nil
@@ -212,11 +376,37 @@ func (s *Stream) HandlePacket(pkt drpcwire.Packet) (err error) {
 
 	s.log("HANDLE", pkt.String)
 
+	if pkt.Kind == drpcwire.KindMessage || pkt.Kind == drpcwire.KindCompressedMessage {
+		// a header is only sent before the first message.
+		s.sigs.header.Set(nil)
+	}
+
 	if pkt.Kind == drpcwire.KindMessage {
+		s.addMessagesRead(1)
 		s.pbuf.Put(pkt.Data)
 		return nil
 	}
 
+	if pkt.Kind == drpcwire.KindCompressedMessage {
+		s.addMessagesRead(1)
+		data, err := s.decompress(pkt.Data)
+		if err != nil && s.opts.RecoverDecodeErrors && s.opts.Compressor != nil {
+			// the sender counts messages before compression against the
+			// window, but the size it decompresses to is not known, so only
+			// credit the bytes actually received rather than letting a bad
+			// message open the window by more than was sent.
+			s.pbuf.PutBad(err, len(pkt.Data))
+			return nil
+		} else if err != nil {
+			s.mu.Lock()
+			s.terminate(err)
+			s.mu.Unlock()
+			return err
+		}
+		s.pbuf.Put(data)
+		return nil
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -228,6 +418,18 @@ func (s *Stream) HandlePacket(pkt drpcwire.Packet) (err error) {
 
 	case drpcwire.KindError:
 		err := drpcwire.UnmarshalError(pkt.Data)
+		if names := s.trailer.recv.Get(errorClassKey); len(names) > 0 {
+			err = drpcerr.WithClasses(err, names)
+			delete(s.trailer.recv, errorClassKey)
+		}
+		if values := s.trailer.recv.Get(errorDetailsKey); len(values) > 0 {
+			details := make([][]byte, len(values))
+			for i, value := range values {
+				details[i] = []byte(value)
+			}
+			err = drpcerr.WithDetails(err, details)
+			delete(s.trailer.recv, errorDetailsKey)
+		}
 		s.sigs.send.Set(io.EOF) // in this state, gRPC returns io.EOF on send.
 		s.terminate(err)
 		return nil
@@ -240,17 +442,62 @@ func (s *Stream) HandlePacket(pkt drpcwire.Packet) (err error) {
 		return nil
 
 	case drpcwire.KindClose:
-		s.sigs.recv.Set(io.EOF)
-		s.pbuf.Close(io.EOF)
+		// only a CloseSend means that the remote is done sending, so a close
+		// without one is reported to receives as the stream being torn down.
 		s.terminate(drpc.ClosedError.New("remote closed the stream"))
 		return nil
 
+	case drpcwire.KindHeader:
+		md, err := drpcmetadata.DecodeMetadata(pkt.Data)
+		if err != nil {
+			err := drpc.ProtocolError.Wrap(err)
+			s.terminate(err)
+			return err
+		}
+		if !s.sigs.header.IsSet() {
+			s.header.recv = md
+			s.sigs.header.Set(nil)
+		}
+		return nil
+
 	case drpcwire.KindCloseSend:
+		s.sigs.header.Set(nil)
 		s.sigs.recv.Set(io.EOF)
 		s.pbuf.Close(io.EOF)
 		s.terminateIfBothClosed()
 		return nil
 
+	case drpcwire.KindTrailer:
+		md, err := drpcmetadata.DecodeMetadata(pkt.Data)
+		if err != nil {
+			err := drpc.ProtocolError.Wrap(err)
+			s.terminate(err)
+			return err
+		}
+		if s.trailer.recv == nil {
+			s.trailer.recv = make(drpcmetadata.Metadata, len(md))
+		}
+		for key, values := range md {
+			s.trailer.recv.Append(key, values...)
+		}
+		return nil
+
+	case drpcwire.KindWindow:
+		_, limit, ok, err := drpcwire.ReadVarint(pkt.Data)
+		if !ok || err != nil {
+			err := drpc.ProtocolError.New("invalid window")
+			s.terminate(err)
+			return err
+		}
+		if limit > s.flow.limit {
+			s.flow.limit = limit
+			if s.flow.update != nil {
+				close(s.flow.update)
+				s.flow.update = nil
+			}
+		}
+		return nil
+
 	default:
 		// ignore any unknown control packets for forwards compatibility
 		if pkt.Control {
@@ -282,6 +529,34 @@ func (s *Stream) checkFinished() {
 	}
 }
 
+// getPacket waits for the next packet like the packet buffer's GetContext, but
+// fails with a RecvTimeoutError if none arrives within the RecvTimeout.
+func (s *Stream) getPacket(ctx context.Context) ([]byte, error) {
+	if s.opts.RecvTimeout <= 0 {
+		return s.pbuf.GetContext(ctx)
+	}
+
+	tctx, cancel := context.WithTimeout(ctx, s.opts.RecvTimeout)
+	defer cancel()
+
+	data, err := s.pbuf.GetContext(tctx)
+	if err != nil && err == tctx.Err() && ctx.Err() == nil {
+		err = RecvTimeoutError.Wrap(context.DeadlineExceeded)
+		s.log("TIMEOUT", err.Error)
+	}
+	return data, err
+}
+
+// checkBadPacket returns the error a receive should return if getting a packet
+// failed with err, counting a bad packet against the window like any other.
+func (s *Stream) checkBadPacket(err error) error {
+	if bad, ok := err.(*badPacket); ok {
+		s.openWindow(bad.size)
+		return bad.err
+	}
+	return err
+}
+
 // checkCancelError will replace the error with one from the cancel signal if it is
 // set. This is to prevent errors from reads/writes to a transport after it has been
 // asynchronously closed due to context cancelation.
@@ -292,6 +567,30 @@ func (s *Stream) checkCancelError(err error) error {
 	return err
 }
 
+// addRead counts n bytes read by the stream.
+func (s *Stream) addRead(n uint64) {
+	s.stats.AddRead(n)
+	drpcopts.GetStreamStats(&s.opts.Internal).AddRead(n)
+}
+
+// addWritten counts n bytes written by the stream.
+func (s *Stream) addWritten(n uint64) {
+	s.stats.AddWritten(n)
+	drpcopts.GetStreamStats(&s.opts.Internal).AddWritten(n)
+}
+
+// addMessagesRead counts n messages read by the stream.
+func (s *Stream) addMessagesRead(n uint64) {
+	s.stats.AddMessagesRead(n)
+	drpcopts.GetStreamStats(&s.opts.Internal).AddMessagesRead(n)
+}
+
+// addMessagesWritten counts n messages written by the stream.
+func (s *Stream) addMessagesWritten(n uint64) {
+	s.stats.AddMessagesWritten(n)
+	drpcopts.GetStreamStats(&s.opts.Internal).AddMessagesWritten(n)
+}
+
 // newFrame bumps the internal message id and returns a frame. It must be called
 // under a mutex.
 func (s *Stream) newFrame(kind drpcwire.Kind) drpcwire.Frame {
@@ -299,6 +598,57 @@ func (s *Stream) newFrame(kind drpcwire.Kind) drpcwire.Frame {
 	return drpcwire.Frame{ID: s.id, Kind: kind}
 }
 
+// CheckSendSize returns a drpc.MessageSizeError if size is larger than max. A max
+// of 0 is unlimited.
+func CheckSendSize(size, max int) error {
+	if max > 0 && size > max {
+		return drpc.MessageSizeError.New("size %d exceeds limit %d", size, max)
+	}
+	return nil
+}
+
+// compress returns the kind and data to send for the encoded message, compressing
+// it if the stream has a Compressor, the remote accepts compressed messages and
+// the message is large enough. The message
+// is sent uncompressed if compressing it does not make it smaller.
+func (s *Stream) compress(data []byte) (drpcwire.Kind, []byte, error) {
+	threshold := s.opts.CompressThreshold
+	if threshold == 0 {
+		threshold = 1024
+	}
+	if s.opts.Compressor == nil || len(data) < threshold || !drpcopts.GetStreamCompress(&s.opts.Internal) {
+		return drpcwire.KindMessage, data, nil
+	}
+
+	cdata, err := s.opts.Compressor.Compress(data)
+	if err != nil {
+		return 0, nil, errs.Wrap(err)
+	} else if len(cdata) >= len(data) {
+		return drpcwire.KindMessage, data, nil
+	}
+	return drpcwire.KindCompressedMessage, cdata, nil
+}
+
+// decompress returns the decompressed form of the data from a compressed message.
+func (s *Stream) decompress(data []byte) ([]byte, error) {
+	if s.opts.Compressor == nil {
+		return nil, drpc.ProtocolError.New("compressed message received without a compressor")
+	}
+	data, err := s.opts.Compressor.Decompress(data, s.decompressLimit())
+	if err != nil {
+		return nil, drpc.ProtocolError.Wrap(err)
+	}
+	return data, nil
+}
+
+// decompressLimit returns the largest size a message may decompress to.
+func (s *Stream) decompressLimit() int {
+	if s.opts.MaximumDecompressedSize == 0 {
+		return 4 << 20
+	}
+	return s.opts.MaximumDecompressedSize
+}
+
 // sendPacket sends the packet in a single write and flushes. It does not check for
 // any conditions to stop it from writing and is meant for internal stream use to
 // do things like signal errors or closes to the remote side.
@@ -308,7 +658,7 @@ func (s *Stream) sendPacket(kind drpcwire.Kind, control bool, data []byte) (err
 	fr.Control = control
 	fr.Done = true
 
-	drpcopts.GetStreamStats(&s.opts.Internal).AddWritten(uint64(len(data)))
+	s.addWritten(uint64(len(data)))
 	s.log("SEND", fr.String)
 
 	if err := s.wr.WriteFrame(fr); err != nil {
@@ -320,6 +670,35 @@ func (s *Stream) sendPacket(kind drpcwire.Kind, control bool, data []byte) (err
 	return nil
 }
 
+// writeTrailer writes any trailer that has been set without flushing, so that
+// it is sent along with the packet that ends the sending side of the stream.
+// It must be called with the write lock held.
+func (s *Stream) writeTrailer() (err error) {
+	s.mu.Lock()
+	md := s.trailer.send
+	s.trailer.send = nil
+	s.mu.Unlock()
+
+	if len(md) == 0 {
+		return nil
+	}
+
+	data, err := drpcmetadata.EncodeMetadata(nil, md)
+	if err != nil {
+		return errs.Wrap(err)
+	}
+
+	fr := s.newFrame(drpcwire.KindTrailer)
+	fr.Data = data
+	fr.Control = true
+	fr.Done = true
+
+	s.addWritten(uint64(len(data)))
+	s.log("SEND", fr.String)
+
+	return errs.Wrap(s.wr.WriteFrame(fr))
+}
+
 // terminateIfBothClosed is a helper to terminate the stream if both sides have
 // issued a CloseSend.
 func (s *Stream) terminateIfBothClosed() {
@@ -346,14 +725,43 @@ func (s *Stream) terminate(err error) {
 func (s *Stream) RawWrite(kind drpcwire.Kind, data []byte) (err error) {
 	defer s.checkFinished()
 	s.write.Lock()
-	defer s.write.Unlock()
+	defer s.unlockWrite()
 
 	return s.rawWriteLocked(kind, data)
 }
 
+// RawWriteMessage sends the already encoded message data, compressing it first
+// if the stream is configured with a Compressor.
+func (s *Stream) RawWriteMessage(data []byte) (err error) {
+	if err := s.waitWindow(context.Background()); err != nil {
+		return err
+	}
+
+	defer s.checkFinished()
+	s.write.Lock()
+	defer s.unlockWrite()
+
+	size := len(data)
+	kind, data, err := s.compress(data)
+	if err != nil {
+		return err
+	}
+	if err := s.rawWriteLocked(kind, data); err != nil {
+		return err
+	}
+	s.addSent(size)
+	return nil
+}
+
 // rawWriteLocked does the body of RawWrite assuming the caller is holding the
 // appropriate locks.
 func (s *Stream) rawWriteLocked(kind drpcwire.Kind, data []byte) (err error) {
+	if kind == drpcwire.KindInvokeMetadata || kind == drpcwire.KindInvoke {
+		if data, err = s.announceHello(kind, data); err != nil {
+			return err
+		}
+	}
+
 	fr := s.newFrame(kind)
 	n := s.opts.SplitSize
 
@@ -368,22 +776,53 @@ func (s *Stream) rawWriteLocked(kind drpcwire.Kind, data []byte) (err error) {
 		fr.Data, data = drpcwire.SplitData(data, n)
 		fr.Done = len(data) == 0
 
-		drpcopts.GetStreamStats(&s.opts.Internal).AddWritten(uint64(len(fr.Data)))
+		s.addWritten(uint64(len(fr.Data)))
 		s.log("SEND", fr.String)
 
 		if err := s.wr.WriteFrame(fr); err != nil {
 			return s.checkCancelError(errs.Wrap(err))
 		} else if fr.Done {
+			if kind == drpcwire.KindMessage || kind == drpcwire.KindCompressedMessage {
+				s.addMessagesWritten(1)
+				s.header.done = true
+			}
 			return nil
 		}
 	}
 }
 
+// announceHello adds the hello returned by the manager, if any, to the metadata
+// sent before the invoke, writing a metadata packet for it if the invoke is
+// being written without one. The hello is only returned once, so a metadata
+// packet carrying it is never written twice. It must be called with the write
+// lock held.
+func (s *Stream) announceHello(kind drpcwire.Kind, data []byte) ([]byte, error) {
+	hello := drpcopts.GetStreamHello(&s.opts.Internal)
+	if hello == nil {
+		return data, nil
+	}
+	value := hello()
+	if value == "" {
+		return data, nil
+	}
+
+	md, err := drpcmetadata.EncodeMetadata(nil, drpcmetadata.Metadata{
+		drpcmetadata.HelloKey: {value},
+	})
+	if err != nil {
+		return nil, errs.Wrap(err)
+	}
+	if kind == drpcwire.KindInvokeMetadata {
+		return append(md, data...), nil
+	}
+	return data, s.rawWriteLocked(drpcwire.KindInvokeMetadata, md)
+}
+
 // RawFlush flushes any buffers of data.
 func (s *Stream) RawFlush() (err error) {
 	defer s.checkFinished()
 	s.write.Lock()
-	defer s.write.Unlock()
+	defer s.unlockWrite()
 
 	return s.rawFlushLocked()
 }
@@ -415,6 +854,8 @@ func (s *Stream) checkRecvFlush() (err error) {
 		return err
 	}
 
+	s.openWindow(0)
+
 	if s.opts.ManualFlush && !s.wr.Empty() {
 		if err := s.RawFlush(); err != nil {
 			return err
@@ -434,12 +875,13 @@ func (s *Stream) RawRecv() (data []byte, err error) {
 	s.read.Lock()
 	defer s.read.Unlock()
 
-	data, err = s.pbuf.Get()
+	data, err = s.getPacket(context.Background())
 	if err != nil {
-		return nil, err
+		return nil, s.checkBadPacket(err)
 	}
 	data = append([]byte(nil), data...)
 	s.pbuf.Done()
+	s.openWindow(len(data))
 
 	return data, nil
 }
@@ -448,32 +890,211 @@ func (s *Stream) RawRecv() (data []byte, err error) {
 // msg read/write
 //
 
-// MsgSend marshals the message with the encoding, writes it, and flushes.
+// MsgSend marshals the message with the encoding, writes it, and flushes. If
+// the stream was configured with an Encoding, it is used instead.
 func (s *Stream) MsgSend(msg drpc.Message, enc drpc.Encoding) (err error) {
+	return s.MsgSendContext(context.Background(), msg, enc)
+}
+
+// MsgSendContext is like MsgSend but returns the context's error once it is
+// done. If that happens while waiting for the remote to open the Window,
+// nothing is sent and the stream can still be used. If it happens while the
+// message is being written, the stream is canceled, because a message that is
+// partially written to a blocked transport cannot be taken back. Note that
+// this is not part of the drpc.Stream interface, so callers have to type
+// assert for it.
+func (s *Stream) MsgSendContext(ctx context.Context, msg drpc.Message, enc drpc.Encoding) (err error) {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	s.flush.Do(func() {})
 
+	if s.opts.Encoding != nil {
+		enc = s.opts.Encoding
+	}
+
+	defer s.startSendTimeout()(&err)
+
+	if err := s.waitWindow(ctx); err != nil {
+		return err
+	}
+
+	if done := ctx.Done(); done != nil {
+		// state is 1 once the send is finished and 2 if the context was done
+		// first, so that only one of them decides how the send ends.
+		var state int32
+		stop := make(chan struct{})
+		go func() {
+			select {
+			case <-done:
+				if atomic.CompareAndSwapInt32(&state, 0, 2) {
+					s.interruptSend(ctx.Err())
+				}
+			case <-stop:
+			}
+		}()
+		defer func() {
+			if !atomic.CompareAndSwapInt32(&state, 0, 1) {
+				err = ctx.Err()
+			}
+			close(stop)
+		}()
+	}
+
 	defer s.checkFinished()
 	s.write.Lock()
-	defer s.write.Unlock()
+	defer s.unlockWrite()
+
+	// the writer copies the frames it is given, so the buffer can go back to
+	// the pool as soon as the message is written.
+	buf := drpcbuffer.Get()
+	defer drpcbuffer.Put(buf, s.opts.MaximumBufferSize)
+
+	if err := s.msgWriteLocked(msg, enc, buf); err != nil {
+		return err
+	}
+	return s.endSendLocked()
+}
 
-	wbuf, err := drpcenc.MarshalAppend(msg, enc, s.wbuf[:0])
+// MsgSendBatch sends the messages in order like calling MsgSend for each of
+// them, but takes the write lock once for as many of them as the Window allows
+// and flushes once after the last one, unless the stream was configured with
+// ManualFlush. The SendTimeout applies to the whole batch. It stops at the
+// first error, returning how many messages were sent before it, and those
+// messages are flushed like they would be on success. Note that this
+// is not part of the drpc.Stream interface, so callers have to type assert for
+// it.
+func (s *Stream) MsgSendBatch(msgs []drpc.Message, enc drpc.Encoding) (n int, err error) {
+	s.flush.Do(func() {})
+
+	if s.opts.Encoding != nil {
+		enc = s.opts.Encoding
+	}
+
+	defer s.startSendTimeout()(&err)
+	defer s.checkFinished()
+
+	buf := drpcbuffer.Get()
+	defer drpcbuffer.Put(buf, s.opts.MaximumBufferSize)
+
+	for n < len(msgs) {
+		if err := s.waitWindow(context.Background()); err != nil {
+			return n, err
+		}
+
+		// the first message is written even if the stream is terminated so
+		// that the write reports why.
+		s.write.Lock()
+		for written := n; ; {
+			if err = s.msgWriteLocked(msgs[n], enc, buf); err != nil {
+				// the messages already written are reported as sent, so
+				// they have to be flushed even though the batch failed.
+				if n > written {
+					_ = s.endSendLocked()
+				}
+				break
+			}
+			if n++; n == len(msgs) {
+				err = s.endSendLocked()
+				break
+			} else if !s.windowOpen() {
+				break
+			}
+		}
+		s.unlockWrite()
+
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// msgWriteLocked marshals the message into buf and writes it without flushing.
+// It assumes the caller is holding the write lock.
+func (s *Stream) msgWriteLocked(msg drpc.Message, enc drpc.Encoding, buf *[]byte) error {
+	wbuf, err := drpcenc.MarshalAppend(msg, enc, (*buf)[:0])
 	if err != nil {
 		return errs.Wrap(err)
 	}
-	if s.opts.MaximumBufferSize == 0 || len(wbuf) < s.opts.MaximumBufferSize {
-		s.wbuf = wbuf
+	*buf = wbuf
+	if err := CheckSendSize(len(wbuf), s.opts.MaximumSendSize); err != nil {
+		return err
+	}
+	kind, data, err := s.compress(wbuf)
+	if err != nil {
+		return err
 	}
-	if err := s.rawWriteLocked(drpcwire.KindMessage, wbuf); err != nil {
+	if err := s.rawWriteLocked(kind, data); err != nil {
 		return err
 	}
+	s.addSent(len(wbuf))
+	return nil
+}
+
+// endSendLocked flushes the written messages, or schedules a flush if the
+// stream was configured with ManualFlush. It assumes the caller is holding the
+// write lock.
+func (s *Stream) endSendLocked() error {
 	if !s.opts.ManualFlush {
 		return s.rawFlushLocked()
 	}
+	s.scheduleFlush()
 	return nil
 }
 
-// MsgRecv recives some message data and unmarshals it with enc into msg.
+// startSendTimeout starts the SendTimeout, if any, and returns a function that
+// stops it. If the timeout fired, the function replaces the error it is passed
+// with the timeout instead of however the cancel caused the send to fail.
+func (s *Stream) startSendTimeout() func(err *error) {
+	if s.opts.SendTimeout <= 0 {
+		return func(*error) {}
+	}
+
+	timer := time.AfterFunc(s.opts.SendTimeout, s.sendTimeout)
+	return func(err *error) {
+		if !timer.Stop() {
+			if cerr := s.sigs.cancel.Err(); SendTimeoutError.Has(cerr) {
+				*err = cerr
+			}
+		}
+	}
+}
+
+// scheduleFlush arranges for the write buffer to be flushed once the FlushDelay
+// elapses, unless a flush is already scheduled.
+func (s *Stream) scheduleFlush() {
+	if s.opts.FlushDelay <= 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.flushing {
+		return
+	}
+	s.flushing = true
+
+	time.AfterFunc(s.opts.FlushDelay, func() {
+		s.mu.Lock()
+		s.flushing = false
+		s.mu.Unlock()
+
+		_ = s.RawFlush()
+	})
+}
+
+// MsgRecv recives some message data and unmarshals it with enc into msg. If
+// the stream was configured with an Encoding, it is used instead.
 func (s *Stream) MsgRecv(msg drpc.Message, enc drpc.Encoding) (err error) {
+	return s.MsgRecvContext(context.Background(), msg, enc)
+}
+
+// MsgRecvContext is like MsgRecv but returns the context's error if it is done
+// before a message is received. The stream can still be used afterwards, and
+// the message is returned by the next receive.
+func (s *Stream) MsgRecvContext(ctx context.Context, msg drpc.Message, enc drpc.Encoding) (err error) {
 	if err := s.checkRecvFlush(); err != nil {
 		return err
 	}
@@ -482,16 +1103,156 @@ func (s *Stream) MsgRecv(msg drpc.Message, enc drpc.Encoding) (err error) {
 	s.read.Lock()
 	defer s.read.Unlock()
 
-	data, err := s.pbuf.Get()
+	data, err := s.getPacket(ctx)
 	if err != nil {
-		return err
+		return s.checkBadPacket(err)
+	}
+	if s.opts.Encoding != nil {
+		enc = s.opts.Encoding
 	}
+	size := len(data)
 	err = enc.Unmarshal(data, msg)
 	s.pbuf.Done()
+	s.openWindow(size)
 
 	return err
 }
 
+//
+// flow control
+//
+
+// waitWindow blocks until the remote allows more messages to be sent, the
+// stream is terminated, in which case the caller's write reports why, or the
+// context is done.
+func (s *Stream) waitWindow(ctx context.Context) error {
+	for {
+		s.mu.Lock()
+		if s.windowOpenLocked() || s.sigs.term.IsSet() {
+			s.mu.Unlock()
+			return nil
+		}
+		if s.flow.update == nil {
+			s.flow.update = make(chan struct{})
+		}
+		update := s.flow.update
+		s.mu.Unlock()
+
+		// the remote can only open the window once it has what was sent.
+		if err := s.RawFlush(); err != nil {
+			return err
+		}
+
+		select {
+		case <-update:
+		case <-s.sigs.term.Signal():
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// OpenWindow advertises the Window to the remote if it has not been already. It
+// lets the remote know about the window before the stream first receives.
+func (s *Stream) OpenWindow() { s.openWindow(0) }
+
+// windowOpen returns true if the remote allows more messages to be sent.
+func (s *Stream) windowOpen() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.windowOpenLocked()
+}
+
+// windowOpenLocked is like windowOpen but assumes the caller is holding the
+// state mutex.
+func (s *Stream) windowOpenLocked() bool {
+	return s.flow.limit == 0 || s.flow.sent < s.flow.limit
+}
+
+// addSent records that a message of size bytes was sent.
+func (s *Stream) addSent(size int) {
+	s.mu.Lock()
+	s.flow.sent += uint64(size)
+	s.mu.Unlock()
+}
+
+// openWindow records that a message of size bytes was received and advertises
+// a larger window to the remote once half of the previous one has been used.
+// The window is sent by whoever holds the write mutex so that a receive never
+// waits on a send that could itself be waiting on the remote.
+func (s *Stream) openWindow(size int) {
+	if s.opts.Window <= 0 {
+		return
+	}
+
+	s.mu.Lock()
+	s.flow.received += uint64(size)
+	window := s.flow.received + uint64(s.opts.Window)
+	advertise := s.flow.window == 0 || window-s.flow.window >= uint64(s.opts.Window+1)/2
+	if advertise {
+		s.flow.window = window
+		s.flow.pending = true
+	}
+	s.mu.Unlock()
+
+	if advertise && s.write.TryLock() {
+		s.unlockWrite()
+	}
+}
+
+// unlockWrite unlocks the write mutex after sending any window that was
+// advertised while it was held.
+func (s *Stream) unlockWrite() {
+	if s.opts.Window <= 0 {
+		s.write.Unlock()
+		return
+	}
+
+	for {
+		s.mu.Lock()
+		pending := s.flow.pending && !s.sigs.term.IsSet()
+		window := s.flow.window
+		s.flow.pending = false
+		s.mu.Unlock()
+
+		if pending {
+			// failing to send the window is not an error for the caller:
+			// any problem with the transport is reported by the next write.
+			_ = s.sendPacket(drpcwire.KindWindow, true, drpcwire.AppendVarint(nil, window))
+		}
+		s.write.Unlock()
+
+		s.mu.Lock()
+		pending = s.flow.pending
+		s.mu.Unlock()
+
+		if !pending || !s.write.TryLock() {
+			return
+		}
+	}
+}
+
+// sendTimeout cancels the stream because a send took longer than the
+// SendTimeout, interrupting any write that is blocked on the transport.
+func (s *Stream) sendTimeout() {
+	err := SendTimeoutError.Wrap(context.DeadlineExceeded)
+	s.log("TIMEOUT", err.Error)
+	s.interruptSend(err)
+}
+
+// interruptSend cancels the stream with err, interrupting any write that is
+// blocked on the transport.
+func (s *Stream) interruptSend(err error) {
+	busy := !s.write.Unlocked()
+	if s.Cancel(err) {
+		return
+	}
+	if interrupt := drpcopts.GetStreamInterrupt(&s.opts.Internal); busy && interrupt != nil {
+		interrupt(err)
+	}
+}
+
 //
 // terminal messages
 //
@@ -503,8 +1264,106 @@ var (
 	termBothClosed = drpc.Error.New("stream terminated by both issuing close send")
 )
 
+// SendHeader sends the metadata to the remote as the header of the stream. It
+// returns an error if a header or any message has already been sent.
+func (s *Stream) SendHeader(md drpcmetadata.Metadata) (err error) {
+	s.log("CALL", func() string { return "SendHeader()" })
+
+	defer s.checkFinished()
+	s.write.Lock()
+	defer s.unlockWrite()
+
+	if s.header.done {
+		return drpc.ProtocolError.New("header sent after a header or message")
+	}
+
+	data, err := drpcmetadata.EncodeMetadata(nil, md)
+	if err != nil {
+		return errs.Wrap(err)
+	}
+
+	switch {
+	case s.sigs.send.IsSet():
+		return s.sigs.send.Err()
+	case s.sigs.term.IsSet():
+		return s.sigs.term.Err()
+	}
+
+	s.header.done = true
+	return s.checkCancelError(s.sendPacket(drpcwire.KindHeader, true, data))
+}
+
+// Header waits for the header sent by the remote and returns it. It returns
+// nil if the remote sends a message or finishes sending without a header, and
+// an error if the stream is terminated before any of those happen.
+func (s *Stream) Header() (drpcmetadata.Metadata, error) {
+	select {
+	case <-s.sigs.header.Signal():
+	case <-s.sigs.term.Signal():
+		if !s.sigs.header.IsSet() {
+			return nil, s.sigs.term.Err()
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.header.recv == nil {
+		return nil, nil
+	}
+	return s.header.recv.Clone(), nil
+}
+
+// SetEncoding causes the stream to use the encoding for every message, like
+// the Encoding option, such as once the encoding of the rpc has been
+// negotiated. It must be called before any message is sent or received.
+func (s *Stream) SetEncoding(enc drpc.Encoding) {
+	s.opts.Encoding = enc
+}
+
+// SetTrailer adds the metadata to the trailer that is sent to the remote right
+// before the stream sends an error or a CloseSend. It has no effect once one
+// of those has been sent.
+func (s *Stream) SetTrailer(md drpcmetadata.Metadata) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.sigs.send.IsSet() {
+		return
+	}
+	if s.trailer.send == nil {
+		s.trailer.send = make(drpcmetadata.Metadata, len(md))
+	}
+	for key, values := range md {
+		s.trailer.send.Append(key, values...)
+	}
+}
+
+// Trailer returns the trailer sent by the remote. It is complete once receives
+// on the stream have returned io.EOF or an error sent by the remote.
+func (s *Stream) Trailer() drpcmetadata.Metadata {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.trailer.recv) == 0 {
+		return nil
+	}
+	return s.trailer.recv.Clone()
+}
+
+// errorClassKey is the trailer key holding the names of the registered classes
+// of an error sent by SendError.
+const errorClassKey = "drpc-error-class"
+
+// errorDetailsKey is the trailer key holding the details of an error sent by
+// SendError.
+const errorDetailsKey = "drpc-error-details"
+
 // SendError terminates the stream and sends the error to the remote. It is a no-op if
-// the stream is already terminated.
+// the stream is already terminated. The names of any classes of the error that
+// were registered with drpcerr.RegisterClass, and any details of the error
+// from drpcerr.Details, are sent in the trailer so that the remote can restore
+// them.
 func (s *Stream) SendError(serr error) (err error) {
 	s.log("CALL", func() string { return fmt.Sprintf("SendError(%v)", serr) })
 
@@ -516,12 +1375,29 @@ func (s *Stream) SendError(serr error) (err error) {
 
 	defer s.checkFinished()
 	s.write.Lock()
-	defer s.write.Unlock()
+	defer s.unlockWrite()
 
 	s.sigs.send.Set(io.EOF) // in this state, gRPC returns io.EOF on send.
 	s.terminate(termError)
+	if names := drpcerr.Classes(serr); len(names) > 0 {
+		if s.trailer.send == nil {
+			s.trailer.send = make(drpcmetadata.Metadata, 1)
+		}
+		s.trailer.send.Append(errorClassKey, names...)
+	}
+	if details := drpcerr.Details(serr); len(details) > 0 {
+		if s.trailer.send == nil {
+			s.trailer.send = make(drpcmetadata.Metadata, 1)
+		}
+		for _, detail := range details {
+			s.trailer.send.Append(errorDetailsKey, string(detail))
+		}
+	}
 	s.mu.Unlock()
 
+	if err := s.writeTrailer(); err != nil {
+		return s.checkCancelError(err)
+	}
 	return s.checkCancelError(s.sendPacket(drpcwire.KindError, false, drpcwire.MarshalError(serr)))
 }
 
@@ -545,7 +1421,7 @@ func (s *Stream) SendCancel(err error) (bool, error) {
 
 	defer s.checkFinished()
 	s.write.Lock()
-	defer s.write.Unlock()
+	defer s.unlockWrite()
 
 	s.sigs.send.Set(io.EOF) // in this state, gRPC returns io.EOF on send.
 	s.terminate(err)
@@ -555,7 +1431,9 @@ func (s *Stream) SendCancel(err error) (bool, error) {
 }
 
 // Close terminates the stream and sends that the stream has been closed to the remote.
-// It is a no-op if the stream is already terminated.
+// Unlike CloseSend, the remote's receives return a drpc.ClosedError instead of io.EOF
+// unless it had already received a CloseSend. It is a no-op if the stream is already
+// terminated.
 func (s *Stream) Close() (err error) {
 	s.log("CALL", func() string { return "Close()" })
 
@@ -567,7 +1445,7 @@ func (s *Stream) Close() (err error) {
 
 	defer s.checkFinished()
 	s.write.Lock()
-	defer s.write.Unlock()
+	defer s.unlockWrite()
 
 	s.terminate(termClosed)
 	s.mu.Unlock()
@@ -575,9 +1453,10 @@ func (s *Stream) Close() (err error) {
 	return s.checkCancelError(s.sendPacket(drpcwire.KindClose, false, nil))
 }
 
-// CloseSend informs the remote that no more messages will be sent. If the remote has
-// also already issued a CloseSend, the stream is terminated. It is a no-op if the
-// stream already has sent a CloseSend or if it is terminated.
+// CloseSend informs the remote that no more messages will be sent, causing its
+// receives to return io.EOF. If the remote has also already issued a CloseSend, the
+// stream is terminated. It is a no-op if the stream already has sent a CloseSend or
+// if it is terminated.
 func (s *Stream) CloseSend() (err error) {
 	s.log("CALL", func() string { return "CloseSend()" })
 
@@ -589,12 +1468,15 @@ func (s *Stream) CloseSend() (err error) {
 
 	defer s.checkFinished()
 	s.write.Lock()
-	defer s.write.Unlock()
+	defer s.unlockWrite()
 
 	s.sigs.send.Set(sendClosed)
 	s.terminateIfBothClosed()
 	s.mu.Unlock()
 
+	if err := s.writeTrailer(); err != nil {
+		return s.checkCancelError(err)
+	}
 	return s.checkCancelError(s.sendPacket(drpcwire.KindCloseSend, false, nil))
 }
 
@@ -9,13 +9,16 @@ import (
 	"errors"
 	"io"
 	"testing"
+	"time"
 
 	"github.com/zeebo/assert"
 	"github.com/zeebo/errs"
 
 	"storj.io/drpc"
+	"storj.io/drpc/drpccompress"
 	"storj.io/drpc/drpctest"
 	"storj.io/drpc/drpcwire"
+	"storj.io/drpc/internal/drpcopts"
 )
 
 func TestStream_StateTransitions(t *testing.T) {
@@ -48,7 +51,7 @@ func TestStream_StateTransitions(t *testing.T) {
 	cases := []struct {
 		Op   func(st *Stream) error
 		Send interface{}
-		Recv error
+		Recv interface{}
 	}{
 		{ // send close
 			Op:   func(st *Stream) error { return st.Close() },
@@ -83,7 +86,7 @@ func TestStream_StateTransitions(t *testing.T) {
 		{ // recv close
 			Op:   func(st *Stream) error { return handlePacket(st, drpcwire.KindClose) },
 			Send: &drpc.ClosedError,
-			Recv: io.EOF,
+			Recv: &drpc.ClosedError,
 		},
 
 		{ // recv error
@@ -214,6 +217,282 @@ func TestStream_Control(t *testing.T) {
 	assert.That(t, drpc.InternalError.Has(st.HandlePacket(drpcwire.Packet{})))
 }
 
+func TestStream_CompressedWithoutCompressor(t *testing.T) {
+	st := New(context.Background(), 0, drpcwire.NewWriter(io.Discard, 0))
+
+	err := st.HandlePacket(drpcwire.Packet{Kind: drpcwire.KindCompressedMessage})
+	assert.That(t, drpc.ProtocolError.Has(err))
+	assert.That(t, st.IsTerminated())
+}
+
+func TestStream_DecompressedSizeLimit(t *testing.T) {
+	comp := drpccompress.Gzip{}
+	st := NewWithOptions(context.Background(), 0, drpcwire.NewWriter(io.Discard, 0), Options{
+		Compressor:              comp,
+		MaximumDecompressedSize: 1 << 20,
+	})
+
+	data, err := comp.Compress(make([]byte, 32<<20))
+	assert.NoError(t, err)
+
+	err = st.HandlePacket(drpcwire.Packet{Kind: drpcwire.KindCompressedMessage, Data: data})
+	assert.That(t, drpc.ProtocolError.Has(err))
+	assert.That(t, st.IsTerminated())
+}
+
+func TestStream_RecoverDecodeErrors(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	comp := drpccompress.Gzip{}
+	st := NewWithOptions(ctx, 0, drpcwire.NewWriter(io.Discard, 0), Options{
+		Compressor:              comp,
+		MaximumDecompressedSize: 1 << 20,
+		RecoverDecodeErrors:     true,
+	})
+
+	large, err := comp.Compress(make([]byte, 2<<20))
+	assert.NoError(t, err)
+	small, err := comp.Compress([]byte("data"))
+	assert.NoError(t, err)
+
+	ctx.Run(func(ctx context.Context) {
+		for _, pkt := range []drpcwire.Packet{
+			{Kind: drpcwire.KindCompressedMessage, Data: large},
+			{Kind: drpcwire.KindCompressedMessage, Data: []byte("garbage")},
+			{Kind: drpcwire.KindMessage, Data: []byte("bad")},
+			{Kind: drpcwire.KindCompressedMessage, Data: small},
+		} {
+			assert.NoError(t, st.HandlePacket(pkt))
+		}
+	})
+
+	// messages that fail to decompress fail only their receive.
+	var out []byte
+	assert.That(t, drpc.ProtocolError.Has(st.MsgRecv(&out, drpctest.ByteEncoding{})))
+	_, err = st.RawRecv()
+	assert.That(t, drpc.ProtocolError.Has(err))
+	assert.That(t, !st.IsTerminated())
+
+	// as do errors from the encoding.
+	assert.Error(t, st.MsgRecv(&out, failingEncoding{}))
+	assert.That(t, !st.IsTerminated())
+
+	assert.NoError(t, st.MsgRecv(&out, drpctest.ByteEncoding{}))
+	assert.Equal(t, string(out), "data")
+}
+
+func TestStream_RecoverDecodeErrorsWindow(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	var buf bytes.Buffer
+	st := NewWithOptions(ctx, 1, drpcwire.NewWriter(&buf, 0), Options{
+		Compressor:          drpccompress.Gzip{},
+		RecoverDecodeErrors: true,
+		Window:              16,
+	})
+
+	// a message that fails to decompress only opens the window by the bytes
+	// that were received for it.
+	garbage := []byte("garbage data")
+	ctx.Run(func(ctx context.Context) {
+		assert.NoError(t, st.HandlePacket(drpcwire.Packet{Kind: drpcwire.KindCompressedMessage, Data: garbage, ID: drpcwire.ID{Stream: 1, Message: 1}}))
+	})
+	_, err := st.RawRecv()
+	assert.That(t, drpc.ProtocolError.Has(err))
+	ctx.Wait()
+
+	var window uint64
+	for rd := drpcwire.NewReader(&buf); ; {
+		pkt, err := rd.ReadPacket()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, pkt.Kind, drpcwire.KindWindow)
+		_, window, _, err = drpcwire.ReadVarint(pkt.Data)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, window, uint64(len(garbage)+16))
+}
+
+type failingEncoding struct{}
+
+func (failingEncoding) Marshal(msg drpc.Message) ([]byte, error) { return nil, errs.New("marshal") }
+func (failingEncoding) Unmarshal(buf []byte, msg drpc.Message) error {
+	return errs.New("unmarshal")
+}
+
+func TestStream_SendTimeout(t *testing.T) {
+	st := NewWithOptions(context.Background(), 0, drpcwire.NewWriter(io.Discard, 0), Options{
+		SendTimeout: 10 * time.Millisecond,
+	})
+
+	// sends that do not block are unaffected.
+	msg := []byte("data")
+	assert.NoError(t, st.MsgSend(&msg, drpctest.ByteEncoding{}))
+
+	// a send waiting for the window to open times out.
+	window := drpcwire.AppendVarint(nil, uint64(len(msg)))
+	assert.NoError(t, st.HandlePacket(drpcwire.Packet{Kind: drpcwire.KindWindow, Data: window}))
+
+	err := st.MsgSend(&msg, drpctest.ByteEncoding{})
+	assert.That(t, SendTimeoutError.Has(err))
+	assert.That(t, errors.Is(err, context.DeadlineExceeded))
+	assert.That(t, st.IsTerminated())
+}
+
+func TestStream_RecvTimeout(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	const timeout = 20 * time.Millisecond
+	st := NewWithOptions(ctx, 0, drpcwire.NewWriter(io.Discard, 0), Options{
+		RecvTimeout: timeout,
+	})
+	msg := []byte("data")
+
+	// a remote that keeps sending within the timeout is unaffected.
+	for i := 0; i < 3; i++ {
+		ctx.Run(func(ctx context.Context) {
+			time.Sleep(timeout / 2)
+			_ = st.HandlePacket(drpcwire.Packet{Kind: drpcwire.KindMessage, Data: msg})
+		})
+		var out []byte
+		assert.NoError(t, st.MsgRecv(&out, drpctest.ByteEncoding{}))
+		assert.Equal(t, string(out), "data")
+	}
+
+	// but once it goes silent, the blocked receive is interrupted.
+	start := time.Now()
+	err := st.MsgRecv(new([]byte), drpctest.ByteEncoding{})
+	assert.That(t, RecvTimeoutError.Has(err))
+	assert.That(t, errors.Is(err, context.DeadlineExceeded))
+	assert.That(t, time.Since(start) < 10*timeout)
+	assert.That(t, !st.IsTerminated())
+
+	// and the stream can keep receiving.
+	ctx.Run(func(ctx context.Context) {
+		_ = st.HandlePacket(drpcwire.Packet{Kind: drpcwire.KindMessage, Data: msg})
+	})
+	data, err := st.RawRecv()
+	assert.NoError(t, err)
+	assert.Equal(t, string(data), "data")
+
+	_, err = st.RawRecv()
+	assert.That(t, RecvTimeoutError.Has(err))
+}
+
+// countingWriter counts the writes made to it.
+type countingWriter struct {
+	bytes.Buffer
+	writes int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return w.Buffer.Write(p)
+}
+
+func TestStream_MsgSendBatch(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	var msgs []drpc.Message
+	for i := 0; i < 10; i++ {
+		msg := []byte{byte(i)}
+		msgs = append(msgs, &msg)
+	}
+
+	// the batch is written with a single flush.
+	var buf countingWriter
+	st := New(ctx, 1, drpcwire.NewWriter(&buf, 1024))
+	n, err := st.MsgSendBatch(msgs, drpctest.ByteEncoding{})
+	assert.NoError(t, err)
+	assert.Equal(t, n, 10)
+	assert.Equal(t, buf.writes, 1)
+
+	// and the other side receives the messages in order.
+	recv := New(ctx, 1, drpcwire.NewWriter(io.Discard, 0))
+	ctx.Run(func(ctx context.Context) {
+		rd := drpcwire.NewReader(&buf.Buffer)
+		for {
+			pkt, err := rd.ReadPacket()
+			if err != nil {
+				return
+			}
+			_ = recv.HandlePacket(pkt)
+		}
+	})
+	for i := 0; i < 10; i++ {
+		var out []byte
+		assert.NoError(t, recv.MsgRecv(&out, drpctest.ByteEncoding{}))
+		assert.DeepEqual(t, out, []byte{byte(i)})
+	}
+
+	// a batch stops at the first error.
+	st = NewWithOptions(ctx, 1, drpcwire.NewWriter(io.Discard, 0), Options{MaximumSendSize: 1})
+	large := []byte("large")
+	n, err = st.MsgSendBatch(append(msgs[:3:3], &large, msgs[3]), drpctest.ByteEncoding{})
+	assert.That(t, drpc.MessageSizeError.Has(err))
+	assert.Equal(t, n, 3)
+}
+
+func TestStream_MsgContext(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	timeout := func() context.Context {
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		t.Cleanup(cancel)
+		return ctx
+	}
+
+	st := New(ctx, 0, drpcwire.NewWriter(io.Discard, 0))
+	msg := []byte("data")
+
+	{ // a receive waiting for a message returns and the message is kept.
+		var out []byte
+		err := st.MsgRecvContext(timeout(), &out, drpctest.ByteEncoding{})
+		assert.That(t, errors.Is(err, context.DeadlineExceeded))
+		assert.That(t, !st.IsTerminated())
+
+		ctx.Run(func(ctx context.Context) {
+			_ = st.HandlePacket(drpcwire.Packet{Kind: drpcwire.KindMessage, Data: msg})
+		})
+		assert.NoError(t, st.MsgRecv(&out, drpctest.ByteEncoding{}))
+		assert.Equal(t, string(out), "data")
+	}
+
+	{ // a send waiting for the window to open returns without sending.
+		window := drpcwire.AppendVarint(nil, uint64(len(msg)))
+		assert.NoError(t, st.HandlePacket(drpcwire.Packet{Kind: drpcwire.KindWindow, Data: window}))
+		assert.NoError(t, st.MsgSend(&msg, drpctest.ByteEncoding{}))
+
+		err := st.MsgSendContext(timeout(), &msg, drpctest.ByteEncoding{})
+		assert.That(t, errors.Is(err, context.DeadlineExceeded))
+		assert.That(t, !st.IsTerminated())
+
+		window = drpcwire.AppendVarint(nil, uint64(2*len(msg)))
+		assert.NoError(t, st.HandlePacket(drpcwire.Packet{Kind: drpcwire.KindWindow, Data: window}))
+		assert.NoError(t, st.MsgSend(&msg, drpctest.ByteEncoding{}))
+	}
+
+	{ // a send blocked on the transport cancels the stream.
+		pr, pw := io.Pipe()
+		defer func() { _ = pr.Close() }()
+
+		var opts Options
+		drpcopts.SetStreamInterrupt(&opts.Internal, func(err error) { _ = pw.CloseWithError(err) })
+		st := NewWithOptions(ctx, 0, drpcwire.NewWriter(pw, 0), opts)
+
+		err := st.MsgSendContext(timeout(), &msg, drpctest.ByteEncoding{})
+		assert.That(t, errors.Is(err, context.DeadlineExceeded))
+		assert.That(t, st.IsTerminated())
+	}
+}
+
 func TestStream_CorkUntilFirstRead(t *testing.T) {
 	run := func() {
 		ctx := drpctest.NewTracker(t)
@@ -0,0 +1,23 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpctest
+
+import (
+	"storj.io/drpc"
+)
+
+// ByteEncoding is a drpc.Encoding for messages that are *[]byte. Marshal
+// returns the bytes as is and Unmarshal replaces them with a copy of the data.
+type ByteEncoding struct{}
+
+var _ drpc.Encoding = ByteEncoding{}
+
+// Marshal returns the bytes the message points to.
+func (ByteEncoding) Marshal(msg drpc.Message) ([]byte, error) { return *msg.(*[]byte), nil }
+
+// Unmarshal sets the message to a copy of buf.
+func (ByteEncoding) Unmarshal(buf []byte, msg drpc.Message) error {
+	*msg.(*[]byte) = append([]byte(nil), buf...)
+	return nil
+}
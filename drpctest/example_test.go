@@ -0,0 +1,79 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpctest_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"storj.io/drpc"
+	"storj.io/drpc/drpctest"
+)
+
+func ExampleMockConn_invoke() {
+	var conn drpctest.MockConn
+	resp := []byte("hello")
+	conn.SetInvoke("/Greet", &resp, nil)
+
+	in, out := []byte("world"), []byte(nil)
+	if err := conn.Invoke(context.Background(), "/Greet", drpctest.ByteEncoding{}, &in, &out); err != nil {
+		panic(err)
+	}
+	fmt.Println(string(out))
+
+	// rpcs that have nothing registered fail.
+	err := conn.Invoke(context.Background(), "/Other", drpctest.ByteEncoding{}, &in, &out)
+	fmt.Println(err)
+
+	for _, call := range conn.Calls() {
+		fmt.Println(call.RPC, string(*call.In.(*[]byte)))
+	}
+
+	// Output:
+	// hello
+	// protocol error: unknown rpc: "/Other"
+	// /Greet world
+	// /Other world
+}
+
+func ExampleMockConn_stream() {
+	var conn drpctest.MockConn
+	a, b := []byte("a"), []byte("b")
+	conn.SetStream("/List", []drpc.Message{&a, &b}, nil)
+
+	stream, err := conn.NewStream(context.Background(), "/List", drpctest.ByteEncoding{})
+	if err != nil {
+		panic(err)
+	}
+	defer func() { _ = stream.Close() }()
+
+	req := []byte("all")
+	if err := stream.MsgSend(&req, drpctest.ByteEncoding{}); err != nil {
+		panic(err)
+	}
+	if err := stream.CloseSend(); err != nil {
+		panic(err)
+	}
+
+	for {
+		var item []byte
+		err := stream.MsgRecv(&item, drpctest.ByteEncoding{})
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			panic(err)
+		}
+		fmt.Println(string(item))
+	}
+
+	sent := conn.Calls()[0].Sent
+	fmt.Println(len(sent), string(*sent[0].(*[]byte)))
+
+	// Output:
+	// a
+	// b
+	// 1 all
+}
@@ -0,0 +1,271 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpctest
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"storj.io/drpc"
+	"storj.io/drpc/drpcerr"
+)
+
+// MockCall records an rpc issued on a MockConn.
+type MockCall struct {
+	// RPC is the rpc string the call was issued with.
+	RPC string
+
+	// In is the input message passed to Invoke. It is nil for streams.
+	In drpc.Message
+
+	// Sent contains the messages sent on the stream, in order. It is nil for
+	// calls to Invoke.
+	Sent []drpc.Message
+}
+
+// MockConn is a drpc.Conn that answers rpcs with the responses registered with
+// it instead of sending them to a server, and records every call issued on it.
+// The zero value is ready to use and fails every rpc with DefaultError.
+type MockConn struct {
+	// DefaultError is returned for rpcs that have nothing registered. If nil,
+	// an error with the drpcerr.Unimplemented code is returned.
+	DefaultError error
+
+	mu      sync.Mutex
+	invokes map[string]drpc.InvokeFunc
+	streams map[string]drpc.NewStreamFunc
+	calls   []*MockCall
+	once    sync.Once
+	closed  chan struct{}
+}
+
+var _ drpc.Conn = (*MockConn)(nil)
+
+// SetInvoke causes Invoke for the rpc to fill in its output with the out
+// message and return err. The out message is passed through the encoding
+// Invoke is called with, so it may be any value the encoding can marshal. If
+// out is nil, only err is returned.
+func (m *MockConn) SetInvoke(rpc string, out drpc.Message, err error) {
+	m.SetInvokeFunc(rpc, func(ctx context.Context, rpc string, enc drpc.Encoding, in, msg drpc.Message) error {
+		if out != nil {
+			if err := convert(enc, out, msg); err != nil {
+				return err
+			}
+		}
+		return err
+	})
+}
+
+// SetInvokeFunc causes Invoke for the rpc to call fn.
+func (m *MockConn) SetInvokeFunc(rpc string, fn drpc.InvokeFunc) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.invokes == nil {
+		m.invokes = make(map[string]drpc.InvokeFunc)
+	}
+	m.invokes[rpc] = fn
+}
+
+// SetStream causes NewStream for the rpc to return a stream that receives the
+// msgs in order, followed by err, or io.EOF if err is nil. Like with
+// SetInvoke, the messages are passed through the encoding of the stream. The
+// messages sent on the stream are recorded in its MockCall.
+func (m *MockConn) SetStream(rpc string, msgs []drpc.Message, err error) {
+	if err == nil {
+		err = io.EOF
+	}
+	m.SetStreamFunc(rpc, func(ctx context.Context, rpc string, enc drpc.Encoding) (drpc.Stream, error) {
+		ctx, cancel := context.WithCancel(ctx)
+		return &mockStream{ctx: ctx, cancel: cancel, msgs: msgs, err: err}, nil
+	})
+}
+
+// SetStreamFunc causes NewStream for the rpc to call fn. Unlike streams
+// created by SetStream, the messages sent on the streams fn returns are not
+// recorded.
+func (m *MockConn) SetStreamFunc(rpc string, fn drpc.NewStreamFunc) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.streams == nil {
+		m.streams = make(map[string]drpc.NewStreamFunc)
+	}
+	m.streams[rpc] = fn
+}
+
+// Calls returns a copy of the calls issued on the conn, in order.
+func (m *MockConn) Calls() []MockCall {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	calls := make([]MockCall, len(m.calls))
+	for i, call := range m.calls {
+		calls[i] = *call
+		calls[i].Sent = append([]drpc.Message(nil), call.Sent...)
+	}
+	return calls
+}
+
+// Close closes the conn. Later rpcs fail with a drpc.ClosedError.
+func (m *MockConn) Close() error {
+	m.once.Do(func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		if m.closed == nil {
+			m.closed = make(chan struct{})
+		}
+		close(m.closed)
+	})
+	return nil
+}
+
+// Closed returns a channel that is closed once the conn is closed.
+func (m *MockConn) Closed() <-chan struct{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed == nil {
+		m.closed = make(chan struct{})
+	}
+	return m.closed
+}
+
+// record adds the call to the conn. It returns an error if the conn is
+// closed.
+func (m *MockConn) record(call *MockCall) error {
+	select {
+	case <-m.Closed():
+		return drpc.ClosedError.New("mock conn closed")
+	default:
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.calls = append(m.calls, call)
+	return nil
+}
+
+// defaultError returns the error for an rpc that has nothing registered.
+func (m *MockConn) defaultError(rpc string) error {
+	if m.DefaultError != nil {
+		return m.DefaultError
+	}
+	return drpcerr.WithCode(drpc.ProtocolError.New("unknown rpc: %q", rpc), drpcerr.Unimplemented)
+}
+
+// Invoke records the call and responds with what is registered for the rpc.
+func (m *MockConn) Invoke(ctx context.Context, rpc string, enc drpc.Encoding, in, out drpc.Message) error {
+	if err := m.record(&MockCall{RPC: rpc, In: in}); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	fn, ok := m.invokes[rpc]
+	m.mu.Unlock()
+
+	if !ok {
+		return m.defaultError(rpc)
+	}
+	return fn(ctx, rpc, enc, in, out)
+}
+
+// NewStream records the call and responds with what is registered for the rpc.
+func (m *MockConn) NewStream(ctx context.Context, rpc string, enc drpc.Encoding) (drpc.Stream, error) {
+	call := &MockCall{RPC: rpc}
+	if err := m.record(call); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	fn, ok := m.streams[rpc]
+	m.mu.Unlock()
+
+	if !ok {
+		return nil, m.defaultError(rpc)
+	}
+	stream, err := fn(ctx, rpc, enc)
+	if ms, ok := stream.(*mockStream); ok {
+		ms.conn, ms.call = m, call
+	}
+	return stream, err
+}
+
+// convert sets dst to the value of src by passing it through the encoding.
+func convert(enc drpc.Encoding, src, dst drpc.Message) error {
+	data, err := enc.Marshal(src)
+	if err != nil {
+		return err
+	}
+	return enc.Unmarshal(data, dst)
+}
+
+// mockStream is a drpc.Stream that receives scripted messages and records the
+// messages sent on it.
+type mockStream struct {
+	ctx    context.Context
+	cancel func()
+	conn   *MockConn
+	call   *MockCall
+
+	mu         sync.Mutex
+	msgs       []drpc.Message
+	err        error
+	sendClosed bool
+	done       bool
+}
+
+func (s *mockStream) Context() context.Context { return s.ctx }
+
+func (s *mockStream) MsgSend(msg drpc.Message, enc drpc.Encoding) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.done || s.sendClosed {
+		return drpc.ClosedError.New("send closed")
+	}
+
+	s.conn.mu.Lock()
+	defer s.conn.mu.Unlock()
+
+	s.call.Sent = append(s.call.Sent, msg)
+	return nil
+}
+
+func (s *mockStream) MsgRecv(msg drpc.Message, enc drpc.Encoding) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.done {
+		return drpc.ClosedError.New("stream closed")
+	} else if err := s.ctx.Err(); err != nil {
+		return err
+	} else if len(s.msgs) == 0 {
+		return s.err
+	}
+
+	next := s.msgs[0]
+	s.msgs = s.msgs[1:]
+	return convert(enc, next, msg)
+}
+
+func (s *mockStream) CloseSend() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sendClosed = true
+	return nil
+}
+
+func (s *mockStream) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.done = true
+	s.cancel()
+	return nil
+}
@@ -0,0 +1,31 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpctest
+
+import (
+	"sync/atomic"
+
+	"storj.io/drpc"
+)
+
+// CountingTransport wraps a drpc.Transport and counts the writes issued to it.
+type CountingTransport struct {
+	drpc.Transport
+
+	writes  int64
+	written int64
+}
+
+// Write counts the write and passes it to the wrapped transport.
+func (c *CountingTransport) Write(p []byte) (int, error) {
+	atomic.AddInt64(&c.writes, 1)
+	atomic.AddInt64(&c.written, int64(len(p)))
+	return c.Transport.Write(p)
+}
+
+// Writes returns the number of times Write has been called.
+func (c *CountingTransport) Writes() int { return int(atomic.LoadInt64(&c.writes)) }
+
+// Written returns the number of bytes passed to Write.
+func (c *CountingTransport) Written() int { return int(atomic.LoadInt64(&c.written)) }
@@ -0,0 +1,5 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+// Package drpctls provides helpers to secure drpc transports with TLS.
+package drpctls
@@ -0,0 +1,192 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpctls
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/zeebo/errs"
+
+	"storj.io/drpc"
+	"storj.io/drpc/drpcctx"
+)
+
+// Error wraps all of the errors returned by this package, including any
+// handshake failures.
+var Error = errs.Class("drpctls")
+
+// Client performs a client TLS handshake over the transport and returns the
+// secured transport to be used by a drpcconn.Conn.
+func Client(ctx context.Context, tr drpc.Transport, config *tls.Config) (*tls.Conn, error) {
+	conn := tls.Client(asNetConn(tr), config)
+	if err := conn.HandshakeContext(ctx); err != nil {
+		_ = conn.Close()
+		return nil, Error.Wrap(err)
+	}
+	return conn, nil
+}
+
+// Server performs a server TLS handshake over the transport and returns the
+// secured transport to be passed to Server.ServeOne.
+func Server(ctx context.Context, tr drpc.Transport, config *tls.Config) (*tls.Conn, error) {
+	conn := tls.Server(asNetConn(tr), config)
+	if err := conn.HandshakeContext(ctx); err != nil {
+		_ = conn.Close()
+		return nil, Error.Wrap(err)
+	}
+	return conn, nil
+}
+
+// NewListener returns a listener that secures every accepted conn with TLS,
+// to be passed to Server.Serve. The handshake is performed on the first read
+// or write so that a slow client does not delay accepting other conns.
+func NewListener(lis net.Listener, config *tls.Config) net.Listener {
+	return &listener{Listener: lis, config: config}
+}
+
+type listener struct {
+	net.Listener
+	config *tls.Config
+}
+
+// Accept waits for and returns the next conn, secured with TLS.
+func (l *listener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &serverConn{Conn: tls.Server(conn, l.config)}, nil
+}
+
+// serverConn performs the handshake on the first read or write, wrapping any
+// failure with the Error class.
+type serverConn struct {
+	*tls.Conn
+	once sync.Once
+	err  error
+}
+
+func (c *serverConn) handshake() error {
+	c.once.Do(func() {
+		if err := c.Conn.Handshake(); err != nil {
+			c.err = Error.Wrap(err)
+		}
+	})
+	return c.err
+}
+
+// Read performs the handshake if necessary and reads from the conn.
+func (c *serverConn) Read(p []byte) (int, error) {
+	if err := c.handshake(); err != nil {
+		return 0, err
+	}
+	return c.Conn.Read(p)
+}
+
+// Write performs the handshake if necessary and writes to the conn.
+func (c *serverConn) Write(p []byte) (int, error) {
+	if err := c.handshake(); err != nil {
+		return 0, err
+	}
+	return c.Conn.Write(p)
+}
+
+// ConnectionState returns the state of the TLS connection used by the rpc the
+// context belongs to, such as the certificates presented by the peer. It
+// returns false if the rpc is not being served over TLS.
+func ConnectionState(ctx context.Context) (tls.ConnectionState, bool) {
+	tr, ok := drpcctx.Transport(ctx)
+	if !ok {
+		return tls.ConnectionState{}, false
+	}
+	conn, ok := tr.(interface{ ConnectionState() tls.ConnectionState })
+	if !ok {
+		return tls.ConnectionState{}, false
+	}
+	return conn.ConnectionState(), true
+}
+
+// PeerCertificates returns the certificates presented by the peer of the rpc
+// the context belongs to, leaf first.
+func PeerCertificates(ctx context.Context) ([]*x509.Certificate, bool) {
+	state, ok := ConnectionState(ctx)
+	if !ok || len(state.PeerCertificates) == 0 {
+		return nil, false
+	}
+	return state.PeerCertificates, true
+}
+
+// TLSInfo describes the verified certificate presented by the peer of an rpc.
+type TLSInfo struct {
+	// Certificate is the peer's leaf certificate.
+	Certificate *x509.Certificate
+
+	// Subject is the subject of the leaf certificate.
+	Subject pkix.Name
+
+	// DNSNames, EmailAddresses, IPAddresses and URIs are the subject
+	// alternative names of the leaf certificate.
+	DNSNames       []string
+	EmailAddresses []string
+	IPAddresses    []net.IP
+	URIs           []*url.URL
+
+	// VerifiedChains are the chains the leaf certificate was verified with,
+	// each starting with the leaf.
+	VerifiedChains [][]*x509.Certificate
+}
+
+// TLSInfoFromContext returns information about the verified certificate of the
+// peer of the rpc the context belongs to, so that handlers can authorize rpcs
+// by certificate. It returns false if the rpc is not being served over TLS or
+// the peer did not present a certificate that was verified, which only happens
+// if the tls.Config has a ClientAuth that verifies client certificates.
+func TLSInfoFromContext(ctx context.Context) (*TLSInfo, bool) {
+	state, ok := ConnectionState(ctx)
+	if !ok || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil, false
+	}
+	leaf := state.VerifiedChains[0][0]
+	return &TLSInfo{
+		Certificate:    leaf,
+		Subject:        leaf.Subject,
+		DNSNames:       leaf.DNSNames,
+		EmailAddresses: leaf.EmailAddresses,
+		IPAddresses:    leaf.IPAddresses,
+		URIs:           leaf.URIs,
+		VerifiedChains: state.VerifiedChains,
+	}, true
+}
+
+// asNetConn returns the transport as a net.Conn, adapting it if necessary.
+func asNetConn(tr drpc.Transport) net.Conn {
+	if conn, ok := tr.(net.Conn); ok {
+		return conn
+	}
+	return transportConn{tr}
+}
+
+// transportConn adapts a drpc.Transport to a net.Conn. It has no addresses
+// and does not support deadlines.
+type transportConn struct {
+	drpc.Transport
+}
+
+func (transportConn) LocalAddr() net.Addr                { return transportAddr{} }
+func (transportConn) RemoteAddr() net.Addr               { return transportAddr{} }
+func (transportConn) SetDeadline(t time.Time) error      { return nil }
+func (transportConn) SetReadDeadline(t time.Time) error  { return nil }
+func (transportConn) SetWriteDeadline(t time.Time) error { return nil }
+
+type transportAddr struct{}
+
+func (transportAddr) Network() string { return "drpc" }
+func (transportAddr) String() string  { return "drpc" }
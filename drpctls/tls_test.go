@@ -0,0 +1,204 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpctls
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+
+	"storj.io/drpc"
+	"storj.io/drpc/drpcconn"
+	"storj.io/drpc/drpcserver"
+	"storj.io/drpc/drpctest"
+)
+
+// peerHandler responds with the common name of the peer's certificate.
+type peerHandler struct{}
+
+func (peerHandler) HandleRPC(stream drpc.Stream, rpc string) error {
+	var in []byte
+	if err := stream.MsgRecv(&in, drpctest.ByteEncoding{}); err != nil {
+		return err
+	}
+	certs, ok := PeerCertificates(stream.Context())
+	if !ok {
+		return Error.New("no peer certificates")
+	}
+	out := []byte(certs[0].Subject.CommonName)
+	return stream.MsgSend(&out, drpctest.ByteEncoding{})
+}
+
+// newCert returns a self-signed certificate with the common name.
+func newCert(t *testing.T, name string) (tls.Certificate, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: cert}, cert
+}
+
+func TestRoundTrip(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	serverCert, serverLeaf := newCert(t, "server")
+	clientCert, clientLeaf := newCert(t, "client")
+
+	serverPool, clientPool := x509.NewCertPool(), x509.NewCertPool()
+	serverPool.AddCert(clientLeaf)
+	clientPool.AddCert(serverLeaf)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	srv := drpcserver.New(peerHandler{})
+	ctx.Run(func(ctx context.Context) {
+		_ = srv.Serve(ctx, NewListener(lis, &tls.Config{
+			Certificates: []tls.Certificate{serverCert},
+			ClientCAs:    serverPool,
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+		}))
+	})
+
+	dial := func(config *tls.Config) (*tls.Conn, error) {
+		rawconn, err := net.Dial("tcp", lis.Addr().String())
+		assert.NoError(t, err)
+		return Client(ctx, rawconn, config)
+	}
+
+	{ // the server sees the client's certificate
+		tconn, err := dial(&tls.Config{
+			Certificates: []tls.Certificate{clientCert},
+			RootCAs:      clientPool,
+			ServerName:   "server",
+		})
+		assert.NoError(t, err)
+
+		conn := drpcconn.New(tconn)
+		defer func() { _ = conn.Close() }()
+
+		in, out := []byte("data"), []byte(nil)
+		assert.NoError(t, conn.Invoke(ctx, "/rpc", drpctest.ByteEncoding{}, &in, &out))
+		assert.Equal(t, string(out), "client")
+	}
+
+	{ // handshake failures are returned as errors
+		_, err := dial(&tls.Config{ServerName: "server"})
+		assert.That(t, Error.Has(err))
+	}
+}
+
+func TestTransport(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	serverCert, serverLeaf := newCert(t, "server")
+	clientPool := x509.NewCertPool()
+	clientPool.AddCert(serverLeaf)
+
+	// transports that are not net.Conns can be secured too.
+	type transport struct{ drpc.Transport }
+	c1, c2 := net.Pipe()
+
+	errs := make(chan error, 1)
+	ctx.Run(func(ctx context.Context) {
+		_, err := Server(ctx, transport{c2}, &tls.Config{Certificates: []tls.Certificate{serverCert}})
+		errs <- err
+	})
+
+	_, err := Client(ctx, transport{c1}, &tls.Config{RootCAs: clientPool, ServerName: "server"})
+	assert.NoError(t, err)
+	assert.NoError(t, <-errs)
+
+	// ConnectionState does not find anything on contexts without a tls transport.
+	_, ok := ConnectionState(ctx)
+	assert.That(t, !ok)
+}
+
+func TestTLSInfoFromContext(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	serverCert, serverLeaf := newCert(t, "server")
+	clientCert, clientLeaf := newCert(t, "client")
+
+	serverPool, clientPool := x509.NewCertPool(), x509.NewCertPool()
+	serverPool.AddCert(clientLeaf)
+	clientPool.AddCert(serverLeaf)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	// the handler responds with the common name of the verified client
+	// certificate, or "none" if there is not one.
+	srv := drpcserver.New(handlerFunc(func(stream drpc.Stream, rpc string) error {
+		var in []byte
+		if err := stream.MsgRecv(&in, drpctest.ByteEncoding{}); err != nil {
+			return err
+		}
+		out := []byte("none")
+		if info, ok := TLSInfoFromContext(stream.Context()); ok {
+			out = []byte(info.Subject.CommonName + " " + info.DNSNames[0])
+		}
+		return stream.MsgSend(&out, drpctest.ByteEncoding{})
+	}))
+	ctx.Run(func(ctx context.Context) {
+		_ = srv.Serve(ctx, NewListener(lis, &tls.Config{
+			Certificates: []tls.Certificate{serverCert},
+			ClientCAs:    serverPool,
+			ClientAuth:   tls.VerifyClientCertIfGiven,
+		}))
+	})
+
+	invoke := func(certs []tls.Certificate) string {
+		rawconn, err := net.Dial("tcp", lis.Addr().String())
+		assert.NoError(t, err)
+		tconn, err := Client(ctx, rawconn, &tls.Config{
+			Certificates: certs,
+			RootCAs:      clientPool,
+			ServerName:   "server",
+		})
+		assert.NoError(t, err)
+
+		conn := drpcconn.New(tconn)
+		defer func() { _ = conn.Close() }()
+
+		in, out := []byte("data"), []byte(nil)
+		assert.NoError(t, conn.Invoke(ctx, "/rpc", drpctest.ByteEncoding{}, &in, &out))
+		return string(out)
+	}
+
+	assert.Equal(t, invoke([]tls.Certificate{clientCert}), "client client")
+	assert.Equal(t, invoke(nil), "none")
+}
+
+type handlerFunc func(stream drpc.Stream, rpc string) error
+
+func (fn handlerFunc) HandleRPC(stream drpc.Stream, rpc string) error { return fn(stream, rpc) }
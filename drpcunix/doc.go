@@ -0,0 +1,6 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+// Package drpcunix provides helpers to serve and dial drpc over Unix domain
+// sockets.
+package drpcunix
@@ -0,0 +1,145 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcunix
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+
+	"github.com/zeebo/errs"
+)
+
+// Error wraps all of the errors returned by this package.
+var Error = errs.Class("drpcunix")
+
+// ListenOptions controls configuration settings for Listen.
+type ListenOptions struct {
+	// RemoveStale causes a socket file left behind at the path by a process
+	// that is no longer listening on it, for example after a crash, to be
+	// removed before listening. Files that are not sockets, and sockets that
+	// are still being listened on, are never removed.
+	RemoveStale bool
+
+	// Mode, if not zero, is set as the permissions of the socket file. The
+	// socket is created in a private directory and only linked to the path
+	// once it has the permissions, so no one can connect to it before then.
+	Mode os.FileMode
+}
+
+// Listen listens on the Unix domain socket at path. The returned listener can
+// be passed to Server.Serve.
+func Listen(path string) (net.Listener, error) {
+	return ListenWithOptions(path, ListenOptions{})
+}
+
+// ListenWithOptions is like Listen but uses the provided options.
+func ListenWithOptions(path string, opts ListenOptions) (net.Listener, error) {
+	if opts.RemoveStale {
+		if err := removeStale(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.Mode != 0 {
+		return listenMode(path, opts.Mode)
+	}
+
+	lis, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, Error.Wrap(err)
+	}
+	return lis, nil
+}
+
+// listenMode listens on a socket in a new directory that only the current user
+// can access, sets its permissions to mode, and then links it to path.
+func listenMode(path string, mode os.FileMode) (_ net.Listener, err error) {
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".drpc")
+	if err != nil {
+		return nil, Error.Wrap(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	tmp := filepath.Join(dir, "s")
+	lis, err := net.ListenUnix("unix", &net.UnixAddr{Name: tmp, Net: "unix"})
+	if err != nil {
+		return nil, Error.Wrap(err)
+	}
+	lis.SetUnlinkOnClose(false)
+
+	if err := os.Chmod(tmp, mode); err != nil {
+		_ = lis.Close()
+		return nil, Error.Wrap(err)
+	}
+
+	// unlike a rename, linking fails instead of replacing an existing file.
+	if err := os.Link(tmp, path); err != nil {
+		_ = lis.Close()
+		return nil, Error.Wrap(err)
+	}
+
+	return &listener{UnixListener: lis, path: path}, nil
+}
+
+// listener removes the socket file at path when it is closed, like the
+// listeners returned by net.Listen do. It reports path as its address rather
+// than the temporary path the socket was created at.
+type listener struct {
+	*net.UnixListener
+	path string
+	once sync.Once
+}
+
+// Addr returns the address of the socket file at path.
+func (l *listener) Addr() net.Addr {
+	return &net.UnixAddr{Name: l.path, Net: "unix"}
+}
+
+// Close closes the listener and removes the socket file.
+func (l *listener) Close() error {
+	err := l.UnixListener.Close()
+	l.once.Do(func() { _ = os.Remove(l.path) })
+	return err
+}
+
+// Dial connects to the Unix domain socket at path. The returned conn can be
+// used as the transport of a drpcconn.Conn.
+func Dial(ctx context.Context, path string) (net.Conn, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", path)
+	if err != nil {
+		return nil, Error.Wrap(err)
+	}
+	return conn, nil
+}
+
+// removeStale removes the socket file at path if nothing is listening on it.
+func removeStale(path string) error {
+	fi, err := os.Lstat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return Error.Wrap(err)
+	} else if fi.Mode()&os.ModeSocket == 0 {
+		return Error.New("%s exists and is not a socket", path)
+	}
+
+	conn, err := net.Dial("unix", path)
+	if err == nil {
+		_ = conn.Close()
+		return Error.New("%s is in use", path)
+	} else if !errors.Is(err, syscall.ECONNREFUSED) {
+		return Error.Wrap(err)
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return Error.Wrap(err)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcunix
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/zeebo/assert"
+
+	"storj.io/drpc"
+	"storj.io/drpc/drpcconn"
+	"storj.io/drpc/drpcserver"
+	"storj.io/drpc/drpctest"
+)
+
+type echoHandler struct{}
+
+func (echoHandler) HandleRPC(stream drpc.Stream, rpc string) error {
+	var in []byte
+	if err := stream.MsgRecv(&in, drpctest.ByteEncoding{}); err != nil {
+		return err
+	}
+	return stream.MsgSend(&in, drpctest.ByteEncoding{})
+}
+
+func TestRoundTrip(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	path := filepath.Join(t.TempDir(), "svc.sock")
+	lis, err := ListenWithOptions(path, ListenOptions{Mode: 0600})
+	assert.NoError(t, err)
+
+	fi, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, fi.Mode().Perm(), os.FileMode(0600))
+	assert.Equal(t, lis.Addr().String(), path)
+
+	srv := drpcserver.New(echoHandler{})
+	ctx.Run(func(ctx context.Context) { _ = srv.Serve(ctx, lis) })
+
+	rawconn, err := Dial(ctx, path)
+	assert.NoError(t, err)
+	conn := drpcconn.New(rawconn)
+	defer func() { _ = conn.Close() }()
+
+	in, out := []byte("data"), []byte(nil)
+	assert.NoError(t, conn.Invoke(ctx, "/rpc", drpctest.ByteEncoding{}, &in, &out))
+	assert.Equal(t, string(out), "data")
+}
+
+func TestRemoveStale(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "svc.sock")
+
+	// leave a socket file behind as if the process crashed.
+	lis, err := Listen(path)
+	assert.NoError(t, err)
+	lis.(*net.UnixListener).SetUnlinkOnClose(false)
+	assert.NoError(t, lis.Close())
+
+	{ // the stale file can not be listened on without removing it
+		_, err := Listen(path)
+		assert.Error(t, err)
+	}
+
+	{ // removing the stale file allows listening
+		lis, err := ListenWithOptions(path, ListenOptions{RemoveStale: true})
+		assert.NoError(t, err)
+		defer func() { _ = lis.Close() }()
+
+		// a socket that is being listened on is not removed
+		_, err = ListenWithOptions(path, ListenOptions{RemoveStale: true})
+		assert.Error(t, err)
+	}
+
+	{ // files that are not sockets are not removed
+		path := filepath.Join(dir, "file")
+		assert.NoError(t, os.WriteFile(path, nil, 0600))
+
+		_, err := ListenWithOptions(path, ListenOptions{RemoveStale: true})
+		assert.Error(t, err)
+		_, err = os.Stat(path)
+		assert.NoError(t, err)
+	}
+}
+
+func TestListenMode(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "svc.sock")
+
+	lis, err := ListenWithOptions(path, ListenOptions{Mode: 0600})
+	assert.NoError(t, err)
+
+	{ // the private directory the socket was created in is removed
+		entries, err := os.ReadDir(dir)
+		assert.NoError(t, err)
+		assert.Equal(t, len(entries), 1)
+		assert.Equal(t, entries[0].Name(), "svc.sock")
+	}
+
+	{ // an existing file is not replaced
+		_, err := ListenWithOptions(path, ListenOptions{Mode: 0600})
+		assert.Error(t, err)
+	}
+
+	// closing the listener removes the socket file
+	assert.NoError(t, lis.Close())
+	_, err = os.Stat(path)
+	assert.That(t, os.IsNotExist(err))
+}
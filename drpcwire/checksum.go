@@ -0,0 +1,61 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcwire
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"sync/atomic"
+
+	"storj.io/drpc"
+)
+
+// castagnoli is the table for the CRC-32C sent in KindChecksum frames.
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// frameChecksum returns the CRC-32C of the marshaled form of the frame.
+func frameChecksum(fr Frame) uint32 {
+	var hdr [maxFrameOverhead]byte
+	sum := crc32.Checksum(appendFrameHeader(hdr[:0], fr), castagnoli)
+	return crc32.Update(sum, castagnoli, fr.Data)
+}
+
+// appendChecksumFrame appends a KindChecksum frame for the frame to buf.
+func appendChecksumFrame(buf []byte, fr Frame) []byte {
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], frameChecksum(fr))
+	return AppendFrame(buf, Frame{Data: sum[:], Kind: KindChecksum, Done: true, Control: true})
+}
+
+// EnableChecksums causes the writer to send a KindChecksum frame before every
+// frame it writes from now on. It should only be called once the remote has
+// announced FeatureChecksum, and it cannot be undone, because the remote
+// expects a checksum before every frame once it has read one.
+func (b *Writer) EnableChecksums() {
+	atomic.StoreUint32(&b.checksum, 1)
+}
+
+// checkFrame validates the raw bytes of the frame against the checksum read
+// before it, if any. It returns true if the frame is itself a checksum, in
+// which case it should be skipped.
+func (r *Reader) checkFrame(fr Frame, raw []byte) (skip bool, err error) {
+	if fr.Kind == KindChecksum && fr.ID == (ID{}) {
+		if len(fr.Data) != 4 || r.sum.set {
+			return false, drpc.ProtocolError.New("invalid checksum frame")
+		}
+		r.sum.value, r.sum.set, r.sum.required = binary.BigEndian.Uint32(fr.Data), true, true
+		return true, nil
+	}
+
+	switch {
+	case r.sum.set:
+		r.sum.set = false
+		if crc32.Checksum(raw, castagnoli) != r.sum.value {
+			return false, drpc.ProtocolError.New("frame checksum mismatch (fr:%v)", fr)
+		}
+	case r.sum.required:
+		return false, drpc.ProtocolError.New("frame without checksum (fr:%v)", fr)
+	}
+	return false, nil
+}
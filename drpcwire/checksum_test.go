@@ -0,0 +1,81 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcwire
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/zeebo/assert"
+
+	"storj.io/drpc"
+)
+
+func TestChecksum(t *testing.T) {
+	pkt := func(message uint64, data string) Packet {
+		return Packet{
+			Data: []byte(data),
+			ID:   ID{Stream: 1, Message: message},
+			Kind: KindMessage,
+		}
+	}
+
+	write := func(plain Packet, summed ...Packet) []byte {
+		var buf bytes.Buffer
+		wr := NewWriter(&buf, 0)
+		assert.NoError(t, wr.WritePacket(plain))
+		wr.EnableChecksums()
+		for _, pkt := range summed {
+			assert.NoError(t, wr.WritePacket(pkt))
+		}
+		assert.NoError(t, wr.Flush())
+		return buf.Bytes()
+	}
+
+	t.Run("Round Trip", func(t *testing.T) {
+		data := write(pkt(1, "before"), pkt(2, "hello"), pkt(3, "world"))
+
+		rd := NewReader(bytes.NewReader(data))
+		for _, exp := range []Packet{pkt(1, "before"), pkt(2, "hello"), pkt(3, "world")} {
+			got, err := rd.ReadPacket()
+			assert.NoError(t, err)
+			assert.Equal(t, got, exp)
+		}
+	})
+
+	t.Run("Corrupted", func(t *testing.T) {
+		data := write(pkt(1, "before"), pkt(2, "hello"))
+
+		// flip a byte of the payload of the checksummed frame.
+		idx := bytes.Index(data, []byte("hello"))
+		assert.That(t, idx >= 0)
+		data[idx] ^= 0x01
+
+		rd := NewReader(bytes.NewReader(data))
+		_, err := rd.ReadPacket()
+		assert.NoError(t, err)
+		_, err = rd.ReadPacket()
+		assert.Error(t, err)
+		assert.That(t, drpc.ProtocolError.Has(err))
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		data := write(pkt(1, "before"), pkt(2, "hello"))
+		data = AppendFrame(data, Frame{
+			Data: []byte("world"),
+			ID:   ID{Stream: 1, Message: 3},
+			Kind: KindMessage,
+			Done: true,
+		})
+
+		rd := NewReader(bytes.NewReader(data))
+		for i := 0; i < 2; i++ {
+			_, err := rd.ReadPacket()
+			assert.NoError(t, err)
+		}
+		_, err := rd.ReadPacket()
+		assert.Error(t, err)
+		assert.That(t, drpc.ProtocolError.Has(err))
+	})
+}
@@ -0,0 +1,98 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcwire
+
+import (
+	"storj.io/drpc"
+)
+
+// ProtocolVersion is the version of the wire protocol spoken by this package.
+// Remotes that do not send a KindHello are assumed to speak version 0.
+const ProtocolVersion = 1
+
+// Features is a set of optional protocol features a side supports.
+type Features uint64
+
+// These are the features that may be announced in a Hello. Remotes may
+// announce features that are not listed here, and they are ignored.
+const (
+	// FeatureCompression is set if KindCompressedMessage is supported.
+	FeatureCompression Features = 1 << iota
+
+	// FeatureWindow is set if KindWindow is supported.
+	FeatureWindow
+
+	// FeatureTrailer is set if KindTrailer is supported.
+	FeatureTrailer
+
+	// FeatureHeader is set if KindHeader is supported.
+	FeatureHeader
+
+	// FeatureKeepalive is set if KindPing is answered with a KindPong that
+	// has the same body.
+	FeatureKeepalive
+
+	// FeatureGoAway is set if KindGoAway is supported.
+	FeatureGoAway
+
+	// FeatureChecksum is set if the side wants KindChecksum frames to be sent
+	// before every frame. It is not part of SupportedFeatures because it adds
+	// overhead, so it is only announced by managers configured to use it.
+	FeatureChecksum
+)
+
+// SupportedFeatures is every feature supported by this package that is
+// announced by default.
+const SupportedFeatures = FeatureCompression | FeatureWindow | FeatureTrailer |
+	FeatureHeader | FeatureKeepalive | FeatureGoAway
+
+// Has returns true if every feature in x is in f.
+func (f Features) Has(x Features) bool { return f&x == x }
+
+// Hello is the protocol version and features announced by a KindHello.
+type Hello struct {
+	// Version is the protocol version.
+	Version uint64
+
+	// Features are the supported features.
+	Features Features
+}
+
+// LocalHello returns the Hello describing this package.
+func LocalHello() Hello {
+	return Hello{Version: ProtocolVersion, Features: SupportedFeatures}
+}
+
+// Negotiate returns the Hello that both h and the remote support: the lower of
+// the versions and the features they have in common.
+func (h Hello) Negotiate(remote Hello) Hello {
+	if remote.Version < h.Version {
+		h.Version = remote.Version
+	}
+	h.Features &= remote.Features
+	return h
+}
+
+// AppendHello appends the body of a KindHello for the hello to buf.
+func AppendHello(buf []byte, h Hello) []byte {
+	buf = AppendVarint(buf, h.Version)
+	return AppendVarint(buf, uint64(h.Features))
+}
+
+// ParseHello parses the body of a KindHello. Missing fields are zero and any
+// data after the known fields is ignored so that later versions may add to it.
+func ParseHello(data []byte) (h Hello, err error) {
+	var features uint64
+	for _, field := range []*uint64{&h.Version, &features} {
+		var ok bool
+		data, *field, ok, err = ReadVarint(data)
+		if err != nil {
+			return Hello{}, drpc.ProtocolError.New("invalid hello: %v", err)
+		} else if !ok {
+			break
+		}
+	}
+	h.Features = Features(features)
+	return h, nil
+}
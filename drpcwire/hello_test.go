@@ -0,0 +1,51 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcwire
+
+import (
+	"testing"
+
+	"github.com/zeebo/assert"
+)
+
+func TestHello(t *testing.T) {
+	t.Run("Round Trip", func(t *testing.T) {
+		h := Hello{Version: 1 << 40, Features: SupportedFeatures | 1<<63}
+		got, err := ParseHello(AppendHello(nil, h))
+		assert.NoError(t, err)
+		assert.Equal(t, got, h)
+	})
+
+	t.Run("Forward Compatible", func(t *testing.T) {
+		// later versions may add fields after the features.
+		data := AppendVarint(AppendHello(nil, LocalHello()), 12345)
+		got, err := ParseHello(data)
+		assert.NoError(t, err)
+		assert.Equal(t, got, LocalHello())
+
+		// and missing fields are zero.
+		got, err = ParseHello(AppendVarint(nil, 7))
+		assert.NoError(t, err)
+		assert.Equal(t, got, Hello{Version: 7})
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := ParseHello([]byte{255, 255, 255, 255, 255, 255, 255, 255, 255, 255})
+		assert.Error(t, err)
+	})
+
+	t.Run("Negotiate", func(t *testing.T) {
+		local := LocalHello()
+
+		// unknown features and later versions are ignored.
+		future := Hello{Version: 99, Features: SupportedFeatures | 1<<50}
+		assert.Equal(t, local.Negotiate(future), local)
+
+		// an older remote limits both.
+		older := Hello{Version: 0, Features: FeatureCompression}
+		assert.Equal(t, local.Negotiate(older), older)
+		assert.That(t, local.Negotiate(older).Features.Has(FeatureCompression))
+		assert.That(t, !local.Negotiate(older).Features.Has(FeatureWindow))
+	})
+}
@@ -36,6 +36,57 @@ const (
 
 	// KindInvokeMetadata includes metadata about the next Invoke packet.
 	KindInvokeMetadata Kind = 7
+
+	// KindCompressedMessage is used to send messages. The body is an encoded
+	// message that has been compressed.
+	KindCompressedMessage Kind = 8
+
+	// KindPing is sent to check that the remote is still alive. It is not part
+	// of any stream, so it always has a stream id of zero. Its body, if any,
+	// identifies the ping.
+	KindPing Kind = 9
+
+	// KindPong is sent in response to a KindPing. Like KindPing, it has a
+	// stream id of zero, and its body is the body of the KindPing.
+	KindPong Kind = 10
+
+	// KindWindow is sent by the receiver of messages on a stream to allow the
+	// remote to send more of them. The body is a varint of the total number of
+	// message bytes the remote may have sent on the stream.
+	KindWindow Kind = 11
+
+	// KindTrailer is sent before the Error or CloseSend that ends the sending
+	// side of a stream. The body is metadata encoded like the body of an
+	// InvokeMetadata, and it is always sent as a control packet so that
+	// remotes that do not support it ignore it.
+	KindTrailer Kind = 12
+
+	// KindHeader is sent by a server before the first message of a stream.
+	// Like KindTrailer, the body is encoded metadata and it is always sent as
+	// a control packet.
+	KindHeader Kind = 13
+
+	// KindGoAway is sent by a server that wants the client to stop using the
+	// connection, for example because it has been open for too long. Like
+	// KindPing, it has a stream id of zero. It has no body. The client finishes
+	// any active stream and then closes the connection. Clients using a
+	// version of drpc that does not support it close the connection with a
+	// protocol error instead.
+	KindGoAway Kind = 14
+
+	// KindHello announces the protocol version and features a side supports.
+	// Like KindPing, it has a stream id of zero. The body is a Hello. Clients
+	// announce theirs in the metadata of an invoke instead, with the key
+	// drpcmetadata.HelloKey, and servers answer with a KindHello, so that it is
+	// only ever sent to remotes using a version of drpc that supports it.
+	KindHello Kind = 15
+
+	// KindChecksum carries the CRC-32C, with the Castagnoli polynomial, of the
+	// raw bytes of the frame that follows it, as 4 big-endian bytes. Like
+	// KindPing, it has a stream id of zero. It is only sent to remotes that
+	// announced FeatureChecksum, and once a Reader has read one, every later
+	// frame must have one.
+	KindChecksum Kind = 16
 )
 
 //
@@ -126,6 +177,12 @@ bad:
 
 // AppendFrame appends a marshaled form of the frame to the provided buffer.
 func AppendFrame(buf []byte, fr Frame) []byte {
+	return append(appendFrameHeader(buf, fr), fr.Data...)
+}
+
+// appendFrameHeader appends the marshaled form of the frame without its data
+// to the provided buffer.
+func appendFrameHeader(buf []byte, fr Frame) []byte {
 	control := byte(fr.Kind << 1)
 	if fr.Done {
 		control |= 0b00000001
@@ -139,7 +196,6 @@ func AppendFrame(buf []byte, fr Frame) []byte {
 	out = AppendVarint(out, fr.ID.Stream)
 	out = AppendVarint(out, fr.ID.Message)
 	out = AppendVarint(out, uint64(len(fr.Data)))
-	out = append(out, fr.Data...)
 	return out
 }
 
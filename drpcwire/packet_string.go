@@ -15,11 +15,20 @@ func _() {
 	_ = x[KindClose-5]
 	_ = x[KindCloseSend-6]
 	_ = x[KindInvokeMetadata-7]
+	_ = x[KindCompressedMessage-8]
+	_ = x[KindPing-9]
+	_ = x[KindPong-10]
+	_ = x[KindWindow-11]
+	_ = x[KindTrailer-12]
+	_ = x[KindHeader-13]
+	_ = x[KindGoAway-14]
+	_ = x[KindHello-15]
+	_ = x[KindChecksum-16]
 }
 
-const _Kind_name = "InvokeMessageErrorCancelCloseCloseSendInvokeMetadata"
+const _Kind_name = "InvokeMessageErrorCancelCloseCloseSendInvokeMetadataCompressedMessagePingPongWindowTrailerHeaderGoAwayHelloChecksum"
 
-var _Kind_index = [...]uint8{0, 6, 13, 18, 24, 29, 38, 52}
+var _Kind_index = [...]uint8{0, 6, 13, 18, 24, 29, 38, 52, 69, 73, 77, 83, 90, 96, 102, 107, 115}
 
 func (i Kind) String() string {
 	i -= 1
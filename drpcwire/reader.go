@@ -7,13 +7,26 @@ import (
 	"io"
 
 	"storj.io/drpc"
+	"storj.io/drpc/drpcstats"
 )
 
 // ReaderOptions controls configuration settings for a reader.
 type ReaderOptions struct {
 	// MaximumBufferSize controls the maximum size of buffered
-	// packet data.
+	// packet data. Any packet with more data causes a ProtocolError,
+	// including packets that were split across many smaller frames.
+	// Frames that declare more data than this in their header are
+	// rejected before any of the data is read.
 	MaximumBufferSize int
+
+	// BufferSize is the smallest amount of space the Reader provides to each
+	// Read call on the io.Reader. Larger sizes allow more frames to be read
+	// with each call. Zero means 4KiB.
+	BufferSize int
+
+	// Stats, if not nil, counts the bytes read from the io.Reader and the
+	// message packets read.
+	Stats *drpcstats.Stats
 }
 
 // Reader reconstructs packets from frames read from an io.Reader.
@@ -24,6 +37,14 @@ type Reader struct {
 	buf  []byte
 	id   ID
 	rerr error
+	keep []Packet
+	kept int // the amount of data in keep
+
+	sum struct {
+		value    uint32 // the checksum of the next frame
+		set      bool   // set if value has been read for the next frame
+		required bool   // set once the remote has sent a checksum
+	}
 }
 
 // A frame adds at most this many bytes of overhead to some data by prefixing
@@ -46,6 +67,9 @@ func NewReaderWithOptions(r io.Reader, opts ReaderOptions) *Reader {
 	if opts.MaximumBufferSize == 0 {
 		opts.MaximumBufferSize = 4 << 20 // Default to 4MiB.
 	}
+	if opts.BufferSize == 0 {
+		opts.BufferSize = 4096
+	}
 
 	return &Reader{
 		opts: opts,
@@ -66,6 +90,7 @@ func (r *Reader) read(p []byte) (n int, err error) {
 			return 0, err
 		}
 		n, r.rerr = r.r.Read(p)
+		r.opts.Stats.AddRead(uint64(n))
 		if n > 0 {
 			return n, nil
 		}
@@ -85,27 +110,47 @@ func (r *Reader) ReadPacket() (pkt Packet, err error) {
 // If the amount of data in the Packet becomes too large, an error is
 // returned. The returned packet's Data field is constructed by appending
 // to the provided buf after it has been resliced to be zero length.
+//
+// Ping, pong, go away and hello frames are not part of any stream and so are
+// exempt from the monotonicity requirement. Checksum frames are used to
+// validate the frame after them and are never returned. If any are read while a packet is
+// being reconstructed, they are returned after that packet with duplicates
+// removed. Their data counts toward the MaximumBufferSize of that packet.
 func (r *Reader) ReadPacketUsing(buf []byte) (pkt Packet, err error) {
 	pkt.Data = buf[:0]
 
+	if len(r.keep) > 0 {
+		kept := r.keep[0]
+		r.keep, r.kept = r.keep[1:], r.kept-len(kept.Data)
+		kept.Data = append(pkt.Data, kept.Data...)
+		return kept, nil
+	}
+
 	var fr Frame
 	var ok bool
 
 	for {
+		prev := r.curr
 		r.curr, fr, ok, err = ParseFrame(r.curr)
 		switch {
 		case err != nil:
 			return Packet{}, drpc.ProtocolError.Wrap(err)
 
 		case !ok:
+			// if the header of the frame is available, we can reject it
+			// before buffering any data if it is too large.
+			if length, ok := frameLength(r.curr); ok && length > uint64(r.opts.MaximumBufferSize) {
+				return Packet{}, drpc.ProtocolError.New("data overflow (len:%v)", length)
+			}
+
 			// r.curr doesn't have enough data for a full frame, so prepend
 			// it to the read buffer if it is in the appropriate state.
 			if len(r.buf) == 0 {
 				r.buf = append(r.buf[:0], r.curr...)
 			}
 
-			if cap(r.buf)-len(r.buf) < 4096 {
-				nbuf := make([]byte, len(r.buf), 2*cap(r.buf)+4096)
+			if cap(r.buf)-len(r.buf) < r.opts.BufferSize {
+				nbuf := make([]byte, len(r.buf), 2*cap(r.buf)+r.opts.BufferSize)
 				copy(nbuf, r.buf)
 				r.buf = nbuf
 			}
@@ -135,6 +180,32 @@ func (r *Reader) ReadPacketUsing(buf []byte) (pkt Packet, err error) {
 			r.buf = r.buf[:0]
 		}
 
+		if skip, err := r.checkFrame(fr, prev[:len(prev)-len(r.curr)]); err != nil {
+			return Packet{}, err
+		} else if skip {
+			continue
+		}
+
+		if isConnFrame(fr) {
+			if pkt.ID == (ID{}) {
+				data := append(pkt.Data[:0], fr.Data...)
+				return Packet{Data: data, Kind: fr.Kind, Control: true}, nil
+			}
+			if containsFrame(r.keep, fr) {
+				continue
+			}
+			if len(pkt.Data)+r.kept+len(fr.Data) > r.opts.MaximumBufferSize {
+				return Packet{}, drpc.ProtocolError.New("data overflow (len:%v)", len(pkt.Data)+r.kept+len(fr.Data))
+			}
+			r.keep = append(r.keep, Packet{
+				Data:    append([]byte(nil), fr.Data...),
+				Kind:    fr.Kind,
+				Control: true,
+			})
+			r.kept += len(fr.Data)
+			continue
+		}
+
 		// If any frames are set to control, then the whole packet is
 		// considered to be control.
 		pkt.Control = pkt.Control || fr.Control
@@ -157,17 +228,70 @@ func (r *Reader) ReadPacketUsing(buf []byte) (pkt Packet, err error) {
 			return Packet{}, drpc.ProtocolError.New("packet kind change (fr:%v pkt:%v)", fr.Kind, pkt.Kind)
 		}
 
-		pkt.Data = append(pkt.Data, fr.Data...)
+		if len(pkt.Data)+r.kept+len(fr.Data) > r.opts.MaximumBufferSize {
+			return Packet{}, drpc.ProtocolError.New("data overflow (len:%v)", len(pkt.Data)+r.kept+len(fr.Data))
+		}
 
-		switch {
-		case len(pkt.Data) > r.opts.MaximumBufferSize:
-			return Packet{}, drpc.ProtocolError.New("data overflow (len:%v)", len(pkt.Data))
+		pkt.Data = append(pkt.Data, fr.Data...)
 
-		case fr.Done:
+		if fr.Done {
 			// increment the message id so that we do not accept any frames
 			// with the same id.
 			r.id.Message++
+			if isMessage(pkt.Kind) {
+				r.opts.Stats.AddMessagesRead(1)
+			}
 			return pkt, nil
 		}
 	}
 }
+
+// isMessage returns true if the kind is used to send messages.
+func isMessage(kind Kind) bool {
+	return kind == KindMessage || kind == KindCompressedMessage
+}
+
+// isConnFrame returns true if the frame is a ping, pong, go away or hello,
+// which are about the whole connection rather than any stream.
+func isConnFrame(fr Frame) bool {
+	if fr.ID.Stream != 0 {
+		return false
+	}
+	switch fr.Kind {
+	case KindPing, KindPong, KindGoAway, KindHello:
+		return true
+	}
+	return false
+}
+
+// containsFrame returns true if a packet with the kind and data of the frame is
+// in pkts.
+func containsFrame(pkts []Packet, fr Frame) bool {
+	for _, pkt := range pkts {
+		if pkt.Kind == fr.Kind && string(pkt.Data) == string(fr.Data) {
+			return true
+		}
+	}
+	return false
+}
+
+// frameLength returns the length of the data declared by the header of the
+// frame at the beginning of buf. It returns false if buf does not contain the
+// full header.
+func frameLength(buf []byte) (length uint64, ok bool) {
+	if len(buf) < 1 {
+		return 0, false
+	}
+
+	var err error
+	rem := buf[1:]
+	for i := 0; i < 2; i++ {
+		rem, _, ok, err = ReadVarint(rem)
+		if !ok || err != nil {
+			return 0, false
+		}
+	}
+
+	_, length, ok, err = ReadVarint(rem)
+	return length, ok && err == nil
+}
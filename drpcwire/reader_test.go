@@ -13,6 +13,8 @@ import (
 	"time"
 
 	"github.com/zeebo/assert"
+
+	"storj.io/drpc"
 )
 
 func TestReader(t *testing.T) {
@@ -154,6 +156,49 @@ func TestReader(t *testing.T) {
 			Frames: []Frame{{ID: ID{Stream: 0, Message: 1}}},
 			Error:  "id monotonicity violation",
 		},
+
+		{ // pings and pongs are returned after any packet being reconstructed
+			Packets: []Packet{
+				{Kind: KindPing, Control: true},
+				p(KindMessage, 1, false, "ab"),
+				{Kind: KindPing, Control: true},
+				{Kind: KindPong, Control: true},
+			},
+			Frames: []Frame{
+				{Kind: KindPing, Done: true},
+				f(KindMessage, 1, "a", false, false),
+				{Kind: KindPing, Done: true},
+				{Kind: KindPong, Done: true},
+				{Kind: KindPing, Done: true},
+				f(KindMessage, 1, "b", true, false),
+			},
+		},
+
+		{ // the data of frames returned after a packet is kept
+			Packets: []Packet{
+				p(KindMessage, 1, false, "ab"),
+				{Kind: KindHello, Data: []byte("hello"), Control: true},
+				{Kind: KindPing, Data: []byte("1"), Control: true},
+				{Kind: KindPing, Data: []byte("2"), Control: true},
+			},
+			Frames: []Frame{
+				f(KindMessage, 1, "a", false, false),
+				{Kind: KindHello, Data: []byte("hello"), Done: true},
+				{Kind: KindPing, Data: []byte("1"), Done: true},
+				{Kind: KindPing, Data: []byte("2"), Done: true},
+				{Kind: KindPing, Data: []byte("1"), Done: true},
+				f(KindMessage, 1, "b", true, false),
+			},
+		},
+
+		{ // the data of frames returned after a packet counts toward its size
+			Options: ReaderOptions{MaximumBufferSize: 4},
+			Frames: []Frame{
+				f(KindMessage, 1, "ab", false, false),
+				{Kind: KindHello, Data: []byte("abc"), Done: true},
+			},
+			Error: "data overflow",
+		},
 	}
 
 	for _, tc := range cases {
@@ -259,6 +304,23 @@ func TestReaderErrorWithData(t *testing.T) {
 	assert.Equal(t, err, io.EOF)
 }
 
+func TestReaderOverflowHeader(t *testing.T) {
+	var reads int
+	r := NewReaderWithOptions(readerFunc(func(b []byte) (int, error) {
+		reads++
+		out := append(b[:0], byte(KindMessage<<1)|1)
+		out = AppendVarint(out, 1)
+		out = AppendVarint(out, 1)
+		out = AppendVarint(out, 1<<40)
+		return len(out), nil
+	}), ReaderOptions{MaximumBufferSize: 1000})
+
+	_, err := r.ReadPacket()
+	assert.That(t, drpc.ProtocolError.Has(err))
+	assert.That(t, strings.Contains(err.Error(), "data overflow"))
+	assert.Equal(t, reads, 1)
+}
+
 func TestReaderErrorNoProgress(t *testing.T) {
 	r := NewReader(readerFunc(func(b []byte) (int, error) {
 		return 0, nil
@@ -267,3 +329,21 @@ func TestReaderErrorNoProgress(t *testing.T) {
 	_, err := r.ReadPacket()
 	assert.That(t, errors.Is(err, io.ErrNoProgress))
 }
+
+func TestReaderBufferSize(t *testing.T) {
+	for _, size := range []int{0, 64 << 10} {
+		var got int
+		r := NewReaderWithOptions(readerFunc(func(b []byte) (int, error) {
+			got = len(b)
+			return 0, io.EOF
+		}), ReaderOptions{BufferSize: size})
+
+		_, err := r.ReadPacket()
+		assert.Equal(t, err, io.EOF)
+
+		if size == 0 {
+			size = 4096
+		}
+		assert.That(t, got >= size)
+	}
+}
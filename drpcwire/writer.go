@@ -10,32 +10,79 @@ import (
 	"sync/atomic"
 
 	"storj.io/drpc/drpcdebug"
+	"storj.io/drpc/drpcstats"
 )
 
 //
 // Writer
 //
 
+// WriterOptions controls configuration settings for a writer.
+type WriterOptions struct {
+	// Size is the amount of data the writer will attempt to buffer before
+	// sending it to the io.Writer. Zero means 4KiB.
+	Size int
+
+	// Stats, if not nil, counts the bytes written to the io.Writer and the
+	// message packets written.
+	Stats *drpcstats.Stats
+}
+
 // Writer is a helper to buffer and write packets and frames to an io.Writer.
 type Writer struct {
-	empty uint32
-	w     io.Writer
-	size  int
-	mu    sync.Mutex
-	buf   []byte
+	empty    uint32
+	checksum uint32 // set once checksums are enabled. atomic.
+	w        io.Writer
+	size     int
+	stats    *drpcstats.Stats
+	mu       sync.Mutex
+	buf      []byte
+	msgs     uint64 // number of messages completed in buf
 }
 
 // NewWriter returns a Writer that will attempt to buffer size data before
 // sending it to the io.Writer.
 func NewWriter(w io.Writer, size int) *Writer {
-	if size == 0 {
-		size = 4 * 1024
+	return NewWriterWithOptions(w, WriterOptions{Size: size})
+}
+
+// NewWriterWithOptions returns a Writer that uses the provided options to
+// manage buffering.
+func NewWriterWithOptions(w io.Writer, opts WriterOptions) *Writer {
+	if opts.Size == 0 {
+		opts.Size = 4 * 1024
 	}
 
 	return &Writer{
-		w:    w,
-		size: size,
-		buf:  make([]byte, 0, size),
+		w:     w,
+		size:  opts.Size,
+		stats: opts.Stats,
+		buf:   make([]byte, 0, opts.Size),
+	}
+}
+
+// write sends the buffered data to the io.Writer and clears the buffer.
+func (b *Writer) write() (err error) {
+	n, err := b.w.Write(b.buf)
+	b.stats.AddWritten(uint64(n))
+	if err == nil {
+		b.stats.AddMessagesWritten(b.msgs)
+	}
+	b.buf = b.buf[:0]
+	b.msgs = 0
+	atomic.StoreUint32(&b.empty, 0)
+	return err
+}
+
+// appendFrame appends the frame into the buffer, preceded by its checksum if
+// they are enabled, keeping track of it if it completes a message.
+func (b *Writer) appendFrame(fr Frame) {
+	if atomic.LoadUint32(&b.checksum) == 1 {
+		b.buf = appendChecksumFrame(b.buf, fr)
+	}
+	b.buf = AppendFrame(b.buf, fr)
+	if fr.Done && isMessage(fr.Kind) {
+		b.msgs++
 	}
 }
 
@@ -67,6 +114,7 @@ func (b *Writer) Reset() *Writer {
 	defer b.mu.Unlock()
 
 	b.buf = b.buf[:0]
+	b.msgs = 0
 	atomic.StoreUint32(&b.empty, 0)
 	return b
 }
@@ -80,16 +128,25 @@ func (b *Writer) WriteFrame(fr Frame) (err error) {
 	if len(b.buf) == 0 {
 		atomic.StoreUint32(&b.empty, 1)
 	}
-	b.buf = AppendFrame(b.buf, fr)
+	b.appendFrame(fr)
 	if len(b.buf) >= b.size {
 		b.log("FLUSH", func() string { return fmt.Sprintf("buffer: %d > %d", len(b.buf), b.size) })
-		_, err = b.w.Write(b.buf)
-		b.buf = b.buf[:0]
-		atomic.StoreUint32(&b.empty, 0)
+		err = b.write()
 	}
 	return err
 }
 
+// FlushFrame appends the frame into the buffer and flushes it. Unlike calling
+// WriteFrame and then Flush, a concurrent Reset cannot discard the frame.
+func (b *Writer) FlushFrame(fr Frame) (err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.appendFrame(fr)
+	b.log("FLUSH", func() string { return fmt.Sprintf("frame: %d", len(b.buf)) })
+	return b.write()
+}
+
 // Flush forces a flush of any buffered data to the io.Writer. It is a no-op if
 // there is no data in the buffer.
 func (b *Writer) Flush() (err error) {
@@ -97,10 +154,8 @@ func (b *Writer) Flush() (err error) {
 	defer b.mu.Unlock()
 
 	if len(b.buf) > 0 {
-		_, err = b.w.Write(b.buf)
 		b.log("FLUSH", func() string { return fmt.Sprintf("explicit: %d", len(b.buf)) })
-		b.buf = b.buf[:0]
-		atomic.StoreUint32(&b.empty, 0)
+		err = b.write()
 	}
 	return err
 }
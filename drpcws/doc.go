@@ -0,0 +1,10 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+// Package drpcws provides a drpc transport over WebSocket connections so that
+// rpcs can pass through proxies that only allow WebSocket traffic.
+//
+// The bytes drpc writes are sent in binary WebSocket messages, and reads
+// continue across message boundaries, so a message may hold any number of
+// drpc frames or part of one.
+package drpcws
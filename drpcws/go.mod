@@ -0,0 +1,14 @@
+module storj.io/drpc/drpcws
+
+go 1.25.0
+
+require (
+	github.com/coder/websocket v1.8.15
+	github.com/zeebo/assert v1.3.0
+	github.com/zeebo/errs v1.2.2
+	storj.io/drpc v0.0.0-00010101000000-000000000000
+)
+
+require google.golang.org/protobuf v1.27.1 // indirect
+
+replace storj.io/drpc => ..
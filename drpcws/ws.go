@@ -0,0 +1,179 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcws
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/zeebo/errs"
+)
+
+// Error wraps all of the errors returned by this package.
+var Error = errs.Class("drpcws")
+
+// Options controls configuration settings for WebSocket connections.
+type Options struct {
+	// PingInterval, if positive, is how often a WebSocket ping is sent on
+	// the connection. This is separate from the keepalive of the drpc
+	// manager, and keeps proxies from closing idle connections.
+	PingInterval time.Duration
+
+	// PingTimeout is how long to wait for the pong before closing the
+	// connection. Zero means the PingInterval.
+	PingTimeout time.Duration
+
+	// Dial is passed to the WebSocket library when dialing.
+	Dial *websocket.DialOptions
+
+	// Accept is passed to the WebSocket library when accepting.
+	Accept *websocket.AcceptOptions
+}
+
+// Dial connects to the WebSocket server at the url, which has a ws or wss
+// scheme. The returned conn can be used as the transport of a drpcconn.Conn.
+func Dial(ctx context.Context, url string) (net.Conn, error) {
+	return DialWithOptions(ctx, url, Options{})
+}
+
+// DialWithOptions is like Dial but uses the provided options.
+func DialWithOptions(ctx context.Context, url string, opts Options) (net.Conn, error) {
+	ws, _, err := websocket.Dial(ctx, url, opts.Dial)
+	if err != nil {
+		return nil, Error.Wrap(err)
+	}
+	return newConn(ws, opts), nil
+}
+
+// Listener is a net.Listener that accepts the WebSocket connections upgraded by
+// its ServeHTTP method, so it can be both registered as an http.Handler and
+// passed to Server.Serve.
+type Listener struct {
+	opts  Options
+	addr  net.Addr
+	conns chan net.Conn
+	once  sync.Once
+	done  chan struct{}
+}
+
+var _ net.Listener = (*Listener)(nil)
+var _ http.Handler = (*Listener)(nil)
+
+// NewListener returns a Listener that reports addr as its address.
+func NewListener(addr net.Addr) *Listener {
+	return NewListenerWithOptions(addr, Options{})
+}
+
+// NewListenerWithOptions is like NewListener but uses the provided options.
+func NewListenerWithOptions(addr net.Addr, opts Options) *Listener {
+	return &Listener{
+		opts:  opts,
+		addr:  addr,
+		conns: make(chan net.Conn),
+		done:  make(chan struct{}),
+	}
+}
+
+// ServeHTTP upgrades the request to a WebSocket connection and waits for it
+// to be returned by Accept.
+func (l *Listener) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	select {
+	case <-l.done:
+		http.Error(w, "listener closed", http.StatusServiceUnavailable)
+		return
+	default:
+	}
+
+	ws, err := websocket.Accept(w, r, l.opts.Accept)
+	if err != nil {
+		// Accept has already written an error response.
+		return
+	}
+
+	conn := newConn(ws, l.opts)
+	select {
+	case l.conns <- conn:
+	case <-l.done:
+		_ = conn.Close()
+	case <-r.Context().Done():
+		_ = conn.Close()
+	}
+}
+
+// Accept waits for and returns the next WebSocket connection.
+func (l *Listener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close causes any blocked Accept calls to return and any later upgrades to
+// be refused. Connections that were already accepted are not closed.
+func (l *Listener) Close() error {
+	l.once.Do(func() { close(l.done) })
+	return nil
+}
+
+// Addr returns the address that the listener was created with.
+func (l *Listener) Addr() net.Addr { return l.addr }
+
+// conn is a net.Conn over a WebSocket connection that sends pings.
+type conn struct {
+	net.Conn
+	ws   *websocket.Conn
+	once sync.Once
+	done chan struct{}
+}
+
+func newConn(ws *websocket.Conn, opts Options) *conn {
+	c := &conn{
+		Conn: websocket.NetConn(context.Background(), ws, websocket.MessageBinary),
+		ws:   ws,
+		done: make(chan struct{}),
+	}
+	if opts.PingInterval > 0 {
+		timeout := opts.PingTimeout
+		if timeout <= 0 {
+			timeout = opts.PingInterval
+		}
+		go c.ping(opts.PingInterval, timeout)
+	}
+	return c
+}
+
+// ping sends a ping every interval until the conn is closed, closing it if a
+// pong is not received within the timeout.
+func (c *conn) ping(interval, timeout time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-c.done:
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		err := c.ws.Ping(ctx)
+		cancel()
+		if err != nil {
+			_ = c.Close()
+			return
+		}
+	}
+}
+
+// Close closes the WebSocket connection.
+func (c *conn) Close() error {
+	c.once.Do(func() { close(c.done) })
+	return c.Conn.Close()
+}
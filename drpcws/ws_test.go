@@ -0,0 +1,123 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcws
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/zeebo/assert"
+
+	"storj.io/drpc"
+	"storj.io/drpc/drpcconn"
+	"storj.io/drpc/drpcserver"
+	"storj.io/drpc/drpctest"
+)
+
+// echoHandler sends back every message it receives.
+type echoHandler struct{}
+
+func (echoHandler) HandleRPC(stream drpc.Stream, rpc string) error {
+	for {
+		var msg []byte
+		if err := stream.MsgRecv(&msg, drpctest.ByteEncoding{}); errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := stream.MsgSend(&msg, drpctest.ByteEncoding{}); err != nil {
+			return err
+		}
+	}
+}
+
+// listen serves the listener over http and returns the url to dial it.
+func listen(t *testing.T, ctx *drpctest.Tracker, opts Options) (*Listener, string) {
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	lis := NewListenerWithOptions(tcp.Addr(), opts)
+	hs := &http.Server{Handler: lis}
+	ctx.Run(func(ctx context.Context) { _ = hs.Serve(tcp) })
+	ctx.Run(func(ctx context.Context) { <-ctx.Done(); _ = hs.Close() })
+
+	return lis, "ws://" + tcp.Addr().String()
+}
+
+func TestRoundTrip(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	opts := Options{PingInterval: 10 * time.Millisecond, PingTimeout: time.Minute}
+	lis, url := listen(t, ctx, opts)
+	ctx.Run(func(ctx context.Context) { _ = drpcserver.New(echoHandler{}).Serve(ctx, lis) })
+
+	tr, err := DialWithOptions(ctx, url, opts)
+	assert.NoError(t, err)
+	conn := drpcconn.New(tr)
+	defer func() { _ = conn.Close() }()
+
+	{ // messages larger than a websocket message round trip
+		in, out := bytes.Repeat([]byte("drpc"), 64<<10), []byte(nil)
+		assert.NoError(t, conn.Invoke(ctx, "/echo", drpctest.ByteEncoding{}, &in, &out))
+		assert.Equal(t, out, in)
+	}
+
+	// let some pings happen while the connection is idle.
+	time.Sleep(50 * time.Millisecond)
+
+	{ // streams round trip
+		stream, err := conn.NewStream(ctx, "/echo", drpctest.ByteEncoding{})
+		assert.NoError(t, err)
+		for _, value := range []string{"a", "b", "c"} {
+			in, out := []byte(value), []byte(nil)
+			assert.NoError(t, stream.MsgSend(&in, drpctest.ByteEncoding{}))
+			assert.NoError(t, stream.MsgRecv(&out, drpctest.ByteEncoding{}))
+			assert.Equal(t, string(out), value)
+		}
+		assert.NoError(t, stream.CloseSend())
+		assert.NoError(t, stream.Close())
+	}
+}
+
+func TestPing(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	lis, url := listen(t, ctx, Options{PingInterval: 10 * time.Millisecond})
+
+	// the client never reads, so it never answers the pings.
+	ws, _, err := websocket.Dial(ctx, url, nil)
+	assert.NoError(t, err)
+	defer func() { _ = ws.CloseNow() }()
+
+	conn, err := lis.Accept()
+	assert.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+
+	_, err = conn.Read(make([]byte, 1))
+	assert.Error(t, err)
+}
+
+func TestListener_Close(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	lis, url := listen(t, ctx, Options{})
+	assert.NoError(t, lis.Close())
+
+	_, err := lis.Accept()
+	assert.That(t, errors.Is(err, net.ErrClosed))
+
+	_, resp, err := websocket.Dial(ctx, url, nil)
+	assert.Error(t, err)
+	assert.Equal(t, resp.StatusCode, http.StatusServiceUnavailable)
+}
@@ -0,0 +1,127 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"storj.io/drpc"
+	"storj.io/drpc/drpcpipe"
+)
+
+type CountRequest struct{ To int }
+
+type CountResponse struct{ Value int }
+
+type jsonEncoding struct{}
+
+func (jsonEncoding) Marshal(msg drpc.Message) ([]byte, error)     { return json.Marshal(msg) }
+func (jsonEncoding) Unmarshal(buf []byte, msg drpc.Message) error { return json.Unmarshal(buf, msg) }
+
+// countHandler serves a server streaming rpc that sends every value up to the
+// one in the request.
+type countHandler struct{}
+
+func (countHandler) HandleRPC(stream drpc.Stream, rpc string) error {
+	ts := drpc.NewTypedStream[*CountResponse, *CountRequest](stream, jsonEncoding{})
+
+	req, err := ts.Recv()
+	if err != nil {
+		return err
+	}
+	for i := 1; i <= req.To; i++ {
+		if err := ts.Send(&CountResponse{Value: i}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func ExampleTypedStream() {
+	conn, cleanup := drpcpipe.New(countHandler{})
+	defer cleanup()
+
+	stream, err := conn.NewStream(context.Background(), "/Count", jsonEncoding{})
+	if err != nil {
+		panic(err)
+	}
+	ts := drpc.NewTypedStream[*CountRequest, *CountResponse](stream, jsonEncoding{})
+	defer func() { _ = ts.Close() }()
+
+	if err := ts.Send(&CountRequest{To: 3}); err != nil {
+		panic(err)
+	}
+	if err := ts.CloseSend(); err != nil {
+		panic(err)
+	}
+
+	for {
+		resp, err := ts.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			panic(err)
+		}
+		fmt.Println(resp.Value)
+	}
+
+	// Output:
+	// 1
+	// 2
+	// 3
+}
+
+type ListItemsRequest struct{ Count int }
+
+type Item struct{ Name string }
+
+func ExampleServerStreamHandler() {
+	handler := drpc.ServerStreamHandler[*ListItemsRequest, *Item]{
+		Encoding: jsonEncoding{},
+		Func: func(req *ListItemsRequest, stream drpc.SendStream[*Item]) error {
+			for i := 0; i < req.Count; i++ {
+				if err := stream.Send(&Item{Name: fmt.Sprintf("item-%d", i)}); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+
+	conn, cleanup := drpcpipe.New(handler)
+	defer cleanup()
+
+	stream, err := conn.NewStream(context.Background(), "/ListItems", jsonEncoding{})
+	if err != nil {
+		panic(err)
+	}
+	ts := drpc.NewTypedStream[*ListItemsRequest, *Item](stream, jsonEncoding{})
+	defer func() { _ = ts.Close() }()
+
+	if err := ts.Send(&ListItemsRequest{Count: 100}); err != nil {
+		panic(err)
+	}
+	if err := ts.CloseSend(); err != nil {
+		panic(err)
+	}
+
+	var items []*Item
+	for {
+		item, err := ts.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			panic(err)
+		}
+		items = append(items, item)
+	}
+	fmt.Println(len(items), items[0].Name, items[len(items)-1].Name)
+
+	// Output:
+	// 100 item-0 item-99
+}
@@ -32,7 +32,19 @@ func TestCompatibility(t *testing.T) {
 	}
 }
 
-func testCombination(t *testing.T, client, server string) {
+func TestCompatibility_Handshake(t *testing.T) {
+	// the old server ignores the option.
+	for _, server := range []string{"old", "new"} {
+		server := server
+		t.Run(fmt.Sprintf("new_client_%s_server", server), func(t *testing.T) {
+			testCombination(t, "./newservice", fmt.Sprintf("./%sservice", server), "handshake")
+		})
+	}
+}
+
+// testCombination runs the client against the server, passing any args to
+// both of them.
+func testCombination(t *testing.T, client, server string, args ...string) {
 	ctx := drpcctx.NewTracker(context.Background())
 	defer ctx.Wait()
 	defer ctx.Cancel()
@@ -42,7 +54,7 @@ func testCombination(t *testing.T, client, server string) {
 
 	// launch the server
 	ctx.Run(func(ctx context.Context) {
-		err := runTestServer(ctx, server, addrCh)
+		err := runTestServer(ctx, server, addrCh, args)
 		if err != nil {
 			sig.Set(err)
 		}
@@ -50,7 +62,7 @@ func testCombination(t *testing.T, client, server string) {
 
 	// launch the client
 	ctx.Run(func(ctx context.Context) {
-		err := runTestClient(ctx, client, addrCh)
+		err := runTestClient(ctx, client, addrCh, args)
 		if err != nil {
 			sig.Set(err)
 		}
@@ -67,7 +79,7 @@ func testCombination(t *testing.T, client, server string) {
 	assert.NoError(t, sig.Err())
 }
 
-func runTestServer(ctx context.Context, server string, addrCh chan string) (err error) {
+func runTestServer(ctx context.Context, server string, addrCh chan string, args []string) (err error) {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 	defer close(addrCh)
@@ -79,7 +91,7 @@ func runTestServer(ctx context.Context, server string, addrCh chan string) (err
 		}
 	}()
 
-	cmd := exec.Command("go", "run", ".", "server", ":0")
+	cmd := exec.Command("go", append([]string{"run", ".", "server", ":0"}, args...)...) //nolint:gosec
 	cmd.Stderr = &stderr
 	cmd.Dir = server
 
@@ -108,7 +120,7 @@ func runTestServer(ctx context.Context, server string, addrCh chan string) (err
 	return cmd.Wait()
 }
 
-func runTestClient(ctx context.Context, client string, addrCh chan string) (err error) {
+func runTestClient(ctx context.Context, client string, addrCh chan string, args []string) (err error) {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
@@ -130,7 +142,7 @@ func runTestClient(ctx context.Context, client string, addrCh chan string) (err
 		}
 	}()
 
-	cmd := exec.Command("go", "run", ".", "client", addr) //nolint:gosec
+	cmd := exec.Command("go", append([]string{"run", ".", "client", addr}, args...)...) //nolint:gosec
 	cmd.Stderr = &stderr
 	cmd.Dir = client
 
@@ -9,6 +9,8 @@ require (
 	storj.io/drpc/internal/backcompat/servicedefs v0.0.0-00010101000000-000000000000
 )
 
+require google.golang.org/protobuf v1.27.1 // indirect
+
 replace (
 	storj.io/drpc => ../..
 	storj.io/drpc/internal/backcompat/servicedefs => ./servicedefs
@@ -6,8 +6,28 @@ package main
 
 import (
 	"context"
+	"os"
 
+	"storj.io/drpc"
+	"storj.io/drpc/drpcconn"
+	"storj.io/drpc/drpcmanager"
+	"storj.io/drpc/drpcserver"
 	"storj.io/drpc/internal/backcompat"
 )
 
-func main() { backcompat.Main(context.Background()) }
+func main() {
+	for _, arg := range os.Args[3:] {
+		switch arg {
+		case "handshake":
+			// checksums are only sent once the handshake negotiates them.
+			opts := drpcmanager.Options{Handshake: true, Checksum: true}
+			backcompat.NewConn = func(tr drpc.Transport) *drpcconn.Conn {
+				return drpcconn.NewWithOptions(tr, drpcconn.Options{Manager: opts})
+			}
+			backcompat.NewServer = func(handler drpc.Handler) *drpcserver.Server {
+				return drpcserver.NewWithOptions(handler, drpcserver.Options{Manager: opts})
+			}
+		}
+	}
+	backcompat.Main(context.Background())
+}
@@ -15,6 +15,10 @@ import (
 	"storj.io/drpc/internal/backcompat/servicedefs"
 )
 
+// NewConn returns the client connection for the transport. The new service
+// replaces it to use options that the old version does not have.
+var NewConn = drpcconn.New
+
 func runClient(ctx context.Context, addr string) error {
 	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
 	if err != nil {
@@ -22,7 +26,7 @@ func runClient(ctx context.Context, addr string) error {
 	}
 	defer func() { _ = conn.Close() }()
 
-	cli := servicedefs.NewDRPCServiceClient(drpcconn.New(conn))
+	cli := servicedefs.NewDRPCServiceClient(NewConn(conn))
 
 	{ // check method 1
 		out, err := cli.Method1(ctx, &servicedefs.In{In: 10})
@@ -11,6 +11,7 @@ import (
 )
 
 // Main runs the service as either a client or server depending on os.Args.
+// Any arguments after the address are options for the new service.
 func Main(ctx context.Context) {
 	var err error
 	switch os.Args[1] {
@@ -17,6 +17,10 @@ import (
 	"storj.io/drpc/internal/backcompat/servicedefs"
 )
 
+// NewServer returns the server for the handler. The new service replaces it to
+// use options that the old version does not have.
+var NewServer = drpcserver.New
+
 func runServer(ctx context.Context, addr string) error {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
@@ -37,7 +41,7 @@ func runServer(ctx context.Context, addr string) error {
 
 	mux := drpcmux.New()
 	_ = servicedefs.DRPCRegisterService(mux, server{})
-	_ = drpcserver.New(mux).ServeOne(ctx, conn)
+	_ = NewServer(mux).ServeOne(ctx, conn)
 	return nil
 }
 
@@ -0,0 +1,5 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+// Package drpcbuffer contains a pool of buffers for encoding messages.
+package drpcbuffer
@@ -0,0 +1,29 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcbuffer
+
+import "sync"
+
+// maxPooled is the capacity of the largest buffer that is kept in the pool so
+// that a few large messages do not pin a lot of memory.
+const maxPooled = 4 << 20
+
+var pool = sync.Pool{New: func() interface{} { return new([]byte) }}
+
+// Get returns an empty buffer from the pool.
+func Get() *[]byte {
+	buf := pool.Get().(*[]byte)
+	*buf = (*buf)[:0]
+	return buf
+}
+
+// Put returns the buffer to the pool unless its capacity is larger than max
+// or the pool's limit. A max of 0 means only the pool's limit applies. The
+// buffer must not be used after it is returned.
+func Put(buf *[]byte, max int) {
+	if c := cap(*buf); c > maxPooled || (max > 0 && c > max) {
+		return
+	}
+	pool.Put(buf)
+}
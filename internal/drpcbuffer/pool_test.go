@@ -0,0 +1,27 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcbuffer
+
+import (
+	"testing"
+
+	"github.com/zeebo/assert"
+)
+
+func TestPool(t *testing.T) {
+	buf := Get()
+	*buf = append(*buf, "data"...)
+	Put(buf, 0)
+
+	// buffers from the pool are always empty.
+	assert.Equal(t, len(*Get()), 0)
+
+	// allocations are avoided once the pool has a buffer.
+	allocs := testing.AllocsPerRun(100, func() {
+		buf := Get()
+		*buf = append(*buf, "data"...)
+		Put(buf, 0)
+	})
+	assert.That(t, allocs < 1)
+}
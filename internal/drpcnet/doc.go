@@ -0,0 +1,5 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+// Package drpcnet contains helpers for transports that are network conns.
+package drpcnet
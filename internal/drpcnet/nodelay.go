@@ -0,0 +1,25 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpcnet
+
+import (
+	"net"
+
+	"storj.io/drpc"
+)
+
+// SetNoDelay sets TCP_NODELAY on the transport if it is a *net.TCPConn, or
+// wraps one like a *tls.Conn does, and otherwise does nothing.
+func SetNoDelay(tr drpc.Transport, noDelay bool) error {
+	for {
+		switch conn := tr.(type) {
+		case *net.TCPConn:
+			return conn.SetNoDelay(noDelay)
+		case interface{ NetConn() net.Conn }:
+			tr = conn.NetConn()
+		default:
+			return nil
+		}
+	}
+}
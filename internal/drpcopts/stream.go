@@ -14,6 +14,9 @@ type Stream struct {
 	fin       chan<- struct{}
 	kind      string
 	stats     *drpcstats.Stats
+	compress  bool
+	interrupt func(error)
+	hello     func() string
 }
 
 // GetStreamTransport returns the drpc.Transport stored in the options.
@@ -39,3 +42,21 @@ func GetStreamStats(opts *Stream) *drpcstats.Stats { return opts.stats }
 
 // SetStreamStats sets the Stats stored in the options.
 func SetStreamStats(opts *Stream, stats *drpcstats.Stats) { opts.stats = stats }
+
+// GetStreamCompress returns if the remote accepts compressed messages.
+func GetStreamCompress(opts *Stream) bool { return opts.compress }
+
+// SetStreamCompress sets if the remote accepts compressed messages.
+func SetStreamCompress(opts *Stream, compress bool) { opts.compress = compress }
+
+// GetStreamInterrupt returns the function that interrupts blocked writes.
+func GetStreamInterrupt(opts *Stream) func(error) { return opts.interrupt }
+
+// SetStreamInterrupt sets the function that interrupts blocked writes.
+func SetStreamInterrupt(opts *Stream, interrupt func(error)) { opts.interrupt = interrupt }
+
+// GetStreamHello returns the function that returns the hello to announce.
+func GetStreamHello(opts *Stream) func() string { return opts.hello }
+
+// SetStreamHello sets the function that returns the hello to announce.
+func SetStreamHello(opts *Stream, hello func() string) { opts.hello = hello }
@@ -15,6 +15,7 @@ import (
 
 	"storj.io/drpc"
 	"storj.io/drpc/drpcconn"
+	"storj.io/drpc/drpcmetadata"
 	"storj.io/drpc/drpcpool"
 	"storj.io/drpc/drpctest"
 )
@@ -180,6 +181,56 @@ func TestCancellationPropagation_Stream(t *testing.T) {
 	}
 }
 
+func TestCancellationPropagation_Recv(t *testing.T) {
+	timeout, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	received := make(chan struct{}, 1)
+	errs := make(chan [2]error, 1)
+
+	blocked := impl{
+		Method4Fn: func(stream DRPCService_Method4Stream) error {
+			_, err := stream.Recv()
+			if err != nil {
+				return err
+			}
+			received <- struct{}{}
+
+			_, err = stream.Recv()
+			errs <- [2]error{err, stream.Context().Err()}
+			return nil
+		},
+	}
+
+	cli, close := createConnection(t, blocked)
+	defer close()
+
+	clientctx, clientcancel := context.WithCancel(ctx)
+	defer clientcancel()
+
+	stream, err := cli.Method4(clientctx)
+	assert.NoError(t, err)
+	assert.NoError(t, stream.Send(in(1)))
+
+	select {
+	case <-received:
+	case <-timeout.Done():
+		t.Fatal("server did not receive")
+	}
+	clientcancel()
+
+	select {
+	case errs := <-errs:
+		assert.That(t, errors.Is(errs[0], context.Canceled))
+		assert.Equal(t, errs[1], context.Canceled)
+	case <-timeout.Done():
+		t.Fatal("did not finish in time")
+	}
+}
+
 func TestCancelWhileWriteBlocked(t *testing.T) {
 	ctx := drpctest.NewTracker(t)
 	defer ctx.Close()
@@ -267,3 +318,99 @@ func TestCancelRepeatedPooled(t *testing.T) {
 	}
 	assert.Equal(t, conns, 1)
 }
+
+func TestDeadlinePropagation(t *testing.T) {
+	timeout, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	type result struct {
+		deadline bool
+		timeout  bool
+		canceled bool
+	}
+	results := make(chan result, 1)
+
+	sleepy := impl{
+		Method1Fn: func(ctx context.Context, _ *In) (*Out, error) {
+			var res result
+			_, res.deadline = ctx.Deadline()
+			md, _ := drpcmetadata.MetadataFromContext(ctx)
+			res.timeout = md.Get(drpcmetadata.TimeoutKey) != nil
+			select {
+			case <-timeout.Done():
+			case <-ctx.Done():
+				res.canceled = true
+			}
+			results <- res
+			return &Out{Out: 1}, nil
+		},
+	}
+
+	cli, close := createConnection(t, sleepy)
+	defer close()
+
+	{ // an already expired deadline fails without issuing the rpc
+		ctx, cancel := context.WithDeadline(ctx, time.Now().Add(-time.Second))
+		defer cancel()
+
+		_, err := cli.Method1(ctx, in(1))
+		assert.That(t, errors.Is(err, context.DeadlineExceeded))
+	}
+
+	{ // a slow handler is canceled once the propagated deadline expires
+		ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		defer cancel()
+
+		_, err := cli.Method1(ctx, in(1))
+		assert.That(t, errors.Is(err, context.DeadlineExceeded))
+	}
+
+	select {
+	case res := <-results:
+		assert.That(t, res.deadline)
+		assert.That(t, !res.timeout)
+		assert.That(t, res.canceled)
+	case <-timeout.Done():
+		t.Fatal("did not finish in time")
+	}
+}
+
+func TestDeadlinePropagation_Queued(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	deadlines := make(chan time.Time, 1)
+	cli, close := createConnection(t, impl{
+		Method1Fn: func(ctx context.Context, _ *In) (*Out, error) {
+			deadline, _ := ctx.Deadline()
+			deadlines <- deadline
+			return &Out{Out: 1}, nil
+		},
+		Method4Fn: func(stream DRPCService_Method4Stream) error {
+			_, _ = stream.Recv()
+			return nil
+		},
+	})
+	defer close()
+
+	// hold the conn with a stream so that the invoke has to wait for it.
+	stream, err := cli.Method4(ctx)
+	assert.NoError(t, err)
+	ctx.Run(func(ctx context.Context) {
+		time.Sleep(200 * time.Millisecond)
+		_ = stream.Close()
+	})
+
+	tctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	deadline, _ := tctx.Deadline()
+
+	_, err = cli.Method1(tctx, in(1))
+	assert.NoError(t, err)
+
+	// the server's deadline does not include the time spent waiting.
+	assert.That(t, (<-deadlines).Before(deadline.Add(50*time.Millisecond)))
+}
@@ -0,0 +1,149 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package integration
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+
+	"github.com/zeebo/assert"
+
+	"storj.io/drpc/drpccompress"
+	"storj.io/drpc/drpcconn"
+	"storj.io/drpc/drpcmanager"
+	"storj.io/drpc/drpcmux"
+	"storj.io/drpc/drpcserver"
+	"storj.io/drpc/drpcstream"
+	"storj.io/drpc/drpctest"
+)
+
+type countingConn struct {
+	net.Conn
+	read    int64
+	written int64
+}
+
+func (c *countingConn) Read(p []byte) (n int, err error) {
+	n, err = c.Conn.Read(p)
+	atomic.AddInt64(&c.read, int64(n))
+	return n, err
+}
+
+func (c *countingConn) Write(p []byte) (n int, err error) {
+	n, err = c.Conn.Write(p)
+	atomic.AddInt64(&c.written, int64(n))
+	return n, err
+}
+
+// compressionServer returns a conn to a server that echoes messages, using the
+// manager options for each side.
+func compressionServer(t *testing.T, ctx *drpctest.Tracker, sopts, copts drpcmanager.Options) (*countingConn, DRPCServiceClient, func()) {
+	c1, c2 := net.Pipe()
+	mux := drpcmux.New()
+	assert.NoError(t, DRPCRegisterService(mux, impl{
+		Method1Fn: func(ctx context.Context, in *In) (*Out, error) {
+			return &Out{Out: in.In, Data: in.Data}, nil
+		},
+		Method4Fn: func(stream DRPCService_Method4Stream) error {
+			for {
+				in, err := stream.Recv()
+				if err != nil {
+					return nil
+				}
+				if err := stream.Send(&Out{Out: in.In, Data: in.Data}); err != nil {
+					return err
+				}
+			}
+		},
+	}))
+	srv := drpcserver.NewWithOptions(mux, drpcserver.Options{Manager: sopts})
+	ctx.Run(func(ctx context.Context) { _ = srv.ServeOne(ctx, c1) })
+
+	tr := &countingConn{Conn: c2}
+	conn := drpcconn.NewWithOptions(tr, drpcconn.Options{Manager: copts})
+	return tr, NewDRPCServiceClient(conn), func() { _ = conn.Close() }
+}
+
+func TestCompression(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	opts := drpcmanager.Options{
+		Stream: drpcstream.Options{Compressor: drpccompress.Gzip{}},
+	}
+	tr, cli, cleanup := compressionServer(t, ctx, opts, opts)
+	defer cleanup()
+
+	large := bytes.Repeat([]byte("compressible "), 1<<20/13)
+
+	{ // a large response shrinks on the wire and decodes identically
+		out, err := cli.Method1(ctx, &In{In: 1, Data: large})
+		assert.NoError(t, err)
+		assert.Equal(t, out.Out, 1)
+		assert.That(t, bytes.Equal(out.Data, large))
+		assert.That(t, atomic.LoadInt64(&tr.read) < int64(len(large))/10)
+
+		// the server had not yet shown that it accepts compressed messages.
+		assert.That(t, atomic.LoadInt64(&tr.written) > int64(len(large)))
+	}
+
+	{ // once it has, large requests shrink too
+		written := atomic.LoadInt64(&tr.written)
+		out, err := cli.Method1(ctx, &In{In: 2, Data: large})
+		assert.NoError(t, err)
+		assert.That(t, bytes.Equal(out.Data, large))
+		assert.That(t, atomic.LoadInt64(&tr.written)-written < int64(len(large))/10)
+	}
+
+	{ // compressed and uncompressed messages can be mixed on a stream
+		stream, err := cli.Method4(ctx)
+		assert.NoError(t, err)
+
+		for i, n := range []int{0, 10, 1 << 16, 20, 1 << 12} {
+			data := bytes.Repeat([]byte{'x'}, n)
+			assert.NoError(t, stream.Send(&In{In: int64(i), Data: data}))
+
+			out, err := stream.Recv()
+			assert.NoError(t, err)
+			assert.Equal(t, out.Out, i)
+			assert.That(t, bytes.Equal(out.Data, data))
+		}
+		assert.NoError(t, stream.Close())
+	}
+}
+
+func TestCompression_OneSided(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	opts := drpcmanager.Options{
+		Stream: drpcstream.Options{Compressor: drpccompress.Gzip{}},
+	}
+	large := bytes.Repeat([]byte("compressible "), 1<<16)
+
+	for _, tc := range []struct {
+		name         string
+		sopts, copts drpcmanager.Options
+	}{
+		{"Server", opts, drpcmanager.Options{}},
+		{"Client", drpcmanager.Options{}, opts},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tr, cli, cleanup := compressionServer(t, ctx, tc.sopts, tc.copts)
+			defer cleanup()
+
+			// nothing is compressed toward a peer without a compressor.
+			for i := 0; i < 2; i++ {
+				out, err := cli.Method1(ctx, &In{In: 1, Data: large})
+				assert.NoError(t, err)
+				assert.That(t, bytes.Equal(out.Data, large))
+			}
+			assert.That(t, atomic.LoadInt64(&tr.read) > 2*int64(len(large)))
+			assert.That(t, atomic.LoadInt64(&tr.written) > 2*int64(len(large)))
+		})
+	}
+}
@@ -6,13 +6,16 @@ package integration
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"strings"
 	"testing"
 
 	"github.com/zeebo/assert"
+	"github.com/zeebo/errs"
 
 	"storj.io/drpc/drpcerr"
+	"storj.io/drpc/drpcstatus"
 	"storj.io/drpc/drpctest"
 )
 
@@ -85,3 +88,27 @@ func TestError_Message(t *testing.T) {
 	assert.Error(t, err)
 	assert.Equal(t, err.Error(), "some unique error message")
 }
+
+func TestError_Status(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	cli, close := createConnection(t, impl{
+		Method1Fn: func(ctx context.Context, in *In) (*Out, error) {
+			return nil, errs.Wrap(drpcstatus.Errorf(drpcstatus.Code(in.In), "status %d", in.In))
+		},
+	})
+	defer close()
+
+	for _, code := range []drpcstatus.Code{
+		drpcstatus.Canceled,
+		drpcstatus.NotFound,
+		drpcstatus.PermissionDenied,
+		drpcstatus.Unavailable,
+	} {
+		out, err := cli.Method1(ctx, in(int64(code)))
+		assert.Nil(t, out)
+		assert.Equal(t, drpcstatus.CodeFromError(err), code)
+		assert.Equal(t, drpcstatus.FromError(err).Message(), fmt.Sprintf("status %d", code))
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package integration
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/zeebo/assert"
+
+	"storj.io/drpc"
+	"storj.io/drpc/drpctest"
+)
+
+func TestHalfClose_Replies(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	errch := make(chan error, 1)
+	cli, close := createConnection(t, impl{
+		Method4Fn: func(stream DRPCService_Method4Stream) error {
+			for {
+				in, err := stream.Recv()
+				if err != nil {
+					errch <- err
+					break
+				}
+				if err := stream.Send(out(in.In)); err != nil {
+					return err
+				}
+			}
+
+			// the client is done sending, but replies are still delivered.
+			return stream.Send(out(100))
+		},
+	})
+	defer close()
+
+	stream, err := cli.Method4(ctx)
+	assert.NoError(t, err)
+
+	for i := int64(0); i < 2; i++ {
+		assert.NoError(t, stream.Send(in(i)))
+		reply, err := stream.Recv()
+		assert.NoError(t, err)
+		assert.Equal(t, reply.Out, i)
+	}
+
+	assert.NoError(t, stream.CloseSend())
+	assert.That(t, errors.Is(<-errch, io.EOF))
+
+	reply, err := stream.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, reply.Out, int64(100))
+
+	_, err = stream.Recv()
+	assert.That(t, errors.Is(err, io.EOF))
+}
+
+func TestHalfClose_Close(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	errch := make(chan error, 1)
+	cli, close := createConnection(t, impl{
+		Method4Fn: func(stream DRPCService_Method4Stream) error {
+			_, err := stream.Recv()
+			errch <- err
+			return nil
+		},
+	})
+	defer close()
+
+	stream, err := cli.Method4(ctx)
+	assert.NoError(t, err)
+
+	// closing without a CloseSend is not a half-close, so the server does
+	// not see an io.EOF.
+	assert.NoError(t, stream.Close())
+
+	err = <-errch
+	assert.That(t, !errors.Is(err, io.EOF))
+	assert.That(t, drpc.ClosedError.Has(err))
+}
@@ -87,7 +87,7 @@ func TestHTTP(t *testing.T) {
 	// non-existing method
 	assertEqual(t, request("/service.Service/DoesNotExist", `{}`), response{
 		StatusCode: http.StatusInternalServerError,
-		Code:       "unknown",
+		Code:       "drpcerr(12)",
 		Msg:        `protocol error: unknown rpc: "/service.Service/DoesNotExist"`,
 	})
 }
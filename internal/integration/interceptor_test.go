@@ -0,0 +1,148 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package integration
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/zeebo/assert"
+	"github.com/zeebo/errs"
+
+	"storj.io/drpc"
+	"storj.io/drpc/drpcconn"
+	"storj.io/drpc/drpcmetadata"
+	"storj.io/drpc/drpcmux"
+	"storj.io/drpc/drpcserver"
+	"storj.io/drpc/drpctest"
+)
+
+type ctxKey struct{}
+
+type clientInterceptor struct {
+	invokes []string
+	streams []string
+}
+
+func (c *clientInterceptor) InterceptInvoke(ctx context.Context, rpc string, enc drpc.Encoding, in, out drpc.Message, next drpc.InvokeFunc) error {
+	c.invokes = append(c.invokes, rpc)
+	return next(drpcmetadata.Add(ctx, "intercepted", "invoke"), rpc, enc, in, out)
+}
+
+func (c *clientInterceptor) InterceptNewStream(ctx context.Context, rpc string, enc drpc.Encoding, next drpc.NewStreamFunc) (drpc.Stream, error) {
+	c.streams = append(c.streams, rpc)
+	return next(drpcmetadata.Add(ctx, "intercepted", "stream"), rpc, enc)
+}
+
+func createInterceptedConnection(t testing.TB, server DRPCServiceServer, ctx *drpctest.Tracker,
+	cint drpc.ClientInterceptor, sint drpc.ServerInterceptor) *drpcconn.Conn {
+	c1, c2 := net.Pipe()
+	mux := drpcmux.NewWithOptions(drpcmux.Options{Interceptor: sint})
+	assert.NoError(t, DRPCRegisterService(mux, server))
+	srv := drpcserver.New(mux)
+	ctx.Run(func(ctx context.Context) { _ = srv.ServeOne(ctx, c1) })
+	return drpcconn.NewWithOptions(c2, drpcconn.Options{Interceptor: cint})
+}
+
+func TestInterceptors(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	var seen []drpc.Message
+	var methods []drpcmux.MethodInfo
+	sint := func(ctx context.Context, rpc string, in drpc.Message, stream drpc.Stream, next drpc.ServerHandler) (drpc.Message, error) {
+		seen = append(seen, in)
+		info, ok := drpcmux.MethodFromContext(ctx)
+		assert.That(t, ok)
+		methods = append(methods, info)
+		if in, ok := in.(*In); ok && in.In == 10 {
+			return &Out{Out: 10}, nil
+		} else if ok && in.In == 11 {
+			return nil, errs.New("rejected")
+		}
+		return next(context.WithValue(ctx, ctxKey{}, rpc), rpc, in, stream)
+	}
+
+	cint := new(clientInterceptor)
+
+	conn := createInterceptedConnection(t, impl{
+		Method1Fn: func(ctx context.Context, in *In) (*Out, error) {
+			assert.Equal(t, ctx.Value(ctxKey{}), "/service.Service/Method1")
+			md, _ := drpcmetadata.Get(ctx)
+			assert.Equal(t, md["intercepted"], "invoke")
+			return &Out{Out: in.In}, nil
+		},
+		Method3Fn: func(in *In, stream DRPCService_Method3Stream) error {
+			return stream.Send(&Out{Out: in.In})
+		},
+		Method4Fn: func(stream DRPCService_Method4Stream) error {
+			assert.Equal(t, stream.Context().Value(ctxKey{}), "/service.Service/Method4")
+			md, _ := drpcmetadata.Get(stream.Context())
+			assert.Equal(t, md["intercepted"], "stream")
+			in, err := stream.Recv()
+			if err != nil {
+				return err
+			}
+			return stream.Send(&Out{Out: in.In})
+		},
+	}, ctx, cint, sint)
+	defer func() { _ = conn.Close() }()
+
+	cli := NewDRPCServiceClient(conn)
+
+	{ // the interceptors see the unary rpc and the handler sees the context
+		out, err := cli.Method1(ctx, in(1))
+		assert.NoError(t, err)
+		assert.True(t, Equal(out, &Out{Out: 1}))
+	}
+
+	{ // the server interceptor can short-circuit with a replacement response
+		out, err := cli.Method1(ctx, in(10))
+		assert.NoError(t, err)
+		assert.True(t, Equal(out, &Out{Out: 10}))
+	}
+
+	{ // the server interceptor can short-circuit with an error
+		_, err := cli.Method1(ctx, in(11))
+		assert.Error(t, err)
+		assert.Equal(t, err.Error(), "rejected")
+	}
+
+	{ // the interceptors see the stream and the handler sees the context
+		stream, err := cli.Method4(ctx)
+		assert.NoError(t, err)
+		assert.NoError(t, stream.Send(in(4)))
+		out, err := stream.Recv()
+		assert.NoError(t, err)
+		assert.True(t, Equal(out, &Out{Out: 4}))
+		assert.NoError(t, stream.Close())
+	}
+
+	{ // the server interceptor can tell that rpcs with an input message stream
+		stream, err := cli.Method3(ctx, in(3))
+		assert.NoError(t, err)
+		out, err := stream.Recv()
+		assert.NoError(t, err)
+		assert.True(t, Equal(out, &Out{Out: 3}))
+		assert.NoError(t, stream.Close())
+	}
+
+	assert.DeepEqual(t, cint.invokes, []string{
+		"/service.Service/Method1",
+		"/service.Service/Method1",
+		"/service.Service/Method1",
+	})
+	assert.DeepEqual(t, cint.streams, []string{"/service.Service/Method4", "/service.Service/Method3"})
+	assert.Equal(t, len(seen), 5)
+	assert.Nil(t, seen[3])
+	assert.NotNil(t, seen[4])
+	assert.DeepEqual(t, methods, []drpcmux.MethodInfo{
+		{RPC: "/service.Service/Method1", Unitary: true},
+		{RPC: "/service.Service/Method1", Unitary: true},
+		{RPC: "/service.Service/Method1", Unitary: true},
+		{RPC: "/service.Service/Method4", Unitary: false},
+		{RPC: "/service.Service/Method3", Unitary: false},
+	})
+}
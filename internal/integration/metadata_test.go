@@ -0,0 +1,103 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package integration
+
+import (
+	"context"
+	"testing"
+
+	"github.com/zeebo/assert"
+
+	"storj.io/drpc/drpcmetadata"
+	"storj.io/drpc/drpctest"
+)
+
+func TestMetadata_Invoke(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	received := make(chan drpcmetadata.Metadata, 1)
+	cli, close := createConnection(t, impl{
+		Method1Fn: func(ctx context.Context, in *In) (*Out, error) {
+			md, _ := drpcmetadata.MetadataFromContext(ctx)
+			received <- md
+			return out(1), nil
+		},
+	})
+	defer close()
+
+	{ // no metadata is received if none is sent
+		_, err := cli.Method1(ctx, in(1))
+		assert.NoError(t, err)
+		assert.Nil(t, <-received)
+	}
+
+	{ // keys are case-insensitive and can have multiple values
+		mctx := drpcmetadata.WithOutgoingMetadata(ctx, drpcmetadata.Metadata{
+			"Foo": {"a", "b"},
+			"bar": {"c"},
+		})
+		_, err := cli.Method1(mctx, in(1))
+		assert.NoError(t, err)
+
+		md := <-received
+		assert.DeepEqual(t, md.Get("FOO"), []string{"a", "b"})
+		assert.DeepEqual(t, md.Get("bar"), []string{"c"})
+	}
+}
+
+func TestMetadata_Stream(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	received := make(chan drpcmetadata.Metadata, 1)
+	cli, close := createConnection(t, impl{
+		Method4Fn: func(stream DRPCService_Method4Stream) error {
+			// the metadata must be available before any messages are received.
+			md, _ := drpcmetadata.MetadataFromContext(stream.Context())
+			received <- md
+
+			for {
+				if _, err := stream.Recv(); err != nil {
+					break
+				}
+			}
+			return stream.Send(out(4))
+		},
+	})
+	defer close()
+
+	mctx := drpcmetadata.WithOutgoingMetadata(ctx, drpcmetadata.Metadata{
+		"key": {"a", "b"},
+	})
+
+	{ // metadata arrives even if no messages are sent
+		stream, err := cli.Method4(mctx)
+		assert.NoError(t, err)
+		assert.NoError(t, stream.CloseSend())
+
+		md := <-received
+		assert.DeepEqual(t, md.Get("key"), []string{"a", "b"})
+
+		got, err := stream.Recv()
+		assert.NoError(t, err)
+		assert.True(t, Equal(got, out(4)))
+		assert.NoError(t, stream.Close())
+	}
+
+	{ // metadata arrives before the first message is received
+		stream, err := cli.Method4(mctx)
+		assert.NoError(t, err)
+		assert.NoError(t, stream.Send(in(1)))
+
+		md := <-received
+		assert.DeepEqual(t, md.Get("key"), []string{"a", "b"})
+
+		assert.NoError(t, stream.CloseSend())
+		got, err := stream.Recv()
+		assert.NoError(t, err)
+		assert.True(t, Equal(got, out(4)))
+		assert.NoError(t, stream.Close())
+	}
+}
@@ -13,6 +13,7 @@ import (
 
 	"github.com/zeebo/assert"
 
+	"storj.io/drpc"
 	"storj.io/drpc/drpcconn"
 	"storj.io/drpc/drpcerr"
 	"storj.io/drpc/drpcmux"
@@ -136,14 +137,14 @@ func TestStats(t *testing.T) {
 	assert.Error(t, err)
 
 	assert.Equal(t, srv.Stats(), map[string]drpcstats.Stats{
-		"/service.Service/Method1": {Read: 2, Written: 12},
+		"/service.Service/Method1": {Read: 2, Written: 12, MessagesRead: 1},
 	})
 
 	_, err = cli.Method1(ctx, in(1))
 	assert.NoError(t, err)
 
 	assert.Equal(t, srv.Stats(), map[string]drpcstats.Stats{
-		"/service.Service/Method1": {Read: 2 + 2, Written: 12 + 2},
+		"/service.Service/Method1": {Read: 2 + 2, Written: 12 + 2, MessagesRead: 2, MessagesWritten: 1},
 	})
 
 	stream, err := cli.Method3(ctx, in(3))
@@ -157,7 +158,51 @@ func TestStats(t *testing.T) {
 	assert.NoError(t, stream.Close())
 
 	assert.Equal(t, srv.Stats(), map[string]drpcstats.Stats{
-		"/service.Service/Method1": {Read: 2 + 2, Written: 12 + 2},
-		"/service.Service/Method3": {Read: 2, Written: 6},
+		"/service.Service/Method1": {Read: 2 + 2, Written: 12 + 2, MessagesRead: 2, MessagesWritten: 1},
+		"/service.Service/Method3": {Read: 2, Written: 6, MessagesRead: 1, MessagesWritten: 3},
 	})
 }
+
+func TestConnStats(t *testing.T) {
+	ctx := drpctest.NewTracker(t)
+	defer ctx.Close()
+
+	c1, c2 := net.Pipe()
+	mux := drpcmux.New()
+	_ = DRPCRegisterService(mux, impl{
+		Method1Fn: func(ctx context.Context, in *In) (*Out, error) {
+			return &Out{Out: in.In, Data: in.Data}, nil
+		},
+	})
+
+	srv := drpcserver.New(mux)
+	ctx.Run(func(ctx context.Context) { _ = srv.ServeOne(ctx, c1) })
+
+	conn := drpcconn.New(c2)
+	defer func() { _ = conn.Close() }()
+	cli := NewDRPCServiceClient(conn)
+
+	assert.Equal(t, conn.Stats(), drpcstats.Stats{})
+
+	const size = 10000
+	_, err := cli.Method1(ctx, &In{In: 1, Data: make([]byte, size)})
+	assert.NoError(t, err)
+
+	// the messages are sent along with the invoke, a close and the framing
+	// for each, which is well under this much overhead.
+	const overhead = 128
+
+	stats := conn.Stats()
+	assert.That(t, stats.Written >= size && stats.Written <= size+overhead)
+	assert.That(t, stats.Read >= size && stats.Read <= size+overhead)
+	assert.Equal(t, stats.MessagesWritten, uint64(1))
+	assert.Equal(t, stats.MessagesRead, uint64(1))
+
+	// the server's writes are only counted once they return, which may be
+	// after the client has read them, so only its reads are checked.
+	conns := srv.ConnStats()
+	assert.Equal(t, len(conns), 1)
+	assert.Equal(t, conns[0].Transport, drpc.Transport(c1))
+	assert.That(t, conns[0].Stats.Read >= size && conns[0].Stats.Read <= stats.Written)
+	assert.Equal(t, conns[0].Stats.MessagesRead, uint64(1))
+}
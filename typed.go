@@ -0,0 +1,93 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// TypedStream wraps a Stream so that it sends messages of type In and receives
+// messages of type Out, both using the same Encoding. Out must be a pointer
+// type, like the messages generated by protobuf, so that Recv can allocate it.
+type TypedStream[In, Out Message] struct {
+	Stream
+	enc Encoding
+	out reflect.Type
+}
+
+// NewTypedStream returns a TypedStream that sends and receives on stream using
+// enc. It panics if Out is not a pointer type.
+func NewTypedStream[In, Out Message](stream Stream, enc Encoding) *TypedStream[In, Out] {
+	out := reflect.TypeOf((*Out)(nil)).Elem()
+	if out.Kind() != reflect.Ptr {
+		panic(fmt.Sprintf("drpc: TypedStream receive type %v is not a pointer", out))
+	}
+	return &TypedStream[In, Out]{Stream: stream, enc: enc, out: out.Elem()}
+}
+
+// Send sends the message to the remote.
+func (t *TypedStream[In, Out]) Send(in In) error {
+	return t.MsgSend(in, t.enc)
+}
+
+// Recv receives a newly allocated message from the remote. Like MsgRecv, it
+// returns io.EOF once the remote has closed its side of the stream.
+func (t *TypedStream[In, Out]) Recv() (Out, error) {
+	out := reflect.New(t.out).Interface().(Out)
+	if err := t.MsgRecv(out, t.enc); err != nil {
+		var zero Out
+		return zero, err
+	}
+	return out, nil
+}
+
+// SendStream is the sending side of a stream of messages of type Out.
+type SendStream[Out Message] interface {
+	// Context returns the context associated with the stream.
+	Context() context.Context
+
+	// Send sends the message to the remote.
+	Send(out Out) error
+}
+
+// ServerStreamHandler is a Handler for server streaming rpcs. It receives the
+// single request message, which must be of a pointer type, and passes it to
+// Func along with a stream to send the responses on.
+type ServerStreamHandler[In, Out Message] struct {
+	// Encoding is used for the request and for the responses.
+	Encoding Encoding
+
+	// Func handles the rpc. The stream is closed once it returns.
+	Func func(in In, stream SendStream[Out]) error
+}
+
+// HandleRPC receives the request from the stream and calls Func with it.
+func (h ServerStreamHandler[In, Out]) HandleRPC(stream Stream, rpc string) error {
+	ts := NewTypedStream[Out, In](stream, h.Encoding)
+	in, err := ts.Recv()
+	if err != nil {
+		return err
+	}
+	return h.Func(in, ts)
+}
+
+// Collect receives messages from stream until the remote closes its side,
+// allocating each one with alloc. It returns the messages received so far
+// along with any error other than io.EOF.
+func Collect[T Message](stream Stream, enc Encoding, alloc func() T) ([]T, error) {
+	var msgs []T
+	for {
+		msg := alloc()
+		if err := stream.MsgRecv(msg, enc); errors.Is(err, io.EOF) {
+			return msgs, nil
+		} else if err != nil {
+			return msgs, err
+		}
+		msgs = append(msgs, msg)
+	}
+}
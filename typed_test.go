@@ -0,0 +1,75 @@
+// Copyright (C) 2026 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package drpc_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/zeebo/assert"
+
+	"storj.io/drpc"
+	"storj.io/drpc/drpcpipe"
+	"storj.io/drpc/drpctest"
+)
+
+type collectHandler struct{}
+
+func (collectHandler) HandleRPC(stream drpc.Stream, rpc string) error {
+	msgs, err := drpc.Collect(stream, drpctest.ByteEncoding{}, func() *[]byte { return new([]byte) })
+	if err != nil {
+		return err
+	}
+	var out []byte
+	for _, msg := range msgs {
+		out = append(out, *msg...)
+	}
+	return stream.MsgSend(&out, drpctest.ByteEncoding{})
+}
+
+func TestCollect(t *testing.T) {
+	conn, cleanup := drpcpipe.New(collectHandler{})
+	defer cleanup()
+
+	stream, err := conn.NewStream(context.Background(), "/Collect", drpctest.ByteEncoding{})
+	assert.NoError(t, err)
+	defer func() { _ = stream.Close() }()
+
+	for _, msg := range []string{"a", "b", "c", "d", "e"} {
+		buf := []byte(msg)
+		assert.NoError(t, stream.MsgSend(&buf, drpctest.ByteEncoding{}))
+	}
+	assert.NoError(t, stream.CloseSend())
+
+	var out []byte
+	assert.NoError(t, stream.MsgRecv(&out, drpctest.ByteEncoding{}))
+	assert.Equal(t, string(out), "abcde")
+}
+
+// failingStream returns its messages from MsgRecv and then err.
+type failingStream struct {
+	drpc.Stream
+	msgs []string
+	err  error
+}
+
+func (s *failingStream) MsgRecv(msg drpc.Message, enc drpc.Encoding) error {
+	if len(s.msgs) == 0 {
+		return s.err
+	}
+	*msg.(*[]byte) = []byte(s.msgs[0])
+	s.msgs = s.msgs[1:]
+	return nil
+}
+
+func TestCollect_Error(t *testing.T) {
+	boom := errors.New("boom")
+	stream := &failingStream{msgs: []string{"a", "b"}, err: boom}
+
+	msgs, err := drpc.Collect[*[]byte](stream, drpctest.ByteEncoding{}, func() *[]byte { return new([]byte) })
+	assert.That(t, errors.Is(err, boom))
+	assert.Equal(t, len(msgs), 2)
+	assert.Equal(t, string(*msgs[1]), "b")
+}